@@ -0,0 +1,262 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/playio"
+)
+
+// runCommand runs the subcommand given on the command line instead of
+// starting the server.
+func runCommand(db *sql.DB, name string, args []string, out io.Writer) error {
+	switch name {
+	case "import-bgstats":
+		return importPlays(db, name, "BACKUP.json", playio.ReadBGStats, args, out)
+	case "import-bgg":
+		return importPlays(db, name, "PLAYS.xml", playio.ReadBGG, args, out)
+	case "admin":
+		return adminCommand(db, args, out)
+	default:
+		return fmt.Errorf("unknown command %q; the commands are import-bgstats, import-bgg, admin and migrate", name)
+	}
+}
+
+// adminCommand makes an account an admin, who may change the hero and
+// scenario catalog, or with -revoke no longer one. The first account to
+// sign up is an admin already.
+func adminCommand(db *sql.DB, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.SetOutput(out)
+	revoke := flags.Bool("revoke", false, "take admin rights away instead of granting them")
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: server admin [flags] USERNAME")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("admin needs exactly one username")
+	}
+
+	store := auth.NewStore(db)
+	user, err := store.GetByUsername(flags.Arg(0))
+	if errors.Is(err, models.ErrNotFound) {
+		return fmt.Errorf("there is no account named %q", flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	if err := store.SetAdmin(user.ID, !*revoke); err != nil {
+		return err
+	}
+	if *revoke {
+		fmt.Fprintf(out, "%s is no longer an admin\n", user.Username)
+	} else {
+		fmt.Fprintf(out, "%s is an admin\n", user.Username)
+	}
+	return nil
+}
+
+// importPlays imports the Marvel Champions plays from a BG Stats JSON
+// backup or a saved BGG plays XML file, the same way as the import page.
+// Plays imported before are skipped, so it is safe to run on every new
+// export. Once there are accounts, -owner names the user the plays belong
+// to.
+func importPlays(db *sql.DB, name, file string, read func(io.Reader, playio.Options) ([]playio.Row, error), args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(out)
+	difficulty := flags.String("difficulty", models.Difficulties[0], "difficulty of plays that name none")
+	aspect := flags.String("aspect", "", "aspect of heroes that name none; by default such plays are reported")
+	dryRun := flags.Bool("dry-run", false, "check the file without importing anything")
+	owner := flags.String("owner", "", "username of the account the plays belong to")
+	flags.Usage = func() {
+		fmt.Fprintf(out, "usage: server %s [flags] %s\n", name, file)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("%s needs exactly one file", name)
+	}
+
+	plays, err := ownerPlays(db, *owner)
+	if err != nil {
+		return err
+	}
+
+	aspects, err := models.NewAspectRepository(db).GetAll()
+	if err != nil {
+		return err
+	}
+	opts := playio.Options{Difficulty: *difficulty, Aspect: *aspect}
+	for _, a := range aspects {
+		opts.Aspects = append(opts.Aspects, a.Name)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	rows, err := read(f, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := playio.Apply(plays, rows, !*dryRun); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.Error != "" {
+			fmt.Fprintf(out, "play %d (%s, %s): %s\n", row.Line, row.Play.Play.Date.Format("2006-01-02"), row.Play.Scenario, row.Error)
+		}
+	}
+
+	counts := playio.Count(rows)
+	switch {
+	case counts.Failed > 0:
+		return fmt.Errorf("%d of %d plays cannot be imported; nothing was saved", counts.Failed, len(rows))
+	case *dryRun:
+		fmt.Fprintf(out, "%d plays would be imported, %d were imported before\n", counts.Ready, counts.Duplicates)
+	default:
+		fmt.Fprintf(out, "%d plays imported, %d were imported before\n", counts.Ready, counts.Duplicates)
+	}
+	return nil
+}
+
+// ownerPlays returns the play repository that imports as the account named
+// owner. Without accounts, plays are imported without an owner and the
+// first user to sign up takes them over.
+func ownerPlays(db *sql.DB, owner string) (*models.PlayRepository, error) {
+	store := auth.NewStore(db)
+	if owner == "" {
+		users, err := store.Count()
+		if err != nil {
+			return nil, err
+		}
+		if users > 0 {
+			return nil, errors.New("-owner is required once there are accounts")
+		}
+		return models.NewPlayRepository(db), nil
+	}
+
+	user, err := store.GetByUsername(owner)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, fmt.Errorf("there is no account named %q", owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return models.NewPlayRepository(db).For(models.Viewer{UserID: user.ID}), nil
+}
+
+// migrateCommand shows which of the migrations in fsys the database has
+// applied, and applies or undoes them. main runs it before applying pending
+// migrations, so that they can be looked at, or tried with -dry-run, first.
+func migrateCommand(db *sql.DB, fsys fs.FS, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "print the SQL that would run instead of running it")
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: server migrate [flags] status | up | down N | goto VERSION | redo")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// number reads the argument of down and goto.
+	number := func(name string, min int) (int, error) {
+		if flags.NArg() != 2 {
+			flags.Usage()
+			return 0, fmt.Errorf("migrate %s needs a %s", flags.Arg(0), name)
+		}
+		n, err := strconv.Atoi(flags.Arg(1))
+		if err != nil || n < min {
+			return 0, fmt.Errorf("%q is not a %s", flags.Arg(1), name)
+		}
+		return n, nil
+	}
+	action := flags.Arg(0)
+	if (action == "status" || action == "up" || action == "redo") && flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("migrate %s takes no arguments", action)
+	}
+
+	m, err := config.NewMigrator(db, fsys)
+	if err != nil {
+		return err
+	}
+	m.DryRun, m.Out = *dryRun, out
+
+	switch action {
+	case "status":
+		return printMigrations(m, out)
+	case "up":
+		return m.Up()
+	case "down":
+		n, err := number("number of migrations", 1)
+		if err != nil {
+			return err
+		}
+		return m.Down(n)
+	case "goto":
+		version, err := number("version", 0)
+		if err != nil {
+			return err
+		}
+		return m.Goto(version)
+	case "redo":
+		return m.Redo()
+	default:
+		flags.Usage()
+		if action == "" {
+			return errors.New("migrate needs an action")
+		}
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
+
+// printMigrations lists every migration with when it was applied, and the
+// version the database is at.
+func printMigrations(m *config.Migrator, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	pending := 0
+	for _, mig := range m.Migrations() {
+		state, appliedAt := "pending", ""
+		if mig.Applied {
+			state, appliedAt = "applied", mig.AppliedAt.Format("2006-01-02 15:04")
+		} else {
+			pending++
+		}
+		var notes []string
+		if mig.Down == "" {
+			notes = append(notes, "no down migration")
+		}
+		if !mig.Applied && mig.RequiresFTS5() {
+			notes = append(notes, "needs SQLite built with FTS5")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", state, appliedAt, mig.Name, strings.Join(notes, ", "))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "version %d, %d pending\n", m.Version(), pending)
+	return err
+}
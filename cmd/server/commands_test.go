@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/migrations"
+)
+
+func TestImportBGStatsCommand(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	const backup = "../../internal/playio/testdata/bgstats.json"
+	countPlays := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&n))
+		return n
+	}
+
+	t.Run("Reports Problems", func(t *testing.T) {
+		var out bytes.Buffer
+		err := runCommand(db, "import-bgstats", []string{backup}, &out)
+		assert.EqualError(t, err, "1 of 4 plays cannot be imported; nothing was saved")
+		assert.Contains(t, out.String(), "play 4 (2024-03-15, Rhino): Captain Marvel has no aspect")
+		assert.Zero(t, countPlays())
+	})
+
+	t.Run("Dry Run", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(db, "import-bgstats", []string{"-aspect", "basic", "-dry-run", backup}, &out))
+		assert.Equal(t, "4 plays would be imported, 0 were imported before\n", out.String())
+		assert.Zero(t, countPlays())
+	})
+
+	t.Run("Import and Re-import", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runCommand(db, "import-bgstats", []string{"-aspect", "basic", backup}, &out))
+		assert.Equal(t, "4 plays imported, 0 were imported before\n", out.String())
+		assert.Equal(t, 4, countPlays())
+
+		out.Reset()
+		require.NoError(t, runCommand(db, "import-bgstats", []string{"-aspect", "basic", backup}, &out))
+		assert.Equal(t, "0 plays imported, 4 were imported before\n", out.String())
+		assert.Equal(t, 4, countPlays())
+	})
+
+	t.Run("Usage Errors", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.Error(t, runCommand(db, "import-bgstats", nil, &out))
+		assert.Contains(t, out.String(), "usage: server import-bgstats")
+		assert.EqualError(t, runCommand(db, "export", nil, &out), `unknown command "export"; the commands are import-bgstats, import-bgg, admin and migrate`)
+	})
+}
+
+func TestImportBGGCommand(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	const plays = "../../internal/playio/testdata/bgg_plays.xml"
+
+	var out bytes.Buffer
+	err = runCommand(db, "import-bgg", []string{plays}, &out)
+	assert.EqualError(t, err, "1 of 4 plays cannot be imported; nothing was saved")
+	assert.Contains(t, out.String(), "play 2 (2024-01-05, Rhino): Captain Marvel has no aspect")
+
+	out.Reset()
+	require.NoError(t, runCommand(db, "import-bgg", []string{"-aspect", "basic", plays}, &out))
+	assert.Equal(t, "4 plays imported, 0 were imported before\n", out.String())
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays WHERE source_id LIKE 'bgg:%'").Scan(&count))
+	assert.Equal(t, 4, count)
+}
+
+func TestImportOwner(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	_, err = db.Exec("INSERT INTO users (id, username, password_hash) VALUES (7, 'alice', '')")
+	require.NoError(t, err)
+
+	const plays = "../../internal/playio/testdata/bgg_plays.xml"
+	var out bytes.Buffer
+	assert.EqualError(t, runCommand(db, "import-bgg", []string{"-aspect", "basic", plays}, &out), "-owner is required once there are accounts")
+	assert.EqualError(t, runCommand(db, "import-bgg", []string{"-aspect", "basic", "-owner", "bob", plays}, &out), `there is no account named "bob"`)
+
+	require.NoError(t, runCommand(db, "import-bgg", []string{"-aspect", "basic", "-owner", "Alice", plays}, &out))
+	var owned int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays WHERE owner_id = 7").Scan(&owned))
+	assert.Equal(t, 4, owned)
+}
+
+func TestAdminCommand(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+	store := auth.NewStore(db)
+	_, err = store.Signup("alice", "password1")
+	require.NoError(t, err)
+	bob, err := store.Signup("bob", "password2")
+	require.NoError(t, err)
+	isAdmin := func() bool {
+		user, err := store.GetByID(bob.ID)
+		require.NoError(t, err)
+		return user.Admin
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, runCommand(db, "admin", []string{"BOB"}, &out))
+	assert.Equal(t, "bob is an admin\n", out.String())
+	assert.True(t, isAdmin())
+
+	out.Reset()
+	require.NoError(t, runCommand(db, "admin", []string{"-revoke", "bob"}, &out))
+	assert.Equal(t, "bob is no longer an admin\n", out.String())
+	assert.False(t, isAdmin())
+
+	assert.EqualError(t, runCommand(db, "admin", []string{"carol"}, &out), `there is no account named "carol"`)
+	assert.Error(t, runCommand(db, "admin", nil, &out))
+	assert.Contains(t, out.String(), "usage: server admin")
+}
+
+func TestMigrateCommand(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	migrate := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := migrateCommand(db, migrations.FS, args, &out)
+		return out.String(), err
+	}
+	tables := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('migrations', 'sqlite_sequence')").Scan(&n))
+		return n
+	}
+
+	out, err := migrate("status")
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^pending +001_initial_schema\.sql`, out)
+	assert.Regexp(t, `(?m)^pending +013_play_search\.sql +needs SQLite built with FTS5`, out)
+	assert.Contains(t, out, "version 0, 17 pending\n")
+
+	out, err = migrate("-dry-run", "up")
+	require.NoError(t, err)
+	assert.Contains(t, out, "-- 001_initial_schema.sql\n")
+	assert.Contains(t, out, "CREATE TABLE IF NOT EXISTS heroes (")
+	assert.Contains(t, out, "-- 012_groups.sql\n")
+	assert.Zero(t, tables(), "a dry run changes nothing")
+
+	_, err = migrate("goto", "11")
+	require.NoError(t, err)
+	out, err = migrate("status")
+	require.NoError(t, err)
+	assert.Regexp(t, `(?m)^applied +\d{4}-\d\d-\d\d \d\d:\d\d +011_users\.sql`, out)
+	assert.Regexp(t, `(?m)^pending +012_groups\.sql`, out)
+	assert.Contains(t, out, "version 11, 6 pending\n")
+
+	out, err = migrate("-dry-run", "down", "2")
+	require.NoError(t, err)
+	assert.Equal(t, "-- 011_users.down.sql\n", out[:len("-- 011_users.down.sql\n")])
+	assert.Contains(t, out, "-- 010_players.down.sql\n")
+	assert.Contains(t, out, "DROP INDEX IF EXISTS idx_decks_player_id;\nALTER TABLE decks DROP COLUMN player_id;\n")
+
+	_, err = migrate("down", "2")
+	require.NoError(t, err)
+	_, err = migrate("redo")
+	require.NoError(t, err)
+	out, err = migrate("status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "version 9, 8 pending\n")
+
+	_, err = migrate("up")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (username, password_hash) VALUES ('alice', '')")
+	assert.NoError(t, err, "users is back")
+
+	t.Run("Usage Errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			err  string
+		}{
+			{nil, "migrate needs an action"},
+			{[]string{"sideways"}, `unknown migrate action "sideways"`},
+			{[]string{"down"}, "migrate down needs a number of migrations"},
+			{[]string{"down", "0"}, `"0" is not a number of migrations`},
+			{[]string{"goto", "latest"}, `"latest" is not a version`},
+			{[]string{"goto", "99"}, "there is no migration 099"},
+			{[]string{"redo", "now"}, "migrate redo takes no arguments"},
+		} {
+			_, err := migrate(tc.args...)
+			assert.EqualError(t, err, tc.err, tc.args)
+		}
+	})
+}
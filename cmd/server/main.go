@@ -1,32 +1,67 @@
 package main
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
 
-	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/app"
 	"marvel_tracker/internal/config"
-	"marvel_tracker/internal/handlers"
 )
 
 func main() {
-	db := config.InitDB()
-	defer db.Close()
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.Level()})))
 
-	if err := config.RunMigrations(db); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	migrationFS := app.Migrations(cfg)
+
+	db, err := config.InitDB(cfg.DB)
+	if err != nil {
+		fatal("Failed to open database", err)
 	}
+	defer db.Close()
 
-	r := gin.Default()
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrateCommand(db, migrationFS, args[1:], os.Stdout); err != nil {
+			fatal("Migrate failed", err)
+		}
+		return
+	}
 
-	r.LoadHTMLGlob("templates/*")
-	r.Static("/static", "./static")
+	if err := config.RunMigrations(db, migrationFS); err != nil {
+		fatal("Failed to run migrations", err)
+	}
 
-	r.GET("/", handlers.Home)
-	r.GET("/plays", handlers.Plays)
-	r.GET("/plays/new", handlers.NewPlay)
+	if len(args) > 0 {
+		if err := runCommand(db, args[0], args[1:], os.Stdout); err != nil {
+			fatal(args[0]+" failed", err)
+		}
+		return
+	}
 
-	log.Println("Starting server on :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server, err := app.New(cfg, db)
+	if err != nil {
+		fatal("Failed to start server", err)
+	}
+	slog.Info("Starting server", "addr", cfg.Addr)
+	if err := http.ListenAndServe(cfg.Addr, server.Routes()); err != nil {
+		fatal("Failed to start server", err)
 	}
 }
+
+// fatal logs err and exits. log.Fatal would log at the info level, which a
+// warn or error log level drops.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
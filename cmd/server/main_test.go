@@ -7,61 +7,35 @@ import (
 	"testing"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/app"
 	"marvel_tracker/internal/config"
-	"marvel_tracker/internal/handlers"
+	"marvel_tracker/migrations"
 )
 
-func setupTestServer(t *testing.T) *gin.Engine {
-	gin.SetMode(gin.TestMode)
+// setupTestServer builds the server the way main does, on a fresh
+// database.
+func setupTestServer(t *testing.T, cfg config.Config) http.Handler {
+	cfg.GinMode = "test"
+	cfg.LogLevel = "warn"
 
 	// Create test database
 	tempDir := t.TempDir()
-	dbPath := tempDir + "/test.db"
-	os.Setenv("DB_PATH", dbPath)
-	defer os.Unsetenv("DB_PATH")
-
-	db := config.InitDB()
-	defer db.Close()
-
-	err := config.RunMigrations(db)
+	cfg.DB.Path = tempDir + "/test.db"
+	db, err := config.InitDB(cfg.DB)
 	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
 
-	r := gin.New()
-
-	// Load templates (create minimal test templates)
-	testTemplatesDir := tempDir + "/templates"
-	err = os.MkdirAll(testTemplatesDir, 0755)
+	require.NoError(t, config.RunMigrations(db, app.Migrations(&cfg)))
+	server, err := app.New(&cfg, db)
 	require.NoError(t, err)
-
-	// Create minimal test templates
-	templates := map[string]string{
-		"index.html":    `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>Home</h1></body></html>`,
-		"plays.html":    `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>Plays</h1></body></html>`,
-		"new_play.html": `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>New Play</h1></body></html>`,
-		"error.html":    `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>Error</h1></body></html>`,
-	}
-
-	for filename, content := range templates {
-		err = os.WriteFile(testTemplatesDir+"/"+filename, []byte(content), 0644)
-		require.NoError(t, err)
-	}
-
-	r.LoadHTMLGlob(testTemplatesDir + "/*")
-
-	// Setup routes like in main
-	r.GET("/", handlers.Home)
-	r.GET("/plays", handlers.Plays)
-	r.GET("/plays/new", handlers.NewPlay)
-
-	return r
+	return server.Routes()
 }
 
 func TestServerInitialization(t *testing.T) {
 	t.Run("Server Routes Registration", func(t *testing.T) {
-		server := setupTestServer(t)
+		server := setupTestServer(t, config.Default())
 
 		// Test that all expected routes are registered and working
 		routes := []struct {
@@ -71,7 +45,9 @@ func TestServerInitialization(t *testing.T) {
 		}{
 			{"GET", "/", http.StatusOK},
 			{"GET", "/plays", http.StatusOK},
-			{"GET", "/plays/new", http.StatusOK},
+			{"GET", "/plays/new", http.StatusSeeOther},
+			{"GET", "/heroes", http.StatusOK},
+			{"GET", "/api/v1/heroes", http.StatusOK},
 		}
 
 		for _, route := range routes {
@@ -85,7 +61,18 @@ func TestServerInitialization(t *testing.T) {
 	})
 
 	t.Run("Template Loading", func(t *testing.T) {
-		server := setupTestServer(t)
+		// Create minimal test templates
+		cfg := config.Default()
+		cfg.TemplateDir = t.TempDir()
+		templates := map[string]string{
+			"index.html": `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>Home</h1></body></html>`,
+			"error.html": `<!DOCTYPE html><html><head><title>{{.title}}</title></head><body><h1>Error</h1></body></html>`,
+		}
+		for filename, content := range templates {
+			err := os.WriteFile(cfg.TemplateDir+"/"+filename, []byte(content), 0644)
+			require.NoError(t, err)
+		}
+		server := setupTestServer(t, cfg)
 
 		// Test that templates are loaded and rendering correctly
 		w := httptest.NewRecorder()
@@ -100,20 +87,16 @@ func TestServerInitialization(t *testing.T) {
 	t.Run("Database Integration", func(t *testing.T) {
 		// Test that database initialization and migrations work
 		tempDir := t.TempDir()
-		dbPath := tempDir + "/integration_test.db"
-
-		os.Setenv("DB_PATH", dbPath)
-		defer os.Unsetenv("DB_PATH")
-
-		db := config.InitDB()
+		db, err := config.InitDB(config.DBConfig{Path: tempDir + "/integration_test.db"})
+		require.NoError(t, err)
 		defer db.Close()
 
 		// Test database connection
-		err := db.Ping()
+		err = db.Ping()
 		assert.NoError(t, err)
 
-		// Test migrations (skip table verification since migrations directory may not exist in test)
-		err = config.RunMigrations(db)
+		// Test migrations
+		err = config.RunMigrations(db, migrations.FS)
 		assert.NoError(t, err)
 
 		// Verify migrations table exists (this should always be created)
@@ -124,9 +107,8 @@ func TestServerInitialization(t *testing.T) {
 	})
 
 	t.Run("Static File Serving Configuration", func(t *testing.T) {
-		server := setupTestServer(t)
-
-		// Create a test static directory and file
+		// Create a test static directory and file, and run in dev mode
+		// from its parent so that it is served from disk
 		tempDir := t.TempDir()
 		staticDir := tempDir + "/static"
 		err := os.MkdirAll(staticDir, 0755)
@@ -136,8 +118,12 @@ func TestServerInitialization(t *testing.T) {
 		err = os.WriteFile(staticDir+"/test.css", []byte(testCSS), 0644)
 		require.NoError(t, err)
 
-		// Add static file serving to test server
-		server.Static("/static", staticDir)
+		originalWd, _ := os.Getwd()
+		defer os.Chdir(originalWd)
+		require.NoError(t, os.Chdir(tempDir))
+		cfg := config.Default()
+		cfg.Dev = true
+		server := setupTestServer(t, cfg)
 
 		// Test static file access
 		w := httptest.NewRecorder()
@@ -149,8 +135,29 @@ func TestServerInitialization(t *testing.T) {
 		assert.Contains(t, w.Header().Get("Content-Type"), "text/css")
 	})
 
+	t.Run("Embedded Assets", func(t *testing.T) {
+		// The binary serves its own templates and static files, whatever
+		// the working directory.
+		originalWd, _ := os.Getwd()
+		defer os.Chdir(originalWd)
+		require.NoError(t, os.Chdir(t.TempDir()))
+		server := setupTestServer(t, config.Default())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Welcome to Marvel Champions Play Tracker")
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/static/favicon.svg", nil)
+		server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "image/svg+xml")
+	})
+
 	t.Run("Error Handling in Production", func(t *testing.T) {
-		server := setupTestServer(t)
+		server := setupTestServer(t, config.Default())
 
 		// Test 404 handling
 		w := httptest.NewRecorder()
@@ -176,13 +183,14 @@ func TestServerInitialization(t *testing.T) {
 				os.Remove(tc.dbPath)
 				defer os.Remove(tc.dbPath)
 
-				os.Setenv("DB_PATH", tc.dbPath)
-				defer os.Unsetenv("DB_PATH")
-
-				db := config.InitDB()
+				env := map[string]string{"DB_PATH": tc.dbPath}
+				cfg, _, err := config.Load(nil, func(name string) string { return env[name] })
+				require.NoError(t, err)
+				db, err := config.InitDB(cfg.DB)
+				require.NoError(t, err)
 				defer db.Close()
 
-				err := db.Ping()
+				err = db.Ping()
 				assert.NoError(t, err)
 
 				// Verify file was created at the expected path
@@ -199,9 +207,9 @@ func TestServerPerformance(t *testing.T) {
 	}
 
 	t.Run("Response Time Benchmarks", func(t *testing.T) {
-		server := setupTestServer(t)
+		server := setupTestServer(t, config.Default())
 
-		routes := []string{"/", "/plays", "/plays/new"}
+		routes := []string{"/", "/plays", "/heroes"}
 
 		for _, route := range routes {
 			start := time.Now()
@@ -219,7 +227,7 @@ func TestServerPerformance(t *testing.T) {
 	})
 
 	t.Run("Concurrent Request Handling", func(t *testing.T) {
-		server := setupTestServer(t)
+		server := setupTestServer(t, config.Default())
 
 		const numRequests = 10
 		results := make(chan bool, numRequests)
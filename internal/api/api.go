@@ -0,0 +1,253 @@
+// Package api serves the tracker as JSON under /api/v1. Every route is
+// declared once in Routes, which is used both to register it with Gin and
+// to describe it in the OpenAPI document served at /api/v1/openapi.json,
+// so the two cannot drift apart.
+//
+// Requests are made as the user logged in with the session cookie, set up
+// by the auth middleware: they see that user's plays and those of users who
+// share theirs, and every request other than a GET needs a login. Only
+// admins may rename, archive or delete heroes and scenarios.
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/stats"
+)
+
+// Prefix is the path every API route is served under.
+const Prefix = "/api/v1"
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 200
+)
+
+// Repositories are the data sources the API handlers read and write.
+type Repositories struct {
+	Plays         *models.PlayRepository
+	Heroes        *models.HeroRepository
+	Scenarios     *models.ScenarioRepository
+	EncounterSets *models.EncounterSetRepository
+	Decks         *models.DeckRepository
+	Players       *models.PlayerRepository
+	Stats         *stats.Repository
+}
+
+// Route is one API endpoint together with the details the OpenAPI
+// document needs. Path is relative to Prefix and uses Gin's :param syntax.
+type Route struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []Param
+	Body     string // schema name of the JSON request body, if any
+	Response string // schema name of the data returned, if any
+	List     bool   // the response is a paginated list of Response
+	Status   int    // status on success; zero means 200
+	Admin    bool   // only admins may make the request
+	Handler  gin.HandlerFunc
+}
+
+// Param is a query parameter accepted by a route.
+type Param struct {
+	Name        string
+	Type        string // "string", "integer" or "boolean"
+	Description string
+}
+
+var pageParams = []Param{
+	{Name: "page", Type: "integer", Description: "Page number, starting at 1."},
+	{Name: "per_page", Type: "integer", Description: "Items per page, at most 200. Defaults to 50."},
+}
+
+// Routes returns every API route, wired to repos.
+func Routes(repos Repositories) []Route {
+	var routes []Route
+	routes = append(routes, playRoutes(repos)...)
+	routes = append(routes, catalogRoutes("/heroes", "Heroes", "Hero", heroCatalog(repos.Heroes))...)
+	routes = append(routes, catalogRoutes("/scenarios", "Scenarios", "Scenario", scenarioCatalog(repos.Scenarios))...)
+	routes = append(routes, encounterSetRoutes(repos)...)
+	routes = append(routes, deckRoutes(repos)...)
+	routes = append(routes, playerRoutes(repos)...)
+	routes = append(routes, statsRoutes(repos)...)
+	return routes
+}
+
+// Register adds every API route, and the OpenAPI document describing them,
+// to r under Prefix.
+func Register(r gin.IRouter, repos Repositories) {
+	routes := Routes(repos)
+	group := r.Group(Prefix)
+	for _, route := range routes {
+		if route.Admin {
+			group.Handle(route.Method, route.Path, auth.RequireAdmin(), route.Handler)
+			continue
+		}
+		group.Handle(route.Method, route.Path, route.Handler)
+	}
+
+	doc := OpenAPI(routes)
+	group.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+}
+
+// errorBody is the envelope every failed API request returns, as
+// {"error": {...}}.
+type errorBody struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// respondError writes the JSON error envelope for err. Validation errors
+// and missing rows are reported as they are; anything else is logged by
+// the error middleware and reported as a generic internal error.
+func respondError(c *gin.Context, err error) {
+	body := errorBody{
+		Status:  http.StatusInternalServerError,
+		Code:    "internal_error",
+		Message: "internal server error",
+	}
+
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		body = errorBody{
+			Status:  http.StatusBadRequest,
+			Code:    "validation_error",
+			Message: validationErr.Message,
+			Field:   validationErr.Field,
+		}
+	case errors.Is(err, models.ErrNotFound):
+		body = errorBody{Status: http.StatusNotFound, Code: "not_found", Message: "not found"}
+	default:
+		c.Error(err)
+	}
+
+	c.AbortWithStatusJSON(body.Status, gin.H{"error": body})
+}
+
+// invalid reports a problem with a request parameter or body field.
+func invalid(c *gin.Context, field, message string) {
+	respondError(c, &models.ValidationError{Field: field, Message: message})
+}
+
+// bindJSON decodes the request body into v, reporting malformed JSON in
+// the error envelope. It returns false if the request has been answered.
+func bindJSON(c *gin.Context, v any) bool {
+	if err := c.ShouldBindJSON(v); err != nil {
+		invalid(c, "body", "request body must be valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// pathID reads the :id path parameter. It returns false if the request
+// has been answered.
+func pathID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id < 1 {
+		respondError(c, models.ErrNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// queryInt reads an optional positive integer query parameter, returning
+// zero when it is absent. It returns false if the request has been
+// answered.
+func queryInt(c *gin.Context, name string) (int, bool) {
+	raw := strings.TrimSpace(c.Query(name))
+	if raw == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		invalid(c, name, name+" must be a positive integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// queryBool reads an optional true/false query parameter. It returns nil
+// when the parameter is absent, and false if the request has been answered.
+func queryBool(c *gin.Context, name string) (*bool, bool) {
+	raw := strings.TrimSpace(c.Query(name))
+	if raw == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		invalid(c, name, name+" must be true or false")
+		return nil, false
+	}
+	return &b, true
+}
+
+// pagination is returned alongside every list response.
+type pagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// readPage reads the page and per_page query parameters. It returns false
+// if the request has been answered.
+func readPage(c *gin.Context) (pagination, bool) {
+	p := pagination{Page: 1, PerPage: defaultPerPage}
+	page, ok := queryInt(c, "page")
+	if !ok {
+		return p, false
+	}
+	perPage, ok := queryInt(c, "per_page")
+	if !ok {
+		return p, false
+	}
+	if page > 0 {
+		p.Page = page
+	}
+	if perPage > 0 {
+		p.PerPage = min(perPage, maxPerPage)
+	}
+	// Keep offset from overflowing; no list comes anywhere near this long.
+	if p.Page > math.MaxInt/p.PerPage {
+		invalid(c, "page", "page is too large")
+		return p, false
+	}
+	return p, true
+}
+
+func (p pagination) offset() int {
+	return (p.Page - 1) * p.PerPage
+}
+
+// respondList writes a page of results. items must be a slice.
+func respondList(c *gin.Context, items any, p pagination, total int) {
+	p.Total = total
+	p.TotalPages = (total + p.PerPage - 1) / p.PerPage
+	c.JSON(http.StatusOK, gin.H{"data": items, "pagination": p})
+}
+
+// respondData writes a single result.
+func respondData(c *gin.Context, status int, data any) {
+	c.JSON(status, gin.H{"data": data})
+}
+
+// pageOf returns the items of a fully loaded list that fall on page p.
+func pageOf[T any](items []T, p pagination) []T {
+	start := min(p.offset(), len(items))
+	end := min(start+p.PerPage, len(items))
+	return append([]T{}, items[start:end]...)
+}
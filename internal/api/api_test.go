@@ -0,0 +1,661 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/middleware"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/stats"
+	"marvel_tracker/migrations"
+)
+
+// setupTestAPI serves the API over an in-memory database migrated with the
+// shipped migrations, so the official catalog is available. Requests are
+// made as the admin "tester" unless they carry a session cookie of their
+// own; an empty one makes a visitor.
+func setupTestAPI(t *testing.T) (*gin.Engine, *sql.DB) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	store := auth.NewStore(db)
+	_, err = db.Exec("INSERT INTO users (username, password_hash, is_admin) VALUES ('tester', '', 1)")
+	require.NoError(t, err)
+	user, err := store.GetByUsername("tester")
+	require.NoError(t, err)
+	token, err := store.CreateSession(user.ID)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if _, err := c.Cookie(auth.CookieName); err != nil {
+			c.Request.AddCookie(&http.Cookie{Name: auth.CookieName, Value: token})
+		}
+		c.Next()
+	})
+	r.Use(middleware.ErrorHandler(), auth.Sessions(store), auth.RequireLoginToChange())
+	Register(r, Repositories{
+		Plays:         models.NewPlayRepository(db),
+		Heroes:        models.NewHeroRepository(db),
+		Scenarios:     models.NewScenarioRepository(db),
+		EncounterSets: models.NewEncounterSetRepository(db),
+		Decks:         models.NewDeckRepository(db),
+		Players:       models.NewPlayerRepository(db),
+		Stats:         stats.NewRepository(db),
+	})
+	return r, db
+}
+
+type response struct {
+	Code     int
+	Location string
+	Body     struct {
+		Data       json.RawMessage `json:"data"`
+		Pagination *pagination     `json:"pagination"`
+		Error      *errorBody      `json:"error"`
+	}
+}
+
+func do(t *testing.T, r http.Handler, method, path, body string) response {
+	t.Helper()
+	req, err := http.NewRequest(method, Prefix+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	res := response{Code: w.Code, Location: w.Header().Get("Location")}
+	if w.Body.Len() > 0 {
+		require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res.Body), w.Body.String())
+	}
+	return res
+}
+
+func decode[T any](t *testing.T, raw json.RawMessage) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(raw, &v))
+	return v
+}
+
+func TestPlaysAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	create := func(body string) models.PlaySummary {
+		t.Helper()
+		res := do(t, r, http.MethodPost, "/plays", body)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+		return decode[models.PlaySummary](t, res.Body.Data)
+	}
+
+	var first models.PlaySummary
+	t.Run("Create", func(t *testing.T) {
+		res := do(t, r, http.MethodPost, "/plays", `{
+			"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+			"notes": " Close one ",
+			"decks": [
+				{"hero": "Spider-Man", "aspects": ["justice"], "player_name": "Sam"},
+				{"hero": "She-Hulk", "aspects": ["aggression"]}
+			]}`)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+
+		first = decode[models.PlaySummary](t, res.Body.Data)
+		assert.Equal(t, "/api/v1/plays/"+itoa(first.ID), res.Location)
+		assert.Equal(t, "Rhino", first.Scenario)
+		assert.Equal(t, "Close one", first.Notes)
+		require.Len(t, first.Heroes, 2)
+		assert.Equal(t, "Spider-Man", first.Heroes[0].Hero)
+		assert.Equal(t, "Sam", first.Heroes[0].PlayerName)
+		assert.Equal(t, []string{"aggression"}, first.Heroes[1].Aspects)
+	})
+
+	t.Run("Create Validation Errors", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			body    string
+			field   string
+			message string
+		}{
+			"Bad Date":       {`{"date": "03/01/2024", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win"}`, "date", "date must be in YYYY-MM-DD format"},
+			"Bad Outcome":    {`{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "draw", "decks": [{"hero": "Thor", "aspects": ["justice"]}]}`, "outcome", ""},
+			"Unknown Aspect": {`{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win", "decks": [{"hero": "Thor", "aspects": ["chaos"]}]}`, "aspect", `unknown aspect "chaos"`},
+			"Malformed JSON": {`{"date": `, "body", ""},
+		} {
+			t.Run(name, func(t *testing.T) {
+				res := do(t, r, http.MethodPost, "/plays", tc.body)
+				assert.Equal(t, http.StatusBadRequest, res.Code)
+				require.NotNil(t, res.Body.Error)
+				assert.Equal(t, http.StatusBadRequest, res.Body.Error.Status)
+				assert.Equal(t, "validation_error", res.Body.Error.Code)
+				assert.Equal(t, tc.field, res.Body.Error.Field)
+				if tc.message != "" {
+					assert.Equal(t, tc.message, res.Body.Error.Message)
+				}
+			})
+		}
+	})
+
+	second := create(`{"date": "2024-03-02", "scenario": "Klaw", "difficulty": "Expert I", "outcome": "loss",
+		"decks": [{"hero": "Spider-Man", "aspects": ["protection"]}]}`)
+	third := create(`{"date": "2024-03-03", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "loss",
+		"decks": [{"hero": "She-Hulk", "aspects": ["leadership"]}]}`)
+
+	t.Run("Get", func(t *testing.T) {
+		res := do(t, r, http.MethodGet, "/plays/"+itoa(second.ID), "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, second, decode[models.PlaySummary](t, res.Body.Data))
+
+		for _, path := range []string{"/plays/999", "/plays/abc"} {
+			res = do(t, r, http.MethodGet, path, "")
+			assert.Equal(t, http.StatusNotFound, res.Code, path)
+			require.NotNil(t, res.Body.Error)
+			assert.Equal(t, "not_found", res.Body.Error.Code)
+		}
+	})
+
+	t.Run("List with Pagination", func(t *testing.T) {
+		res := do(t, r, http.MethodGet, "/plays?per_page=2", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, &pagination{Page: 1, PerPage: 2, Total: 3, TotalPages: 2}, res.Body.Pagination)
+		page := decode[[]models.PlaySummary](t, res.Body.Data)
+		assert.Equal(t, []int{third.ID, second.ID}, summaryIDs(page))
+
+		res = do(t, r, http.MethodGet, "/plays?per_page=2&page=2", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, []int{first.ID}, summaryIDs(decode[[]models.PlaySummary](t, res.Body.Data)))
+
+		res = do(t, r, http.MethodGet, "/plays?page=5", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.JSONEq(t, `[]`, string(res.Body.Data))
+
+		res = do(t, r, http.MethodGet, "/plays?per_page=0", "")
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "per_page", res.Body.Error.Field)
+
+		// A page this large would overflow the offset.
+		for _, path := range []string{"/plays?page=46116860184273882&per_page=200", "/heroes?page=46116860184273882&per_page=200"} {
+			res = do(t, r, http.MethodGet, path, "")
+			assert.Equal(t, http.StatusBadRequest, res.Code, path)
+			assert.Equal(t, "validation_error", res.Body.Error.Code, path)
+			assert.Equal(t, "page", res.Body.Error.Field, path)
+		}
+	})
+
+	t.Run("List with Filters", func(t *testing.T) {
+		spiderMan := first.Heroes[0].HeroID
+		for query, want := range map[string][]int{
+			"?outcome=loss":                          {third.ID, second.ID},
+			"?scenario_id=" + itoa(first.ScenarioID): {third.ID, first.ID},
+			"?hero_id=" + itoa(spiderMan):            {second.ID, first.ID},
+			"?difficulty=Expert%20I":                 {second.ID},
+			"?aspect=leadership":                     {third.ID},
+			"?players=1&hero_id=" + itoa(spiderMan):  {second.ID},
+			"?from=2024-03-02&to=2024-03-02":         {second.ID},
+		} {
+			res := do(t, r, http.MethodGet, "/plays"+query, "")
+			require.Equal(t, http.StatusOK, res.Code, query)
+			assert.Equal(t, want, summaryIDs(decode[[]models.PlaySummary](t, res.Body.Data)), query)
+		}
+
+		for query, field := range map[string]string{
+			"?outcome=draw":    "outcome",
+			"?from=yesterday":  "from",
+			"?hero_id=-1":      "hero_id",
+			"?scenario_id=abc": "scenario_id",
+			"?players=0":       "players",
+		} {
+			res := do(t, r, http.MethodGet, "/plays"+query, "")
+			assert.Equal(t, http.StatusBadRequest, res.Code, query)
+			assert.Equal(t, field, res.Body.Error.Field, query)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		res := do(t, r, http.MethodPut, "/plays/"+itoa(third.ID), `{
+			"date": "2024-03-04", "scenario_id": `+itoa(second.ScenarioID)+`,
+			"difficulty": "Expert I", "outcome": "win", "notes": "Rematch"}`)
+		require.Equal(t, http.StatusOK, res.Code, res.Body.Error)
+
+		updated := decode[models.PlaySummary](t, res.Body.Data)
+		assert.Equal(t, "Klaw", updated.Scenario)
+		assert.Equal(t, "win", updated.Outcome)
+		assert.Equal(t, "Rematch", updated.Notes)
+		assert.Equal(t, third.Heroes, updated.Heroes, "decks are left alone")
+
+		res = do(t, r, http.MethodPut, "/plays/999", `{"date": "2024-03-04", "scenario": "Klaw", "difficulty": "Expert I", "outcome": "win"}`)
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		res := do(t, r, http.MethodDelete, "/plays/"+itoa(third.ID), "")
+		assert.Equal(t, http.StatusNoContent, res.Code)
+
+		res = do(t, r, http.MethodGet, "/plays/"+itoa(third.ID), "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		res = do(t, r, http.MethodDelete, "/plays/"+itoa(third.ID), "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+}
+
+func TestCatalogAPI(t *testing.T) {
+	r, db := setupTestAPI(t)
+
+	t.Run("List and Search", func(t *testing.T) {
+		res := do(t, r, http.MethodGet, "/heroes?q=spider&per_page=200", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		heroes := decode[[]models.Hero](t, res.Body.Data)
+		require.NotEmpty(t, heroes)
+		for _, h := range heroes {
+			assert.Contains(t, strings.ToLower(h.Name), "spider")
+		}
+		assert.Equal(t, len(heroes), res.Body.Pagination.Total)
+
+		res = do(t, r, http.MethodGet, "/scenarios?archived=true", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.JSONEq(t, `[]`, string(res.Body.Data))
+
+		res = do(t, r, http.MethodGet, "/scenarios?archived=maybe", "")
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "archived", res.Body.Error.Field)
+	})
+
+	var custom models.Hero
+	t.Run("Create", func(t *testing.T) {
+		res := do(t, r, http.MethodPost, "/heroes", `{"name": " Squirrel Girl "}`)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+		custom = decode[models.Hero](t, res.Body.Data)
+		assert.Equal(t, "Squirrel Girl", custom.Name)
+		assert.Nil(t, custom.PackID)
+		assert.Equal(t, "/api/v1/heroes/"+itoa(custom.ID), res.Location)
+
+		res = do(t, r, http.MethodPost, "/heroes", `{"name": "spider-man"}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "name", res.Body.Error.Field)
+
+		res = do(t, r, http.MethodPost, "/heroes", `{}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "name is required", res.Body.Error.Message)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		res := do(t, r, http.MethodPut, "/heroes/"+itoa(custom.ID), `{"name": "Doreen Green", "archived": true}`)
+		require.Equal(t, http.StatusOK, res.Code, res.Body.Error)
+		updated := decode[models.Hero](t, res.Body.Data)
+		assert.Equal(t, "Doreen Green", updated.Name)
+		assert.NotNil(t, updated.ArchivedAt)
+
+		// Official heroes cannot be renamed, but can be archived by sending
+		// the object back unchanged.
+		res = do(t, r, http.MethodGet, "/heroes?q=spider-man", "")
+		official := decode[[]models.Hero](t, res.Body.Data)[0]
+		res = do(t, r, http.MethodPut, "/heroes/"+itoa(official.ID), `{"name": "`+official.Name+`", "archived": true}`)
+		require.Equal(t, http.StatusOK, res.Code, res.Body.Error)
+		assert.NotNil(t, decode[models.Hero](t, res.Body.Data).ArchivedAt)
+
+		res = do(t, r, http.MethodPut, "/heroes/"+itoa(official.ID), `{"name": "Spidey"}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+
+		res = do(t, r, http.MethodPut, "/scenarios/999", `{"archived": false}`)
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		res := do(t, r, http.MethodPost, "/scenarios", `{"name": "Homebrew Villain"}`)
+		require.Equal(t, http.StatusCreated, res.Code)
+		scenario := decode[models.Scenario](t, res.Body.Data)
+
+		res = do(t, r, http.MethodDelete, "/scenarios/"+itoa(scenario.ID), "")
+		assert.Equal(t, http.StatusNoContent, res.Code)
+		res = do(t, r, http.MethodGet, "/scenarios/"+itoa(scenario.ID), "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+
+		res = do(t, r, http.MethodGet, "/scenarios?q=rhino", "")
+		rhino := decode[[]models.Scenario](t, res.Body.Data)[0]
+		res = do(t, r, http.MethodDelete, "/scenarios/"+itoa(rhino.ID), "")
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "official scenarios cannot be deleted", res.Body.Error.Message)
+	})
+
+	t.Run("Changes Need An Admin", func(t *testing.T) {
+		_, err := db.Exec("UPDATE users SET is_admin = 0")
+		require.NoError(t, err)
+		defer db.Exec("UPDATE users SET is_admin = 1")
+
+		res := do(t, r, http.MethodPost, "/heroes", `{"name": "Howard the Duck"}`)
+		require.Equal(t, http.StatusCreated, res.Code, "any user may add a custom hero")
+		hero := decode[models.Hero](t, res.Body.Data)
+
+		res = do(t, r, http.MethodPut, "/heroes/"+itoa(hero.ID), `{"name": "Howard", "archived": true}`)
+		assert.Equal(t, http.StatusForbidden, res.Code)
+		assert.Equal(t, "forbidden", res.Body.Error.Code)
+		res = do(t, r, http.MethodDelete, "/heroes/"+itoa(hero.ID), "")
+		assert.Equal(t, http.StatusForbidden, res.Code)
+
+		res = do(t, r, http.MethodGet, "/heroes/"+itoa(hero.ID), "")
+		assert.Equal(t, "Howard the Duck", decode[models.Hero](t, res.Body.Data).Name)
+	})
+}
+
+func TestDecksAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	res := do(t, r, http.MethodPost, "/plays", `{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	play := decode[models.PlaySummary](t, res.Body.Data)
+
+	heroID := func(name string) int {
+		res := do(t, r, http.MethodGet, "/heroes?q="+strings.ReplaceAll(name, " ", "%20"), "")
+		require.Equal(t, http.StatusOK, res.Code)
+		for _, h := range decode[[]models.Hero](t, res.Body.Data) {
+			if h.Name == name {
+				return h.ID
+			}
+		}
+		t.Fatalf("no hero named %q", name)
+		return 0
+	}
+
+	var added models.Deck
+	t.Run("Create", func(t *testing.T) {
+		res := do(t, r, http.MethodPost, "/decks", `{"play_id": `+itoa(play.ID)+`, "hero_id": `+itoa(heroID("Iron Man"))+`, "aspects": ["aggression"], "player_name": "Alex"}`)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+		added = decode[models.Deck](t, res.Body.Data)
+		assert.Equal(t, play.ID, added.PlayID)
+		assert.Equal(t, []string{"aggression"}, added.Aspects)
+		assert.Equal(t, "Alex", added.PlayerName)
+		assert.Equal(t, "/api/v1/decks/"+itoa(added.ID), res.Location)
+
+		res = do(t, r, http.MethodPost, "/decks", `{"play_id": `+itoa(play.ID)+`, "hero_id": `+itoa(play.Heroes[0].HeroID)+`, "aspects": ["justice"]}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "each hero can only appear once in a play", res.Body.Error.Message)
+
+		res = do(t, r, http.MethodPost, "/decks", `{"play_id": 999, "hero_id": 1, "aspects": ["justice"]}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "unknown play", res.Body.Error.Message)
+	})
+
+	t.Run("Player Limit", func(t *testing.T) {
+		for _, name := range []string{"Thor", "Black Widow"} {
+			res := do(t, r, http.MethodPost, "/decks", `{"play_id": `+itoa(play.ID)+`, "hero_id": `+itoa(heroID(name))+`, "aspects": ["basic"]}`)
+			require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+		}
+		res := do(t, r, http.MethodPost, "/decks", `{"play_id": `+itoa(play.ID)+`, "hero_id": `+itoa(heroID("Captain Marvel"))+`, "aspects": ["basic"]}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "a play can have at most 4 heroes", res.Body.Error.Message)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		res := do(t, r, http.MethodGet, "/decks?play_id="+itoa(play.ID), "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.Len(t, decode[[]models.Deck](t, res.Body.Data), 4)
+		assert.Equal(t, 4, res.Body.Pagination.Total)
+
+		res = do(t, r, http.MethodGet, "/decks?play_id=999", "")
+		require.Equal(t, http.StatusOK, res.Code)
+		assert.JSONEq(t, `[]`, string(res.Body.Data))
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		res := do(t, r, http.MethodPut, "/decks/"+itoa(added.ID), `{"hero_id": `+itoa(added.HeroID)+`, "aspects": ["leadership", "pool"]}`)
+		require.Equal(t, http.StatusOK, res.Code, res.Body.Error)
+		updated := decode[models.Deck](t, res.Body.Data)
+		assert.Equal(t, []string{"leadership", "pool"}, updated.Aspects)
+		assert.Empty(t, updated.PlayerName)
+
+		res = do(t, r, http.MethodPut, "/decks/999", `{"hero_id": 1, "aspects": ["pool"]}`)
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		res := do(t, r, http.MethodDelete, "/decks/"+itoa(added.ID), "")
+		assert.Equal(t, http.StatusNoContent, res.Code)
+		res = do(t, r, http.MethodGet, "/decks/"+itoa(added.ID), "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+	})
+
+	t.Run("Deleting a Play Removes Its Decks", func(t *testing.T) {
+		res := do(t, r, http.MethodDelete, "/plays/"+itoa(play.ID), "")
+		require.Equal(t, http.StatusNoContent, res.Code)
+		res = do(t, r, http.MethodGet, "/decks?play_id="+itoa(play.ID), "")
+		assert.JSONEq(t, `[]`, string(res.Body.Data))
+	})
+}
+
+func TestStatsAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	for _, body := range []string{
+		`{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win", "decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`,
+		`{"date": "2024-04-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "loss", "decks": [{"hero": "Spider-Man", "aspects": ["justice"]}, {"hero": "Thor", "aspects": ["basic"]}]}`,
+	} {
+		res := do(t, r, http.MethodPost, "/plays", body)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	}
+
+	res := do(t, r, http.MethodGet, "/stats/hero", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	rows := decode[[]stats.Row](t, res.Body.Data)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "Spider-Man", rows[0].Name)
+	assert.Equal(t, 2, rows[0].Plays)
+	assert.InDelta(t, 0.5, rows[0].WinRate, 0.001)
+
+	res = do(t, r, http.MethodGet, "/stats/scenario?players=1", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	rows = decode[[]stats.Row](t, res.Body.Data)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 1, rows[0].Wins)
+
+	res = do(t, r, http.MethodGet, "/stats/villain", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "dimension", res.Body.Error.Field)
+
+	res = do(t, r, http.MethodGet, "/stats/hero?from=soon", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "from", res.Body.Error.Field)
+}
+
+func TestEncounterSetsAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	res := do(t, r, http.MethodGet, "/encounter-sets?per_page=200", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	sets := decode[[]models.EncounterSet](t, res.Body.Data)
+	require.NotEmpty(t, sets)
+	assert.Equal(t, "Core Set", sets[0].Pack)
+	var bombScare int
+	for _, set := range sets {
+		if set.Name == "Bomb Scare" {
+			bombScare = set.ID
+		}
+	}
+	require.NotZero(t, bombScare)
+
+	res = do(t, r, http.MethodPost, "/plays", `{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"encounter_set_ids": [`+itoa(bombScare)+`], "decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	assert.Equal(t, []string{"Bomb Scare"}, decode[models.PlaySummary](t, res.Body.Data).EncounterSets)
+
+	res = do(t, r, http.MethodPost, "/plays", `{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"encounter_set_ids": [99999], "decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "encounter_set", res.Body.Error.Field)
+
+	res = do(t, r, http.MethodGet, "/stats/encounter_set", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	rows := decode[[]stats.Row](t, res.Body.Data)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Bomb Scare", rows[0].Name)
+	assert.Equal(t, 1, rows[0].Wins)
+}
+
+func TestEndStateAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	res := do(t, r, http.MethodPost, "/plays", `{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "loss",
+		"end_reason": "heroes_defeated", "rounds": 8, "villain_stage": 2, "remaining_threat": 4,
+		"decks": [{"hero": "Spider-Man", "aspects": ["justice"], "remaining_hp": 0}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	summary := decode[models.PlaySummary](t, res.Body.Data)
+	assert.Equal(t, "heroes_defeated", summary.EndReason)
+	require.NotNil(t, summary.Rounds)
+	assert.Equal(t, 8, *summary.Rounds)
+	require.NotNil(t, summary.Heroes[0].RemainingHP)
+	assert.Zero(t, *summary.Heroes[0].RemainingHP)
+
+	res = do(t, r, http.MethodPost, "/plays", `{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"end_reason": "heroes_defeated", "decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "end_reason", res.Body.Error.Field)
+
+	res = do(t, r, http.MethodGet, "/stats/loss-reasons", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	rows := decode[[]stats.LossReasonRow](t, res.Body.Data)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "Rhino", rows[0].Scenario)
+	assert.Equal(t, map[string]int{"heroes_defeated": 1}, rows[0].Reasons)
+
+	res = do(t, r, http.MethodGet, "/stats/loss-reasons?to=later", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "to", res.Body.Error.Field)
+}
+
+func TestPlayersAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	for _, body := range []string{
+		`{"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "loss", "decks": [{"hero": "Spider-Man", "aspects": ["justice"], "player_name": "Ann"}, {"hero": "Thor", "aspects": ["basic"], "player_name": "Ben"}]}`,
+		`{"date": "2024-04-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win", "decks": [{"hero": "Spider-Man", "aspects": ["justice"], "player_name": "ann"}]}`,
+	} {
+		res := do(t, r, http.MethodPost, "/plays", body)
+		require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	}
+
+	res := do(t, r, http.MethodGet, "/players", "")
+	require.Equal(t, http.StatusOK, res.Code)
+	players := decode[[]models.Player](t, res.Body.Data)
+	require.Len(t, players, 2)
+	assert.Equal(t, "Ann", players[0].Name)
+	ann := itoa(players[0].ID)
+
+	res = do(t, r, http.MethodPost, "/plays", `{"date": "2024-05-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"decks": [{"hero": "Spider-Man", "aspects": ["justice"], "player_id": `+ann+`}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	assert.Equal(t, "Ann", decode[models.PlaySummary](t, res.Body.Data).Heroes[0].PlayerName)
+
+	res = do(t, r, http.MethodGet, "/stats/hero?player_id="+ann, "")
+	require.Equal(t, http.StatusOK, res.Code)
+	rows := decode[[]stats.Row](t, res.Body.Data)
+	require.Len(t, rows, 1, "Ben's Thor deck is not Ann's")
+	assert.Equal(t, 3, rows[0].Plays)
+
+	res = do(t, r, http.MethodGet, "/stats/streaks?player_id="+ann, "")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, stats.Streaks{Current: 2, CurrentOutcome: "win", LongestWin: 2, LongestLoss: 1}, decode[stats.Streaks](t, res.Body.Data))
+
+	res = do(t, r, http.MethodGet, "/stats/streaks?player_id=someone", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Equal(t, "player_id", res.Body.Error.Field)
+}
+
+func TestOpenAPI(t *testing.T) {
+	r, _ := setupTestAPI(t)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, Prefix+"/openapi.json", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	// Every registered route is documented, and every schema it refers to
+	// is defined.
+	for _, route := range Routes(Repositories{}) {
+		path, _ := openAPIPath(route.Path)
+		op, ok := doc.Paths[path][strings.ToLower(route.Method)]
+		if !assert.True(t, ok, "%s %s is not documented", route.Method, path) {
+			continue
+		}
+		assert.NotEmpty(t, op["summary"])
+		for _, schema := range []string{route.Body, route.Response} {
+			if schema != "" {
+				assert.Contains(t, doc.Components.Schemas, schema)
+			}
+		}
+		_, forbidden := op["responses"].(map[string]any)["403"]
+		assert.Equal(t, route.Admin, forbidden, "%s %s documents a 403 only for admins", route.Method, path)
+	}
+	assert.Contains(t, doc.Paths, "/plays/{id}")
+	assert.Contains(t, doc.Components.Schemas, "Error")
+	assert.Contains(t, doc.Components.Schemas, "Pagination")
+}
+
+func TestAPIVisitors(t *testing.T) {
+	r, db := setupTestAPI(t)
+
+	res := do(t, r, http.MethodPost, "/plays", `{
+		"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+		"decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]}`)
+	require.Equal(t, http.StatusCreated, res.Code, res.Body.Error)
+	play := decode[models.PlaySummary](t, res.Body.Data)
+	assert.Equal(t, "tester", play.Owner)
+
+	visit := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, Prefix+path, nil)
+		req.AddCookie(&http.Cookie{Name: auth.CookieName, Value: ""})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := visit(http.MethodGet, "/plays")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":0`, "tester does not share their plays")
+	assert.Equal(t, http.StatusNotFound, visit(http.MethodGet, "/plays/"+itoa(play.ID)).Code)
+
+	_, err := db.Exec("UPDATE users SET shares_plays = 1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, visit(http.MethodGet, "/plays/"+itoa(play.ID)).Code)
+
+	w = visit(http.MethodDelete, "/plays/"+itoa(play.ID))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"unauthorized"`)
+}
+
+func summaryIDs(summaries []models.PlaySummary) []int {
+	var ids []int
+	for _, s := range summaries {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/models"
+)
+
+// catalog adapts a hero or scenario repository to the catalog routes. The
+// name and archived functions read the fields the list filters need.
+type catalog[T any] struct {
+	getAll      func() ([]T, error)
+	getByID     func(id int) (*T, error)
+	create      func(name string) (int, error)
+	rename      func(id int, name string) error
+	setArchived func(id int, archived bool) error
+	delete      func(id int) error
+	name        func(T) string
+	archived    func(T) bool
+}
+
+func heroCatalog(repo *models.HeroRepository) catalog[models.Hero] {
+	return catalog[models.Hero]{
+		getAll:      repo.GetAll,
+		getByID:     repo.GetByID,
+		create:      repo.Create,
+		rename:      repo.Rename,
+		setArchived: repo.SetArchived,
+		delete:      repo.Delete,
+		name:        func(h models.Hero) string { return h.Name },
+		archived:    func(h models.Hero) bool { return h.ArchivedAt != nil },
+	}
+}
+
+func scenarioCatalog(repo *models.ScenarioRepository) catalog[models.Scenario] {
+	return catalog[models.Scenario]{
+		getAll:      repo.GetAll,
+		getByID:     repo.GetByID,
+		create:      repo.Create,
+		rename:      repo.Rename,
+		setArchived: repo.SetArchived,
+		delete:      repo.Delete,
+		name:        func(s models.Scenario) string { return s.Name },
+		archived:    func(s models.Scenario) bool { return s.ArchivedAt != nil },
+	}
+}
+
+// catalogRequest is the body of POST and PUT on a catalog resource. On PUT
+// both fields are optional and only the ones present are changed.
+type catalogRequest struct {
+	Name     *string `json:"name"`
+	Archived *bool   `json:"archived"`
+}
+
+func catalogRoutes[T any](path, tag, schema string, cat catalog[T]) []Route {
+	singular := strings.ToLower(schema)
+	return []Route{
+		{
+			Method: http.MethodGet, Path: path, Tag: tag,
+			Summary: "List " + strings.ToLower(tag) + " by name",
+			Query: append([]Param{
+				{Name: "q", Type: "string", Description: "Only names containing this text, ignoring case."},
+				{Name: "archived", Type: "boolean", Description: "Only archived (true) or active (false) entries."},
+			}, pageParams...),
+			Response: schema, List: true,
+			Handler: listCatalog(cat),
+		},
+		{
+			Method: http.MethodGet, Path: path + "/:id", Tag: tag,
+			Summary:  "Get a " + singular,
+			Response: schema,
+			Handler:  getCatalog(cat),
+		},
+		{
+			Method: http.MethodPost, Path: path, Tag: tag,
+			Summary: "Add a custom " + singular,
+			Body:    "CatalogRequest", Response: schema, Status: http.StatusCreated,
+			Handler: createCatalog(cat, path),
+		},
+		{
+			Method: http.MethodPut, Path: path + "/:id", Tag: tag,
+			Summary: "Rename a custom " + singular + ", or archive or restore any " + singular,
+			Body:    "CatalogRequest", Response: schema,
+			Admin:   true,
+			Handler: updateCatalog(cat),
+		},
+		{
+			Method: http.MethodDelete, Path: path + "/:id", Tag: tag,
+			Summary: "Delete a custom " + singular + " that has never been played",
+			Status:  http.StatusNoContent,
+			Admin:   true,
+			Handler: deleteCatalog(cat),
+		},
+	}
+}
+
+func listCatalog[T any](cat catalog[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		archived, ok := queryBool(c, "archived")
+		if !ok {
+			return
+		}
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+
+		all, err := cat.getAll()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+		matches := make([]T, 0, len(all))
+		for _, item := range all {
+			if q != "" && !strings.Contains(strings.ToLower(cat.name(item)), q) {
+				continue
+			}
+			if archived != nil && cat.archived(item) != *archived {
+				continue
+			}
+			matches = append(matches, item)
+		}
+		respondList(c, pageOf(matches, page), page, len(matches))
+	}
+}
+
+func getCatalog[T any](cat catalog[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		item, err := cat.getByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, item)
+	}
+}
+
+func createCatalog[T any](cat catalog[T], path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req catalogRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		if req.Name == nil {
+			invalid(c, "name", "name is required")
+			return
+		}
+
+		id, err := cat.create(*req.Name)
+		if err == nil && req.Archived != nil && *req.Archived {
+			err = cat.setArchived(id, true)
+		}
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		item, err := cat.getByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Header("Location", Prefix+path+"/"+strconv.Itoa(id))
+		respondData(c, http.StatusCreated, item)
+	}
+}
+
+func updateCatalog[T any](cat catalog[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		var req catalogRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+
+		current, err := cat.getByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		// Sending back an unchanged name is not a rename, so official
+		// entries can still be archived with a full object.
+		if req.Name != nil && strings.TrimSpace(*req.Name) != cat.name(*current) {
+			if err := cat.rename(id, *req.Name); err != nil {
+				respondError(c, err)
+				return
+			}
+		}
+		if req.Archived != nil {
+			if err := cat.setArchived(id, *req.Archived); err != nil {
+				respondError(c, err)
+				return
+			}
+		}
+
+		item, err := cat.getByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, item)
+	}
+}
+
+func deleteCatalog[T any](cat catalog[T]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		if err := cat.delete(id); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// encounterSetRoutes lists the modular encounter sets, whose ids are what
+// encounter_set_ids on a new play refers to. They are read-only here; the
+// catalog migrations maintain them.
+func encounterSetRoutes(repos Repositories) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/encounter-sets", Tag: "Encounter Sets",
+			Summary:  "List modular encounter sets by release",
+			Query:    pageParams,
+			Response: "EncounterSet", List: true,
+			Handler: listEncounterSets(repos.EncounterSets),
+		},
+	}
+}
+
+func listEncounterSets(repo *models.EncounterSetRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+		sets, err := repo.GetAll()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondList(c, pageOf(sets, page), page, len(sets))
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// deckRequest is the body of POST and PUT /decks. PlayID is ignored on PUT
+// because a deck cannot move between plays.
+type deckRequest struct {
+	PlayID      int      `json:"play_id"`
+	HeroID      int      `json:"hero_id"`
+	Aspects     []string `json:"aspects"`
+	PlayerID    int      `json:"player_id"`
+	PlayerName  string   `json:"player_name"`
+	RemainingHP *int     `json:"remaining_hp"`
+}
+
+func (r deckRequest) deck() models.Deck {
+	return models.Deck{
+		PlayID:      r.PlayID,
+		HeroID:      r.HeroID,
+		Aspects:     r.Aspects,
+		PlayerID:    r.PlayerID,
+		PlayerName:  r.PlayerName,
+		RemainingHP: r.RemainingHP,
+	}
+}
+
+func deckRoutes(repos Repositories) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/decks", Tag: "Decks",
+			Summary: "List decks by play",
+			Query: append([]Param{
+				{Name: "play_id", Type: "integer", Description: "Only decks of this play."},
+				{Name: "hero_id", Type: "integer", Description: "Only decks of this hero."},
+			}, pageParams...),
+			Response: "Deck", List: true,
+			Handler: listDecks(repos.Decks),
+		},
+		{
+			Method: http.MethodGet, Path: "/decks/:id", Tag: "Decks",
+			Summary:  "Get a deck",
+			Response: "Deck",
+			Handler:  getDeck(repos.Decks),
+		},
+		{
+			Method: http.MethodPost, Path: "/decks", Tag: "Decks",
+			Summary: "Add a hero to an existing play",
+			Body:    "DeckRequest", Response: "Deck", Status: http.StatusCreated,
+			Handler: createDeck(repos.Decks),
+		},
+		{
+			Method: http.MethodPut, Path: "/decks/:id", Tag: "Decks",
+			Summary: "Replace the hero, aspects and player name of a deck",
+			Body:    "DeckRequest", Response: "Deck",
+			Handler: updateDeck(repos.Decks),
+		},
+		{
+			Method: http.MethodDelete, Path: "/decks/:id", Tag: "Decks",
+			Summary: "Remove a hero from a play",
+			Status:  http.StatusNoContent,
+			Handler: deleteDeck(repos.Decks),
+		},
+	}
+}
+
+func listDecks(repo *models.DeckRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		var f models.DeckFilter
+		var ok bool
+		if f.PlayID, ok = queryInt(c, "play_id"); !ok {
+			return
+		}
+		if f.HeroID, ok = queryInt(c, "hero_id"); !ok {
+			return
+		}
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+
+		decks, err := repo.List(f)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondList(c, pageOf(decks, page), page, len(decks))
+	}
+}
+
+func getDeck(repo *models.DeckRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		deck, err := repo.GetByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, deck)
+	}
+}
+
+func createDeck(repo *models.DeckRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		var req deckRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		deck := req.deck()
+		if err := repo.Create(&deck); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		saved, err := repo.GetByID(deck.ID)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Header("Location", Prefix+"/decks/"+strconv.Itoa(deck.ID))
+		respondData(c, http.StatusCreated, saved)
+	}
+}
+
+func updateDeck(repo *models.DeckRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		var req deckRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		deck := req.deck()
+		deck.ID = id
+		if err := repo.Update(&deck); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		saved, err := repo.GetByID(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, saved)
+	}
+}
+
+func deleteDeck(repo *models.DeckRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		if err := repo.Delete(id); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
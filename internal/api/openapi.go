@@ -0,0 +1,388 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"marvel_tracker/internal/models"
+)
+
+// object is a JSON object in the OpenAPI document.
+type object = map[string]any
+
+// OpenAPI describes routes as an OpenAPI 3.0 document.
+func OpenAPI(routes []Route) object {
+	paths := object{}
+	for _, route := range routes {
+		path, pathParams := openAPIPath(route.Path)
+		item, ok := paths[path].(object)
+		if !ok {
+			item = object{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(route, pathParams)
+	}
+
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "Marvel Champions Tracker API",
+			"version":     "1",
+			"description": "Plays, heroes, scenarios, decks and win rates. Failed requests return an Error envelope.",
+		},
+		"servers": []object{{"url": Prefix}},
+		"paths":   paths,
+		"components": object{
+			"schemas": schemas,
+		},
+	}
+}
+
+// openAPIPath converts a Gin path such as /plays/:id to /plays/{id} and
+// returns the names of its path parameters.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func operation(route Route, pathParams []string) object {
+	op := object{
+		"tags":    []string{route.Tag},
+		"summary": route.Summary,
+	}
+
+	var params []object
+	for _, name := range pathParams {
+		schema := object{"type": "integer"}
+		if name != "id" {
+			schema = object{"type": "string"}
+		}
+		params = append(params, object{"name": name, "in": "path", "required": true, "schema": schema})
+	}
+	for _, p := range route.Query {
+		params = append(params, described(object{
+			"name":   p.Name,
+			"in":     "query",
+			"schema": object{"type": p.Type},
+		}, p.Description))
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if route.Body != "" {
+		op["requestBody"] = object{
+			"required": true,
+			"content":  jsonContent(ref(route.Body)),
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := object{"description": http.StatusText(status)}
+	switch {
+	case route.Response != "" && route.List:
+		success["content"] = jsonContent(object{
+			"type":     "object",
+			"required": []string{"data", "pagination"},
+			"properties": object{
+				"data":       object{"type": "array", "items": ref(route.Response)},
+				"pagination": ref("Pagination"),
+			},
+		})
+	case route.Response != "":
+		success["content"] = jsonContent(object{
+			"type":       "object",
+			"required":   []string{"data"},
+			"properties": object{"data": ref(route.Response)},
+		})
+	}
+
+	responses := object{strconv.Itoa(status): success}
+	if route.Body != "" || len(route.Query) > 0 {
+		responses["400"] = errorResponse("Invalid request")
+	}
+	if route.Method != http.MethodGet {
+		responses["401"] = errorResponse("Not logged in")
+	}
+	if route.Admin {
+		responses["403"] = errorResponse("Not an admin")
+	}
+	if len(pathParams) > 0 {
+		responses["404"] = errorResponse("Not found")
+	}
+	responses["500"] = errorResponse("Internal error")
+	op["responses"] = responses
+	return op
+}
+
+func ref(schema string) object {
+	return object{"$ref": "#/components/schemas/" + schema}
+}
+
+func jsonContent(schema object) object {
+	return object{"application/json": object{"schema": schema}}
+}
+
+func errorResponse(description string) object {
+	return object{"description": description, "content": jsonContent(ref("Error"))}
+}
+
+func str(description string) object {
+	return described(object{"type": "string"}, description)
+}
+
+func integer(description string) object {
+	return described(object{"type": "integer"}, description)
+}
+
+func described(schema object, description string) object {
+	if description != "" {
+		schema["description"] = description
+	}
+	return schema
+}
+
+var (
+	timestamp  = object{"type": "string", "format": "date-time"}
+	releasedOn = object{"type": "string", "format": "date-time", "description": "Release date of the pack, when known."}
+	stringList = object{"type": "array", "items": object{"type": "string"}}
+	endReason  = object{"type": "string", "enum": endReasonNames(), "description": "How the play ended; must go with the outcome."}
+)
+
+func endReasonNames() []string {
+	names := make([]string, 0, len(models.EndReasons))
+	for _, r := range models.EndReasons {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func catalogSchema(kind string) object {
+	return object{
+		"type":     "object",
+		"required": []string{"id", "name", "created_at", "updated_at"},
+		"properties": object{
+			"id":          integer(""),
+			"name":        str(""),
+			"pack_id":     integer("Set for " + kind + " from the official catalog, which cannot be renamed or deleted."),
+			"pack":        str("Product the " + kind + " was released in."),
+			"wave":        integer(""),
+			"released_on": releasedOn,
+			"archived_at": timestamp,
+			"created_at":  timestamp,
+			"updated_at":  timestamp,
+		},
+	}
+}
+
+// schemas mirror the JSON encoding of the models the routes return.
+var schemas = object{
+	"PlaySummary": object{
+		"type":     "object",
+		"required": []string{"id", "date", "outcome", "difficulty", "scenario_id", "scenario", "heroes"},
+		"properties": object{
+			"id":               integer(""),
+			"date":             timestamp,
+			"outcome":          object{"type": "string", "enum": []string{"win", "loss"}},
+			"difficulty":       str(""),
+			"notes":            str(""),
+			"scenario_id":      integer(""),
+			"scenario":         str("Scenario name."),
+			"source_id":        str("Where an imported play came from, such as bgstats:<uuid> or bgg:<play id>."),
+			"end_reason":       endReason,
+			"rounds":           integer(""),
+			"villain_stage":    integer("Villain stage the play ended on."),
+			"remaining_threat": integer("Threat left on the main scheme."),
+			"owner_id":         integer("User who logged the play. Absent for plays from before there were accounts."),
+			"owner":            str("Username of the user who logged the play."),
+			"group_id":         integer("Group the play was logged into, if any."),
+			"group":            str("Name of the group the play was logged into."),
+			"heroes":           object{"type": "array", "items": ref("HeroAspect")},
+			"encounter_sets": object{
+				"type":        "array",
+				"items":       object{"type": "string"},
+				"description": "Modular sets used, by name. Absent when they were not recorded.",
+			},
+		},
+	},
+	"HeroAspect": object{
+		"type":     "object",
+		"required": []string{"hero_id", "hero", "aspects"},
+		"properties": object{
+			"hero_id":      integer(""),
+			"hero":         str("Hero name."),
+			"aspects":      stringList,
+			"player_id":    integer("Player from /players. Absent when the deck has no player."),
+			"player_name":  str(""),
+			"remaining_hp": integer("Hit points the hero ended the play with."),
+		},
+	},
+	"PlayRequest": object{
+		"type":     "object",
+		"required": []string{"date", "difficulty", "outcome"},
+		"properties": object{
+			"date":             str("YYYY-MM-DD."),
+			"scenario_id":      integer("Scenario from the catalog. Either this or scenario is required."),
+			"scenario":         str("Scenario name, added to the catalog if it is new."),
+			"difficulty":       str(""),
+			"outcome":          object{"type": "string", "enum": []string{"win", "loss"}},
+			"notes":            str(""),
+			"end_reason":       endReason,
+			"rounds":           integer("At least 1."),
+			"villain_stage":    integer("At least 1."),
+			"remaining_threat": integer("Zero or more."),
+			"encounter_set_ids": object{
+				"type":        "array",
+				"items":       object{"type": "integer"},
+				"description": "Modular sets used, from /encounter-sets. Only read when creating a play.",
+			},
+			"group_id": integer("Group to log the play into, which you must own or edit. Its members see the play. Only read when creating a play."),
+			"decks": object{
+				"type":        "array",
+				"description": "One to four heroes. Only read when creating a play.",
+				"items": object{
+					"type": "object",
+					"properties": object{
+						"hero_id":      integer("Hero from the catalog. Either this or hero is required."),
+						"hero":         str("Hero name, added to the catalog if it is new."),
+						"aspects":      stringList,
+						"player_id":    integer("Player from /players. Either this or player_name, or neither."),
+						"player_name":  str("Player name, added to the players if it is new."),
+						"remaining_hp": integer("Zero or more."),
+					},
+				},
+			},
+		},
+	},
+	"Hero":     catalogSchema("heroes"),
+	"Scenario": catalogSchema("scenarios"),
+	"EncounterSet": object{
+		"type":     "object",
+		"required": []string{"id", "name"},
+		"properties": object{
+			"id":          integer(""),
+			"name":        str(""),
+			"pack_id":     integer("Set for encounter sets from the official catalog."),
+			"pack":        str("Product the encounter set was released in."),
+			"wave":        integer(""),
+			"released_on": releasedOn,
+		},
+	},
+	"CatalogRequest": object{
+		"type": "object",
+		"properties": object{
+			"name":     str("Required when creating."),
+			"archived": object{"type": "boolean"},
+		},
+	},
+	"Deck": object{
+		"type":     "object",
+		"required": []string{"id", "play_id", "hero_id", "aspects", "created_at", "updated_at"},
+		"properties": object{
+			"id":           integer(""),
+			"play_id":      integer(""),
+			"hero_id":      integer(""),
+			"aspects":      stringList,
+			"player_id":    integer(""),
+			"player_name":  str(""),
+			"remaining_hp": integer(""),
+			"created_at":   timestamp,
+			"updated_at":   timestamp,
+		},
+	},
+	"DeckRequest": object{
+		"type":     "object",
+		"required": []string{"hero_id", "aspects"},
+		"properties": object{
+			"play_id":      integer("Required when creating; ignored when updating."),
+			"hero_id":      integer(""),
+			"aspects":      stringList,
+			"player_id":    integer("Player from /players. Either this or player_name, or neither."),
+			"player_name":  str("Player name, added to the players if it is new."),
+			"remaining_hp": integer("Zero or more."),
+		},
+	},
+	"StatsRow": object{
+		"type":     "object",
+		"required": []string{"name", "plays", "wins", "losses", "win_rate", "last_played"},
+		"properties": object{
+			"name":        str("Hero, aspect, scenario, difficulty, modular set or player."),
+			"plays":       integer(""),
+			"wins":        integer(""),
+			"losses":      integer(""),
+			"win_rate":    object{"type": "number", "description": "Between 0 and 1."},
+			"last_played": timestamp,
+		},
+	},
+	"Player": object{
+		"type":     "object",
+		"required": []string{"id", "name", "created_at", "updated_at"},
+		"properties": object{
+			"id":         integer(""),
+			"name":       str(""),
+			"created_at": timestamp,
+			"updated_at": timestamp,
+		},
+	},
+	"Streaks": object{
+		"type":     "object",
+		"required": []string{"current", "longest_win", "longest_loss"},
+		"properties": object{
+			"current":         integer("Length of the run the latest play belongs to."),
+			"current_outcome": object{"type": "string", "enum": []string{"win", "loss"}},
+			"longest_win":     integer(""),
+			"longest_loss":    integer(""),
+		},
+	},
+	"LossReasonRow": object{
+		"type":     "object",
+		"required": []string{"scenario", "losses", "reasons", "unrecorded"},
+		"properties": object{
+			"scenario": str(""),
+			"losses":   integer(""),
+			"reasons": object{
+				"type":                 "object",
+				"additionalProperties": object{"type": "integer"},
+				"description":          "Losses by end reason.",
+			},
+			"unrecorded": integer("Losses logged without an end reason."),
+		},
+	},
+	"Pagination": object{
+		"type":     "object",
+		"required": []string{"page", "per_page", "total", "total_pages"},
+		"properties": object{
+			"page":        integer(""),
+			"per_page":    integer(""),
+			"total":       integer("Items across all pages."),
+			"total_pages": integer(""),
+		},
+	},
+	"Error": object{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": object{
+			"error": object{
+				"type":     "object",
+				"required": []string{"status", "code", "message"},
+				"properties": object{
+					"status":  integer("HTTP status code."),
+					"code":    object{"type": "string", "enum": []string{"validation_error", "not_found", "internal_error"}},
+					"message": str(""),
+					"field":   str("The parameter or body field that was rejected, for validation errors."),
+				},
+			},
+		},
+	},
+}
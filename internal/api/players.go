@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+func playerRoutes(repos Repositories) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/players", Tag: "Players",
+			Summary:  "List your players by name",
+			Query:    pageParams,
+			Response: "Player", List: true,
+			Handler: listPlayers(repos.Players),
+		},
+	}
+}
+
+func listPlayers(repo *models.PlayerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+		players, err := repo.For(auth.Viewer(c)).GetAll()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondList(c, pageOf(players, page), page, len(players))
+	}
+}
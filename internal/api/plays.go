@@ -0,0 +1,263 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// playRequest is the body of POST and PUT /plays. The scenario may be given
+// by id or, for scenarios not in the catalog, by name. Decks, modular sets
+// and the group are only read when creating a play; use /decks to change
+// decks afterwards.
+type playRequest struct {
+	Date            string            `json:"date"`
+	ScenarioID      int               `json:"scenario_id"`
+	Scenario        string            `json:"scenario"`
+	Difficulty      string            `json:"difficulty"`
+	Outcome         string            `json:"outcome"`
+	Notes           string            `json:"notes"`
+	EndReason       string            `json:"end_reason"`
+	Rounds          *int              `json:"rounds"`
+	VillainStage    *int              `json:"villain_stage"`
+	RemainingThreat *int              `json:"remaining_threat"`
+	EncounterSetIDs []int             `json:"encounter_set_ids"`
+	GroupID         int               `json:"group_id"`
+	Decks           []playDeckRequest `json:"decks"`
+}
+
+// playDeckRequest is one hero of a new play, given by id or by name.
+type playDeckRequest struct {
+	HeroID      int      `json:"hero_id"`
+	Hero        string   `json:"hero"`
+	Aspects     []string `json:"aspects"`
+	PlayerID    int      `json:"player_id"`
+	PlayerName  string   `json:"player_name"`
+	RemainingHP *int     `json:"remaining_hp"`
+}
+
+func (r playRequest) play() (models.Play, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(r.Date))
+	if err != nil {
+		return models.Play{}, &models.ValidationError{Field: "date", Message: "date must be in YYYY-MM-DD format"}
+	}
+	return models.Play{
+		Date:            date,
+		Outcome:         r.Outcome,
+		Difficulty:      r.Difficulty,
+		Notes:           strings.TrimSpace(r.Notes),
+		ScenarioID:      r.ScenarioID,
+		EndReason:       r.EndReason,
+		Rounds:          r.Rounds,
+		VillainStage:    r.VillainStage,
+		RemainingThreat: r.RemainingThreat,
+		EncounterSetIDs: r.EncounterSetIDs,
+		GroupID:         r.GroupID,
+	}, nil
+}
+
+func playRoutes(repos Repositories) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/plays", Tag: "Plays",
+			Summary: "List plays, newest first",
+			Query: append([]Param{
+				{Name: "scenario_id", Type: "integer", Description: "Only plays against this scenario."},
+				{Name: "hero_id", Type: "integer", Description: "Only plays that include this hero."},
+				{Name: "aspect", Type: "string", Description: "Only plays with a deck of this aspect; with hero_id, the hero's deck."},
+				{Name: "players", Type: "integer", Description: "Only plays with this many heroes."},
+				{Name: "outcome", Type: "string", Description: "win or loss."},
+				{Name: "difficulty", Type: "string", Description: "Only plays at this difficulty."},
+				{Name: "from", Type: "string", Description: "Earliest play date, YYYY-MM-DD."},
+				{Name: "to", Type: "string", Description: "Latest play date, YYYY-MM-DD."},
+			}, pageParams...),
+			Response: "PlaySummary", List: true,
+			Handler: listPlays(repos.Plays),
+		},
+		{
+			Method: http.MethodGet, Path: "/plays/:id", Tag: "Plays",
+			Summary:  "Get a play with its scenario and heroes",
+			Response: "PlaySummary",
+			Handler:  getPlay(repos.Plays),
+		},
+		{
+			Method: http.MethodPost, Path: "/plays", Tag: "Plays",
+			Summary: "Log a play together with its decks",
+			Body:    "PlayRequest", Response: "PlaySummary", Status: http.StatusCreated,
+			Handler: createPlay(repos.Plays),
+		},
+		{
+			Method: http.MethodPut, Path: "/plays/:id", Tag: "Plays",
+			Summary: "Replace the date, scenario, difficulty, outcome and notes of a play",
+			Body:    "PlayRequest", Response: "PlaySummary",
+			Handler: updatePlay(repos.Plays),
+		},
+		{
+			Method: http.MethodDelete, Path: "/plays/:id", Tag: "Plays",
+			Summary: "Delete a play and its decks",
+			Status:  http.StatusNoContent,
+			Handler: deletePlay(repos.Plays),
+		},
+	}
+}
+
+func listPlays(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		var f models.PlayFilter
+		var ok bool
+		if f.ScenarioID, ok = queryInt(c, "scenario_id"); !ok {
+			return
+		}
+		if f.HeroID, ok = queryInt(c, "hero_id"); !ok {
+			return
+		}
+		if f.Players, ok = queryInt(c, "players"); !ok {
+			return
+		}
+		f.Aspect = strings.TrimSpace(c.Query("aspect"))
+		f.Outcome = c.Query("outcome")
+		if f.Outcome != "" && f.Outcome != "win" && f.Outcome != "loss" {
+			invalid(c, "outcome", "outcome must be win or loss")
+			return
+		}
+		f.Difficulty = c.Query("difficulty")
+		for _, bound := range []struct {
+			name string
+			dest *time.Time
+		}{{"from", &f.From}, {"to", &f.To}} {
+			raw := strings.TrimSpace(c.Query(bound.name))
+			if raw == "" {
+				continue
+			}
+			date, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				invalid(c, bound.name, bound.name+" must be in YYYY-MM-DD format")
+				return
+			}
+			*bound.dest = date
+		}
+
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+
+		plays, total, err := repo.ListSummaries(f, page.PerPage, page.offset())
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		if plays == nil {
+			plays = []models.PlaySummary{}
+		}
+		respondList(c, plays, page, total)
+	}
+}
+
+func getPlay(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		summary, err := repo.GetSummary(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, summary)
+	}
+}
+
+func createPlay(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		var req playRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		play, err := req.play()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		entries := make([]models.DeckEntry, 0, len(req.Decks))
+		for _, d := range req.Decks {
+			entries = append(entries, models.DeckEntry{
+				HeroID:      d.HeroID,
+				HeroName:    d.Hero,
+				Aspects:     d.Aspects,
+				PlayerID:    d.PlayerID,
+				PlayerName:  d.PlayerName,
+				RemainingHP: d.RemainingHP,
+			})
+		}
+		if err := repo.CreateWithDecks(&play, req.Scenario, entries); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		summary, err := repo.GetSummary(play.ID)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Header("Location", Prefix+"/plays/"+strconv.Itoa(play.ID))
+		respondData(c, http.StatusCreated, summary)
+	}
+}
+
+func updatePlay(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		var req playRequest
+		if !bindJSON(c, &req) {
+			return
+		}
+		play, err := req.play()
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+
+		play.ID = id
+		if err := repo.Update(&play, req.Scenario); err != nil {
+			respondError(c, err)
+			return
+		}
+
+		summary, err := repo.GetSummary(id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, summary)
+	}
+}
+
+func deletePlay(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		id, ok := pathID(c)
+		if !ok {
+			return
+		}
+		if err := repo.Delete(id); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
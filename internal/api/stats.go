@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/stats"
+)
+
+// statsFilterParams are the query parameters read by readStatsFilter.
+var statsFilterParams = []Param{
+	{Name: "from", Type: "string", Description: "Earliest play date, YYYY-MM-DD."},
+	{Name: "to", Type: "string", Description: "Latest play date, YYYY-MM-DD."},
+	{Name: "players", Type: "integer", Description: "Only plays with this many heroes."},
+	{Name: "player_id", Type: "integer", Description: "Only plays this player took part in, counting only their decks."},
+	{Name: "scope", Type: "string", Description: "all (the default) for every play you can see, me for the plays you logged, or group:ID for a group's plays."},
+}
+
+func statsRoutes(repos Repositories) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/stats/:dimension", Tag: "Stats",
+			Summary:  "Win rates grouped by hero, aspect, hero_aspect, scenario, difficulty, encounter_set or player",
+			Query:    statsFilterParams,
+			Response: "StatsRow", List: true,
+			Handler: getStats(repos.Stats),
+		},
+		{
+			Method: http.MethodGet, Path: "/stats/loss-reasons", Tag: "Stats",
+			Summary:  "How the losses on each scenario ended",
+			Query:    statsFilterParams,
+			Response: "LossReasonRow", List: true,
+			Handler: getLossReasons(repos.Stats),
+		},
+		{
+			Method: http.MethodGet, Path: "/stats/streaks", Tag: "Stats",
+			Summary:  "Current and longest win and loss streaks",
+			Query:    statsFilterParams,
+			Response: "Streaks",
+			Handler:  getStreaks(repos.Stats),
+		},
+	}
+}
+
+// getStats returns every group of one dimension; the groups are few enough
+// that the page parameters are accepted but rarely needed.
+func getStats(repo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		dim := stats.Dimension(c.Param("dimension"))
+		known := false
+		for _, d := range stats.Dimensions {
+			known = known || d == dim
+		}
+		if !known {
+			invalid(c, "dimension", "dimension must be one of hero, aspect, hero_aspect, scenario, difficulty, encounter_set or player")
+			return
+		}
+
+		filter, ok := readStatsFilter(c)
+		if !ok {
+			return
+		}
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+
+		rows, err := repo.By(dim, filter)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondList(c, pageOf(rows, page), page, len(rows))
+	}
+}
+
+// getLossReasons returns the end reasons of the losses on each scenario,
+// scenarios with the most losses first.
+func getLossReasons(repo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		filter, ok := readStatsFilter(c)
+		if !ok {
+			return
+		}
+		page, ok := readPage(c)
+		if !ok {
+			return
+		}
+
+		rows, err := repo.LossReasons(filter)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondList(c, pageOf(rows, page), page, len(rows))
+	}
+}
+
+// getStreaks returns the streaks of the plays matching the filter, usually
+// one player's.
+func getStreaks(repo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		filter, ok := readStatsFilter(c)
+		if !ok {
+			return
+		}
+		streaks, err := repo.Streaks(filter)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		respondData(c, http.StatusOK, streaks)
+	}
+}
+
+// readStatsFilter reads statsFilterParams. If they are invalid an error
+// response has been written and ok is false.
+func readStatsFilter(c *gin.Context) (stats.Filter, bool) {
+	filter, err := stats.ParseFilter(c.Query("from"), c.Query("to"), c.Query("players"))
+	if err != nil {
+		respondError(c, err)
+		return stats.Filter{}, false
+	}
+	var ok bool
+	if filter.PlayerID, ok = queryInt(c, "player_id"); !ok {
+		return stats.Filter{}, false
+	}
+	if err := filter.SetScope(c.Query("scope"), auth.Viewer(c).UserID); err != nil {
+		respondError(c, err)
+		return stats.Filter{}, false
+	}
+	return filter, true
+}
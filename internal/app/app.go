@@ -0,0 +1,202 @@
+// Package app assembles the tracker: it owns the database, the
+// repositories built on it, the templates and the configuration, and wires
+// them into the routing table. main serves that table and the tests
+// exercise it, and both build the App the same way: open the database with
+// config.InitDB, migrate it from Migrations and pass it to New.
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/api"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/handlers"
+	"marvel_tracker/internal/middleware"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/stats"
+	"marvel_tracker/migrations"
+	"marvel_tracker/static"
+	"marvel_tracker/templates"
+)
+
+// App is the tracker's web server and what it is built from.
+type App struct {
+	Config *config.Config
+	DB     *sql.DB
+
+	Plays         *models.PlayRepository
+	Heroes        *models.HeroRepository
+	Scenarios     *models.ScenarioRepository
+	Aspects       *models.AspectRepository
+	EncounterSets *models.EncounterSetRepository
+	Decks         *models.DeckRepository
+	Campaigns     *models.CampaignRepository
+	Players       *models.PlayerRepository
+	Groups        *models.GroupRepository
+	Stats         *stats.Repository
+	Auth          *auth.Store
+
+	// templates are the templates built into the binary, or nil when they
+	// are read from Config.TemplateDir.
+	templates *template.Template
+}
+
+// New builds the App for cfg on db, which must be migrated already. It
+// sets Gin's mode, which is global, to cfg.GinMode.
+func New(cfg *config.Config, db *sql.DB) (*App, error) {
+	a := &App{
+		Config:        cfg,
+		DB:            db,
+		Plays:         models.NewPlayRepository(db),
+		Heroes:        models.NewHeroRepository(db),
+		Scenarios:     models.NewScenarioRepository(db),
+		Aspects:       models.NewAspectRepository(db),
+		EncounterSets: models.NewEncounterSetRepository(db),
+		Decks:         models.NewDeckRepository(db),
+		Campaigns:     models.NewCampaignRepository(db),
+		Players:       models.NewPlayerRepository(db),
+		Groups:        models.NewGroupRepository(db),
+		Stats:         stats.NewRepository(db),
+		Auth:          auth.NewStore(db),
+	}
+
+	// Templates on disk are parsed again for every request, but parse them
+	// once here too so that a broken one stops the server from starting.
+	var err error
+	if cfg.TemplateDir != "" {
+		_, err = template.ParseGlob(filepath.Join(cfg.TemplateDir, "*.html"))
+	} else {
+		a.templates, err = template.ParseFS(templates.FS, "*.html")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing templates: %w", err)
+	}
+
+	gin.SetMode(cfg.GinMode)
+	return a, nil
+}
+
+// Migrations returns the migrations to apply for cfg: the ones built into
+// the binary or, with Dev, the ones in the working directory.
+func Migrations(cfg *config.Config) fs.FS {
+	if cfg.Dev {
+		return os.DirFS("migrations")
+	}
+	return migrations.FS
+}
+
+// Routes returns the tracker's routing table: the pages, the static files
+// and the JSON API.
+func (a *App) Routes() http.Handler {
+	r := gin.New()
+	if a.Config.Level() <= slog.LevelInfo {
+		r.Use(gin.Logger())
+	}
+	r.Use(gin.Recovery())
+	// Config.Validate has checked the proxies already.
+	_ = r.SetTrustedProxies(a.Config.TrustedProxies)
+	r.Use(middleware.ErrorHandler())
+	r.Use(auth.Sessions(a.Auth))
+	r.Use(auth.RequireLoginToChange("/login", "/signup"))
+
+	if a.templates != nil {
+		r.SetHTMLTemplate(a.templates)
+	} else {
+		// In debug mode gin parses the templates again for every request,
+		// so edits show up without restarting the server.
+		r.LoadHTMLGlob(filepath.Join(a.Config.TemplateDir, "*.html"))
+	}
+	if a.Config.Dev {
+		r.Static("/static", "./static")
+	} else {
+		r.StaticFS("/static", http.FS(static.FS))
+	}
+
+	r.GET("/", handlers.Home)
+	r.GET("/login", handlers.LoginPage)
+	r.POST("/login", handlers.Login(a.Auth))
+	r.GET("/signup", handlers.SignupPage)
+	r.POST("/signup", handlers.Signup(a.Auth))
+	r.POST("/logout", handlers.Logout(a.Auth))
+	r.GET("/account", auth.RequireLogin(), handlers.Account)
+	r.POST("/account", handlers.UpdateAccount(a.Auth))
+
+	r.GET("/plays", handlers.Plays(a.Plays, a.Heroes, a.Scenarios, a.Aspects))
+	r.GET("/plays/new", auth.RequireLogin(), handlers.NewPlay(a.Heroes, a.Scenarios, a.Aspects, a.EncounterSets, a.Players, a.Groups))
+	r.GET("/plays/new/hero-row", handlers.HeroRow(a.Heroes, a.Aspects))
+	r.GET("/plays/search", handlers.SearchPlays(a.Plays))
+	r.GET("/plays/new/encounter-sets", handlers.EncounterSetPicker(a.EncounterSets))
+	r.GET("/plays/export.csv", handlers.ExportPlaysCSV(a.Plays))
+	r.GET("/plays/export.xml", handlers.ExportPlaysBGG(a.Plays))
+	r.GET("/plays/import", auth.RequireLogin(), handlers.ImportPage(a.Aspects))
+	r.POST("/plays/import", handlers.ImportPlays(a.Plays, a.Aspects))
+	r.POST("/plays", handlers.CreatePlay(a.Plays, a.Heroes, a.Scenarios, a.Aspects, a.EncounterSets, a.Players, a.Groups))
+	r.GET("/plays/:id", handlers.PlayRow(a.Plays))
+	playEditor := middleware.RequirePlayEditor(a.Plays)
+	r.GET("/plays/:id/edit", playEditor, handlers.EditPlay(a.Plays, a.Scenarios))
+	r.PUT("/plays/:id", playEditor, handlers.UpdatePlay(a.Plays, a.Scenarios))
+	r.DELETE("/plays/:id", playEditor, handlers.DeletePlay(a.Plays))
+
+	r.GET("/campaigns", handlers.Campaigns(a.Campaigns))
+	r.POST("/campaigns", handlers.CreateCampaign(a.Campaigns))
+	r.GET("/campaigns/:id", handlers.Campaign(a.Campaigns))
+	r.POST("/campaigns/:id/entries", handlers.LogCampaignStep(a.Campaigns))
+
+	r.GET("/players", handlers.Players(a.Players, a.Stats))
+	r.POST("/players", handlers.CreatePlayer(a.Players, a.Stats))
+	r.GET("/players/:id", handlers.Player(a.Players, a.Stats))
+	r.POST("/players/:id/rename", handlers.RenamePlayer(a.Players, a.Stats))
+	r.POST("/players/:id/delete", handlers.DeletePlayer(a.Players, a.Stats))
+
+	groupMember := middleware.RequireGroupRole(a.Groups, models.GroupViewer)
+	groupOwner := middleware.RequireGroupRole(a.Groups, models.GroupOwner)
+	r.GET("/groups", auth.RequireLogin(), handlers.Groups(a.Groups))
+	r.POST("/groups", handlers.CreateGroup(a.Groups))
+	r.GET("/groups/:id", groupMember, handlers.Group(a.Groups))
+	r.POST("/groups/:id/rename", groupOwner, handlers.RenameGroup(a.Groups))
+	r.POST("/groups/:id/delete", groupOwner, handlers.DeleteGroup(a.Groups))
+	r.POST("/groups/:id/leave", groupMember, handlers.LeaveGroup(a.Groups))
+	r.POST("/groups/:id/invites", groupOwner, handlers.CreateGroupInvite(a.Groups))
+	r.POST("/groups/:id/invites/:token/revoke", groupOwner, handlers.RevokeGroupInvite(a.Groups))
+	r.POST("/groups/:id/members/:user/role", groupOwner, handlers.SetGroupRole(a.Groups))
+	r.POST("/groups/:id/members/:user/remove", groupOwner, handlers.RemoveGroupMember(a.Groups))
+	r.GET("/invite/:token", auth.RequireLogin(), handlers.Invite(a.Groups))
+	r.POST("/invite/:token", handlers.JoinGroup(a.Groups))
+
+	r.GET("/stats", handlers.Stats(a.Stats, a.Groups))
+
+	// Any user may add custom entries to the catalog, which everyone
+	// shares, but only admins may change the ones there.
+	admin := auth.RequireAdmin()
+	for _, page := range []handlers.CatalogPage{
+		handlers.HeroesPage(a.Heroes),
+		handlers.ScenariosPage(a.Scenarios),
+	} {
+		r.GET(page.Path, handlers.ListCatalog(page))
+		r.POST(page.Path, handlers.CreateCatalogEntry(page))
+		r.POST(page.Path+"/merge", admin, handlers.MergeCatalogEntries(page))
+		r.POST(page.Path+"/:id/rename", admin, handlers.RenameCatalogEntry(page))
+		r.POST(page.Path+"/:id/archive", admin, handlers.ArchiveCatalogEntry(page))
+	}
+
+	api.Register(r, api.Repositories{
+		Plays:         a.Plays,
+		Heroes:        a.Heroes,
+		Scenarios:     a.Scenarios,
+		EncounterSets: a.EncounterSets,
+		Decks:         a.Decks,
+		Players:       a.Players,
+		Stats:         a.Stats,
+	})
+
+	return r
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+)
+
+// newTestApp builds an App the way main does, on a fresh database.
+func newTestApp(t *testing.T, cfg config.Config) *App {
+	cfg.DB.Path = filepath.Join(t.TempDir(), "test.db")
+	cfg.GinMode = "test"
+	cfg.LogLevel = "warn"
+	db, err := config.InitDB(cfg.DB)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, config.RunMigrations(db, Migrations(&cfg)))
+
+	a, err := New(&cfg, db)
+	require.NoError(t, err)
+	return a
+}
+
+// serve makes a request to h with the session cookie, if any.
+func serve(h http.Handler, method, target, body string, session *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	switch {
+	case strings.HasPrefix(target, "/api/"):
+		req.Header.Set("Content-Type", "application/json")
+	case body != "":
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if session != nil {
+		req.AddCookie(session)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestRoutes(t *testing.T) {
+	a := newTestApp(t, config.Default())
+	routes := a.Routes()
+
+	t.Run("Visitors", func(t *testing.T) {
+		w := serve(routes, http.MethodGet, "/", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Welcome to Marvel Champions Play Tracker")
+
+		w = serve(routes, http.MethodGet, "/plays/new", "", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login?next=%2Fplays%2Fnew", w.Header().Get("Location"))
+
+		w = serve(routes, http.MethodPost, "/api/v1/plays", "{}", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = serve(routes, http.MethodGet, "/nonexistent", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Signed Up User", func(t *testing.T) {
+		w := serve(routes, http.MethodPost, "/signup", url.Values{"username": {"alice"}, "password": {"correct horse"}}.Encode(), nil)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		var session *http.Cookie
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == auth.CookieName {
+				session = cookie
+			}
+		}
+		require.NotNil(t, session)
+
+		w = serve(routes, http.MethodGet, "/plays/new", "", session)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = serve(routes, http.MethodPost, "/api/v1/plays", `{
+			"date": "2024-03-01", "scenario": "Rhino", "difficulty": "Standard I", "outcome": "win",
+			"decks": [{"hero": "Spider-Man", "aspects": ["justice"]}]
+		}`, session)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		// The play the API saved shows up on the pages, through the same
+		// repositories.
+		w = serve(routes, http.MethodGet, "/plays", "", session)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Rhino")
+		var plays int
+		require.NoError(t, a.DB.QueryRow("SELECT COUNT(*) FROM plays").Scan(&plays))
+		assert.Equal(t, 1, plays)
+	})
+
+	t.Run("Static Files", func(t *testing.T) {
+		w := serve(routes, http.MethodGet, "/static/favicon.svg", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "image/svg+xml")
+	})
+}
+
+func TestTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.TemplateDir = dir
+
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte(content), 0644))
+	}
+	write(`<h1>{{.title}}</h1>`)
+	a := newTestApp(t, cfg)
+	routes := a.Routes()
+
+	w := serve(routes, http.MethodGet, "/", "", nil)
+	assert.Equal(t, "<h1>Marvel Champions Play Tracker</h1>", w.Body.String())
+
+	t.Run("Broken Template", func(t *testing.T) {
+		write(`{{if}}`)
+		_, err := New(a.Config, a.DB)
+		assert.ErrorContains(t, err, "parsing templates")
+	})
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/models"
+)
+
+// CookieName is the name of the session cookie.
+const CookieName = "session"
+
+// userKey is where Sessions stores the logged-in user on the Gin context.
+const userKey = "auth.user"
+
+// Sessions loads the user logged in with the request's session cookie, for
+// CurrentUser and Viewer. A cookie for a session that has expired or been
+// logged out is cleared. It must run before RequireLogin and
+// RequireLoginToChange.
+func Sessions(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := c.Cookie(CookieName)
+		if err == nil && token != "" {
+			user, err := store.SessionUser(token)
+			switch {
+			case err == nil:
+				c.Set(userKey, user)
+			case errors.Is(err, models.ErrNotFound):
+				setCookie(c, "", -1)
+			default:
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the logged-in user, or nil for a visitor.
+func CurrentUser(c *gin.Context) *User {
+	if v, ok := c.Get(userKey); ok {
+		return v.(*User)
+	}
+	return nil
+}
+
+// Viewer returns who the request reads and changes plays for: the
+// logged-in user, or a visitor who only sees shared plays.
+func Viewer(c *gin.Context) models.Viewer {
+	if user := CurrentUser(c); user != nil {
+		return models.Viewer{UserID: user.ID}
+	}
+	return models.Viewer{}
+}
+
+// LogIn starts a session for user and sets the session cookie.
+func LogIn(c *gin.Context, store *Store, user *User) error {
+	token, err := store.CreateSession(user.ID)
+	if err != nil {
+		return err
+	}
+	setCookie(c, token, int(SessionTTL.Seconds()))
+	c.Set(userKey, user)
+	return nil
+}
+
+// LogOut ends the request's session, if any, and clears the cookie.
+func LogOut(c *gin.Context, store *Store) error {
+	if token, err := c.Cookie(CookieName); err == nil && token != "" {
+		if err := store.DeleteSession(token); err != nil {
+			return err
+		}
+	}
+	setCookie(c, "", -1)
+	return nil
+}
+
+// setCookie writes the session cookie. It is kept from scripts, and
+// SameSite=Lax keeps other sites from making changes with it, since every
+// change is a POST, PUT or DELETE. The cookie is marked Secure whenever the
+// request came in over HTTPS, directly or through a proxy.
+func setCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// RequireLogin turns away visitors who have not logged in. Pages send them
+// to the login page and come back afterwards; HTMX requests get a 401 that
+// redirects the browser there, and API requests a 401 JSON error.
+func RequireLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if CurrentUser(c) == nil {
+			turnAway(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLoginToChange applies RequireLogin to every request that can
+// change something, which is anything but GET, HEAD and OPTIONS. Requests
+// to the paths in except, such as the login form, are let through.
+func RequireLoginToChange(except ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if CurrentUser(c) == nil && !slices.Contains(except, c.Request.URL.Path) {
+			turnAway(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin turns away visitors as RequireLogin does, and answers users
+// who are not admins with a 403: a JSON error for API requests, and the
+// error page otherwise.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		switch {
+		case user == nil:
+			turnAway(c)
+		case !user.Admin:
+			c.Error(fmt.Errorf("user %d is not an admin", user.ID))
+			if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": gin.H{
+					"status":  http.StatusForbidden,
+					"code":    "forbidden",
+					"message": "only admins can do that",
+				}})
+				return
+			}
+			c.AbortWithStatus(http.StatusForbidden)
+		default:
+			c.Next()
+		}
+	}
+}
+
+func turnAway(c *gin.Context) {
+	login := "/login"
+	if c.Request.Method == http.MethodGet {
+		login += "?next=" + url.QueryEscape(c.Request.URL.RequestURI())
+	}
+
+	switch {
+	case strings.HasPrefix(c.Request.URL.Path, "/api/"):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": gin.H{
+			"status":  http.StatusUnauthorized,
+			"code":    "unauthorized",
+			"message": "log in first",
+		}})
+	case c.GetHeader("HX-Request") == "true":
+		c.Header("HX-Redirect", login)
+		c.AbortWithStatus(http.StatusUnauthorized)
+	default:
+		c.Redirect(http.StatusSeeOther, login)
+		c.Abort()
+	}
+}
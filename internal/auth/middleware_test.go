@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, _ := setupTestStore(t)
+	alice, err := store.Signup("alice", "password1")
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(Sessions(store), RequireLoginToChange("/login"))
+	r.POST("/login", func(c *gin.Context) {
+		require.NoError(t, LogIn(c, store, alice))
+		c.Status(http.StatusNoContent)
+	})
+	r.POST("/logout", func(c *gin.Context) {
+		require.NoError(t, LogOut(c, store))
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, "%d", Viewer(c).UserID)
+	})
+	r.GET("/private", RequireLogin(), func(c *gin.Context) {
+		c.String(http.StatusOK, CurrentUser(c).Username)
+	})
+	r.POST("/plays", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.POST("/api/v1/plays", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.POST("/heroes/merge", RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusSeeOther) })
+	r.PUT("/api/v1/heroes/1", RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(method, path string, cookie *http.Cookie, headers ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == CookieName {
+				return c
+			}
+		}
+		return nil
+	}
+
+	t.Run("Visitors", func(t *testing.T) {
+		w := serve(http.MethodGet, "/whoami", nil)
+		assert.Equal(t, "0", w.Body.String())
+
+		w = serve(http.MethodGet, "/private?x=1", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login?next=%2Fprivate%3Fx%3D1", w.Header().Get("Location"))
+
+		w = serve(http.MethodPost, "/plays", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login", w.Header().Get("Location"))
+
+		w = serve(http.MethodPost, "/plays", nil, "HX-Request", "true")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "/login", w.Header().Get("HX-Redirect"))
+
+		w = serve(http.MethodPost, "/api/v1/plays", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error": {"status": 401, "code": "unauthorized", "message": "log in first"}}`, w.Body.String())
+	})
+
+	t.Run("Log In And Out", func(t *testing.T) {
+		w := serve(http.MethodPost, "/login", nil, "X-Forwarded-Proto", "https")
+		cookie := sessionCookie(w)
+		require.NotNil(t, cookie)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(t, "/", cookie.Path)
+
+		assert.Equal(t, "alice", serve(http.MethodGet, "/private", cookie).Body.String())
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/plays", cookie).Code)
+		assert.Equal(t, http.StatusSeeOther, serve(http.MethodPost, "/heroes/merge", cookie).Code, "alice signed up first, so is the admin")
+
+		w = serve(http.MethodPost, "/logout", cookie)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		cleared := sessionCookie(w)
+		require.NotNil(t, cleared)
+		assert.Empty(t, cleared.Value)
+
+		w = serve(http.MethodGet, "/whoami", cookie)
+		assert.Equal(t, "0", w.Body.String(), "the old cookie no longer logs in")
+		cleared = sessionCookie(w)
+		require.NotNil(t, cleared, "and is cleared")
+		assert.Equal(t, -1, cleared.MaxAge)
+	})
+
+	t.Run("Admins", func(t *testing.T) {
+		bob, err := store.Signup("bob", "password2")
+		require.NoError(t, err)
+		token, err := store.CreateSession(bob.ID)
+		require.NoError(t, err)
+		cookie := &http.Cookie{Name: CookieName, Value: token}
+
+		w := serve(http.MethodPost, "/heroes/merge", cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = serve(http.MethodPut, "/api/v1/heroes/1", cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.JSONEq(t, `{"error": {"status": 403, "code": "forbidden", "message": "only admins can do that"}}`, w.Body.String())
+
+		w = serve(http.MethodPost, "/heroes/merge", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/login", w.Header().Get("Location"), "visitors are sent to log in")
+
+		require.NoError(t, store.SetAdmin(bob.ID, true))
+		assert.Equal(t, http.StatusOK, serve(http.MethodPut, "/api/v1/heroes/1", cookie).Code)
+	})
+}
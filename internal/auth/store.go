@@ -0,0 +1,261 @@
+// Package auth provides local user accounts with bcrypt-hashed passwords
+// and login sessions stored in SQLite, along with the Gin middleware that
+// reads the session cookie and turns away visitors who have not logged in.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"marvel_tracker/internal/models"
+)
+
+// User is an account that can log in. Plays logged while logged in belong
+// to the user.
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	// SharesPlays lets everyone on the site see the user's plays and
+	// campaigns.
+	SharesPlays bool `json:"shares_plays"`
+	// Admin lets the user rename, merge, archive and delete entries of the
+	// hero and scenario catalog.
+	Admin     bool      `json:"admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErrInvalidLogin is returned by Authenticate for an unknown username or a
+// wrong password, without saying which.
+var ErrInvalidLogin = errors.New("incorrect username or password")
+
+const (
+	// MinPasswordLength is the shortest password Signup accepts. bcrypt
+	// only uses the first 72 bytes, so that is the longest.
+	MinPasswordLength = 8
+	maxPasswordLength = 72
+
+	// SessionTTL is how long a login lasts.
+	SessionTTL = 30 * 24 * time.Hour
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,32}$`)
+
+type Store struct {
+	db   *sql.DB
+	cost int
+	now  func() time.Time
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, cost: bcrypt.DefaultCost, now: time.Now}
+}
+
+// Signup creates an account. Usernames are unique regardless of case. The
+// first account to be created is an admin, and takes over the plays,
+// campaigns and players added before there were accounts.
+func (s *Store) Signup(username, password string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return nil, &models.ValidationError{Field: "username", Message: "username must be 3 to 32 letters, digits, dots, dashes or underscores"}
+	}
+	if len(password) < MinPasswordLength {
+		return nil, &models.ValidationError{Field: "password", Message: "password must be at least 8 characters"}
+	}
+	if len(password) > maxPasswordLength {
+		return nil, &models.ValidationError{Field: "password", Message: "password must be at most 72 bytes"}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", username).Scan(&taken); err != nil {
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, &models.ValidationError{Field: "username", Message: "that username is taken"}
+	}
+	result, err := tx.Exec("INSERT INTO users (username, password_hash) VALUES (?, ?)", username, string(hash))
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	var users int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users").Scan(&users); err != nil {
+		return nil, err
+	}
+	if users == 1 {
+		if _, err := tx.Exec("UPDATE users SET is_admin = 1 WHERE id = ?", id); err != nil {
+			return nil, err
+		}
+		for _, table := range []string{"plays", "campaigns", "players"} {
+			if _, err := tx.Exec("UPDATE "+table+" SET owner_id = ? WHERE owner_id IS NULL", id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetByID(int(id))
+}
+
+// Authenticate returns the user with the given username and password, or
+// ErrInvalidLogin.
+func (s *Store) Authenticate(username, password string) (*User, error) {
+	var id int
+	var hash string
+	err := s.db.QueryRow("SELECT id, password_hash FROM users WHERE username = ?", strings.TrimSpace(username)).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		// Hash the password anyway, so that an unknown username takes as
+		// long to turn down as a wrong password.
+		bcrypt.CompareHashAndPassword(s.dummy(), []byte(password))
+		return nil, ErrInvalidLogin
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return nil, ErrInvalidLogin
+	}
+	return s.GetByID(id)
+}
+
+func (s *Store) dummy() []byte {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), s.cost)
+	})
+	return s.dummyHash
+}
+
+const userSelect = "SELECT id, username, shares_plays, is_admin, created_at FROM users"
+
+// GetByID returns the user with the given id, or models.ErrNotFound.
+func (s *Store) GetByID(id int) (*User, error) {
+	return scanUser(s.db.QueryRow(userSelect+" WHERE id = ?", id))
+}
+
+// GetByUsername returns the user with the given username, matched
+// regardless of case, or models.ErrNotFound.
+func (s *Store) GetByUsername(username string) (*User, error) {
+	return scanUser(s.db.QueryRow(userSelect+" WHERE username = ?", strings.TrimSpace(username)))
+}
+
+// Count returns the number of accounts.
+func (s *Store) Count() (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+	return n, err
+}
+
+// SetSharesPlays turns sharing of a user's plays on or off.
+func (s *Store) SetSharesPlays(id int, share bool) error {
+	result, err := s.db.Exec("UPDATE users SET shares_plays = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", share, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// SetAdmin makes a user an admin, or no longer one.
+func (s *Store) SetAdmin(id int, admin bool) error {
+	result, err := s.db.Exec("UPDATE users SET is_admin = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", admin, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+func scanUser(row *sql.Row) (*User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Username, &u.SharesPlays, &u.Admin, &u.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateSession logs a user in and returns the token for their session
+// cookie. Only a hash of the token is stored. Expired sessions are
+// cleared out at the same time.
+func (s *Store) CreateSession(userID int) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := s.now().UTC()
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now); err != nil {
+		return "", err
+	}
+	_, err := s.db.Exec(
+		"INSERT INTO sessions (id, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashToken(token), userID, now, now.Add(SessionTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SessionUser returns the user logged in with token, or models.ErrNotFound
+// if the session does not exist or has expired.
+func (s *Store) SessionUser(token string) (*User, error) {
+	return scanUser(s.db.QueryRow(`
+		SELECT u.id, u.username, u.shares_plays, u.is_admin, u.created_at
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.id = ? AND s.expires_at > ?`, hashToken(token), s.now().UTC()))
+}
+
+// DeleteSession logs a session out. Deleting a session that does not exist
+// is not an error.
+func (s *Store) DeleteSession(token string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE id = ?", hashToken(token))
+	return err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/migrations"
+)
+
+// setupTestStore returns a store over an in-memory database migrated with
+// the shipped migrations. Passwords are hashed at the lowest cost to keep
+// the tests fast.
+func setupTestStore(t *testing.T) (*Store, *sql.DB) {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	store := NewStore(db)
+	store.cost = bcrypt.MinCost
+	return store, db
+}
+
+func TestStore_Signup(t *testing.T) {
+	store, db := setupTestStore(t)
+
+	_, err := db.Exec(`
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 1);
+		INSERT INTO campaigns (id, name, pack_id, started_on) VALUES (1, 'Run', 1, '2024-01-01');
+	`)
+	require.NoError(t, err)
+
+	t.Run("Validation", func(t *testing.T) {
+		for _, tc := range []struct {
+			username, password, field string
+		}{
+			{"al", "password1", "username"},
+			{"al ice", "password1", "username"},
+			{"alice", "short", "password"},
+			{"alice", string(make([]byte, 73)), "password"},
+		} {
+			_, err := store.Signup(tc.username, tc.password)
+			var validationErr *models.ValidationError
+			require.ErrorAs(t, err, &validationErr, tc)
+			assert.Equal(t, tc.field, validationErr.Field)
+		}
+	})
+
+	t.Run("First User Takes Over Existing Plays", func(t *testing.T) {
+		alice, err := store.Signup(" alice ", "password1")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", alice.Username)
+		assert.False(t, alice.SharesPlays)
+		assert.True(t, alice.Admin, "the first user is the admin")
+
+		for _, table := range []string{"plays", "campaigns"} {
+			var owner int
+			require.NoError(t, db.QueryRow("SELECT owner_id FROM "+table+" WHERE id = 1").Scan(&owner))
+			assert.Equal(t, alice.ID, owner, table)
+		}
+	})
+
+	t.Run("Later Users Start Empty", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (2, '2024-01-02', 'win', 'Standard I', 1)")
+		require.NoError(t, err)
+		bob, err := store.Signup("bob", "password2")
+		require.NoError(t, err)
+		assert.False(t, bob.Admin)
+
+		var owner sql.NullInt64
+		require.NoError(t, db.QueryRow("SELECT owner_id FROM plays WHERE id = 2").Scan(&owner))
+		assert.False(t, owner.Valid)
+
+		require.NoError(t, store.SetAdmin(bob.ID, true))
+		bob, err = store.GetByID(bob.ID)
+		require.NoError(t, err)
+		assert.True(t, bob.Admin)
+		require.NoError(t, store.SetAdmin(bob.ID, false))
+		assert.ErrorIs(t, store.SetAdmin(999, true), models.ErrNotFound)
+	})
+
+	t.Run("Username Taken", func(t *testing.T) {
+		_, err := store.Signup("ALICE", "password3")
+		var validationErr *models.ValidationError
+		require.ErrorAs(t, err, &validationErr, "usernames are unique regardless of case")
+		assert.Equal(t, "username", validationErr.Field)
+
+		n, err := store.Count()
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestStore_Authenticate(t *testing.T) {
+	store, _ := setupTestStore(t)
+	alice, err := store.Signup("alice", "password1")
+	require.NoError(t, err)
+
+	user, err := store.Authenticate("Alice", "password1")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+
+	_, err = store.Authenticate("alice", "password2")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = store.Authenticate("nobody", "password1")
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+
+	require.NoError(t, store.SetSharesPlays(alice.ID, true))
+	user, err = store.GetByUsername("ALICE")
+	require.NoError(t, err)
+	assert.True(t, user.SharesPlays)
+	assert.ErrorIs(t, store.SetSharesPlays(999, true), models.ErrNotFound)
+	_, err = store.GetByID(999)
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestStore_Sessions(t *testing.T) {
+	store, db := setupTestStore(t)
+	alice, err := store.Signup("alice", "password1")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	token, err := store.CreateSession(alice.ID)
+	require.NoError(t, err)
+	other, err := store.CreateSession(alice.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+
+	var stored int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ?", token).Scan(&stored))
+	assert.Zero(t, stored, "only a hash of the token is stored")
+
+	user, err := store.SessionUser(token)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	_, err = store.SessionUser("not-a-token")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	require.NoError(t, store.DeleteSession(token))
+	_, err = store.SessionUser(token)
+	assert.ErrorIs(t, err, models.ErrNotFound, "a logged-out session ends")
+	require.NoError(t, store.DeleteSession(token))
+
+	now = now.Add(SessionTTL)
+	_, err = store.SessionUser(other)
+	assert.ErrorIs(t, err, models.ErrNotFound, "sessions expire")
+
+	_, err = store.CreateSession(alice.ID)
+	require.NoError(t, err)
+	var sessions int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions))
+	assert.Equal(t, 1, sessions, "expired sessions are cleared out")
+}
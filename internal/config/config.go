@@ -0,0 +1,311 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server's settings. Load builds it from, each overriding
+// the one before, the defaults, an optional TOML or YAML config file,
+// environment variables and command-line flags.
+type Config struct {
+	// Addr is the address the server listens on, as in ":8080" or
+	// "127.0.0.1:8080".
+	Addr string   `toml:"addr" yaml:"addr"`
+	DB   DBConfig `toml:"db" yaml:"db"`
+	// TemplateDir, if set, is a directory to read the templates from
+	// instead of the ones built into the binary. In the debug Gin mode
+	// they are parsed again for every request, so edits show up on reload.
+	TemplateDir string `toml:"template_dir" yaml:"template_dir"`
+	// Dev reads the templates, static files and migrations from the
+	// working directory instead of the binary. TemplateDir defaults to
+	// "templates" with it.
+	Dev bool `toml:"dev" yaml:"dev"`
+	// LogLevel is the least severe level logged: debug, info, warn or
+	// error. Requests are logged at info.
+	LogLevel string `toml:"log_level" yaml:"log_level"`
+	// TrustedProxies are the IP addresses and CIDR ranges of the reverse
+	// proxies whose X-Forwarded-For headers are believed. There are none
+	// by default, so clients are known by the address they connect from.
+	TrustedProxies []string `toml:"trusted_proxies" yaml:"trusted_proxies"`
+	// GinMode is debug, release or test.
+	GinMode string `toml:"gin_mode" yaml:"gin_mode"`
+}
+
+// DBConfig is where the SQLite database is and how it is opened.
+type DBConfig struct {
+	// Path is the database file, whose directory is created if need be.
+	// ":memory:" and "file:" URIs are passed to SQLite as they are.
+	Path string `toml:"path" yaml:"path"`
+	// JournalMode and Synchronous set the pragmas of the same names on
+	// every connection, as in WAL and NORMAL. Empty leaves SQLite's
+	// defaults.
+	JournalMode string `toml:"journal_mode" yaml:"journal_mode"`
+	Synchronous string `toml:"synchronous" yaml:"synchronous"`
+	// BusyTimeout is how many milliseconds a connection waits for a lock
+	// held by another before failing.
+	BusyTimeout int `toml:"busy_timeout" yaml:"busy_timeout"`
+}
+
+// Default returns the settings used where nothing else sets them.
+func Default() Config {
+	return Config{
+		Addr: ":8080",
+		DB: DBConfig{
+			Path:        "./data/marvel_tracker.db",
+			BusyTimeout: 5000,
+		},
+		LogLevel: "info",
+		GinMode:  "debug",
+	}
+}
+
+// setting ties a field of Config to the environment variable and flag
+// that set it.
+type setting struct {
+	env, flag, usage string
+	value            func(c *Config) flag.Value
+}
+
+var settings = []setting{
+	{"ADDR", "addr", "`address` to listen on", func(c *Config) flag.Value { return (*stringValue)(&c.Addr) }},
+	{"DB_PATH", "db", "SQLite database `file`", func(c *Config) flag.Value { return (*stringValue)(&c.DB.Path) }},
+	{"DB_JOURNAL_MODE", "db-journal-mode", "SQLite journal `mode`, as in WAL", func(c *Config) flag.Value { return (*stringValue)(&c.DB.JournalMode) }},
+	{"DB_SYNCHRONOUS", "db-synchronous", "SQLite synchronous `level`, as in NORMAL", func(c *Config) flag.Value { return (*stringValue)(&c.DB.Synchronous) }},
+	{"DB_BUSY_TIMEOUT", "db-busy-timeout", "`milliseconds` to wait for a locked database", func(c *Config) flag.Value { return (*intValue)(&c.DB.BusyTimeout) }},
+	{"TEMPLATE_DIR", "template-dir", "read templates from `directory` instead of the binary", func(c *Config) flag.Value { return (*stringValue)(&c.TemplateDir) }},
+	{"DEV", "dev", "read templates, static files and migrations from the working directory", func(c *Config) flag.Value { return (*boolValue)(&c.Dev) }},
+	{"LOG_LEVEL", "log-level", "least severe `level` to log: debug, info, warn or error", func(c *Config) flag.Value { return (*stringValue)(&c.LogLevel) }},
+	{"TRUSTED_PROXIES", "trusted-proxies", "comma-separated IP `addresses` and CIDR ranges of trusted reverse proxies", func(c *Config) flag.Value { return (*listValue)(&c.TrustedProxies) }},
+	{"GIN_MODE", "gin-mode", "Gin `mode`: debug, release or test", func(c *Config) flag.Value { return (*stringValue)(&c.GinMode) }},
+}
+
+// Load reads the configuration for a command line, with getenv looking up
+// environment variables; main passes os.Args[1:] and os.Getenv. The config
+// file is named by the -config flag or the CONFIG_FILE variable, and its
+// format follows its extension: .toml, .yaml or .yml. Empty environment
+// variables count as unset. Load returns the arguments left after the
+// flags, and an error listing every invalid setting.
+func Load(args []string, getenv func(string) string) (*Config, []string, error) {
+	// The config file comes before the environment and flags that override
+	// it, so parse the flags once just to find out which file they name.
+	var path string
+	scratch := Default()
+	if err := newFlagSet(&scratch, &path, os.Stderr).Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if path == "" {
+		path = getenv("CONFIG_FILE")
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, s := range settings {
+		if v := getenv(s.env); v != "" {
+			if err := s.value(&cfg).Set(v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	flags := newFlagSet(&cfg, &path, io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Dev && cfg.TemplateDir == "" {
+		cfg.TemplateDir = "templates"
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, flags.Args(), nil
+}
+
+// newFlagSet returns the flags for the settings, writing them to cfg, and
+// for the config file, writing it to path.
+func newFlagSet(cfg *Config, path *string, out io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	flags.SetOutput(out)
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: server [flags] [command [arguments]]")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(path, "config", "", "TOML or YAML config `file` ($CONFIG_FILE)")
+	for _, s := range settings {
+		flags.Var(s.value(cfg), s.flag, s.usage+" ($"+s.env+")")
+	}
+	return flags
+}
+
+// readFile decodes the config file at path into cfg, leaving the settings
+// it does not mention alone. Unknown settings are an error, so that a
+// misspelt one is not silently ignored.
+func readFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.NewDecoder(f).DisallowUnknownFields().Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return fmt.Errorf("config file %s is neither .toml nor .yaml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+var (
+	journalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronous  = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+	ginModes     = []string{"debug", "release", "test"}
+)
+
+// Validate reports every invalid setting, one per line.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		invalid("addr %q is not a host:port address", c.Addr)
+	}
+	if c.DB.Path == "" {
+		invalid("db path is empty")
+	}
+	if c.DB.JournalMode != "" && !slices.Contains(journalModes, strings.ToUpper(c.DB.JournalMode)) {
+		invalid("db journal_mode %q is not one of %s", c.DB.JournalMode, strings.Join(journalModes, ", "))
+	}
+	if c.DB.Synchronous != "" && !slices.Contains(synchronous, strings.ToUpper(c.DB.Synchronous)) {
+		invalid("db synchronous %q is not one of %s", c.DB.Synchronous, strings.Join(synchronous, ", "))
+	}
+	if c.DB.BusyTimeout < 0 {
+		invalid("db busy_timeout %d is negative", c.DB.BusyTimeout)
+	}
+	if c.TemplateDir != "" {
+		if info, err := os.Stat(c.TemplateDir); err != nil || !info.IsDir() {
+			invalid("template_dir %q is not a directory", c.TemplateDir)
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		invalid("log_level %q is not one of debug, info, warn, error", c.LogLevel)
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				invalid("trusted_proxies: %q is not an IP address or CIDR range", proxy)
+			}
+		}
+	}
+	if !slices.Contains(ginModes, c.GinMode) {
+		invalid("gin_mode %q is not one of %s", c.GinMode, strings.Join(ginModes, ", "))
+	}
+	return errors.Join(errs...)
+}
+
+// Level returns LogLevel as a slog level. It is only meaningful once the
+// config has been validated.
+func (c *Config) Level() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// stringValue, intValue, boolValue and listValue are flag.Values that set
+// fields of Config, from flags and environment variables alike.
+type (
+	stringValue string
+	intValue    int
+	boolValue   bool
+	listValue   []string
+)
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return string(*v)
+}
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string {
+	return strconv.FormatBool(v != nil && bool(*v))
+}
+
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+// Set splits a comma-separated list, dropping empty items.
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+
+func (v *listValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return strings.Join(*v, ",")
+}
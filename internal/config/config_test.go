@@ -0,0 +1,150 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	noEnv := func(string) string { return "" }
+	envOf := func(env map[string]string) func(string) string {
+		return func(name string) string { return env[name] }
+	}
+	writeFile := func(t *testing.T, name, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, args, err := Load(nil, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, Default(), *cfg)
+		assert.Empty(t, args)
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		path := writeFile(t, "server.toml", `
+addr = ":9000"
+log_level = "warn"
+trusted_proxies = ["10.0.0.0/8"]
+
+[db]
+path = "file.db"
+journal_mode = "WAL"
+`)
+		env := envOf(map[string]string{
+			"CONFIG_FILE": path,
+			"DB_PATH":     "env.db",
+			"LOG_LEVEL":   "debug",
+		})
+
+		cfg, _, err := Load(nil, env)
+		require.NoError(t, err)
+		assert.Equal(t, ":9000", cfg.Addr, "from the file")
+		assert.Equal(t, "WAL", cfg.DB.JournalMode, "from the file")
+		assert.Equal(t, 5000, cfg.DB.BusyTimeout, "defaults fill in what the file leaves out")
+		assert.Equal(t, "env.db", cfg.DB.Path, "the environment overrides the file")
+		assert.Equal(t, []string{"10.0.0.0/8"}, cfg.TrustedProxies)
+
+		cfg, args, err := Load([]string{"-db", "flag.db", "-trusted-proxies", "127.0.0.1, ::1", "import-bgg", "-aspect", "basic"}, env)
+		require.NoError(t, err)
+		assert.Equal(t, "flag.db", cfg.DB.Path, "flags override the environment")
+		assert.Equal(t, "debug", cfg.LogLevel, "the environment overrides the file")
+		assert.Equal(t, []string{"127.0.0.1", "::1"}, cfg.TrustedProxies)
+		assert.Equal(t, []string{"import-bgg", "-aspect", "basic"}, args, "a command's own flags are left to it")
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		path := writeFile(t, "server.yaml", `
+addr: 127.0.0.1:8081
+gin_mode: release
+db:
+  busy_timeout: 250
+  synchronous: normal
+`)
+		cfg, _, err := Load([]string{"-config", path}, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1:8081", cfg.Addr)
+		assert.Equal(t, "release", cfg.GinMode)
+		assert.Equal(t, DBConfig{Path: "./data/marvel_tracker.db", Synchronous: "normal", BusyTimeout: 250}, cfg.DB)
+
+		empty := writeFile(t, "empty.yml", "")
+		cfg, _, err = Load([]string{"-config", empty}, noEnv)
+		require.NoError(t, err)
+		assert.Equal(t, Default(), *cfg)
+	})
+
+	t.Run("Dev", func(t *testing.T) {
+		originalWd, _ := os.Getwd()
+		defer os.Chdir(originalWd)
+		require.NoError(t, os.Chdir("../.."))
+
+		cfg, _, err := Load([]string{"-dev"}, noEnv)
+		require.NoError(t, err)
+		assert.True(t, cfg.Dev)
+		assert.Equal(t, "templates", cfg.TemplateDir)
+
+		cfg, _, err = Load(nil, envOf(map[string]string{"DEV": "true", "TEMPLATE_DIR": "internal"}))
+		require.NoError(t, err)
+		assert.True(t, cfg.Dev)
+		assert.Equal(t, "internal", cfg.TemplateDir)
+	})
+
+	t.Run("File Errors", func(t *testing.T) {
+		_, _, err := Load([]string{"-config", filepath.Join(t.TempDir(), "missing.toml")}, noEnv)
+		assert.ErrorContains(t, err, "reading config file")
+
+		path := writeFile(t, "server.json", "{}")
+		_, _, err = Load([]string{"-config", path}, noEnv)
+		assert.EqualError(t, err, "config file "+path+" is neither .toml nor .yaml")
+
+		path = writeFile(t, "server.toml", "adr = \":9000\"\n")
+		_, _, err = Load([]string{"-config", path}, noEnv)
+		assert.ErrorContains(t, err, "config file "+path)
+
+		path = writeFile(t, "server.yaml", "db:\n  pth: x.db\n")
+		_, _, err = Load([]string{"-config", path}, noEnv)
+		assert.ErrorContains(t, err, "field pth not found")
+	})
+
+	t.Run("Bad Values", func(t *testing.T) {
+		_, _, err := Load(nil, envOf(map[string]string{"DB_BUSY_TIMEOUT": "soon"}))
+		assert.EqualError(t, err, `DB_BUSY_TIMEOUT: "soon" is not a number`)
+
+		_, _, err = Load([]string{"-dev=maybe"}, noEnv)
+		assert.Error(t, err)
+
+		_, _, err = Load([]string{"-h"}, noEnv)
+		assert.ErrorIs(t, err, flag.ErrHelp)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "missing")
+		_, _, err := Load([]string{
+			"-addr", "8080",
+			"-db", "",
+			"-db-journal-mode", "fast",
+			"-db-synchronous", "sometimes",
+			"-db-busy-timeout", "-1",
+			"-template-dir", missing,
+			"-log-level", "loud",
+			"-trusted-proxies", "10.0.0.0/8,proxy.local",
+			"-gin-mode", "production",
+		}, noEnv)
+		assert.EqualError(t, err, `addr "8080" is not a host:port address
+db path is empty
+db journal_mode "fast" is not one of DELETE, TRUNCATE, PERSIST, MEMORY, WAL, OFF
+db synchronous "sometimes" is not one of OFF, NORMAL, FULL, EXTRA
+db busy_timeout -1 is negative
+template_dir "`+missing+`" is not a directory
+log_level "loud" is not one of debug, info, warn, error
+trusted_proxies: "proxy.local" is not an IP address or CIDR range
+gin_mode "production" is not one of debug, release, test`)
+	})
+}
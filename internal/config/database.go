@@ -2,31 +2,58 @@ package config
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func InitDB() *sql.DB {
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "./data/marvel_tracker.db"
+// InitDB opens the database cfg describes, creating its directory if need
+// be, and checks that it can be reached.
+func InitDB(cfg DBConfig) (*sql.DB, error) {
+	if cfg.Path != ":memory:" && !strings.HasPrefix(cfg.Path, "file:") {
+		if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+			return nil, fmt.Errorf("creating database directory: %w", err)
+		}
 	}
 
-	if err := os.MkdirAll("./data", 0755); err != nil {
-		log.Fatal("Failed to create data directory:", err)
-	}
-
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", cfg.dsn())
 	if err != nil {
-		log.Fatal("Failed to open database:", err)
+		return nil, fmt.Errorf("opening database: %w", err)
 	}
-
 	if err := db.Ping(); err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		db.Close()
+		return nil, fmt.Errorf("connecting to database at %s: %w", cfg.Path, err)
+	}
+
+	slog.Info("Connected to SQLite database", "path", cfg.Path)
+	return db, nil
+}
+
+// dsn returns the go-sqlite3 data source name for the database. The driver
+// runs the pragmas it names on every pooled connection, which matters as a
+// pragma only applies to the connection it runs on.
+func (cfg DBConfig) dsn() string {
+	dsn := dsnWithForeignKeys(cfg.Path)
+	if cfg.JournalMode != "" {
+		dsn += "&_journal_mode=" + strings.ToUpper(cfg.JournalMode)
+	}
+	if cfg.Synchronous != "" {
+		dsn += "&_synchronous=" + strings.ToUpper(cfg.Synchronous)
 	}
+	return dsn + "&_busy_timeout=" + strconv.Itoa(cfg.BusyTimeout)
+}
 
-	log.Printf("Connected to SQLite database at %s", dbPath)
-	return db
+// dsnWithForeignKeys adds foreign_keys=ON to dbPath. Foreign keys are off
+// by default in SQLite.
+func dsnWithForeignKeys(dbPath string) string {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + "_foreign_keys=on"
 }
@@ -6,13 +6,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitDB(t *testing.T) {
 	t.Run("Default Database Path", func(t *testing.T) {
-		// Ensure no DB_PATH environment variable is set
-		os.Unsetenv("DB_PATH")
-
 		// Create a temporary directory for testing
 		tempDir := t.TempDir()
 		originalWd, _ := os.Getwd()
@@ -21,11 +19,12 @@ func TestInitDB(t *testing.T) {
 		// Change to temp directory so default ./data path is created there
 		os.Chdir(tempDir)
 
-		db := InitDB()
+		db, err := InitDB(Default().DB)
+		require.NoError(t, err)
 		defer db.Close()
 
 		// Verify database connection works
-		err := db.Ping()
+		err = db.Ping()
 		assert.NoError(t, err)
 
 		// Verify data directory was created
@@ -41,35 +40,68 @@ func TestInitDB(t *testing.T) {
 		tempDir := t.TempDir()
 		dbPath := filepath.Join(tempDir, "custom.db")
 
-		// Set custom DB_PATH
-		os.Setenv("DB_PATH", dbPath)
-		defer os.Unsetenv("DB_PATH")
+		// Run from another directory to check that no ./data appears in it
+		workDir := t.TempDir()
+		originalWd, _ := os.Getwd()
+		defer os.Chdir(originalWd)
+		os.Chdir(workDir)
 
-		db := InitDB()
+		db, err := InitDB(DBConfig{Path: dbPath})
+		require.NoError(t, err)
 		defer db.Close()
 
 		// Verify database connection works
-		err := db.Ping()
+		err = db.Ping()
 		assert.NoError(t, err)
 
 		// Verify custom database file exists
 		_, err = os.Stat(dbPath)
 		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(workDir, "data"))
+		assert.True(t, os.IsNotExist(err), "only the database's own directory is created")
 	})
 
-	t.Run("Custom Database Path with Directory Creation", func(t *testing.T) {
+	t.Run("Foreign Keys Enabled", func(t *testing.T) {
 		tempDir := t.TempDir()
-		dbPath := filepath.Join(tempDir, "subdir", "custom.db")
+		db, err := InitDB(DBConfig{Path: filepath.Join(tempDir, "fk.db")})
+		require.NoError(t, err)
+		defer db.Close()
 
-		// Set custom DB_PATH with subdirectory
-		os.Setenv("DB_PATH", dbPath)
-		defer os.Unsetenv("DB_PATH")
+		// Check several pooled connections, not just the first one.
+		db.SetMaxIdleConns(0)
+		for i := 0; i < 3; i++ {
+			var enabled int
+			err := db.QueryRow("PRAGMA foreign_keys").Scan(&enabled)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, enabled)
+		}
+	})
 
-		// Create the subdirectory first since SQLite requires parent directory to exist
-		err := os.MkdirAll(filepath.Dir(dbPath), 0755)
-		assert.NoError(t, err)
+	t.Run("Pragmas", func(t *testing.T) {
+		tempDir := t.TempDir()
+		db, err := InitDB(DBConfig{Path: filepath.Join(tempDir, "pragmas.db"), JournalMode: "wal", Synchronous: "normal", BusyTimeout: 1234})
+		require.NoError(t, err)
+		defer db.Close()
+
+		db.SetMaxIdleConns(0)
+		for i := 0; i < 3; i++ {
+			var journalMode string
+			var synchronous, busyTimeout int
+			require.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+			require.NoError(t, db.QueryRow("PRAGMA synchronous").Scan(&synchronous))
+			require.NoError(t, db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout))
+			assert.Equal(t, "wal", journalMode)
+			assert.Equal(t, 1, synchronous, "NORMAL")
+			assert.Equal(t, 1234, busyTimeout)
+		}
+	})
 
-		db := InitDB()
+	t.Run("Custom Database Path with Directory Creation", func(t *testing.T) {
+		tempDir := t.TempDir()
+		dbPath := filepath.Join(tempDir, "subdir", "custom.db")
+
+		db, err := InitDB(DBConfig{Path: dbPath})
+		require.NoError(t, err)
 		defer db.Close()
 
 		// Verify database connection works
@@ -84,4 +116,14 @@ func TestInitDB(t *testing.T) {
 		_, err = os.Stat(dbPath)
 		assert.NoError(t, err)
 	})
+
+	t.Run("Unreachable Database", func(t *testing.T) {
+		// A file where the database's directory should be
+		tempDir := t.TempDir()
+		blocker := filepath.Join(tempDir, "blocker")
+		require.NoError(t, os.WriteFile(blocker, nil, 0644))
+
+		_, err := InitDB(DBConfig{Path: filepath.Join(blocker, "x.db")})
+		assert.ErrorContains(t, err, "creating database directory")
+	})
 }
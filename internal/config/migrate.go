@@ -1,89 +1,387 @@
 package config
 
 import (
+	"crypto/sha256"
 	"database/sql"
-	"log"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
 	"os"
-	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func RunMigrations(db *sql.DB) error {
+// Migration is one numbered migration: the NNN_name.sql file that applies
+// it and, if there is one, the NNN_name.down.sql file that undoes it.
+type Migration struct {
+	// Version is the number the file names start with.
+	Version int
+	// Name is the file name of the up migration, as recorded in the
+	// migrations table.
+	Name string
+	Up   string
+	// Down is the SQL of the down migration, or "" if there is none.
+	Down string
+	// Applied is set for migrations recorded in the migrations table.
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// RequiresFTS5 reports whether the migration starts with
+// "-- requires: fts5", which skips it on SQLite built without FTS5.
+func (m Migration) RequiresFTS5() bool {
+	return strings.HasPrefix(m.Up, "-- requires: fts5")
+}
+
+// Migrator applies and undoes a set of migrations, such as the ones the
+// migrations package builds into the binary.
+// Each migration runs in its own transaction, so one that fails leaves
+// nothing behind, and is recorded in the migrations table with a checksum
+// of its file. NewMigrator refuses to work on a database where a file that
+// was already applied has been edited since: a change to the schema needs
+// a new migration.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	fts5       bool
+
+	// DryRun makes the migrator print the SQL it would run to Out rather
+	// than running it.
+	DryRun bool
+	Out    io.Writer
+}
+
+// NewMigrator reads the migrations at the root of fsys and which of them
+// the database has applied.
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
 	if err := createMigrationsTable(db); err != nil {
-		return err
+		return nil, err
+	}
+	migrations, err := readMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range migrations {
+		m := &migrations[i]
+		record, ok := applied[m.Name]
+		if !ok {
+			continue
+		}
+		m.Applied, m.AppliedAt = true, record.appliedAt
+
+		sum := checksum(m.Up)
+		switch record.checksum {
+		case "":
+			// Applied before checksums were recorded: trust the file as
+			// it is now.
+			if _, err := db.Exec("UPDATE migrations SET checksum = ? WHERE filename = ?", sum, m.Name); err != nil {
+				return nil, err
+			}
+		case sum:
+		default:
+			return nil, fmt.Errorf("migration %s has been edited since it was applied; restore it and add the change as a new migration", m.Name)
+		}
 	}
 
-	files, err := filepath.Glob("migrations/*.sql")
+	fts5, err := fts5Available(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, fts5: fts5, Out: os.Stdout}, nil
+}
+
+// RunMigrations applies every migration at the root of fsys that has not
+// been applied yet. It refuses to run a database that has applied an FTS5
+// migration on SQLite built without FTS5, as saving a play would fail.
+func RunMigrations(db *sql.DB, fsys fs.FS) error {
+	m, err := NewMigrator(db, fsys)
 	if err != nil {
 		return err
 	}
+	for _, mig := range m.migrations {
+		if mig.Applied && mig.RequiresFTS5() && !m.fts5 {
+			return fmt.Errorf("the database has applied migration %s, which needs FTS5, but SQLite was built without it; build with -tags sqlite_fts5", mig.Name)
+		}
+	}
+	return m.Up()
+}
 
-	sort.Strings(files)
+// Migrations returns every migration in version order, with whether it
+// has been applied.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Version returns the version of the newest applied migration, or 0 if
+// none has been applied.
+func (m *Migrator) Version() int {
+	version := 0
+	for _, mig := range m.migrations {
+		if mig.Applied {
+			version = mig.Version
+		}
+	}
+	return version
+}
 
-	for _, file := range files {
-		filename := filepath.Base(file)
+// Up applies every pending migration in version order. Migrations that
+// need FTS5 are skipped, and left pending, on SQLite built without it, so
+// they run once the server is built with FTS5.
+func (m *Migrator) Up() error {
+	return m.upTo(math.MaxInt)
+}
 
-		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM migrations WHERE filename = ?", filename).Scan(&count)
-		if err != nil {
+// Down undoes the n newest applied migrations, newest first.
+func (m *Migrator) Down(n int) error {
+	applied := m.applied()
+	if n < 0 || n > len(applied) {
+		return fmt.Errorf("cannot undo %d migrations: %d are applied", n, len(applied))
+	}
+	for _, i := range applied[:n] {
+		if err := m.undo(i); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		if count > 0 {
-			log.Printf("Migration %s already applied, skipping", filename)
-			continue
+// Goto applies or undoes migrations until version is the newest one
+// applied. Pending migrations older than version are applied too; goto 0
+// undoes every migration.
+func (m *Migrator) Goto(version int) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(mig Migration) bool { return mig.Version == version }) {
+		return fmt.Errorf("there is no migration %03d", version)
+	}
+	for _, i := range m.applied() {
+		if m.migrations[i].Version <= version {
+			break
 		}
-
-		log.Printf("Running migration: %s", filename)
-
-		content, err := os.ReadFile(file)
-		if err != nil {
+		if err := m.undo(i); err != nil {
 			return err
 		}
+	}
+	return m.upTo(version)
+}
 
-		// Execute the entire migration file as one transaction
-		content_str := string(content)
+// Redo undoes the newest applied migration and applies it again.
+func (m *Migrator) Redo() error {
+	applied := m.applied()
+	if len(applied) == 0 {
+		return errors.New("there is no applied migration to redo")
+	}
+	if err := m.undo(applied[0]); err != nil {
+		return err
+	}
+	return m.apply(applied[0])
+}
 
-		// Remove comments and split by semicolon
-		lines := strings.Split(content_str, "\n")
-		var cleanedLines []string
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" && !strings.HasPrefix(line, "--") {
-				cleanedLines = append(cleanedLines, line)
-			}
+// applied returns the indexes of the applied migrations, newest first.
+func (m *Migrator) applied() []int {
+	var applied []int
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		if m.migrations[i].Applied {
+			applied = append(applied, i)
 		}
+	}
+	return applied
+}
 
-		if len(cleanedLines) == 0 {
+// upTo applies the pending migrations up to and including version.
+func (m *Migrator) upTo(version int) error {
+	for i, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if mig.Applied {
+			slog.Info("Migration already applied, skipping", "migration", mig.Name)
 			continue
 		}
+		if mig.RequiresFTS5() && !m.fts5 {
+			slog.Warn("Skipping migration: SQLite was built without FTS5 (build with -tags sqlite_fts5)", "migration", mig.Name)
+			continue
+		}
+		if err := m.apply(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs the up migration at index i and records it.
+func (m *Migrator) apply(i int) error {
+	mig := &m.migrations[i]
+	slog.Info("Running migration", "migration", mig.Name)
+	err := m.run(mig.Name, mig.Up, "INSERT INTO migrations (filename, checksum) VALUES (?, ?)", mig.Name, checksum(mig.Up))
+	if err != nil {
+		return err
+	}
+	if !m.DryRun {
+		mig.Applied, mig.AppliedAt = true, time.Now()
+	}
+	slog.Info("Migration completed successfully", "migration", mig.Name)
+	return nil
+}
 
-		// Join lines and split by semicolon
-		fullContent := strings.Join(cleanedLines, " ")
-		statements := strings.Split(fullContent, ";")
+// undo runs the down migration of the migration at index i and forgets
+// that it was applied.
+func (m *Migrator) undo(i int) error {
+	mig := &m.migrations[i]
+	if mig.Down == "" {
+		return fmt.Errorf("migration %s has no down migration", mig.Name)
+	}
+	slog.Info("Undoing migration", "migration", mig.Name)
+	if err := m.run(downName(mig.Name), mig.Down, "DELETE FROM migrations WHERE filename = ?", mig.Name); err != nil {
+		return err
+	}
+	if !m.DryRun {
+		mig.Applied, mig.AppliedAt = false, time.Time{}
+	}
+	slog.Info("Migration undone", "migration", mig.Name)
+	return nil
+}
 
+// run executes the statements of a migration file and then record, which
+// updates the migrations table, in one transaction. A dry run prints the
+// statements instead.
+func (m *Migrator) run(filename, content, record string, args ...any) error {
+	statements := splitStatements(content)
+	if m.DryRun {
+		fmt.Fprintf(m.Out, "-- %s\n", filename)
 		for _, stmt := range statements {
-			stmt = strings.TrimSpace(stmt)
-			if stmt == "" {
-				continue
-			}
+			fmt.Fprintf(m.Out, "%s;\n", stmt)
+		}
+		return nil
+	}
 
-			if _, err := db.Exec(stmt); err != nil {
-				log.Printf("Error executing statement: %s", stmt)
-				return err
-			}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			slog.Error("Error executing statement", "migration", filename, "statement", stmt, "err", err)
+			return fmt.Errorf("migration %s: %w", filename, err)
 		}
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// readMigrations reads the migrations at the root of fsys, pairing each
+// NNN_name.sql file with its NNN_name.down.sql file, in version order.
+func readMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = db.Exec("INSERT INTO migrations (filename) VALUES (?)", filename)
+	byName := make(map[string]*Migration)
+	var migrations []*Migration
+	downs := make(map[string]string)
+	for _, filename := range files {
+		content, err := fs.ReadFile(fsys, filename)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if strings.HasSuffix(filename, ".down.sql") {
+			downs[filename] = string(content)
+			continue
 		}
 
-		log.Printf("Migration %s completed successfully", filename)
+		digits, _, ok := strings.Cut(filename, "_")
+		version, err := strconv.Atoi(digits)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s does not start with a version number, as in 001_name.sql", filename)
+		}
+		mig := &Migration{Version: version, Name: filename, Up: string(content)}
+		migrations = append(migrations, mig)
+		byName[filename] = mig
 	}
 
-	return nil
+	for filename, content := range downs {
+		mig, ok := byName[strings.TrimSuffix(filename, ".down.sql")+".sql"]
+		if !ok {
+			return nil, fmt.Errorf("down migration %s has no up migration", filename)
+		}
+		mig.Down = content
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	sorted := make([]Migration, len(migrations))
+	for i, mig := range migrations {
+		if i > 0 && mig.Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migrations[i-1].Name, mig.Name)
+		}
+		sorted[i] = *mig
+	}
+	return sorted, nil
+}
+
+// downName returns the file name of the down migration of the migration
+// in filename.
+func downName(filename string) string {
+	return strings.TrimSuffix(filename, ".sql") + ".down.sql"
+}
+
+// appliedMigration is a row of the migrations table.
+type appliedMigration struct {
+	appliedAt time.Time
+	// checksum is "" for migrations applied before checksums were
+	// recorded.
+	checksum string
+}
+
+// appliedMigrations returns the migrations table by file name.
+func appliedMigrations(db *sql.DB) (map[string]appliedMigration, error) {
+	rows, err := db.Query("SELECT filename, applied_at, COALESCE(checksum, '') FROM migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var filename string
+		var record appliedMigration
+		if err := rows.Scan(&filename, &record.appliedAt, &record.checksum); err != nil {
+			return nil, err
+		}
+		applied[filename] = record
+	}
+	return applied, rows.Err()
+}
+
+// checksum returns the SHA-256 of a migration's contents in hex. Line
+// endings are normalized first, so that a checkout converting them does
+// not count as an edit.
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(strings.ReplaceAll(content, "\r\n", "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// fts5Available reports whether SQLite was compiled with the FTS5
+// extension, which go-sqlite3 only includes with the sqlite_fts5 build
+// tag.
+func fts5Available(db *sql.DB) (bool, error) {
+	var used bool
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used)
+	return used, err
 }
 
 func createMigrationsTable(db *sql.DB) error {
@@ -91,9 +389,22 @@ func createMigrationsTable(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS migrations (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		filename TEXT NOT NULL UNIQUE,
-		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checksum TEXT
 	)`
 
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Databases migrated before checksums were recorded lack the column.
+	var hasChecksum bool
+	if err := db.QueryRow("SELECT COUNT(*) > 0 FROM pragma_table_info('migrations') WHERE name = 'checksum'").Scan(&hasChecksum); err != nil {
+		return err
+	}
+	if !hasChecksum {
+		_, err := db.Exec("ALTER TABLE migrations ADD COLUMN checksum TEXT")
+		return err
+	}
+	return nil
 }
@@ -2,13 +2,19 @@ package config
 
 import (
 	"database/sql"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"marvel_tracker/migrations"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -86,6 +92,7 @@ func TestCreateMigrationsTable(t *testing.T) {
 	assert.True(t, columns["id"])
 	assert.True(t, columns["filename"])
 	assert.True(t, columns["applied_at"])
+	assert.True(t, columns["checksum"])
 }
 
 func TestRunMigrations(t *testing.T) {
@@ -99,11 +106,8 @@ func TestRunMigrations(t *testing.T) {
 		createTestMigrationFiles(t, migrationDir)
 
 		// Change working directory temporarily
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
 
-		err := RunMigrations(db)
+		err := RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err)
 
 		// Verify migrations table exists
@@ -154,11 +158,8 @@ func TestRunMigrations(t *testing.T) {
 		require.NoError(t, err)
 
 		// Change working directory temporarily
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
 
-		err = RunMigrations(db)
+		err = RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err)
 
 		// Should still create migrations table
@@ -178,16 +179,13 @@ func TestRunMigrations(t *testing.T) {
 		createTestMigrationFiles(t, migrationDir)
 
 		// Change working directory temporarily
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
 
 		// Run migrations first time
-		err := RunMigrations(db)
+		err := RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err)
 
 		// Run migrations second time - should skip all
-		err = RunMigrations(db)
+		err = RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err)
 
 		// Should still only have 3 migration records
@@ -217,11 +215,8 @@ CREATE INVALID TABLE syntax;
 		require.NoError(t, err)
 
 		// Change working directory temporarily
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
 
-		err = RunMigrations(db)
+		err = RunMigrations(db, os.DirFS(migrationDir))
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "syntax error")
 	})
@@ -255,11 +250,8 @@ CREATE TABLE test_table (
 		require.NoError(t, err)
 
 		// Change working directory temporarily
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
 
-		err = RunMigrations(db)
+		err = RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err)
 
 		// Verify table was created despite comments
@@ -269,17 +261,65 @@ CREATE TABLE test_table (
 		assert.Equal(t, "test_table", tableName)
 	})
 
-	t.Run("No Migrations Directory", func(t *testing.T) {
+	t.Run("Triggers", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+
+		tempDir := t.TempDir()
+		migrationDir := filepath.Join(tempDir, "migrations")
+		require.NoError(t, os.MkdirAll(migrationDir, 0755))
+		migration := `
+CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE item_log (item_id INTEGER, note TEXT);
+CREATE TRIGGER item_insert AFTER INSERT ON items
+BEGIN
+    INSERT INTO item_log (item_id, note) VALUES (new.id, 'added');
+    INSERT INTO item_log (item_id, note) VALUES (new.id, 'twice');
+END;
+INSERT INTO items (name) VALUES ('first');
+`
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "001_triggers.sql"), []byte(migration), 0644))
+
+		require.NoError(t, RunMigrations(db, os.DirFS(migrationDir)))
+		var logged int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM item_log").Scan(&logged))
+		assert.Equal(t, 2, logged, "both statements of the trigger body ran")
+	})
+
+	t.Run("Requires FTS5", func(t *testing.T) {
 		db := setupTestDB(t)
 		defer db.Close()
 
-		// Use a directory without migrations folder
 		tempDir := t.TempDir()
-		originalWd, _ := os.Getwd()
-		defer os.Chdir(originalWd)
-		os.Chdir(tempDir)
+		migrationDir := filepath.Join(tempDir, "migrations")
+		require.NoError(t, os.MkdirAll(migrationDir, 0755))
+		migration := "-- requires: fts5\nCREATE VIRTUAL TABLE docs USING fts5(body);\n"
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "001_search.sql"), []byte(migration), 0644))
+
+		require.NoError(t, RunMigrations(db, os.DirFS(migrationDir)))
+		available, err := fts5Available(db)
+		require.NoError(t, err)
+		var applied int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&applied))
+		if available {
+			assert.Equal(t, 1, applied)
+			return
+		}
+		assert.Zero(t, applied, "the migration waits for a build with FTS5")
+
+		// A database indexed by a build with FTS5 cannot be run without it.
+		_, err = db.Exec("INSERT INTO migrations (filename) VALUES ('001_search.sql')")
+		require.NoError(t, err)
+		assert.ErrorContains(t, RunMigrations(db, os.DirFS(migrationDir)), "build with -tags sqlite_fts5")
+	})
+
+	t.Run("No Migrations Directory", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
 
-		err := RunMigrations(db)
+		// Use a directory that does not exist
+		migrationDir := filepath.Join(t.TempDir(), "migrations")
+		err := RunMigrations(db, os.DirFS(migrationDir))
 		assert.NoError(t, err) // Should not error, just find no files
 
 		// Should still create migrations table
@@ -288,4 +328,778 @@ CREATE TABLE test_table (
 		assert.NoError(t, err)
 		assert.Equal(t, 0, count)
 	})
+
+	t.Run("Failed Migration Rolls Back", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+
+		tempDir := t.TempDir()
+		migrationDir := filepath.Join(tempDir, "migrations")
+		require.NoError(t, os.MkdirAll(migrationDir, 0755))
+		migration := `
+CREATE TABLE half_done (id INTEGER PRIMARY KEY);
+INSERT INTO half_done (id) VALUES (1);
+INSERT INTO missing_table (id) VALUES (1);
+`
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "001_half_done.sql"), []byte(migration), 0644))
+
+		err := RunMigrations(db, os.DirFS(migrationDir))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "001_half_done.sql")
+
+		var tables, applied int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&tables))
+		assert.Zero(t, tables, "the statements before the failure are rolled back")
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&applied))
+		assert.Zero(t, applied)
+	})
+
+	t.Run("Edited Migration", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+
+		tempDir := t.TempDir()
+		migrationDir := filepath.Join(tempDir, "migrations")
+		createTestMigrationFiles(t, migrationDir)
+
+		require.NoError(t, RunMigrations(db, os.DirFS(migrationDir)))
+		var sum string
+		require.NoError(t, db.QueryRow("SELECT checksum FROM migrations WHERE filename = '002_create_posts.sql'").Scan(&sum))
+		content, err := os.ReadFile(filepath.Join(migrationDir, "002_create_posts.sql"))
+		require.NoError(t, err)
+		assert.Equal(t, checksum(string(content)), sum)
+
+		// A new migration is not applied while an applied one is edited.
+		edited := strings.Replace(string(content), "title TEXT NOT NULL", "title TEXT", 1)
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "002_create_posts.sql"), []byte(edited), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "004_tags.sql"), []byte("CREATE TABLE tags (id INTEGER PRIMARY KEY);"), 0644))
+
+		err = RunMigrations(db, os.DirFS(migrationDir))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "migration 002_create_posts.sql has been edited")
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&count))
+		assert.Equal(t, 3, count)
+
+		// Converted line endings are not an edit.
+		crlf := strings.ReplaceAll(string(content), "\n", "\r\n")
+		require.NoError(t, os.WriteFile(filepath.Join(migrationDir, "002_create_posts.sql"), []byte(crlf), 0644))
+		require.NoError(t, RunMigrations(db, os.DirFS(migrationDir)))
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&count))
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("Records Checksums Of Earlier Migrations", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+
+		// The migrations table as it was before checksums.
+		_, err := db.Exec(`
+			CREATE TABLE migrations (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				filename TEXT NOT NULL UNIQUE,
+				applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL);
+			INSERT INTO migrations (filename) VALUES ('001_create_users.sql');`)
+		require.NoError(t, err)
+
+		tempDir := t.TempDir()
+		migrationDir := filepath.Join(tempDir, "migrations")
+		createTestMigrationFiles(t, migrationDir)
+
+		require.NoError(t, RunMigrations(db, os.DirFS(migrationDir)))
+		var missing int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations WHERE checksum IS NULL").Scan(&missing))
+		assert.Zero(t, missing)
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&count))
+		assert.Equal(t, 3, count)
+	})
+}
+
+func TestShippedMigrations(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// The shipped migrations live at the repository root.
+	require.NoError(t, RunMigrations(db, migrations.FS))
+
+	t.Run("Catalog Seeded", func(t *testing.T) {
+		var pack string
+		var wave int
+		err := db.QueryRow(`
+			SELECT p.name, p.wave FROM heroes h JOIN packs p ON p.id = h.pack_id
+			WHERE h.name = 'Spider-Man'`).Scan(&pack, &wave)
+		require.NoError(t, err)
+		assert.Equal(t, "Core Set", pack)
+		assert.Equal(t, 1, wave)
+
+		err = db.QueryRow(`
+			SELECT p.name FROM scenarios s JOIN packs p ON p.id = s.pack_id
+			WHERE s.name = 'Red Skull'`).Scan(&pack)
+		require.NoError(t, err)
+		assert.Equal(t, "The Rise of Red Skull", pack)
+
+		var uncatalogued int
+		err = db.QueryRow("SELECT COUNT(*) FROM heroes WHERE pack_id IS NULL").Scan(&uncatalogued)
+		require.NoError(t, err)
+		assert.Zero(t, uncatalogued)
+	})
+
+	t.Run("Play Source IDs", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x')")
+		require.NoError(t, err)
+		insert := func(ownerID int, sourceID any) error {
+			_, err := db.Exec("INSERT INTO plays (date, outcome, difficulty, scenario_id, owner_id, source_id) VALUES ('2024-01-01', 'win', 'Standard I', 1, ?, ?)", ownerID, sourceID)
+			return err
+		}
+		require.NoError(t, insert(1, nil))
+		require.NoError(t, insert(1, nil), "plays logged in the tracker have no source")
+		require.NoError(t, insert(1, "bgstats:abc"))
+		assert.Error(t, insert(1, "bgstats:abc"), "a user can only import a source id once")
+		require.NoError(t, insert(2, "bgstats:abc"), "each user can import the same source id")
+
+		for _, table := range []string{"plays", "users"} {
+			_, err = db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("Campaigns", func(t *testing.T) {
+		var packID int
+		require.NoError(t, db.QueryRow("SELECT id FROM packs WHERE name = 'The Rise of Red Skull'").Scan(&packID))
+		_, err := db.Exec("INSERT INTO campaigns (id, name, pack_id, started_on) VALUES (1, 'Run', ?, '2024-01-01')", packID)
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (100, '2024-01-01', 'win', 'Standard I', 1)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO campaign_entries (campaign_id, play_id, position) VALUES (1, 100, 1)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO campaign_entries (campaign_id, play_id, position) VALUES (1, 100, 2)")
+		assert.Error(t, err, "a play belongs to at most one campaign step")
+		_, err = db.Exec("INSERT INTO campaigns (name, pack_id, mode, started_on) VALUES ('Run', ?, 'heroic', '2024-01-01')", packID)
+		assert.Error(t, err)
+
+		_, err = db.Exec("DELETE FROM plays")
+		require.NoError(t, err)
+		_, err = db.Exec("DELETE FROM campaigns")
+		require.NoError(t, err)
+	})
+
+	t.Run("Encounter Sets", func(t *testing.T) {
+		// Every seeded set and recommendation joined onto a real pack or
+		// scenario; a typo in a name would silently drop the row.
+		var sets, recommendations int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM encounter_sets WHERE pack_id IS NOT NULL").Scan(&sets))
+		assert.Equal(t, 64, sets)
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM scenario_encounter_sets").Scan(&recommendations))
+		assert.Equal(t, 21, recommendations)
+
+		var name string
+		err := db.QueryRow(`
+			SELECT e.name FROM scenario_encounter_sets r
+			JOIN scenarios s ON s.id = r.scenario_id
+			JOIN encounter_sets e ON e.id = r.encounter_set_id
+			WHERE s.name = 'Rhino'`).Scan(&name)
+		require.NoError(t, err)
+		assert.Equal(t, "Bomb Scare", name)
+
+		_, err = db.Exec("INSERT INTO encounter_sets (name) VALUES ('bomb scare')")
+		assert.Error(t, err, "encounter set names are unique regardless of case")
+	})
+
+	t.Run("Play End State", func(t *testing.T) {
+		insert := func(column string, value any) error {
+			_, err := db.Exec("INSERT INTO plays (date, outcome, difficulty, scenario_id, "+column+") VALUES ('2024-01-01', 'loss', 'Standard I', 1, ?)", value)
+			return err
+		}
+		require.NoError(t, insert("end_reason", nil), "the end state is optional")
+		require.NoError(t, insert("end_reason", "heroes_defeated"))
+		assert.Error(t, insert("end_reason", "timed_out"))
+		assert.Error(t, insert("rounds", 0))
+		assert.Error(t, insert("villain_stage", 0))
+		require.NoError(t, insert("remaining_threat", 0))
+		assert.Error(t, insert("remaining_threat", -1))
+
+		_, err := db.Exec("INSERT INTO decks (play_id, hero_id, remaining_hp) SELECT MAX(id), 1, -1 FROM plays")
+		assert.Error(t, err)
+
+		_, err = db.Exec("DELETE FROM plays")
+		require.NoError(t, err)
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'Alice', 'x')")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO users (username, password_hash) VALUES ('alice', 'x')")
+		assert.Error(t, err, "usernames are unique regardless of case")
+		_, err = db.Exec("UPDATE users SET shares_plays = 2 WHERE id = 1")
+		assert.Error(t, err)
+
+		_, err = db.Exec("INSERT INTO plays (date, outcome, difficulty, scenario_id, owner_id) VALUES ('2024-01-01', 'win', 'Standard I', 1, 1)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO campaigns (name, pack_id, started_on, owner_id) VALUES ('Run', 1, '2024-01-01', 1)")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO sessions (id, user_id) VALUES ('abc', 1)")
+		assert.Error(t, err, "sessions expire")
+
+		for _, table := range []string{"plays", "campaigns", "users"} {
+			_, err = db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x')")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO groups (id, name) VALUES (1, 'Tuesday Table')")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO group_members (group_id, user_id, role) VALUES (1, 1, 'owner')")
+		require.NoError(t, err)
+		_, err = db.Exec("INSERT INTO group_members (group_id, user_id, role) VALUES (1, 1, 'editor')")
+		assert.Error(t, err, "a user is a member once")
+		_, err = db.Exec("UPDATE group_members SET role = 'admin'")
+		assert.Error(t, err)
+		_, err = db.Exec("INSERT INTO group_invites (token, group_id, role, expires_at) VALUES ('abc', 1, 'owner', '2030-01-01')")
+		assert.Error(t, err, "invites are for editors and viewers")
+		_, err = db.Exec("INSERT INTO plays (date, outcome, difficulty, scenario_id, owner_id, group_id) VALUES ('2024-01-01', 'win', 'Standard I', 1, 1, 1)")
+		require.NoError(t, err)
+
+		for _, table := range []string{"plays", "group_members", "groups", "users"} {
+			_, err = db.Exec("DELETE FROM " + table)
+			require.NoError(t, err)
+		}
+	})
+
+}
+
+func TestSeedCatalogMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m, err := NewMigrator(db, migrations.FS)
+	require.NoError(t, err)
+	require.NoError(t, m.Goto(1))
+	_, err = db.Exec(`
+		INSERT INTO heroes (id, name) VALUES (500, 'spider-man'), (501, 'Fan-Made Hero');
+		INSERT INTO scenarios (id, name) VALUES (500, 'RHINO');
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(2))
+
+	packOf := func(table string, id int) sql.NullString {
+		var pack sql.NullString
+		require.NoError(t, db.QueryRow("SELECT p.name FROM "+table+" t LEFT JOIN packs p ON p.id = t.pack_id WHERE t.id = ?", id).Scan(&pack))
+		return pack
+	}
+	named := func(table, name string) int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE name = ? COLLATE NOCASE", name).Scan(&n))
+		return n
+	}
+	assert.Equal(t, "Core Set", packOf("heroes", 500).String, "a user-added hero is matched ignoring case")
+	assert.Equal(t, 1, named("heroes", "Spider-Man"))
+	assert.Equal(t, "Core Set", packOf("scenarios", 500).String)
+	assert.Equal(t, 1, named("scenarios", "Rhino"))
+	assert.False(t, packOf("heroes", 501).Valid, "other user-added heroes stay out of the catalog")
+
+	_, err = db.Exec(`
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 500);
+		INSERT INTO decks (id, play_id, hero_id, aspect) VALUES (1, 1, (SELECT id FROM heroes WHERE name = 'Thor'), 'aggression');
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Down(1))
+	var heroes []string
+	rows, err := db.Query("SELECT name FROM heroes ORDER BY id")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		heroes = append(heroes, name)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"spider-man", "Fan-Made Hero", "Thor"}, heroes,
+		"user-added heroes and played catalog heroes stay")
+	assert.Equal(t, 1, named("scenarios", "Rhino"))
+	assert.Zero(t, named("scenarios", "Klaw"))
+}
+
+func TestDeckAspectsMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	fsys := fstest.MapFS{}
+	copyMigration := func(name string) {
+		content, err := fs.ReadFile(migrations.FS, name)
+		require.NoError(t, err)
+		fsys[name] = &fstest.MapFile{Data: content}
+	}
+
+	// Bring the database to the state before deck_aspects existed and log
+	// a play with the old single-aspect column.
+	for _, name := range []string{"001_initial_schema.sql", "002_seed_catalog.sql", "003_archive_catalog_entries.sql", "004_deck_player_names.sql"} {
+		copyMigration(name)
+	}
+	require.NoError(t, RunMigrations(db, fsys))
+	_, err = db.Exec(`
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 1);
+		INSERT INTO decks (id, play_id, hero_id, aspect, player_name) VALUES (7, 1, 1, 'justice', 'Sam');
+	`)
+	require.NoError(t, err)
+
+	copyMigration("005_deck_aspects.sql")
+	require.NoError(t, RunMigrations(db, fsys))
+
+	var aspect, playerName string
+	err = db.QueryRow(`
+		SELECT a.name, d.player_name FROM decks d
+		JOIN deck_aspects da ON da.deck_id = d.id
+		JOIN aspects a ON a.id = da.aspect_id
+		WHERE d.id = 7`).Scan(&aspect, &playerName)
+	require.NoError(t, err)
+	assert.Equal(t, "justice", aspect)
+	assert.Equal(t, "Sam", playerName)
+
+	// The foreign key followed the rename, so deleting the play still
+	// cascades all the way to deck_aspects.
+	_, err = db.Exec("DELETE FROM plays WHERE id = 1")
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM deck_aspects").Scan(&count))
+	assert.Zero(t, count)
+
+	_, err = db.Exec("INSERT INTO aspects (name) VALUES ('Justice')")
+	assert.Error(t, err, "aspect names are unique regardless of case")
+}
+
+func TestEncounterSetsMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	fsys := fstest.MapFS{}
+	copyMigration := func(name string) {
+		content, err := fs.ReadFile(migrations.FS, name)
+		require.NoError(t, err)
+		fsys[name] = &fstest.MapFile{Data: content}
+	}
+
+	names, err := fs.Glob(migrations.FS, "00[1-7]_*.sql")
+	require.NoError(t, err)
+	require.Len(t, names, 14, "seven migrations, each with its down migration")
+	for _, name := range names {
+		copyMigration(name)
+	}
+	require.NoError(t, RunMigrations(db, fsys))
+	_, err = db.Exec("INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 1)")
+	require.NoError(t, err)
+
+	copyMigration("008_encounter_sets.sql")
+	require.NoError(t, RunMigrations(db, fsys))
+
+	var plays, modulars int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&plays))
+	assert.Equal(t, 1, plays)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM play_encounter_sets").Scan(&modulars))
+	assert.Zero(t, modulars, "existing plays have no modular sets recorded")
+
+	_, err = db.Exec("INSERT INTO play_encounter_sets (play_id, encounter_set_id) SELECT 1, id FROM encounter_sets WHERE name = 'Bomb Scare'")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM plays WHERE id = 1")
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM play_encounter_sets").Scan(&modulars))
+	assert.Zero(t, modulars, "deleting a play removes its modular sets")
+}
+
+func TestPlayersMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	fsys := fstest.MapFS{}
+	copyMigration := func(name string) {
+		content, err := fs.ReadFile(migrations.FS, name)
+		require.NoError(t, err)
+		fsys[name] = &fstest.MapFile{Data: content}
+	}
+
+	names, err := fs.Glob(migrations.FS, "00[1-9]_*.sql")
+	require.NoError(t, err)
+	require.Len(t, names, 18, "nine migrations, each with its down migration")
+	for _, name := range names {
+		copyMigration(name)
+	}
+	require.NoError(t, RunMigrations(db, fsys))
+	_, err = db.Exec(`
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 1);
+		INSERT INTO decks (id, play_id, hero_id, player_name) VALUES (1, 1, 1, 'Sam'), (2, 1, 2, ' sam '), (3, 1, 3, NULL), (4, 1, 4, 'Alex');
+	`)
+	require.NoError(t, err)
+
+	copyMigration("010_players.sql")
+	require.NoError(t, RunMigrations(db, fsys))
+
+	var players int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM players").Scan(&players))
+	assert.Equal(t, 2, players, "names logged on decks become players, regardless of case")
+
+	var linked int
+	require.NoError(t, db.QueryRow(`
+		SELECT COUNT(*) FROM decks d JOIN players pl ON pl.id = d.player_id WHERE pl.name = 'Sam'`).Scan(&linked))
+	assert.Equal(t, 2, linked)
+
+	var unlinked sql.NullInt64
+	require.NoError(t, db.QueryRow("SELECT player_id FROM decks WHERE id = 3").Scan(&unlinked))
+	assert.False(t, unlinked.Valid, "decks without a player name stay unlinked")
+
+	_, err = db.Exec("DELETE FROM players WHERE name = 'Alex'")
+	require.NoError(t, err)
+	var playerName string
+	require.NoError(t, db.QueryRow("SELECT player_id, player_name FROM decks WHERE id = 4").Scan(&unlinked, &playerName))
+	assert.False(t, unlinked.Valid, "deleting a player unlinks their decks")
+	assert.Equal(t, "Alex", playerName)
+}
+
+func TestPlayerOwnersMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m, err := NewMigrator(db, migrations.FS)
+	require.NoError(t, err)
+	require.NoError(t, m.Goto(14))
+	_, err = db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', 'x'), (2, 'bob', 'x');
+		INSERT INTO players (id, name) VALUES (1, 'Sam'), (2, 'Alex');
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id, owner_id) VALUES
+			(1, '2024-01-01', 'win', 'Standard I', 1, 1),
+			(2, '2024-01-02', 'win', 'Standard I', 1, 2);
+		INSERT INTO decks (id, play_id, hero_id, player_id, player_name) VALUES (1, 1, 1, 1, 'Sam'), (2, 2, 1, 1, 'Sam');
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(15))
+
+	ownerOf := func(playerID int) int {
+		var owner int
+		require.NoError(t, db.QueryRow("SELECT owner_id FROM players WHERE id = ?", playerID).Scan(&owner))
+		return owner
+	}
+	playerOf := func(deckID int) int {
+		var player int
+		require.NoError(t, db.QueryRow("SELECT player_id FROM decks WHERE id = ?", deckID).Scan(&player))
+		return player
+	}
+	assert.Equal(t, 1, ownerOf(1), "a player goes to the owner of their first play")
+	assert.Equal(t, 1, ownerOf(2), "a player who has not played goes to the first user")
+	assert.Equal(t, 1, playerOf(1))
+	copied := playerOf(2)
+	assert.NotEqual(t, 1, copied, "another user's deck links to their own copy of the player")
+	assert.Equal(t, 2, ownerOf(copied))
+
+	_, err = db.Exec("INSERT INTO players (name, owner_id) VALUES ('Alex', 2)")
+	assert.NoError(t, err, "names are unique per owner")
+	_, err = db.Exec("INSERT INTO players (name, owner_id) VALUES ('alex', 1)")
+	assert.Error(t, err, "names are unique per owner regardless of case")
+
+	_, err = db.Exec("DELETE FROM players WHERE owner_id = 2 AND name = 'Alex'")
+	require.NoError(t, err)
+	require.NoError(t, m.Down(1))
+	var players int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM players").Scan(&players))
+	assert.Equal(t, 2, players, "undoing merges the copies back by name")
+	assert.Equal(t, 1, playerOf(1))
+	assert.Equal(t, 1, playerOf(2))
+}
+
+func TestCatalogReleasesMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m, err := NewMigrator(db, migrations.FS)
+	require.NoError(t, err)
+	require.NoError(t, m.Goto(15))
+	_, err = db.Exec(`
+		INSERT INTO heroes (id, name) VALUES (500, 'Bishop'), (501, 'magik');
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id) VALUES (1, '2024-01-01', 'win', 'Standard I', 1);
+		INSERT INTO decks (id, play_id, hero_id) VALUES (1, 1, 500);
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(16))
+
+	var undated int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM packs WHERE released_on IS NULL").Scan(&undated))
+	assert.Zero(t, undated, "every pack has a release date")
+
+	var pack string
+	require.NoError(t, db.QueryRow("SELECT p.name FROM heroes h JOIN packs p ON p.id = h.pack_id WHERE h.id = 500").Scan(&pack))
+	assert.Equal(t, "Age of Apocalypse", pack, "a user-added hero keeps its id and joins the catalog")
+	require.NoError(t, db.QueryRow("SELECT p.name FROM heroes h JOIN packs p ON p.id = h.pack_id WHERE h.id = 501").Scan(&pack))
+	assert.Equal(t, "Age of Apocalypse", pack, "names are matched ignoring case")
+	var magiks int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes WHERE name = 'Magik' COLLATE NOCASE").Scan(&magiks))
+	assert.Equal(t, 1, magiks)
+	var scenarios int
+	require.NoError(t, db.QueryRow(`
+		SELECT COUNT(*) FROM scenarios s JOIN packs p ON p.id = s.pack_id WHERE p.name = 'Agents of S.H.I.E.L.D.'`).Scan(&scenarios))
+	assert.Equal(t, 5, scenarios)
+
+	require.NoError(t, m.Down(1))
+	var packID sql.NullInt64
+	require.NoError(t, db.QueryRow("SELECT pack_id FROM heroes WHERE id = 500").Scan(&packID))
+	assert.False(t, packID.Valid, "a played hero stays, without its pack")
+	require.NoError(t, db.QueryRow("SELECT pack_id FROM heroes WHERE id = 501").Scan(&packID))
+	assert.False(t, packID.Valid, "an unplayed user-added hero stays, without its pack")
+	var heroes, packs int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes WHERE name = 'Iceman'").Scan(&heroes))
+	assert.Zero(t, heroes, "unplayed heroes are removed")
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM packs WHERE released_on IS NOT NULL OR wave > 7").Scan(&packs))
+	assert.Zero(t, packs)
+}
+
+func TestUserAdminsMigration(t *testing.T) {
+	db, err := sql.Open("sqlite3", dsnWithForeignKeys(":memory:"))
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m, err := NewMigrator(db, migrations.FS)
+	require.NoError(t, err)
+	require.NoError(t, m.Goto(16))
+	_, err = db.Exec("INSERT INTO users (id, username, password_hash) VALUES (2, 'alice', ''), (3, 'bob', '')")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(17))
+	var admins []string
+	rows, err := db.Query("SELECT username FROM users WHERE is_admin = 1")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		admins = append(admins, name)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"alice"}, admins, "the first user is the admin")
+}
+
+// schemaOf describes every table of db other than migrations by its
+// columns, indexes and triggers, to compare schemas however the SQL that
+// created them was written.
+func schemaOf(t *testing.T, db *sql.DB) map[string][]string {
+	rows, err := db.Query(`
+		SELECT type, name, tbl_name FROM sqlite_master
+		WHERE name NOT IN ('migrations', 'sqlite_sequence') AND name NOT LIKE 'sqlite_autoindex_%'`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	schema := make(map[string][]string)
+	var tables []string
+	for rows.Next() {
+		var kind, name, table string
+		require.NoError(t, rows.Scan(&kind, &name, &table))
+		if kind == "table" {
+			tables = append(tables, name)
+			continue
+		}
+		schema[table] = append(schema[table], kind+" "+name)
+	}
+	require.NoError(t, rows.Err())
+
+	for _, table := range tables {
+		columns, err := db.Query("SELECT name, type, \"notnull\", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?)", table)
+		require.NoError(t, err)
+		for columns.Next() {
+			var name, dataType, dflt string
+			var notNull, pk int
+			require.NoError(t, columns.Scan(&name, &dataType, &notNull, &dflt, &pk))
+			schema[table] = append(schema[table], fmt.Sprintf("column %s %s %d %s %d", name, dataType, notNull, dflt, pk))
+		}
+		require.NoError(t, columns.Err())
+		columns.Close()
+	}
+	for table := range schema {
+		sort.Strings(schema[table])
+	}
+	return schema
+}
+
+func TestShippedMigrationsUpAndDown(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	m, err := NewMigrator(db, migrations.FS)
+	require.NoError(t, err)
+	fts5, err := fts5Available(db)
+	require.NoError(t, err)
+
+	// Apply the migrations one at a time, checking that each one's down
+	// migration restores the schema from before it and that it applies
+	// again after being undone. Catalog updates only change rows.
+	dataOnly := map[string]bool{"016_catalog_releases.sql": true}
+	var versions []int
+	for _, mig := range m.Migrations() {
+		require.NotEmpty(t, mig.Down, "%s has a down migration", mig.Name)
+		if mig.RequiresFTS5() && !fts5 {
+			continue
+		}
+		versions = append(versions, mig.Version)
+
+		before := schemaOf(t, db)
+		require.NoError(t, m.Goto(mig.Version), mig.Name)
+		after := schemaOf(t, db)
+		if !dataOnly[mig.Name] {
+			require.NotEqual(t, before, after, mig.Name)
+		}
+
+		require.NoError(t, m.Down(1), mig.Name)
+		assert.Equal(t, before, schemaOf(t, db), "%s is undone", mig.Name)
+		require.NoError(t, m.Goto(mig.Version), mig.Name)
+		assert.Equal(t, after, schemaOf(t, db), "%s applies again", mig.Name)
+		require.NoError(t, m.Redo(), mig.Name)
+		assert.Equal(t, after, schemaOf(t, db), "%s is redone", mig.Name)
+	}
+	assert.Equal(t, versions[len(versions)-1], m.Version())
+
+	// Undo everything with a play logged, going through the migrations that
+	// move data between tables.
+	_, err = db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', '');
+		INSERT INTO players (id, name) VALUES (1, 'Sam');
+		INSERT INTO plays (id, date, outcome, difficulty, scenario_id, owner_id, notes) VALUES (1, '2024-01-01', 'win', 'Standard I', (SELECT MIN(id) FROM scenarios), 1, 'Close');
+		INSERT INTO decks (id, play_id, hero_id, player_id, player_name) VALUES (1, 1, (SELECT MIN(id) FROM heroes), 1, 'Sam');
+		INSERT INTO deck_aspects (deck_id, aspect_id) SELECT 1, id FROM aspects WHERE name IN ('pool', 'justice', 'aggression');`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Goto(4))
+	var aspect, playerName string
+	require.NoError(t, db.QueryRow("SELECT aspect, player_name FROM decks WHERE id = 1").Scan(&aspect, &playerName))
+	assert.Equal(t, "justice", aspect, "a deck keeps its first classic aspect")
+	assert.Equal(t, "Sam", playerName)
+	var heroes int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes").Scan(&heroes))
+	assert.Greater(t, heroes, 1)
+
+	require.NoError(t, m.Goto(1))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes").Scan(&heroes))
+	assert.Equal(t, 1, heroes, "only the catalog hero that was played stays")
+
+	require.NoError(t, m.Goto(0))
+	assert.Empty(t, schemaOf(t, db))
+	assert.Zero(t, m.Version())
+	var recorded int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations").Scan(&recorded))
+	assert.Zero(t, recorded)
+
+	require.NoError(t, m.Up())
+	assert.Equal(t, versions[len(versions)-1], m.Version())
+}
+
+func TestMigrator(t *testing.T) {
+	setup := func(t *testing.T, files map[string]string) (*sql.DB, *Migrator) {
+		db := setupTestDB(t)
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() { db.Close() })
+
+		fsys := fstest.MapFS{}
+		for name, content := range files {
+			fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		}
+		m, err := NewMigrator(db, fsys)
+		require.NoError(t, err)
+		return db, m
+	}
+	files := map[string]string{
+		"001_users.sql":      "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"001_users.down.sql": "DROP TABLE users;",
+		"002_posts.sql":      "CREATE TABLE posts (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_posts ON posts(id);",
+		"002_posts.down.sql": "DROP TABLE posts;",
+		"003_tags.sql":       "CREATE TABLE tags (id INTEGER PRIMARY KEY);",
+	}
+	tableExists := func(t *testing.T, db *sql.DB, name string) bool {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n))
+		return n > 0
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		_, m := setup(t, files)
+		require.NoError(t, m.Goto(2))
+
+		migrations := m.Migrations()
+		require.Len(t, migrations, 3)
+		assert.Equal(t, []int{1, 2, 3}, []int{migrations[0].Version, migrations[1].Version, migrations[2].Version})
+		assert.True(t, migrations[1].Applied)
+		assert.False(t, migrations[1].AppliedAt.IsZero())
+		assert.False(t, migrations[2].Applied)
+		assert.Equal(t, "DROP TABLE posts;", migrations[1].Down)
+		assert.Empty(t, migrations[2].Down)
+		assert.Equal(t, 2, m.Version())
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		db, m := setup(t, files)
+		require.NoError(t, m.Up())
+
+		err := m.Down(1)
+		assert.EqualError(t, err, "migration 003_tags.sql has no down migration")
+		assert.True(t, tableExists(t, db, "tags"))
+
+		assert.EqualError(t, m.Goto(2), "migration 003_tags.sql has no down migration")
+	})
+
+	t.Run("Goto", func(t *testing.T) {
+		db, m := setup(t, files)
+		require.NoError(t, m.Goto(2))
+		assert.True(t, tableExists(t, db, "posts"))
+		assert.False(t, tableExists(t, db, "tags"))
+
+		require.NoError(t, m.Goto(1))
+		assert.False(t, tableExists(t, db, "posts"))
+		assert.Equal(t, 1, m.Version())
+
+		assert.EqualError(t, m.Goto(7), "there is no migration 007")
+		assert.EqualError(t, m.Down(2), "cannot undo 2 migrations: 1 are applied")
+	})
+
+	t.Run("Dry Run", func(t *testing.T) {
+		db, m := setup(t, files)
+		require.NoError(t, m.Goto(1))
+
+		var out strings.Builder
+		m.DryRun, m.Out = true, &out
+		require.NoError(t, m.Up())
+		assert.Equal(t, "-- 002_posts.sql\nCREATE TABLE posts (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_posts ON posts(id);\n"+
+			"-- 003_tags.sql\nCREATE TABLE tags (id INTEGER PRIMARY KEY);\n", out.String())
+		assert.False(t, tableExists(t, db, "posts"))
+		assert.Equal(t, 1, m.Version())
+
+		out.Reset()
+		require.NoError(t, m.Redo())
+		assert.Equal(t, "-- 001_users.down.sql\nDROP TABLE users;\n-- 001_users.sql\nCREATE TABLE users (id INTEGER PRIMARY KEY);\n", out.String())
+		assert.True(t, tableExists(t, db, "users"))
+	})
+
+	t.Run("Unpaired Down Migration", func(t *testing.T) {
+		db := setupTestDB(t)
+		defer db.Close()
+		fsys := fstest.MapFS{"004_gone.down.sql": {Data: []byte("SELECT 1;")}}
+		_, err := NewMigrator(db, fsys)
+		assert.EqualError(t, err, "down migration 004_gone.down.sql has no up migration")
+	})
 }
@@ -0,0 +1,140 @@
+package config
+
+import "strings"
+
+// splitStatements splits a migration into the SQL statements it holds.
+// A semicolon ends a statement except inside a string literal, a quoted
+// identifier, a comment or the BEGIN ... END body of a CREATE TRIGGER.
+// Statements are trimmed and keep their comments; the semicolon that ends
+// them is dropped, as are statements holding nothing but comments.
+func splitStatements(script string) []string {
+	var statements []string
+	var s statementState
+	start := 0
+	for i := 0; i < len(script); {
+		ch := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "--"):
+			i = skipPast(script, i+2, "\n")
+			continue
+		case strings.HasPrefix(script[i:], "/*"):
+			i = skipPast(script, i+2, "*/")
+			continue
+		case ch == '\'' || ch == '"' || ch == '`':
+			i = skipQuoted(script, i)
+			s.hasCode = true
+			continue
+		case ch == '[':
+			i = skipPast(script, i+1, "]")
+			s.hasCode = true
+			continue
+		case isWordByte(ch):
+			end := i
+			for end < len(script) && isWordByte(script[end]) {
+				end++
+			}
+			s.word(strings.ToUpper(script[i:end]))
+			i = end
+			continue
+		case ch == ';' && !s.inBody:
+			if s.hasCode {
+				statements = append(statements, strings.TrimSpace(script[start:i]))
+			}
+			s = statementState{}
+			start = i + 1
+		case ch != ' ' && ch != '\t' && ch != '\n' && ch != '\r':
+			s.hasCode = true
+		}
+		i++
+	}
+	if s.hasCode {
+		statements = append(statements, strings.TrimSpace(script[start:]))
+	}
+	return statements
+}
+
+// statementState follows the words of the statement splitStatements is
+// in, to tell the semicolons inside a trigger body from the one that ends
+// the statement.
+type statementState struct {
+	// hasCode is set once the statement holds more than comments and
+	// white space.
+	hasCode bool
+	// lead holds the statement's first three words, upper-cased.
+	lead []string
+	// trigger is set for a CREATE TRIGGER statement, and inBody while in
+	// its BEGIN ... END body. cases counts the CASE expressions open in the
+	// body, whose END does not close it.
+	trigger bool
+	inBody  bool
+	cases   int
+}
+
+func (s *statementState) word(w string) {
+	s.hasCode = true
+	if len(s.lead) < 3 {
+		s.lead = append(s.lead, w)
+		s.trigger = isCreateTrigger(s.lead)
+	}
+	if !s.trigger {
+		return
+	}
+	switch {
+	case w == "BEGIN" && !s.inBody:
+		s.inBody = true
+	case w == "CASE" && s.inBody:
+		s.cases++
+	case w == "END" && s.inBody:
+		if s.cases > 0 {
+			s.cases--
+		} else {
+			s.inBody = false
+		}
+	}
+}
+
+// isCreateTrigger reports whether a statement starting with the words lead
+// is CREATE [TEMP | TEMPORARY] TRIGGER.
+func isCreateTrigger(lead []string) bool {
+	if len(lead) < 2 || lead[0] != "CREATE" {
+		return false
+	}
+	if lead[1] == "TEMP" || lead[1] == "TEMPORARY" {
+		return len(lead) == 3 && lead[2] == "TRIGGER"
+	}
+	return lead[1] == "TRIGGER"
+}
+
+// isWordByte reports whether ch may be part of a keyword or unquoted
+// identifier. Bytes of multi-byte characters count, as SQLite allows them
+// in identifiers.
+func isWordByte(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 0x80 ||
+		('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
+
+// skipPast returns the index just past the first end in script at or after
+// i, or the length of script if there is none.
+func skipPast(script string, i int, end string) int {
+	if n := strings.Index(script[i:], end); n >= 0 {
+		return i + n + len(end)
+	}
+	return len(script)
+}
+
+// skipQuoted returns the index just past the quoted string or identifier
+// that starts at i. A doubled quote inside it stands for the quote itself.
+func skipQuoted(script string, i int) int {
+	quote := script[i]
+	for j := i + 1; j < len(script); j++ {
+		if script[j] != quote {
+			continue
+		}
+		if j+1 < len(script) && script[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(script)
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	for name, tc := range map[string]struct {
+		script string
+		want   []string
+	}{
+		"Statements": {
+			script: "CREATE TABLE a (id INTEGER);\nINSERT INTO a VALUES (1);",
+			want:   []string{"CREATE TABLE a (id INTEGER)", "INSERT INTO a VALUES (1)"},
+		},
+		"No Final Semicolon": {
+			script: "SELECT 1; SELECT 2",
+			want:   []string{"SELECT 1", "SELECT 2"},
+		},
+		"Comments": {
+			script: "-- requires: nothing; really\nSELECT 1; -- trailing; comment\n/* block; comment */\n-- only a comment;\n",
+			want:   []string{"-- requires: nothing; really\nSELECT 1"},
+		},
+		"Strings And Identifiers": {
+			script: `INSERT INTO "odd;name" ([semi;colon], ` + "`x;y`" + `) VALUES ('It''s; fine', '--not a comment');SELECT 2;`,
+			want:   []string{`INSERT INTO "odd;name" ([semi;colon], ` + "`x;y`" + `) VALUES ('It''s; fine', '--not a comment')`, "SELECT 2"},
+		},
+		"Trigger": {
+			script: `CREATE TRIGGER t AFTER INSERT ON a
+BEGIN
+    INSERT INTO log VALUES (new.id);
+    UPDATE a SET n = CASE WHEN new.id > 1 THEN 1 ELSE 0 END;
+END;
+SELECT 1;`,
+			want: []string{`CREATE TRIGGER t AFTER INSERT ON a
+BEGIN
+    INSERT INTO log VALUES (new.id);
+    UPDATE a SET n = CASE WHEN new.id > 1 THEN 1 ELSE 0 END;
+END`, "SELECT 1"},
+		},
+		"Temporary Trigger": {
+			script: "create temp trigger t after delete on a begin delete from b; end; select 1",
+			want:   []string{"create temp trigger t after delete on a begin delete from b; end", "select 1"},
+		},
+		"Transaction Keywords Outside Triggers": {
+			script: "BEGIN; SELECT 1; END;",
+			want:   []string{"BEGIN", "SELECT 1", "END"},
+		},
+		"Empty": {
+			script: "\n  ;; -- nothing\n",
+			want:   nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, splitStatements(tc.script))
+		})
+	}
+}
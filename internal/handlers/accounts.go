@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// LoginPage shows the login form. The next query parameter is where to go
+// once logged in.
+func LoginPage(c *gin.Context) {
+	renderAccountForm(c, http.StatusOK, "login.html", "Log In", "")
+}
+
+// Login checks the submitted username and password and starts a session,
+// then redirects to next.
+func Login(store *auth.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := store.Authenticate(c.PostForm("username"), c.PostForm("password"))
+		if errors.Is(err, auth.ErrInvalidLogin) {
+			renderAccountForm(c, http.StatusUnauthorized, "login.html", "Log In", err.Error())
+			return
+		}
+		if err == nil {
+			err = auth.LogIn(c, store, user)
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, safeNext(c.PostForm("next")))
+	}
+}
+
+// SignupPage shows the form to create an account.
+func SignupPage(c *gin.Context) {
+	renderAccountForm(c, http.StatusOK, "signup.html", "Sign Up", "")
+}
+
+// Signup creates an account, logs it in and redirects to next.
+func Signup(store *auth.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := store.Signup(c.PostForm("username"), c.PostForm("password"))
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderAccountForm(c, http.StatusBadRequest, "signup.html", "Sign Up", validationErr.Message)
+			return
+		}
+		if err == nil {
+			err = auth.LogIn(c, store, user)
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, safeNext(c.PostForm("next")))
+	}
+}
+
+// Logout ends the session and goes back to the home page.
+func Logout(store *auth.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := auth.LogOut(c, store); err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/")
+	}
+}
+
+// Account shows the logged-in user's settings. It must be behind
+// auth.RequireLogin.
+func Account(c *gin.Context) {
+	c.HTML(http.StatusOK, "account.html", gin.H{
+		"title": "Account",
+		"user":  auth.CurrentUser(c),
+		"saved": c.Query("saved") == "1",
+	})
+}
+
+// UpdateAccount saves the settings form: whether the user shares their
+// plays.
+func UpdateAccount(store *auth.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := auth.CurrentUser(c)
+		if err := store.SetSharesPlays(user.ID, c.PostForm("shares_plays") == "1"); err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/account?saved=1")
+	}
+}
+
+func renderAccountForm(c *gin.Context, status int, template, title, message string) {
+	next := c.Query("next")
+	if c.Request.Method == http.MethodPost {
+		next = c.PostForm("next")
+	}
+	c.HTML(status, template, gin.H{
+		"title":       title,
+		"username":    c.PostForm("username"),
+		"next":        safeNext(next),
+		"minPassword": auth.MinPasswordLength,
+		"error":       message,
+	})
+}
+
+// safeNext returns next if it is a path on this site, and the play list
+// otherwise, so that a link to the login page cannot send someone off to
+// another site once they have logged in.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/plays"
+	}
+	return next
+}
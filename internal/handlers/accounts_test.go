@@ -0,0 +1,122 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+func TestAccountHandlers(t *testing.T) {
+	s := newTestServer(t)
+	serve := s.serve
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == auth.CookieName && c.Value != "" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		w := serve(http.MethodGet, "/login?next=/plays/new", nil, visitor)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="next" value="/plays/new"`)
+
+		w = serve(http.MethodGet, "/signup?next=https://evil.example", nil, visitor)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `name="next" value="/plays"`, "only paths on this site are followed")
+	})
+
+	t.Run("Sign Up", func(t *testing.T) {
+		w := serve(http.MethodPost, "/signup", url.Values{"username": {"alice"}, "password": {"short"}, "next": {"/stats"}}, visitor)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "password must be at least 8 characters")
+		assert.Contains(t, w.Body.String(), `value="alice"`)
+
+		w = serve(http.MethodPost, "/signup", url.Values{"username": {"alice"}, "password": {"password1"}, "next": {"/stats"}}, visitor)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/stats", w.Header().Get("Location"))
+		require.NotNil(t, sessionCookie(w), "signing up logs in")
+
+		w = serve(http.MethodPost, "/signup", url.Values{"username": {"Alice"}, "password": {"password1"}}, visitor)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "that username is taken")
+	})
+
+	t.Run("Log In", func(t *testing.T) {
+		w := serve(http.MethodPost, "/login", url.Values{"username": {"alice"}, "password": {"wrong-password"}}, visitor)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "incorrect username or password")
+		assert.Nil(t, sessionCookie(w))
+
+		w = serve(http.MethodPost, "/login", url.Values{"username": {"alice"}, "password": {"password1"}, "next": {"//evil.example"}}, visitor)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/plays", w.Header().Get("Location"))
+		cookie := sessionCookie(w)
+		require.NotNil(t, cookie)
+
+		w = serve(http.MethodGet, "/account", nil, cookie)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<span class="font-semibold">alice</span>`)
+
+		w = serve(http.MethodPost, "/logout", nil, cookie)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		w = serve(http.MethodGet, "/account", nil, cookie)
+		assert.Equal(t, http.StatusSeeOther, w.Code, "the session has ended")
+		assert.Equal(t, "/login?next=%2Faccount", w.Header().Get("Location"))
+	})
+
+	t.Run("Share Plays", func(t *testing.T) {
+		w := serve(http.MethodPost, "/account", url.Values{"shares_plays": {"1"}}, nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/account?saved=1", w.Header().Get("Location"))
+
+		user, err := s.Auth.GetByID(s.viewer.UserID)
+		require.NoError(t, err)
+		assert.True(t, user.SharesPlays)
+
+		w = serve(http.MethodGet, "/account?saved=1", nil, nil)
+		assert.Contains(t, w.Body.String(), "Your settings have been saved.")
+		assert.Contains(t, w.Body.String(), "checked")
+
+		serve(http.MethodPost, "/account", url.Values{}, nil)
+		user, err = s.Auth.GetByID(s.viewer.UserID)
+		require.NoError(t, err)
+		assert.False(t, user.SharesPlays)
+	})
+}
+
+func TestPlaysArePerUser(t *testing.T) {
+	s := newTestServer(t)
+	get := s.get
+
+	bob, err := s.Auth.Signup("bob", "password1")
+	require.NoError(t, err)
+	bobPlay := &models.Play{Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I"}
+	require.NoError(t, s.Plays.For(models.Viewer{UserID: bob.ID}).CreateWithDecks(bobPlay, "Klaw", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+
+	assert.Contains(t, get("/plays").Body.String(), "No plays recorded yet.", "bob's plays are his own")
+
+	_, err = s.DB.Exec("UPDATE users SET shares_plays = 1 WHERE id = ?", bob.ID)
+	require.NoError(t, err)
+	body := get("/plays").Body.String()
+	assert.Contains(t, body, "Klaw", "bob shares his plays")
+	assert.Contains(t, body, "by bob")
+
+	id := strconv.Itoa(bobPlay.ID)
+	assert.Equal(t, http.StatusForbidden, get("/plays/"+id+"/edit").Code, "only bob may edit his plays")
+	assert.Equal(t, http.StatusForbidden, s.serve(http.MethodDelete, "/plays/"+id, nil, nil).Code)
+
+	_, err = s.DB.Exec("UPDATE users SET shares_plays = 0 WHERE id = ?", bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, get("/plays/"+id+"/edit").Code, "a play you cannot see is not found")
+}
@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// campaignForm holds the values submitted on the Start Campaign form.
+type campaignForm struct {
+	Name      string
+	PackID    int
+	Mode      string
+	StartedOn string
+	Notes     string
+}
+
+// campaignStepForm holds the values submitted on the Log Step form of a
+// campaign page. Hero rows are repeated log_* fields matched by position.
+type campaignStepForm struct {
+	PlayID   int
+	Final    bool
+	Heroes   []campaignHeroForm
+	Counters string
+}
+
+type campaignHeroForm struct {
+	Hero        string
+	HitPoints   string
+	Upgrades    string
+	Obligations string
+}
+
+// campaignScenario is one scenario of a campaign box with how the campaign
+// has fared against it so far.
+type campaignScenario struct {
+	Name     string
+	Attempts int
+	Won      bool
+}
+
+// Campaigns lists the campaigns the user may see with the form to start a new one.
+func Campaigns(repo *models.CampaignRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		form := campaignForm{Mode: models.CampaignModes[0], StartedOn: time.Now().Format("2006-01-02")}
+		renderCampaigns(c, http.StatusOK, repo, form, "")
+	}
+}
+
+// CreateCampaign starts a campaign and redirects to its page.
+func CreateCampaign(repo *models.CampaignRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		packID, _ := strconv.Atoi(c.PostForm("pack_id"))
+		form := campaignForm{
+			Name:      c.PostForm("name"),
+			PackID:    packID,
+			Mode:      c.PostForm("mode"),
+			StartedOn: c.PostForm("started_on"),
+			Notes:     c.PostForm("notes"),
+		}
+
+		campaign := models.Campaign{Name: form.Name, PackID: form.PackID, Mode: form.Mode, Notes: strings.TrimSpace(form.Notes)}
+		startedOn, err := time.Parse("2006-01-02", form.StartedOn)
+		if err != nil {
+			err = &models.ValidationError{Field: "started_on", Message: "start date must be in YYYY-MM-DD format"}
+		} else {
+			campaign.StartedOn = startedOn
+			err = repo.Create(&campaign)
+		}
+
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderCampaigns(c, http.StatusBadRequest, repo, form, validationErr.Message)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Redirect(http.StatusSeeOther, "/campaigns/"+strconv.Itoa(campaign.ID))
+	}
+}
+
+func renderCampaigns(c *gin.Context, status int, repo *models.CampaignRepository, form campaignForm, message string) {
+	campaigns, err := repo.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	boxes, err := repo.Boxes()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.HTML(status, "campaigns.html", gin.H{
+		"title":     "Campaigns",
+		"campaigns": campaigns,
+		"boxes":     boxes,
+		"modes":     models.CampaignModes,
+		"form":      form,
+		"error":     message,
+	})
+}
+
+// Campaign shows a campaign's progress through its box, every step logged
+// so far with the campaign log after it, and the form to log the next step.
+func Campaign(repo *models.CampaignRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		campaign, ok := loadCampaign(c, repo)
+		if !ok {
+			return
+		}
+		renderCampaign(c, http.StatusOK, repo, campaign, nil, "")
+	}
+}
+
+// LogCampaignStep adds a play and the campaign log after it as the next
+// step of a campaign. Invalid input re-renders the campaign page with a
+// message.
+func LogCampaignStep(repo *models.CampaignRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		campaign, ok := loadCampaign(c, repo)
+		if !ok {
+			return
+		}
+
+		form := readCampaignStepForm(c)
+		entry := models.CampaignEntry{CampaignID: campaign.ID, PlayID: form.PlayID, Final: form.Final}
+		var err error
+		entry.Log, err = form.log()
+		if err == nil && entry.PlayID == 0 {
+			err = &models.ValidationError{Field: "play_id", Message: "choose a play"}
+		}
+		if err == nil {
+			err = repo.AddEntry(&entry)
+		}
+		if errors.Is(err, models.ErrNotFound) {
+			// Someone else's campaign, which can be seen but not changed.
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderCampaign(c, http.StatusBadRequest, repo, campaign, &form, validationErr.Message)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Redirect(http.StatusSeeOther, "/campaigns/"+strconv.Itoa(campaign.ID))
+	}
+}
+
+// renderCampaign renders a campaign page. A nil form pre-fills the Log
+// Step form from the campaign log of the last step.
+func renderCampaign(c *gin.Context, status int, repo *models.CampaignRepository, campaign *models.Campaign, form *campaignStepForm, message string) {
+	box, err := repo.Box(campaign.PackID)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	entries, err := repo.Entries(campaign.ID)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	available, err := repo.AvailablePlays(campaign.ID)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var current models.CampaignLog
+	if len(entries) > 0 {
+		current = entries[len(entries)-1].Log
+	}
+
+	progress := make([]campaignScenario, len(box.Scenarios))
+	next := ""
+	for i, s := range box.Scenarios {
+		progress[i].Name = s.Name
+		for _, e := range entries {
+			if e.Play.ScenarioID == s.ID {
+				progress[i].Attempts++
+				progress[i].Won = progress[i].Won || e.Play.Outcome == "win"
+			}
+		}
+		if next == "" && !progress[i].Won {
+			next = s.Name
+		}
+	}
+
+	if form == nil {
+		form = &campaignStepForm{Heroes: heroForms(current), Counters: counterLines(current.Counters)}
+		// Offer the most recent play of the next scenario first.
+		for _, p := range available {
+			if p.Scenario == next {
+				form.PlayID = p.ID
+				break
+			}
+		}
+	}
+
+	c.HTML(status, "campaign.html", gin.H{
+		"title":     campaign.Name,
+		"campaign":  campaign,
+		"progress":  progress,
+		"next":      next,
+		"entries":   entries,
+		"current":   current,
+		"available": available,
+		"form":      form,
+		"error":     message,
+	})
+}
+
+// heroForms returns a Log Step hero row for every hero in log plus empty
+// rows up to MaxPlayers.
+func heroForms(log models.CampaignLog) []campaignHeroForm {
+	rows := make([]campaignHeroForm, 0, models.MaxPlayers)
+	for _, h := range log.Heroes {
+		row := campaignHeroForm{
+			Hero:        h.Hero,
+			Upgrades:    strings.Join(h.Upgrades, ", "),
+			Obligations: strings.Join(h.ObligationsRemoved, ", "),
+		}
+		if h.HitPoints != nil {
+			row.HitPoints = strconv.Itoa(*h.HitPoints)
+		}
+		rows = append(rows, row)
+	}
+	for len(rows) < models.MaxPlayers {
+		rows = append(rows, campaignHeroForm{})
+	}
+	return rows
+}
+
+func readCampaignStepForm(c *gin.Context) campaignStepForm {
+	playID, _ := strconv.Atoi(c.PostForm("play_id"))
+	form := campaignStepForm{
+		PlayID:   playID,
+		Final:    c.PostForm("final") == "1",
+		Counters: c.PostForm("counters"),
+	}
+	hitPoints := c.PostFormArray("log_hit_points")
+	upgrades := c.PostFormArray("log_upgrades")
+	obligations := c.PostFormArray("log_obligations")
+	for i, hero := range c.PostFormArray("log_hero") {
+		form.Heroes = append(form.Heroes, campaignHeroForm{
+			Hero:        hero,
+			HitPoints:   valueAt(hitPoints, i),
+			Upgrades:    valueAt(upgrades, i),
+			Obligations: valueAt(obligations, i),
+		})
+	}
+	return form
+}
+
+// log reads the campaign log from the form. Hero rows left empty are
+// ignored; upgrades and obligations are comma-separated, and counters are
+// written one per line as "Resources: 3".
+func (f campaignStepForm) log() (models.CampaignLog, error) {
+	var log models.CampaignLog
+	for _, row := range f.Heroes {
+		hero := strings.TrimSpace(row.Hero)
+		if hero == "" && strings.TrimSpace(row.HitPoints+row.Upgrades+row.Obligations) == "" {
+			continue
+		}
+		h := models.CampaignHero{
+			Hero:               hero,
+			Upgrades:           splitComma(row.Upgrades),
+			ObligationsRemoved: splitComma(row.Obligations),
+		}
+		if raw := strings.TrimSpace(row.HitPoints); raw != "" {
+			hp, err := strconv.Atoi(raw)
+			if err != nil {
+				return log, &models.ValidationError{Field: "log", Message: "hit points must be a whole number"}
+			}
+			h.HitPoints = &hp
+		}
+		log.Heroes = append(log.Heroes, h)
+	}
+
+	for _, line := range strings.Split(f.Counters, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, ":")
+		if i < 0 {
+			return log, &models.ValidationError{Field: "counters", Message: "write each counter as name: value, as in \"Resources: 3\""}
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return log, &models.ValidationError{Field: "counters", Message: "counter " + strings.TrimSpace(line[:i]) + " must be a whole number"}
+		}
+		if log.Counters == nil {
+			log.Counters = make(map[string]int)
+		}
+		log.Counters[strings.TrimSpace(line[:i])] = value
+	}
+	return log, nil
+}
+
+// counterLines writes the counters of a campaign log the way the Log Step
+// form reads them.
+func counterLines(counters map[string]int) string {
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + ": " + strconv.Itoa(counters[name])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func splitComma(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// loadCampaign looks up the campaign named by the :id route parameter. If
+// it cannot be found the request is aborted with a 404 and ok is false.
+func loadCampaign(c *gin.Context, repo *models.CampaignRepository) (*models.Campaign, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	campaign, err := repo.GetByID(id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+	return campaign, true
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+func TestCampaignHandlers(t *testing.T) {
+	s := newTestServer(t)
+	redSkull := strconv.Itoa(s.id(t, "packs", "The Rise of Red Skull"))
+
+	plays := s.Plays.For(s.viewer)
+	logPlay := func(scenario, outcome string) int {
+		play := &models.Play{Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Outcome: outcome, Difficulty: "Standard I"}
+		require.NoError(t, plays.CreateWithDecks(play, scenario, []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+		return play.ID
+	}
+
+	request := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		return s.serve(method, path, form, nil)
+	}
+
+	t.Run("List and Start Form", func(t *testing.T) {
+		w := request(http.MethodGet, "/campaigns", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "No campaigns yet.")
+		assert.Contains(t, body, `<option value="`+redSkull+`">The Rise of Red Skull</option>`)
+		assert.NotContains(t, body, "Core Set", "only campaign boxes are offered")
+	})
+
+	t.Run("Start Rejects Invalid Input", func(t *testing.T) {
+		w := request(http.MethodPost, "/campaigns", url.Values{"name": {"Run"}, "pack_id": {strconv.Itoa(s.id(t, "packs", "Core Set"))}, "started_on": {"2024-02-01"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "choose a campaign box")
+		assert.Contains(t, w.Body.String(), `value="Run"`)
+	})
+
+	var path string
+	var absorbingMan int
+	t.Run("Start", func(t *testing.T) {
+		w := request(http.MethodPost, "/campaigns", url.Values{
+			"name": {"Avengers Assemble"}, "pack_id": {redSkull}, "mode": {"expert"}, "started_on": {"2024-02-01"},
+		})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		path = w.Header().Get("Location")
+		assert.True(t, strings.HasPrefix(path, "/campaigns/"))
+
+		w = request(http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "The Rise of Red Skull, expert campaign")
+		assert.Contains(t, body, "Next scenario: <strong>Crossbones</strong>")
+		assert.Contains(t, body, "No plays of The Rise of Red Skull scenarios are waiting to be logged.")
+	})
+
+	t.Run("Log Steps", func(t *testing.T) {
+		crossbones := logPlay("Crossbones", "win")
+
+		w := request(http.MethodGet, path, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<option value="`+strconv.Itoa(crossbones)+`" selected>`)
+
+		w = request(http.MethodPost, path+"/entries", url.Values{
+			"play_id":        {strconv.Itoa(crossbones)},
+			"log_hero":       {"Spider-Man", ""},
+			"log_hit_points": {"lots", ""},
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "hit points must be a whole number")
+		assert.Contains(t, w.Body.String(), `value="lots"`)
+
+		w = request(http.MethodPost, path+"/entries", url.Values{
+			"play_id":         {strconv.Itoa(crossbones)},
+			"log_hero":        {"Spider-Man", ""},
+			"log_hit_points":  {"7", ""},
+			"log_upgrades":    {"Improvised Weapon, Shield Tech", ""},
+			"log_obligations": {"", ""},
+			"counters":        {"Resources: 2\n\n"},
+		})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, path, w.Header().Get("Location"))
+
+		w = request(http.MethodGet, path, nil)
+		body := w.Body.String()
+		assert.Contains(t, body, "7 HP")
+		assert.Contains(t, body, "Upgrades: Improvised Weapon, Shield Tech")
+		assert.Contains(t, body, "Resources: 2")
+		assert.Contains(t, body, "Next scenario: <strong>Absorbing Man</strong>")
+
+		// The next step starts from the current campaign log.
+		absorbingMan = logPlay("Absorbing Man", "loss")
+		w = request(http.MethodGet, path, nil)
+		body = w.Body.String()
+		assert.Contains(t, body, `name="log_upgrades" value="Improvised Weapon, Shield Tech"`)
+		assert.Contains(t, body, "Resources: 2</textarea>")
+	})
+
+	t.Run("Final Step", func(t *testing.T) {
+		w := request(http.MethodPost, path+"/entries", url.Values{"play_id": {strconv.Itoa(absorbingMan)}, "final": {"1"}})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+
+		w = request(http.MethodGet, path, nil)
+		body := w.Body.String()
+		assert.Contains(t, body, "Campaign lost")
+		assert.NotContains(t, body, "Log the next scenario")
+
+		w = request(http.MethodGet, "/campaigns", nil)
+		assert.Contains(t, w.Body.String(), "Avengers Assemble")
+	})
+
+	t.Run("Unknown Campaign", func(t *testing.T) {
+		w := request(http.MethodGet, "/campaigns/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// CatalogRepository is implemented by models.HeroRepository and
+// models.ScenarioRepository, which share the same management operations.
+type CatalogRepository interface {
+	Entries() ([]models.CatalogEntry, error)
+	Create(name string) (int, error)
+	Rename(id int, name string) error
+	SetArchived(id int, archived bool) error
+	Merge(fromID, intoID int) error
+}
+
+// CatalogPage describes one of the hero or scenario management pages.
+type CatalogPage struct {
+	Title    string
+	Singular string
+	Path     string
+	Repo     CatalogRepository
+}
+
+func HeroesPage(repo *models.HeroRepository) CatalogPage {
+	return CatalogPage{Title: "Heroes", Singular: "hero", Path: "/heroes", Repo: repo}
+}
+
+func ScenariosPage(repo *models.ScenarioRepository) CatalogPage {
+	return CatalogPage{Title: "Scenarios", Singular: "scenario", Path: "/scenarios", Repo: repo}
+}
+
+// ListCatalog renders the management page listing every entry.
+func ListCatalog(page CatalogPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderCatalog(c, http.StatusOK, page, "")
+	}
+}
+
+// CreateCatalogEntry adds a user-defined entry from the form's name field.
+func CreateCatalogEntry(page CatalogPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, err := page.Repo.Create(c.PostForm("name"))
+		finishCatalogChange(c, page, err)
+	}
+}
+
+// RenameCatalogEntry renames the entry named by the :id route parameter.
+func RenameCatalogEntry(page CatalogPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := catalogID(c)
+		if !ok {
+			return
+		}
+		finishCatalogChange(c, page, page.Repo.Rename(id, c.PostForm("name")))
+	}
+}
+
+// ArchiveCatalogEntry archives the entry named by the :id route parameter,
+// or restores it when the form's archived field is "false".
+func ArchiveCatalogEntry(page CatalogPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := catalogID(c)
+		if !ok {
+			return
+		}
+		archived := c.PostForm("archived") != "false"
+		finishCatalogChange(c, page, page.Repo.SetArchived(id, archived))
+	}
+}
+
+// MergeCatalogEntries merges the entry from_id into into_id, re-pointing
+// all plays that used it.
+func MergeCatalogEntries(page CatalogPage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fromID, err := parseOptionalID(c.PostForm("from_id"), "from_id")
+		if err == nil {
+			var intoID int
+			intoID, err = parseOptionalID(c.PostForm("into_id"), "into_id")
+			if err == nil && (fromID == 0 || intoID == 0) {
+				err = &models.ValidationError{Field: "from_id", Message: "choose two " + page.Title + " to merge"}
+			}
+			if err == nil {
+				err = page.Repo.Merge(fromID, intoID)
+			}
+		}
+		if errors.Is(err, models.ErrNotFound) {
+			err = &models.ValidationError{Field: "from_id", Message: "unknown " + page.Singular}
+		}
+		finishCatalogChange(c, page, err)
+	}
+}
+
+// finishCatalogChange redirects back to the management page after a
+// successful change, or re-renders it with the problem.
+func finishCatalogChange(c *gin.Context, page CatalogPage, err error) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		renderCatalog(c, http.StatusBadRequest, page, validationErr.Message)
+	case errors.Is(err, models.ErrNotFound):
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+	case err != nil:
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Redirect(http.StatusSeeOther, page.Path)
+	}
+}
+
+func renderCatalog(c *gin.Context, status int, page CatalogPage, message string) {
+	entries, err := page.Repo.Entries()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	user := auth.CurrentUser(c)
+	c.HTML(status, "catalog.html", gin.H{
+		"title":    page.Title,
+		"singular": page.Singular,
+		"path":     page.Path,
+		"entries":  entries,
+		"admin":    user != nil && user.Admin,
+		"error":    message,
+	})
+}
+
+func catalogID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
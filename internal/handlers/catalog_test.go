@@ -0,0 +1,128 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+)
+
+func TestCatalogHandlers(t *testing.T) {
+	s := newTestServer(t)
+	get, post := s.get, s.post
+	heroID := func(name string) int {
+		return s.id(t, "heroes", name)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		w := get("/heroes")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "<title>Heroes - Marvel Champions Play Tracker</title>")
+		assert.Contains(t, body, "Spider-Man")
+		assert.Contains(t, body, "Core Set")
+		assert.Contains(t, body, "Add a custom hero")
+
+		w = get("/scenarios")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Klaw")
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		w := post("/heroes", url.Values{"name": {"Squirrel Girl"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/heroes", w.Header().Get("Location"))
+
+		w = get("/heroes")
+		assert.Contains(t, w.Body.String(), `value="Squirrel Girl"`)
+		assert.Contains(t, w.Body.String(), "Custom")
+	})
+
+	t.Run("Create Duplicate", func(t *testing.T) {
+		w := post("/scenarios", url.Values{"name": {"rhino"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "already exists")
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		id := heroID("Squirrel Girl")
+		w := post("/heroes/"+strconv.Itoa(id)+"/rename", url.Values{"name": {"The Unbeatable Squirrel Girl"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, id, heroID("The Unbeatable Squirrel Girl"))
+
+		w = post("/heroes/1/rename", url.Values{"name": {"Spidey"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "official heroes cannot be renamed")
+
+		w = post("/heroes/999/rename", url.Values{"name": {"Nobody"}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Archive", func(t *testing.T) {
+		id := heroID("The Unbeatable Squirrel Girl")
+
+		w := post("/heroes/"+strconv.Itoa(id)+"/archive", url.Values{"archived": {"true"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Contains(t, get("/heroes").Body.String(), "Restore")
+
+		w = post("/heroes/"+strconv.Itoa(id)+"/archive", url.Values{"archived": {"false"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.NotContains(t, get("/heroes").Body.String(), "Restore")
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		require.Equal(t, http.StatusSeeOther, post("/heroes", url.Values{"name": {"Spidre-Man"}}).Code)
+		typo := heroID("Spidre-Man")
+
+		w := post("/heroes/merge", url.Values{"from_id": {strconv.Itoa(typo)}, "into_id": {"1"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.NotContains(t, get("/heroes").Body.String(), "Spidre-Man")
+
+		w = post("/heroes/merge", url.Values{"from_id": {"1"}, "into_id": {"2"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "cannot be merged away")
+
+		w = post("/heroes/merge", url.Values{"from_id": {"999"}, "into_id": {"1"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown hero")
+
+		w = post("/heroes/merge", url.Values{})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Changes Need An Admin", func(t *testing.T) {
+		// The test user signed up first, so is the admin; bob is not.
+		user, err := s.Auth.Signup("bob", "password1")
+		require.NoError(t, err)
+		token, err := s.Auth.CreateSession(user.ID)
+		require.NoError(t, err)
+		bob := &http.Cookie{Name: auth.CookieName, Value: token}
+
+		w := s.serve(http.MethodPost, "/heroes", url.Values{"name": {"Howard the Duck"}}, bob)
+		require.Equal(t, http.StatusSeeOther, w.Code, "any user may add a custom hero")
+		id := strconv.Itoa(heroID("Howard the Duck"))
+
+		for target, form := range map[string]url.Values{
+			"/heroes/" + id + "/rename":  {"name": {"Howard"}},
+			"/heroes/" + id + "/archive": {"archived": {"true"}},
+			"/heroes/merge":              {"from_id": {id}, "into_id": {"1"}},
+		} {
+			w := s.serve(http.MethodPost, target, form, bob)
+			assert.Equal(t, http.StatusForbidden, w.Code, target)
+			assert.Contains(t, w.Body.String(), "Access Denied", target)
+		}
+		heroID("Howard the Duck") // not renamed or merged away
+		assert.NotContains(t, get("/heroes").Body.String(), "Restore", "not archived")
+
+		body := s.serve(http.MethodGet, "/heroes", nil, bob).Body.String()
+		assert.NotContains(t, body, "Merge duplicates")
+		assert.NotContains(t, body, "/heroes/"+id+"/rename")
+		assert.NotContains(t, body, "Archive")
+		assert.Contains(t, get("/heroes").Body.String(), "Merge duplicates", "the admin sees the controls")
+	})
+}
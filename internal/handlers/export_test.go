@@ -0,0 +1,4 @@
+package handlers
+
+// PlaysPerPage exports playsPerPage to the handlers_test package.
+const PlaysPerPage = playsPerPage
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/middleware"
+	"marvel_tracker/internal/models"
+)
+
+// The group pages rely on middleware.RequireGroupRole for the :id routes:
+// it loads the group and turns away anyone whose role is too low, so the
+// handlers below only act on middleware.Group(c).
+
+// Groups lists the user's groups with the form to start one.
+func Groups(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderGroups(c, http.StatusOK, groups, "")
+	}
+}
+
+// CreateGroup starts a group from the form's name field with the user as
+// its owner, and opens it.
+func CreateGroup(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := groups.For(auth.Viewer(c)).Create(c.PostForm("name"))
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderGroups(c, http.StatusBadRequest, groups, validationErr.Message)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, groupPath(group.ID))
+	}
+}
+
+func renderGroups(c *gin.Context, status int, groups *models.GroupRepository, message string) {
+	all, err := groups.For(auth.Viewer(c)).GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.HTML(status, "groups.html", gin.H{
+		"title":  "Groups",
+		"groups": all,
+		"error":  message,
+	})
+}
+
+// Group shows a group's members to any member. Owners also see the forms
+// to manage members and the group's open invite links.
+func Group(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderGroup(c, http.StatusOK, groups, "")
+	}
+}
+
+// RenameGroup renames the group from the form's name field.
+func RenameGroup(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := middleware.Group(c)
+		finishGroupChange(c, groups, groups.Rename(group.ID, c.PostForm("name")), groupPath(group.ID))
+	}
+}
+
+// DeleteGroup removes the group. Its plays stay with the users who logged
+// them.
+func DeleteGroup(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		finishGroupChange(c, groups, groups.Delete(middleware.Group(c).ID), "/groups")
+	}
+}
+
+// CreateGroupInvite makes an invite link for the role in the form's role
+// field.
+func CreateGroupInvite(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := middleware.Group(c)
+		_, err := groups.For(auth.Viewer(c)).CreateInvite(group.ID, models.GroupRole(c.PostForm("role")))
+		finishGroupChange(c, groups, err, groupPath(group.ID))
+	}
+}
+
+// RevokeGroupInvite stops the invite link named by the :token route
+// parameter from working.
+func RevokeGroupInvite(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := middleware.Group(c)
+		finishGroupChange(c, groups, groups.RevokeInvite(group.ID, c.Param("token")), groupPath(group.ID))
+	}
+}
+
+// SetGroupRole changes the role of the member named by the :user route
+// parameter to the form's role field.
+func SetGroupRole(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := middleware.Group(c)
+		userID, ok := memberID(c)
+		if !ok {
+			return
+		}
+		err := groups.SetRole(group.ID, userID, models.GroupRole(c.PostForm("role")))
+		finishGroupChange(c, groups, err, groupPath(group.ID))
+	}
+}
+
+// RemoveGroupMember takes the member named by the :user route parameter
+// out of the group.
+func RemoveGroupMember(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := middleware.Group(c)
+		userID, ok := memberID(c)
+		if !ok {
+			return
+		}
+		finishGroupChange(c, groups, groups.RemoveMember(group.ID, userID), groupPath(group.ID))
+	}
+}
+
+// LeaveGroup takes the user out of the group. The plays they logged into
+// it stay there.
+func LeaveGroup(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := groups.RemoveMember(middleware.Group(c).ID, auth.Viewer(c).UserID)
+		finishGroupChange(c, groups, err, "/groups")
+	}
+}
+
+// memberID reads the :user route parameter. A malformed id is a 404.
+func memberID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("user"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// finishGroupChange redirects to next after a successful change to the
+// group, or re-renders the group page with the problem.
+func finishGroupChange(c *gin.Context, groups *models.GroupRepository, err error, next string) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		renderGroup(c, http.StatusBadRequest, groups, validationErr.Message)
+	case errors.Is(err, models.ErrNotFound):
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+	case err != nil:
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Redirect(http.StatusSeeOther, next)
+	}
+}
+
+func renderGroup(c *gin.Context, status int, groups *models.GroupRepository, message string) {
+	group := middleware.Group(c)
+	members, err := groups.Members(group.ID)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	var invites []models.GroupInvite
+	if group.Role == models.GroupOwner {
+		if invites, err = groups.Invites(group.ID); err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.HTML(status, "group.html", gin.H{
+		"title":   group.Name,
+		"group":   group,
+		"isOwner": group.Role == models.GroupOwner,
+		"userID":  auth.Viewer(c).UserID,
+		"members": members,
+		"invites": invites,
+		"roles":   models.GroupRoles,
+		"baseURL": baseURL(c),
+		"error":   message,
+	})
+}
+
+// Invite shows the group an invite link is for, with the button to join
+// it. An unknown or expired link is a 404.
+func Invite(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invite, err := groups.Invite(c.Param("token"))
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.HTML(http.StatusOK, "invite.html", gin.H{
+			"title":  "Join " + invite.Group,
+			"invite": invite,
+		})
+	}
+}
+
+// JoinGroup makes the user a member of the invite link's group and opens
+// it.
+func JoinGroup(groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := groups.For(auth.Viewer(c)).Join(c.Param("token"))
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, groupPath(group.ID))
+	}
+}
+
+// editableGroups returns the groups the user may log plays into, or none
+// for a visitor.
+func editableGroups(c *gin.Context, groups *models.GroupRepository) ([]models.Group, error) {
+	viewer := auth.Viewer(c)
+	if viewer.UserID == 0 {
+		return nil, nil
+	}
+	all, err := groups.For(viewer).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var editable []models.Group
+	for _, g := range all {
+		if g.Role.AtLeast(models.GroupEditor) {
+			editable = append(editable, g)
+		}
+	}
+	return editable, nil
+}
+
+func groupPath(id int) string {
+	return "/groups/" + strconv.Itoa(id)
+}
+
+// baseURL returns the scheme and host the request was made to, for links
+// that are copied out of the site such as invite links.
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
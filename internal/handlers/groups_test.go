@@ -0,0 +1,108 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+)
+
+func TestGroupHandlers(t *testing.T) {
+	s := newTestServer(t)
+	serve := s.serve
+
+	// Requests are made as the test user, who starts the group, unless
+	// they carry bob's session cookie.
+	user, err := s.Auth.Signup("bob", "password1")
+	require.NoError(t, err)
+	token, err := s.Auth.CreateSession(user.ID)
+	require.NoError(t, err)
+	bob := &http.Cookie{Name: auth.CookieName, Value: token}
+
+	logPlay := func(groupID string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		return serve(http.MethodPost, "/plays", url.Values{
+			"date": {"2024-05-01"}, "scenario_id": {"2"}, "difficulty": {"Standard I"}, "outcome": {"win"},
+			"group_id": {groupID}, "deck_row": {"0"}, "hero_id": {"1"}, "aspect_0": {"justice"},
+		}, cookie)
+	}
+
+	w := serve(http.MethodPost, "/groups", url.Values{"name": {""}}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "name is required")
+
+	w = serve(http.MethodPost, "/groups", url.Values{"name": {"Tuesday Table"}}, nil)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/groups/1", w.Header().Get("Location"))
+	assert.Contains(t, serve(http.MethodGet, "/groups", nil, nil).Body.String(), "Tuesday Table")
+
+	t.Run("Invite", func(t *testing.T) {
+		w := serve(http.MethodPost, "/groups/1/invites", url.Values{"role": {"editor"}}, nil)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		invites, err := s.Groups.Invites(1)
+		require.NoError(t, err)
+		require.Len(t, invites, 1)
+		body := serve(http.MethodGet, "/groups/1", nil, nil).Body.String()
+		assert.Contains(t, body, "/invite/"+invites[0].Token)
+
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/groups/1", nil, bob).Code, "bob is not a member yet")
+		w = serve(http.MethodGet, "/invite/"+invites[0].Token, nil, bob)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Join Tuesday Table")
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/invite/nope", nil, bob).Code)
+
+		w = serve(http.MethodPost, "/invite/"+invites[0].Token, nil, bob)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/groups/1", w.Header().Get("Location"))
+
+		w = serve(http.MethodGet, "/groups/1", nil, bob)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "tester")
+		assert.NotContains(t, w.Body.String(), "Invite links", "only owners manage invites")
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/groups/1/invites", url.Values{"role": {"editor"}}, bob).Code)
+	})
+
+	t.Run("Log A Play Into The Group", func(t *testing.T) {
+		assert.Contains(t, serve(http.MethodGet, "/plays/new", nil, bob).Body.String(), `<option value="1">Tuesday Table</option>`)
+
+		w := logPlay("1", bob)
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		body := serve(http.MethodGet, "/plays", nil, nil).Body.String()
+		assert.Contains(t, body, "by bob", "the group's plays are seen by every member")
+		assert.Contains(t, body, "Tuesday Table")
+
+		w = serve(http.MethodGet, "/stats?scope=group:1", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `value="group:1" selected`)
+		assert.Contains(t, w.Body.String(), "Klaw")
+		assert.NotContains(t, serve(http.MethodGet, "/stats?scope=me", nil, nil).Body.String(), "Klaw", "the test user has logged nothing")
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, "/stats?scope=everyone", nil, nil).Code)
+	})
+
+	t.Run("Roles", func(t *testing.T) {
+		w := serve(http.MethodPost, "/groups/1/members/"+strconv.Itoa(user.ID)+"/role", url.Values{"role": {"viewer"}}, nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		w = logPlay("1", bob)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "you cannot log plays into that group")
+
+		w = serve(http.MethodPost, "/groups/1/members/"+strconv.Itoa(s.viewer.UserID)+"/remove", nil, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "a group needs at least one owner")
+	})
+
+	t.Run("Leave And Delete", func(t *testing.T) {
+		w := serve(http.MethodPost, "/groups/1/leave", nil, bob)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/groups/1", nil, bob).Code)
+
+		w = serve(http.MethodPost, "/groups/1/delete", nil, nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/groups", w.Header().Get("Location"))
+		assert.Contains(t, serve(http.MethodGet, "/groups", nil, nil).Body.String(), "You are not in any groups yet.")
+	})
+}
@@ -1,9 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
 )
 
 func Home(c *gin.Context) {
@@ -12,15 +20,797 @@ func Home(c *gin.Context) {
 	})
 }
 
-func Plays(c *gin.Context) {
-	c.HTML(http.StatusOK, "plays.html", gin.H{
-		"title": "Plays",
-		"plays": []gin.H{},
+// playsPerPage is how many plays the plays page lists at a time.
+const playsPerPage = 25
+
+// Plays lists the plays the user may see with their scenario and heroes,
+// a page at a time, narrowed by the filters in the query string and in the
+// order of its sort and dir parameters, newest first by default. When the
+// filter form, a column header or the Load more link asks through HTMX,
+// only the table is rendered: the filter form and the headers target the
+// whole table, and their responses push the URL of the list so that it can
+// be shared, while Load more only adds rows.
+func Plays(plays *models.PlayRepository, heroes *models.HeroRepository, scenarios *models.ScenarioRepository, aspects *models.AspectRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, query := readPlayFilter(c)
+		order := readPlaySort(c, query)
+		after := parsePlayCursor(c.Query("after"), order)
+		page, next, err := plays.For(auth.Viewer(c)).ListPage(filter, order, after, playsPerPage)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		data := gin.H{
+			"plays":    page,
+			"filtered": !filter.IsZero(),
+			"next":     "",
+			"sort":     order.Key,
+			"asc":      order.Asc,
+			"sortURLs": playsSortURLs(query, order),
+		}
+		if !next.IsZero() {
+			query.Set("after", formatPlayCursor(next))
+			data["next"] = "/plays?" + query.Encode()
+			query.Del("after")
+		}
+
+		if c.GetHeader("HX-Request") == "true" {
+			switch c.GetHeader("HX-Target") {
+			case "plays-table":
+				listURL := "/plays"
+				if len(query) > 0 {
+					listURL += "?" + query.Encode()
+				}
+				c.Header("HX-Push-Url", listURL)
+				c.HTML(http.StatusOK, "plays_table.html", data)
+				return
+			case "plays-more":
+				c.HTML(http.StatusOK, "plays_page.html", data)
+				return
+			}
+		}
+
+		heroList, err := heroes.GetAll()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		scenarioList, err := scenarios.GetAll()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		aspectList, err := aspects.GetAll()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		playerCounts := make([]int, models.MaxPlayers)
+		for i := range playerCounts {
+			playerCounts[i] = i + 1
+		}
+
+		data["title"] = "Plays"
+		data["filter"] = filter
+		data["from"] = query.Get("from")
+		data["to"] = query.Get("to")
+		data["heroes"] = heroList
+		data["scenarios"] = scenarioList
+		data["aspects"] = aspectList
+		data["difficulties"] = models.Difficulties
+		data["playerCounts"] = playerCounts
+		c.HTML(http.StatusOK, "plays.html", data)
+	}
+}
+
+// readPlayFilter reads the filters of the plays page from the query
+// string, together with the query parameters of the filters it applied.
+// Values the filter form cannot send are ignored rather than rejected, so
+// an edited link still lists plays.
+func readPlayFilter(c *gin.Context) (models.PlayFilter, url.Values) {
+	var f models.PlayFilter
+	applied := url.Values{}
+	readID := func(name string, dest *int, limit int) {
+		raw := strings.TrimSpace(c.Query(name))
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 && (limit == 0 || n <= limit) {
+			*dest = n
+			applied.Set(name, raw)
+		}
+	}
+	readChoice := func(name string, dest *string, choices []string) {
+		if raw := c.Query(name); slices.Contains(choices, raw) {
+			*dest = raw
+			applied.Set(name, raw)
+		}
+	}
+	readDate := func(name string, dest *time.Time) {
+		raw := strings.TrimSpace(c.Query(name))
+		if date, err := time.Parse("2006-01-02", raw); err == nil {
+			*dest = date
+			applied.Set(name, raw)
+		}
+	}
+
+	readID("hero_id", &f.HeroID, 0)
+	readID("scenario_id", &f.ScenarioID, 0)
+	readID("players", &f.Players, models.MaxPlayers)
+	if aspect := strings.TrimSpace(c.Query("aspect")); aspect != "" {
+		f.Aspect = aspect
+		applied.Set("aspect", aspect)
+	}
+	readChoice("difficulty", &f.Difficulty, models.Difficulties)
+	readChoice("outcome", &f.Outcome, models.Outcomes)
+	readDate("from", &f.From)
+	readDate("to", &f.To)
+	return f, applied
+}
+
+// readPlaySort reads the sort and dir query parameters of the plays page,
+// and adds them to query unless they ask for the default order. An unknown
+// sort column lists the newest plays first.
+func readPlaySort(c *gin.Context, query url.Values) models.PlaySort {
+	key := c.Query("sort")
+	if !slices.Contains(models.PlaySortKeys, key) {
+		return models.PlaySort{Key: "date"}
+	}
+	order := models.PlaySort{Key: key, Asc: c.Query("dir") == "asc"}
+	if order != (models.PlaySort{Key: "date"}) {
+		query.Set("sort", key)
+		query.Set("dir", sortDir(order.Asc))
+	}
+	return order
+}
+
+// playsSortURLs returns, for each of models.PlaySortKeys, the link its
+// column header should point at: the list with the filters of query sorted
+// by that column, flipping the direction if it is already the sort column.
+// Dates and hero counts are sorted largest first to begin with, scenarios
+// and results alphabetically.
+func playsSortURLs(query url.Values, order models.PlaySort) map[string]string {
+	urls := make(map[string]string, len(models.PlaySortKeys))
+	for _, key := range models.PlaySortKeys {
+		asc := key == "scenario" || key == "result"
+		if key == order.Key {
+			asc = !order.Asc
+		}
+		sorted := url.Values{}
+		for name, values := range query {
+			sorted[name] = values
+		}
+		sorted.Set("sort", key)
+		sorted.Set("dir", sortDir(asc))
+		urls[key] = "/plays?" + sorted.Encode()
+	}
+	return urls
+}
+
+func sortDir(asc bool) string {
+	if asc {
+		return "asc"
+	}
+	return "desc"
+}
+
+// formatPlayCursor writes a page cursor of the plays list for the after
+// query parameter, as the sort column's value and the id of the page's
+// last play, such as 2024-03-01_42.
+func formatPlayCursor(cursor models.PlayCursor) string {
+	return cursor.Value + "_" + strconv.Itoa(cursor.ID)
+}
+
+// parsePlayCursor reads an after query parameter written by
+// formatPlayCursor for a list in the order order. An empty or malformed
+// one, or one whose value the sort column cannot hold, starts the list
+// from the top.
+func parsePlayCursor(raw string, order models.PlaySort) models.PlayCursor {
+	i := strings.LastIndex(raw, "_")
+	if i < 0 {
+		return models.PlayCursor{}
+	}
+	id, err := strconv.Atoi(raw[i+1:])
+	if err != nil {
+		return models.PlayCursor{}
+	}
+	cursor := models.PlayCursor{Value: raw[:i], ID: id}
+	if !order.ValidCursor(cursor) {
+		return models.PlayCursor{}
+	}
+	return cursor
+}
+
+// searchLimit is how many matches the plays search box shows.
+const searchLimit = 20
+
+// SearchPlays renders the matches for the plays search box: the plays the
+// user may see whose notes, scenario or heroes match the q query
+// parameter, best first, each with the matching words highlighted.
+func SearchPlays(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := strings.TrimSpace(c.Query("q"))
+		matches, err := repo.For(auth.Viewer(c)).Search(query, searchLimit)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.HTML(http.StatusOK, "play_search.html", gin.H{
+			"query":   query,
+			"matches": matches,
+		})
+	}
+}
+
+// playForm holds the raw values submitted on a play form so they can be
+// written back into the form when it is re-rendered with an error.
+type playForm struct {
+	Date            string
+	ScenarioID      int
+	Difficulty      string
+	Outcome         string
+	Notes           string
+	EndReason       string
+	Rounds          string
+	VillainStage    string
+	RemainingThreat string
+	EncounterSetIDs []int
+	GroupID         int
+	Decks           []deckForm
+}
+
+// deckForm holds the values of one hero row of the New Play form. Key
+// identifies the row within the form: hero_id, player_name and remaining_hp
+// are repeated fields matched to the row by position, while a row's aspects
+// are posted as aspect_<Key> so that each row can carry several.
+type deckForm struct {
+	Key         int
+	HeroID      int
+	Aspects     []string
+	PlayerName  string
+	RemainingHP string
+}
+
+// heroRow is the data for the hero_row.html partial: one row's values plus
+// the options for its inputs.
+type heroRow struct {
+	deckForm
+	Heroes     []models.Hero
+	AllAspects []models.Aspect
+}
+
+// HasAspect reports whether the row has the named aspect selected.
+func (r heroRow) HasAspect(name string) bool {
+	for _, aspect := range r.Aspects {
+		if aspect == name {
+			return true
+		}
+	}
+	return false
+}
+
+// encounterSetPicker is the data for the encounter_sets.html partial: the
+// modular sets offered on the New Play form, grouped by the pack they came
+// in, and the ids currently selected.
+type encounterSetPicker struct {
+	Groups   []encounterSetGroup
+	Selected []int
+}
+
+type encounterSetGroup struct {
+	Pack string
+	Sets []models.EncounterSet
+}
+
+// IsSelected reports whether the set with the given id is selected.
+func (p encounterSetPicker) IsSelected(id int) bool {
+	for _, selected := range p.Selected {
+		if selected == id {
+			return true
+		}
+	}
+	return false
+}
+
+// newEncounterSetPicker groups sets, which GetAll returns in pack order,
+// by pack. Sets added by users are grouped last under "Other".
+func newEncounterSetPicker(sets []models.EncounterSet, selected []int) encounterSetPicker {
+	picker := encounterSetPicker{Selected: selected}
+	for _, set := range sets {
+		pack := set.Pack
+		if pack == "" {
+			pack = "Other"
+		}
+		if n := len(picker.Groups); n == 0 || picker.Groups[n-1].Pack != pack {
+			picker.Groups = append(picker.Groups, encounterSetGroup{Pack: pack})
+		}
+		last := &picker.Groups[len(picker.Groups)-1]
+		last.Sets = append(last.Sets, set)
+	}
+	return picker
+}
+
+func readPlayForm(c *gin.Context) playForm {
+	scenarioID, _ := strconv.Atoi(c.PostForm("scenario_id"))
+	groupID, _ := strconv.Atoi(c.PostForm("group_id"))
+	form := playForm{
+		Date:       c.PostForm("date"),
+		ScenarioID: scenarioID,
+		Difficulty: c.PostForm("difficulty"),
+		Outcome:    c.PostForm("outcome"),
+		Notes:      c.PostForm("notes"),
+
+		EndReason:       c.PostForm("end_reason"),
+		Rounds:          c.PostForm("rounds"),
+		VillainStage:    c.PostForm("villain_stage"),
+		RemainingThreat: c.PostForm("remaining_threat"),
+		GroupID:         groupID,
+	}
+	for _, raw := range c.PostFormArray("encounter_set_id") {
+		if id, err := strconv.Atoi(raw); err == nil {
+			form.EncounterSetIDs = append(form.EncounterSetIDs, id)
+		}
+	}
+
+	heroIDs := c.PostFormArray("hero_id")
+	playerNames := c.PostFormArray("player_name")
+	remainingHPs := c.PostFormArray("remaining_hp")
+	for i, rawKey := range c.PostFormArray("deck_row") {
+		key, _ := strconv.Atoi(rawKey)
+		heroID, _ := strconv.Atoi(valueAt(heroIDs, i))
+		form.Decks = append(form.Decks, deckForm{
+			Key:         key,
+			HeroID:      heroID,
+			Aspects:     c.PostFormArray("aspect_" + rawKey),
+			PlayerName:  valueAt(playerNames, i),
+			RemainingHP: valueAt(remainingHPs, i),
+		})
+	}
+	return form
+}
+
+// NewPlay renders the New Play form with hero, aspect, scenario and
+// modular set options populated from the catalog, and the groups the user
+// may log the play into. The form starts with one hero row for each player
+// of the last play that recorded players, so a group that plays together
+// does not have to retype their names.
+func NewPlay(heroes *models.HeroRepository, scenarios *models.ScenarioRepository, aspects *models.AspectRepository, encounterSets *models.EncounterSetRepository, players *models.PlayerRepository, groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group, err := players.For(auth.Viewer(c)).LastGroup()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		var form playForm
+		for i, p := range group {
+			form.Decks = append(form.Decks, deckForm{Key: i, PlayerName: p.Name})
+		}
+		renderNewPlay(c, http.StatusOK, heroes, scenarios, aspects, encounterSets, players, groups, form, "")
+	}
+}
+
+// EncounterSetPicker renders the modular set select of the New Play form
+// with the recommended sets of the scenario_id query parameter selected.
+// The form fetches it whenever a scenario is chosen.
+func EncounterSetPicker(repo *models.EncounterSetRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sets, err := repo.GetAll()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var selected []int
+		if scenarioID, err := strconv.Atoi(c.Query("scenario_id")); err == nil {
+			if selected, err = repo.Recommended(scenarioID); err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		c.HTML(http.StatusOK, "encounter_sets.html", newEncounterSetPicker(sets, selected))
+	}
+}
+
+// HeroRow renders one more hero row for the New Play form. The form sends
+// its current deck_row keys along; the new row gets the next free key, and
+// nothing is returned once the form already has MaxPlayers rows.
+func HeroRow(heroRepo *models.HeroRepository, aspectRepo *models.AspectRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := c.QueryArray("deck_row")
+		if len(keys) >= models.MaxPlayers {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		next := 0
+		for _, raw := range keys {
+			if key, err := strconv.Atoi(raw); err == nil && key >= next {
+				next = key + 1
+			}
+		}
+
+		heroes, err := heroRepo.GetActive()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		aspects, err := aspectRepo.GetAll()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.HTML(http.StatusOK, "hero_row.html", heroRow{
+			deckForm:   deckForm{Key: next},
+			Heroes:     heroes,
+			AllAspects: aspects,
+		})
+	}
+}
+
+func renderNewPlay(c *gin.Context, status int, heroRepo *models.HeroRepository, scenarioRepo *models.ScenarioRepository, aspectRepo *models.AspectRepository, encounterSetRepo *models.EncounterSetRepository, playerRepo *models.PlayerRepository, groupRepo *models.GroupRepository, form playForm, message string) {
+	heroes, err := heroRepo.GetActive()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	scenarios, err := scenarioRepo.GetActive()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	aspects, err := aspectRepo.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	encounterSets, err := encounterSetRepo.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	players, err := playerRepo.For(auth.Viewer(c)).GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	groups, err := editableGroups(c, groupRepo)
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	decks := form.Decks
+	if len(decks) == 0 {
+		decks = []deckForm{{}}
+	}
+	rows := make([]heroRow, 0, len(decks))
+	for _, deck := range decks {
+		rows = append(rows, heroRow{deckForm: deck, Heroes: heroes, AllAspects: aspects})
+	}
+
+	c.HTML(status, "new_play.html", gin.H{
+		"title":         "New Play",
+		"form":          form,
+		"heroRows":      rows,
+		"encounterSets": newEncounterSetPicker(encounterSets, form.EncounterSetIDs),
+		"maxPlayers":    models.MaxPlayers,
+		"error":         message,
+		"scenarios":     scenarios,
+		"players":       players,
+		"groups":        groups,
+		"difficulties":  models.Difficulties,
+		"winReasons":    models.EndReasonsFor("win"),
+		"lossReasons":   models.EndReasonsFor("loss"),
 	})
 }
 
-func NewPlay(c *gin.Context) {
-	c.HTML(http.StatusOK, "new_play.html", gin.H{
-		"title": "New Play",
+// CreatePlay handles submissions of the New Play form. Invalid input
+// re-renders the form with a message; anything else redirects to the play
+// list once the play and its decks have been saved.
+func CreatePlay(plays *models.PlayRepository, heroes *models.HeroRepository, scenarios *models.ScenarioRepository, aspects *models.AspectRepository, encounterSets *models.EncounterSetRepository, players *models.PlayerRepository, groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		play, entries, err := parsePlayForm(c)
+		if err == nil {
+			err = play.Validate()
+		}
+		if err == nil && len(entries) == 0 {
+			err = &models.ValidationError{Field: "hero", Message: "add at least one hero"}
+		}
+		if err == nil {
+			err = plays.For(auth.Viewer(c)).CreateWithDecks(&play, "", entries)
+		}
+
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderNewPlay(c, http.StatusBadRequest, heroes, scenarios, aspects, encounterSets, players, groups, readPlayForm(c), validationErr.Message)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Redirect(http.StatusSeeOther, "/plays")
+	}
+}
+
+// parsePlayForm reads the play fields and the hero rows from the submitted
+// form (see deckForm for how rows are posted). Rows with neither a hero nor
+// an aspect are ignored so an unused hero row can be left empty.
+func parsePlayForm(c *gin.Context) (models.Play, []models.DeckEntry, error) {
+	date, err := time.Parse("2006-01-02", c.PostForm("date"))
+	if err != nil {
+		return models.Play{}, nil, &models.ValidationError{Field: "date", Message: "date must be in YYYY-MM-DD format"}
+	}
+
+	scenarioID, err := parseOptionalID(c.PostForm("scenario_id"), "scenario")
+	if err != nil {
+		return models.Play{}, nil, err
+	}
+	groupID, err := parseOptionalID(c.PostForm("group_id"), "group")
+	if err != nil {
+		return models.Play{}, nil, err
+	}
+
+	play := models.Play{
+		Date:       date,
+		Outcome:    c.PostForm("outcome"),
+		Difficulty: c.PostForm("difficulty"),
+		Notes:      strings.TrimSpace(c.PostForm("notes")),
+		ScenarioID: scenarioID,
+		EndReason:  c.PostForm("end_reason"),
+		GroupID:    groupID,
+	}
+	if play.Rounds, err = parseOptionalInt(c.PostForm("rounds"), "rounds", "rounds"); err != nil {
+		return models.Play{}, nil, err
+	}
+	if play.VillainStage, err = parseOptionalInt(c.PostForm("villain_stage"), "villain_stage", "villain stage"); err != nil {
+		return models.Play{}, nil, err
+	}
+	if play.RemainingThreat, err = parseOptionalInt(c.PostForm("remaining_threat"), "remaining_threat", "remaining threat"); err != nil {
+		return models.Play{}, nil, err
+	}
+	for _, raw := range c.PostFormArray("encounter_set_id") {
+		id, err := parseOptionalID(raw, "encounter_set")
+		if err != nil {
+			return models.Play{}, nil, err
+		}
+		if id != 0 {
+			play.EncounterSetIDs = append(play.EncounterSetIDs, id)
+		}
+	}
+
+	heroIDs := c.PostFormArray("hero_id")
+	playerNames := c.PostFormArray("player_name")
+	remainingHPs := c.PostFormArray("remaining_hp")
+	var entries []models.DeckEntry
+	for i, key := range c.PostFormArray("deck_row") {
+		rawID := valueAt(heroIDs, i)
+		aspects := c.PostFormArray("aspect_" + key)
+		if strings.TrimSpace(rawID) == "" && len(aspects) == 0 {
+			continue
+		}
+		heroID, err := parseOptionalID(rawID, "hero")
+		if err != nil {
+			return models.Play{}, nil, err
+		}
+		remainingHP, err := parseOptionalInt(valueAt(remainingHPs, i), "remaining_hp", "remaining hit points")
+		if err != nil {
+			return models.Play{}, nil, err
+		}
+		entries = append(entries, models.DeckEntry{
+			HeroID:      heroID,
+			Aspects:     aspects,
+			PlayerName:  valueAt(playerNames, i),
+			RemainingHP: remainingHP,
+		})
+	}
+
+	return play, entries, nil
+}
+
+// valueAt returns values[i], or "" if the slice is too short. Repeated form
+// fields are matched up by position.
+func valueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// parseOptionalID parses an id submitted from a dropdown. A blank value is
+// returned as zero so the repository can report the field as missing.
+func parseOptionalID(raw, field string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, &models.ValidationError{Field: field, Message: "unknown " + field}
+	}
+	return id, nil
+}
+
+// parseOptionalInt parses an optional number field. A blank value is
+// returned as nil; the model checks the range. label names the field in
+// the error message.
+func parseOptionalInt(raw, field, label string) (*int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, &models.ValidationError{Field: field, Message: label + " must be a whole number"}
+	}
+	return &n, nil
+}
+
+// PlayRow renders a single row of the plays table. It is used by HTMX to
+// swap an edit form back to the read-only row.
+func PlayRow(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, ok := loadPlaySummary(c, repo.For(auth.Viewer(c)))
+		if !ok {
+			return
+		}
+		c.HTML(http.StatusOK, "play_row.html", summary)
+	}
+}
+
+// EditPlay renders the inline edit form for a row of the plays table.
+// middleware.RequirePlayEditor decides who may edit a play.
+func EditPlay(plays *models.PlayRepository, scenarios *models.ScenarioRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, ok := loadPlaySummary(c, plays.For(auth.Viewer(c)))
+		if !ok {
+			return
+		}
+
+		form := playForm{
+			Date:       summary.Date.Format("2006-01-02"),
+			ScenarioID: summary.ScenarioID,
+			Difficulty: summary.Difficulty,
+			Outcome:    summary.Outcome,
+			Notes:      summary.Notes,
+
+			EndReason:       summary.EndReason,
+			Rounds:          formatOptionalInt(summary.Rounds),
+			VillainStage:    formatOptionalInt(summary.VillainStage),
+			RemainingThreat: formatOptionalInt(summary.RemainingThreat),
+		}
+		renderEditPlayRow(c, http.StatusOK, scenarios, summary, form, "")
+	}
+}
+
+// formatOptionalInt writes an optional number back into a form field.
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// UpdatePlay saves an inline edit and responds with the updated row, or
+// with the edit form and a message if the input is invalid.
+func UpdatePlay(plays *models.PlayRepository, scenarios *models.ScenarioRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plays := plays.For(auth.Viewer(c))
+		summary, ok := loadPlaySummary(c, plays)
+		if !ok {
+			return
+		}
+
+		play, _, err := parsePlayForm(c)
+		if err == nil {
+			play.ID = summary.ID
+			err = plays.Update(&play, "")
+		}
+
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderEditPlayRow(c, http.StatusBadRequest, scenarios, summary, readPlayForm(c), validationErr.Message)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		updated, err := plays.GetSummary(summary.ID)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.HTML(http.StatusOK, "play_row.html", updated)
+	}
+}
+
+// DeletePlay removes a play the user may change and its decks. The empty
+// response lets HTMX remove the row from the table.
+func DeletePlay(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		err = repo.For(auth.Viewer(c)).Delete(id)
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Status(http.StatusOK)
+	}
+}
+
+func renderEditPlayRow(c *gin.Context, status int, scenarioRepo *models.ScenarioRepository, summary *models.PlaySummary, form playForm, message string) {
+	scenarios, err := scenarioRepo.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.HTML(status, "play_edit_row.html", gin.H{
+		"id":           summary.ID,
+		"heroes":       summary.Heroes,
+		"scenarios":    scenarios,
+		"form":         form,
+		"error":        message,
+		"difficulties": models.Difficulties,
+		"endReasons":   models.EndReasons,
 	})
 }
+
+// loadPlaySummary looks up the play named by the :id route parameter. If it
+// cannot be found the request is aborted with a 404 and ok is false.
+func loadPlaySummary(c *gin.Context, repo *models.PlayRepository) (*models.PlaySummary, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+
+	summary, err := repo.GetSummary(id)
+	if errors.Is(err, models.ErrNotFound) {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+	return summary, true
+}
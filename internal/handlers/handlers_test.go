@@ -1,64 +1,181 @@
-package handlers
+package handlers_test
 
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/handlers"
+	"marvel_tracker/internal/models"
 )
 
-// setupTestRouter initializes a Gin router for testing, loading all templates.
-func setupTestRouter() *gin.Engine {
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-
-	// Load templates explicitly to avoid conflicts between content blocks
-	r.LoadHTMLFiles(
-		"../../templates/index.html",
-		"../../templates/plays.html",
-		"../../templates/new_play.html",
-		"../../templates/error.html",
-	)
-
-	return r
-}
-
 func TestHomeHandler(t *testing.T) {
-	r := setupTestRouter()
-	r.GET("/", Home)
-
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/", nil)
-	r.ServeHTTP(w, req)
-
-	t.Logf("Response Body for Home Handler:\n%s", w.Body.String())
+	s := newTestServer(t)
+	w := s.serve(http.MethodGet, "/", nil, visitor)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Welcome to Marvel Champions Play Tracker")
 }
 
 func TestPlaysHandler(t *testing.T) {
-	r := setupTestRouter()
-	r.GET("/plays", Plays)
+	s := newTestServer(t)
+	db := s.DB
+	repo := s.Plays.For(s.viewer)
 
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/plays", nil)
-	r.ServeHTTP(w, req)
+	t.Run("Empty", func(t *testing.T) {
+		w := s.get("/plays")
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "Play History")
-	assert.Contains(t, w.Body.String(), "No plays recorded yet.")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Play History")
+		assert.Contains(t, w.Body.String(), "No plays recorded yet.")
+	})
+
+	t.Run("With Plays", func(t *testing.T) {
+		play := &models.Play{
+			Date:       time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Expert I",
+		}
+		err := repo.CreateWithDecks(play, "Klaw", []models.DeckEntry{
+			{HeroName: "Black Panther", Aspects: []string{"protection"}},
+			{HeroName: "Ms. Marvel", Aspects: []string{"justice"}},
+		})
+		require.NoError(t, err)
+
+		w := s.get("/plays")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.NotContains(t, body, "No plays recorded yet.")
+		assert.Contains(t, body, "May 4, 2024")
+		assert.Contains(t, body, "Klaw")
+		assert.Contains(t, body, "Black Panther")
+		assert.Contains(t, body, "(protection)")
+		assert.Contains(t, body, "Ms. Marvel")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		play := &models.Play{
+			Date:       time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			Outcome:    "loss",
+			Difficulty: "Standard I",
+		}
+		require.NoError(t, repo.CreateWithDecks(play, "Rhino", []models.DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}},
+		}))
+
+		w := s.get("/plays?outcome=loss&players=1&aspect=justice&from=2024-06-01&hero_id=bogus")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Jun 1, 2024")
+		assert.NotContains(t, body, "May 4, 2024")
+		assert.Contains(t, body, `<option value="loss" selected>Losses</option>`)
+		assert.Contains(t, body, `<option value="1" selected>1</option>`)
+		assert.Contains(t, body, `<option value="justice" selected>justice</option>`)
+		assert.Contains(t, body, `name="from" value="2024-06-01"`)
+
+		w = s.get("/plays?outcome=win&from=2024-06-01")
+		assert.Contains(t, w.Body.String(), "No plays match these filters.")
+		assert.NotContains(t, w.Body.String(), "No plays recorded yet.")
+	})
+
+	swapTable := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("HX-Request", "true")
+		req.Header.Set("HX-Target", "plays-table")
+		return s.do(req, nil)
+	}
+
+	t.Run("HTMX Swaps Table", func(t *testing.T) {
+		w := swapTable("/plays?hero_id=&aspect=&outcome=loss&players=&sort=date&dir=desc")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.NotContains(t, body, "<html")
+		assert.Contains(t, body, `<thead`)
+		assert.Contains(t, body, "Jun 1, 2024")
+		assert.NotContains(t, body, "May 4, 2024")
+		assert.Equal(t, "/plays?outcome=loss", w.Header().Get("HX-Push-Url"), "empty filters and the default order are left out of the URL")
+	})
+
+	t.Run("Sort", func(t *testing.T) {
+		w := swapTable("/plays?sort=scenario&dir=asc")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Less(t, strings.Index(body, "Klaw"), strings.Index(body, "Rhino"))
+		assert.Equal(t, "/plays?dir=asc&sort=scenario", w.Header().Get("HX-Push-Url"))
+		assert.Contains(t, body, `href="/plays?dir=desc&amp;sort=scenario"`, "the sort column's header flips the direction")
+		assert.Contains(t, body, `href="/plays?dir=desc&amp;sort=date"`)
+		assert.Contains(t, body, `name="sort" value="scenario" form="plays-filter"`, "the filter form keeps the order")
+
+		w = swapTable("/plays?sort=heroes&dir=desc&outcome=win")
+		assert.Contains(t, w.Body.String(), `href="/plays?dir=asc&amp;outcome=win&amp;sort=heroes"`, "the headers keep the filters")
+
+		body = s.get("/plays?sort=result&dir=asc").Body.String()
+		assert.Less(t, strings.Index(body, "Jun 1, 2024"), strings.Index(body, "May 4, 2024"), "losses sort before wins")
+
+		body = s.get("/plays?sort=notes&dir=asc").Body.String()
+		assert.Less(t, strings.Index(body, "Jun 1, 2024"), strings.Index(body, "May 4, 2024"), "an unknown column lists the newest first")
+	})
+
+	t.Run("Load More", func(t *testing.T) {
+		for day := 1; day <= handlers.PlaysPerPage; day++ {
+			play := &models.Play{Date: time.Date(2023, 1, day, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I"}
+			require.NoError(t, repo.CreateWithDecks(play, "Klaw", nil))
+		}
+
+		w := s.get("/plays?outcome=win")
+		body := w.Body.String()
+		// The win from May 2024 fills the first page with January 2023
+		// down to the 2nd.
+		assert.Contains(t, body, "May 4, 2024")
+		assert.Contains(t, body, "Jan 2, 2023")
+		assert.NotContains(t, body, "Jan 1, 2023")
+		assert.Contains(t, body, `id="plays-more"`)
+
+		var lastShown int
+		require.NoError(t, db.QueryRow("SELECT id FROM plays WHERE date LIKE '2023-01-02%'").Scan(&lastShown))
+		after := "2023-01-02_" + strconv.Itoa(lastShown)
+		assert.Contains(t, body, `hx-get="/plays?after=`+after+`&amp;outcome=win"`)
+
+		// Deleting the last play shown, as its row's delete button does,
+		// leaves the next page where it was.
+		require.Equal(t, http.StatusOK, s.serve(http.MethodDelete, "/plays/"+strconv.Itoa(lastShown), nil, nil).Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/plays?after="+after+"&outcome=win", nil)
+		req.Header.Set("HX-Request", "true")
+		req.Header.Set("HX-Target", "plays-more")
+		w = s.do(req, nil)
+		body = w.Body.String()
+		assert.NotContains(t, body, "<html")
+		assert.Contains(t, body, "Jan 1, 2023")
+		assert.NotContains(t, body, "Jan 2, 2023")
+		assert.NotContains(t, body, `id="plays-more"`)
+		assert.Empty(t, w.Header().Get("HX-Push-Url"), "loading more does not change the URL")
+
+		w = s.get("/plays?after=" + strconv.Itoa(lastShown) + "&outcome=win")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "May 4, 2024", "a malformed cursor starts from the top")
+	})
+
+	t.Run("Database Error", func(t *testing.T) {
+		db.Close()
+
+		w := s.get("/plays")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
 }
 
 func TestNewPlayHandler(t *testing.T) {
-	r := setupTestRouter()
-	r.GET("/plays/new", NewPlay)
-
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest(http.MethodGet, "/plays/new", nil)
-	r.ServeHTTP(w, req)
+	s := newTestServer(t)
+	w := s.get("/plays/new")
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "Log New Play")
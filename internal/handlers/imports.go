@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/playio"
+)
+
+// maxImportSize caps uploaded import files; a BG Stats backup with years of
+// plays for every game is a few megabytes.
+const maxImportSize = 20 << 20
+
+// importForm is the state of the import page, carried from the upload to
+// the confirmation so the same data is imported that was previewed.
+type importForm struct {
+	Format     string // "csv", "bgstats" or "bgg"
+	Difficulty string // BG Stats and BGG only: default difficulty
+	Aspect     string // BG Stats and BGG only: default aspect, may be empty
+	Data       string
+}
+
+func readImportForm(c *gin.Context) importForm {
+	form := importForm{
+		Format:     c.DefaultPostForm("format", "csv"),
+		Difficulty: c.DefaultPostForm("difficulty", models.Difficulties[0]),
+		Aspect:     c.PostForm("aspect"),
+		Data:       c.PostForm("data"),
+	}
+	if form.Format != "bgstats" && form.Format != "bgg" {
+		form.Format = "csv"
+	}
+	return form
+}
+
+// ExportPlaysCSV downloads the plays the user may see as CSV in the format
+// ImportPlays reads.
+func ExportPlaysCSV(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plays, err := repo.For(auth.Viewer(c)).GetSummaries()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="plays.csv"`)
+		c.Status(http.StatusOK)
+		if err := playio.WriteCSV(c.Writer, plays); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// ExportPlaysBGG downloads the plays the user may see as BoardGameGeek plays
+// XML, which ImportPlays also reads.
+func ExportPlaysBGG(repo *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plays, err := repo.For(auth.Viewer(c)).GetSummaries()
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("Content-Type", "application/xml; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="plays.xml"`)
+		c.Status(http.StatusOK)
+		if err := playio.WriteBGG(c.Writer, plays); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// ImportPage shows the upload form for CSV files, BG Stats backups and BGG
+// plays XML.
+func ImportPage(aspects *models.AspectRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderImport(c, http.StatusOK, aspects, readImportForm(c), nil, "")
+	}
+}
+
+// ImportPlays previews an uploaded file, listing any problems row by row.
+// The preview page posts the same data back with confirm set, which
+// imports every row in one transaction and redirects to the play list.
+// Nothing is imported unless every row is valid; plays imported before
+// are skipped. Imported plays belong to the user.
+func ImportPlays(plays *models.PlayRepository, aspects *models.AspectRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		form := readImportForm(c)
+		confirm := c.PostForm("confirm") == "1"
+
+		if !confirm {
+			file, err := c.FormFile("file")
+			if err != nil {
+				renderImport(c, http.StatusBadRequest, aspects, form, nil, "choose a file to import")
+				return
+			}
+			f, err := file.Open()
+			if err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			defer f.Close()
+			raw, err := io.ReadAll(io.LimitReader(f, maxImportSize+1))
+			if err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if len(raw) > maxImportSize {
+				renderImport(c, http.StatusBadRequest, aspects, form, nil, "the file is larger than 20 MB")
+				return
+			}
+			form.Data = string(raw)
+		}
+
+		var rows []playio.Row
+		var err error
+		switch form.Format {
+		case "bgstats", "bgg":
+			known, loadErr := aspects.GetAll()
+			if loadErr != nil {
+				c.Error(loadErr)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			opts := playio.Options{Difficulty: form.Difficulty, Aspect: form.Aspect}
+			for _, a := range known {
+				opts.Aspects = append(opts.Aspects, a.Name)
+			}
+			if form.Format == "bgg" {
+				rows, err = playio.ReadBGG(strings.NewReader(form.Data), opts)
+			} else {
+				rows, err = playio.ReadBGStats(strings.NewReader(form.Data), opts)
+			}
+		default:
+			rows, err = playio.ReadCSV(strings.NewReader(form.Data))
+		}
+		if err != nil {
+			form.Data = ""
+			renderImport(c, http.StatusBadRequest, aspects, form, nil, "could not read the file: "+err.Error())
+			return
+		}
+		if len(rows) == 0 {
+			renderImport(c, http.StatusBadRequest, aspects, form, nil, "the file has no plays")
+			return
+		}
+
+		committed, err := playio.Apply(plays.For(auth.Viewer(c)), rows, confirm)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if committed {
+			c.Redirect(http.StatusSeeOther, "/plays")
+			return
+		}
+
+		status, message := http.StatusOK, ""
+		if playio.Count(rows).Failed > 0 {
+			status, message = http.StatusBadRequest, "nothing was imported; fix the rows marked below and upload the file again"
+		}
+		renderImport(c, status, aspects, form, rows, message)
+	}
+}
+
+func renderImport(c *gin.Context, status int, aspectRepo *models.AspectRepository, form importForm, rows []playio.Row, message string) {
+	aspects, err := aspectRepo.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.HTML(status, "import.html", gin.H{
+		"title":        "Import Plays",
+		"form":         form,
+		"rows":         rows,
+		"counts":       playio.Count(rows),
+		"error":        message,
+		"header":       strings.Join(playio.CSVHeader, ","),
+		"difficulties": models.Difficulties,
+		"aspects":      aspects,
+	})
+}
@@ -0,0 +1,201 @@
+package handlers_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+func TestImportExportHandlers(t *testing.T) {
+	s := newTestServer(t)
+	db := s.DB
+	plays := s.Plays.For(s.viewer)
+
+	upload := func(content string, fields ...string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for i := 0; i+1 < len(fields); i += 2 {
+			require.NoError(t, form.WriteField(fields[i], fields[i+1]))
+		}
+		if content != "" {
+			part, err := form.CreateFormFile("file", "plays.csv")
+			require.NoError(t, err)
+			_, err = part.Write([]byte(content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/plays/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		return s.do(req, nil)
+	}
+	confirm := func(content string, fields ...string) *httptest.ResponseRecorder {
+		form := url.Values{"confirm": {"1"}, "data": {content}}
+		for i := 0; i+1 < len(fields); i += 2 {
+			form.Set(fields[i], fields[i+1])
+		}
+		return s.post("/plays/import", form)
+	}
+	countPlays := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&n))
+		return n
+	}
+
+	const valid = "date,scenario,difficulty,outcome,heroes,players\n" +
+		"2024-03-01,Rhino,Standard I,win,Spider-Man:justice; She-Hulk:aggression,Sam\n" +
+		"2024-03-02,Klaw,Expert I,loss,Squirrel Girl:protection,\n"
+
+	t.Run("Upload Page", func(t *testing.T) {
+		w := s.get("/plays/import")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `enctype="multipart/form-data"`)
+		assert.Contains(t, w.Body.String(), "date,scenario,modulars,difficulty,outcome,notes,heroes,players,remaining_hp,end_reason,rounds,villain_stage,remaining_threat")
+	})
+
+	t.Run("Preview", func(t *testing.T) {
+		w := upload(valid)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Mar 1, 2024")
+		assert.Contains(t, body, "Squirrel Girl")
+		assert.Contains(t, body, "adds Squirrel Girl to the catalog")
+		assert.Contains(t, body, "Import 2 plays")
+		assert.Contains(t, body, `name="confirm" value="1"`)
+		assert.Zero(t, countPlays(), "previewing saves nothing")
+	})
+
+	t.Run("Preview Reports Row Errors", func(t *testing.T) {
+		w := upload(valid + "2024-03-03,Rhino,Legendary,win,Spider-Man:justice\n")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "nothing was imported")
+		assert.Contains(t, body, "unknown difficulty")
+		assert.NotContains(t, body, `name="confirm"`)
+		assert.Zero(t, countPlays())
+	})
+
+	t.Run("Unusable Uploads", func(t *testing.T) {
+		w := upload("")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "choose a file to import")
+
+		w = upload("date,scenario\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "the header has no difficulty column")
+
+		w = upload("date,scenario,difficulty,outcome,heroes\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "the file has no plays")
+	})
+
+	t.Run("Confirm Imports", func(t *testing.T) {
+		w := confirm(valid)
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/plays", w.Header().Get("Location"))
+		assert.Equal(t, 2, countPlays())
+
+		summaries, err := plays.GetSummaries()
+		require.NoError(t, err)
+		assert.Equal(t, "Klaw", summaries[0].Scenario)
+		assert.Equal(t, "Sam", summaries[1].Heroes[0].PlayerName)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		require.NoError(t, plays.CreateWithDecks(&models.Play{
+			Date: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", Notes: "Quick, easy",
+		}, "Rhino", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"pool"}}}))
+
+		w := s.get("/plays/export.csv")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="plays.csv"`)
+		assert.Equal(t,
+			"date,scenario,modulars,difficulty,outcome,notes,heroes,players,remaining_hp,end_reason,rounds,villain_stage,remaining_threat\n"+
+				"2024-03-01,Rhino,,Standard I,win,,Spider-Man:justice; She-Hulk:aggression,Sam,,,,,\n"+
+				"2024-03-02,Klaw,,Expert I,loss,,Squirrel Girl:protection,,,,,,\n"+
+				"2024-03-03,Rhino,,Standard I,win,\"Quick, easy\",Spider-Man:pool,,,,,,\n",
+			w.Body.String())
+	})
+
+	t.Run("Confirm Refuses Invalid Data", func(t *testing.T) {
+		w := confirm("date,scenario,difficulty,outcome,heroes\n2024-03-05,Rhino,Standard I,draw,Spider-Man:justice\n")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "outcome must be win or loss")
+		assert.Equal(t, 3, countPlays())
+	})
+
+	t.Run("BG Stats Backup", func(t *testing.T) {
+		backup, err := os.ReadFile("../playio/testdata/bgstats.json")
+		require.NoError(t, err)
+
+		w := upload(string(backup), "format", "bgstats")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Captain Marvel has no aspect")
+
+		w = upload(string(backup), "format", "bgstats", "aspect", "basic", "difficulty", "Expert I")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Import 4 plays")
+		assert.Contains(t, body, `<input type="hidden" name="format" value="bgstats">`)
+		assert.Contains(t, body, `<input type="hidden" name="aspect" value="basic">`)
+		assert.Contains(t, body, "Spider-Man (Miles Morales)")
+
+		w = confirm(string(backup), "format", "bgstats", "aspect", "basic", "difficulty", "Expert I")
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, 7, countPlays())
+		var difficulty string
+		require.NoError(t, db.QueryRow("SELECT difficulty FROM plays WHERE source_id = 'bgstats:c3c3c3c3-0000-4000-8000-000000000001'").Scan(&difficulty))
+		assert.Equal(t, "Expert I", difficulty)
+
+		w = upload(string(backup), "format", "bgstats", "aspect", "basic")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body = w.Body.String()
+		assert.Contains(t, body, "Already imported")
+		assert.Contains(t, body, "Every play in this file has already been imported.")
+		assert.NotContains(t, body, "Import 4 plays")
+	})
+	t.Run("BGG Plays", func(t *testing.T) {
+		w := s.get("/plays/export.xml")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="plays.xml"`)
+		exported := w.Body.String()
+		assert.Contains(t, exported, `<play id="0" date="2024-03-03"`)
+		assert.Contains(t, exported, `color="Spider-Man (Pool)"`)
+		assert.Contains(t, exported, "Scenario: Rhino&#xA;Difficulty: Standard I&#xA;Quick, easy")
+
+		plays, err := os.ReadFile("../playio/testdata/bgg_plays.xml")
+		require.NoError(t, err)
+
+		w = upload(string(plays), "format", "bgg", "aspect", "basic")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Import 4 plays")
+		assert.Contains(t, body, `<input type="hidden" name="format" value="bgg">`)
+
+		before := countPlays()
+		w = confirm(string(plays), "format", "bgg", "aspect", "basic")
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, before+4, countPlays())
+		var outcome string
+		require.NoError(t, db.QueryRow("SELECT outcome FROM plays WHERE source_id = 'bgg:80000003'").Scan(&outcome))
+		assert.Equal(t, "win", outcome)
+	})
+}
@@ -1,79 +1,116 @@
-package handlers
+package handlers_test
 
 import (
 	"database/sql"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/app"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
 )
 
-func setupIntegrationTestRouter(t *testing.T) (*gin.Engine, *sql.DB) {
-	gin.SetMode(gin.TestMode)
+// testServer is the tracker's routing table as main serves it, on a fresh
+// database with the shipped migrations and catalog, and a user to make
+// requests as.
+type testServer struct {
+	*app.App
+	routes http.Handler
+	// session is the test user's session cookie, and viewer the viewer that
+	// sees and owns their plays.
+	session *http.Cookie
+	viewer  models.Viewer
+}
 
-	// Create in-memory database for integration tests
-	db, err := sql.Open("sqlite3", ":memory:")
-	require.NoError(t, err)
+// visitor is an empty session cookie, which makes a request as a visitor
+// rather than the test user.
+var visitor = &http.Cookie{Name: auth.CookieName, Value: ""}
 
-	// Create test schema
-	schema := `
-	CREATE TABLE plays (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		date DATE NOT NULL,
-		outcome TEXT NOT NULL CHECK(outcome IN ('win', 'loss')),
-		difficulty TEXT NOT NULL,
-		notes TEXT,
-		scenario_id INTEGER NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE scenarios (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	_, err = db.Exec(schema)
+func newTestServer(t *testing.T) *testServer {
+	cfg := config.Default()
+	cfg.DB.Path = filepath.Join(t.TempDir(), "test.db")
+	cfg.GinMode = "test"
+	cfg.LogLevel = "warn"
+	db, err := config.InitDB(cfg.DB)
 	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, config.RunMigrations(db, app.Migrations(&cfg)))
 
-	// Insert test data
-	_, err = db.Exec("INSERT INTO scenarios (id, name) VALUES (1, 'Rhino'), (2, 'Klaw')")
+	a, err := app.New(&cfg, db)
+	require.NoError(t, err)
+	user, err := a.Auth.Signup("tester", "correct horse")
+	require.NoError(t, err)
+	token, err := a.Auth.CreateSession(user.ID)
 	require.NoError(t, err)
 
-	r := gin.New()
+	return &testServer{
+		App:     a,
+		routes:  a.Routes(),
+		session: &http.Cookie{Name: auth.CookieName, Value: token},
+		viewer:  models.Viewer{UserID: user.ID},
+	}
+}
 
-	// Load templates for integration testing
-	r.LoadHTMLFiles(
-		"../../templates/index.html",
-		"../../templates/plays.html",
-		"../../templates/new_play.html",
-		"../../templates/error.html",
-	)
+// serve makes a request with the form, if any, as the test user or, if
+// cookie is not nil, with cookie instead.
+func (s *testServer) serve(method, target string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req := httptest.NewRequest(method, target, body)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return s.do(req, cookie)
+}
 
-	return r, db
+// do serves req as the test user or, if cookie is not nil, with cookie
+// instead.
+func (s *testServer) do(req *http.Request, cookie *http.Cookie) *httptest.ResponseRecorder {
+	if cookie == nil {
+		cookie = s.session
+	}
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	s.routes.ServeHTTP(w, req)
+	return w
 }
 
-func TestHandlers_Integration(t *testing.T) {
-	r, db := setupIntegrationTestRouter(t)
-	defer db.Close()
+func (s *testServer) get(target string) *httptest.ResponseRecorder {
+	return s.serve(http.MethodGet, target, nil, nil)
+}
+
+func (s *testServer) post(target string, form url.Values) *httptest.ResponseRecorder {
+	if form == nil {
+		form = url.Values{}
+	}
+	return s.serve(http.MethodPost, target, form, nil)
+}
 
-	// Setup routes
-	r.GET("/", Home)
-	r.GET("/plays", Plays)
-	r.GET("/plays/new", NewPlay)
+// id returns the id of the row of table with the given name.
+func (s *testServer) id(t *testing.T, table, name string) int {
+	var id int
+	require.NoError(t, s.DB.QueryRow("SELECT id FROM "+table+" WHERE name = ?", name).Scan(&id))
+	return id
+}
+
+func TestHandlers_Integration(t *testing.T) {
+	s := newTestServer(t)
 
 	t.Run("Full Navigation Flow", func(t *testing.T) {
 		// Test home page
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/", nil)
-		r.ServeHTTP(w, req)
+		w := s.get("/")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Welcome to Marvel Champions Play Tracker")
@@ -81,18 +118,14 @@ func TestHandlers_Integration(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "Log New Play")
 
 		// Test plays page
-		w = httptest.NewRecorder()
-		req, _ = http.NewRequest(http.MethodGet, "/plays", nil)
-		r.ServeHTTP(w, req)
+		w = s.get("/plays")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Play History")
 		assert.Contains(t, w.Body.String(), "No plays recorded yet")
 
 		// Test new play page
-		w = httptest.NewRecorder()
-		req, _ = http.NewRequest(http.MethodGet, "/plays/new", nil)
-		r.ServeHTTP(w, req)
+		w = s.get("/plays/new")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Log New Play")
@@ -104,23 +137,17 @@ func TestHandlers_Integration(t *testing.T) {
 
 	t.Run("Template Variables", func(t *testing.T) {
 		// Test that title variables are properly passed to templates
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/", nil)
-		r.ServeHTTP(w, req)
+		w := s.get("/")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "<title>Marvel Champions Play Tracker - Marvel Champions Play Tracker</title>")
 
-		w = httptest.NewRecorder()
-		req, _ = http.NewRequest(http.MethodGet, "/plays", nil)
-		r.ServeHTTP(w, req)
+		w = s.get("/plays")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "<title>Plays - Marvel Champions Play Tracker</title>")
 
-		w = httptest.NewRecorder()
-		req, _ = http.NewRequest(http.MethodGet, "/plays/new", nil)
-		r.ServeHTTP(w, req)
+		w = s.get("/plays/new")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "<title>New Play - Marvel Champions Play Tracker</title>")
@@ -130,9 +157,7 @@ func TestHandlers_Integration(t *testing.T) {
 		pages := []string{"/", "/plays", "/plays/new"}
 
 		for _, page := range pages {
-			w := httptest.NewRecorder()
-			req, _ := http.NewRequest(http.MethodGet, page, nil)
-			r.ServeHTTP(w, req)
+			w := s.get(page)
 
 			assert.Equal(t, http.StatusOK, w.Code)
 
@@ -146,9 +171,7 @@ func TestHandlers_Integration(t *testing.T) {
 	})
 
 	t.Run("Form Elements on New Play Page", func(t *testing.T) {
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/plays/new", nil)
-		r.ServeHTTP(w, req)
+		w := s.get("/plays/new")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		body := w.Body.String()
@@ -159,7 +182,11 @@ func TestHandlers_Integration(t *testing.T) {
 
 		// Verify form fields
 		assert.Contains(t, body, `name="date"`)
-		assert.Contains(t, body, `name="scenario"`)
+		assert.Contains(t, body, `name="scenario_id"`)
+		assert.Contains(t, body, `name="hero_id"`)
+		assert.Contains(t, body, `name="deck_row" value="0"`)
+		assert.Contains(t, body, `name="aspect_0" value="pool"`)
+		assert.Contains(t, body, `name="aspect_0" value="basic"`)
 		assert.Contains(t, body, `name="difficulty"`)
 		assert.Contains(t, body, `name="outcome"`)
 		assert.Contains(t, body, `name="notes"`)
@@ -171,6 +198,10 @@ func TestHandlers_Integration(t *testing.T) {
 		assert.Contains(t, body, "Expert II")
 		assert.Contains(t, body, "Heroic I")
 
+		// Verify catalog dropdowns
+		assert.Contains(t, body, `<option value="1">Rhino (Core Set)</option>`)
+		assert.Contains(t, body, `<option value="`+strconv.Itoa(s.id(t, "heroes", "She-Hulk"))+`">She-Hulk</option>`)
+
 		// Verify outcome options
 		assert.Contains(t, body, `value="win"`)
 		assert.Contains(t, body, `value="loss"`)
@@ -184,9 +215,7 @@ func TestHandlers_Integration(t *testing.T) {
 		pages := []string{"/", "/plays", "/plays/new"}
 
 		for _, page := range pages {
-			w := httptest.NewRecorder()
-			req, _ := http.NewRequest(http.MethodGet, page, nil)
-			r.ServeHTTP(w, req)
+			w := s.get(page)
 
 			assert.Equal(t, http.StatusOK, w.Code)
 			body := w.Body.String()
@@ -200,26 +229,445 @@ func TestHandlers_Integration(t *testing.T) {
 	})
 }
 
-func TestHandlers_ErrorScenarios(t *testing.T) {
-	r, db := setupIntegrationTestRouter(t)
-	defer db.Close()
+func TestHandlers_CreatePlay(t *testing.T) {
+	s := newTestServer(t)
+	db := s.DB
+	spiderMan, sheHulk := strconv.Itoa(s.id(t, "heroes", "Spider-Man")), strconv.Itoa(s.id(t, "heroes", "She-Hulk"))
+
+	postForm := func(form url.Values, rows ...url.Values) *httptest.ResponseRecorder {
+		for i, row := range rows {
+			key := strconv.Itoa(i)
+			form.Add("deck_row", key)
+			form.Add("hero_id", row.Get("hero_id"))
+			form.Add("player_name", row.Get("player_name"))
+			form.Add("remaining_hp", row.Get("remaining_hp"))
+			for _, aspect := range row["aspect"] {
+				form.Add("aspect_"+key, aspect)
+			}
+		}
+
+		return s.post("/plays", form)
+	}
+
+	t.Run("Valid Submission", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":             {"2024-03-10"},
+			"scenario_id":      {"2"},
+			"difficulty":       {"Standard II"},
+			"outcome":          {"win"},
+			"notes":            {"Close one"},
+			"encounter_set_id": {"2"},
+		}, deckRow(spiderMan, "justice"), deckRow(sheHulk, "aggression", "protection"))
+
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/plays", w.Header().Get("Location"))
+
+		var notes string
+		var scenarioID int
+		err := db.QueryRow("SELECT notes, scenario_id FROM plays").Scan(&notes, &scenarioID)
+		require.NoError(t, err)
+		assert.Equal(t, "Close one", notes)
+		assert.Equal(t, 2, scenarioID)
+
+		var deckCount int
+		err = db.QueryRow("SELECT COUNT(*) FROM decks").Scan(&deckCount)
+		require.NoError(t, err)
+		assert.Equal(t, 2, deckCount)
+
+		var aspectCount int
+		err = db.QueryRow("SELECT COUNT(*) FROM deck_aspects").Scan(&aspectCount)
+		require.NoError(t, err)
+		assert.Equal(t, 3, aspectCount)
+
+		var encounterSetID int
+		err = db.QueryRow("SELECT encounter_set_id FROM play_encounter_sets").Scan(&encounterSetID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, encounterSetID)
+	})
+
+	t.Run("Invalid Submission Re-renders Form", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":             {"2024-03-11"},
+			"scenario_id":      {"1"},
+			"difficulty":       {"Impossible"},
+			"outcome":          {"win"},
+			"encounter_set_id": {"1", "2"},
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "unknown difficulty")
+		assert.Contains(t, body, `<option value="1" selected>Rhino (Core Set)</option>`)
+		assert.Contains(t, body, `<option value="1" selected>Bomb Scare</option>`)
+		assert.Contains(t, body, `<option value="2" selected>Masters of Evil</option>`)
+
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM plays WHERE date LIKE '2024-03-11%'").Scan(&count)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Unknown Scenario", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":        {"2024-03-12"},
+			"scenario_id": {"99"},
+			"difficulty":  {"Standard I"},
+			"outcome":     {"win"},
+		}, deckRow(spiderMan, "justice"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown scenario")
+	})
+
+	t.Run("Missing Scenario", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":       {"2024-03-12"},
+			"difficulty": {"Standard I"},
+			"outcome":    {"win"},
+		}, deckRow(spiderMan, "justice"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "scenario is required")
+	})
+
+	t.Run("Malformed Date", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":        {"10/03/2024"},
+			"scenario_id": {"1"},
+			"difficulty":  {"Standard I"},
+			"outcome":     {"loss"},
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "YYYY-MM-DD")
+	})
+
+	t.Run("Player Names", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":        {"2024-03-13"},
+			"scenario_id": {"1"},
+			"difficulty":  {"Standard I"},
+			"outcome":     {"win"},
+		}, url.Values{"hero_id": {spiderMan}, "aspect": {"justice"}, "player_name": {"Alice"}}, deckRow(sheHulk, "protection"))
+		require.Equal(t, http.StatusSeeOther, w.Code)
+
+		rows, err := db.Query(`SELECT d.player_name FROM decks d
+			JOIN plays p ON p.id = d.play_id
+			WHERE p.date LIKE '2024-03-13%' ORDER BY d.id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		var names []sql.NullString
+		for rows.Next() {
+			var name sql.NullString
+			require.NoError(t, rows.Scan(&name))
+			names = append(names, name)
+		}
+		require.Len(t, names, 2)
+		assert.Equal(t, "Alice", names[0].String)
+		assert.False(t, names[1].Valid)
+	})
+
+	t.Run("End State", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":             {"2024-03-14"},
+			"scenario_id":      {"1"},
+			"difficulty":       {"Standard I"},
+			"outcome":          {"loss"},
+			"end_reason":       {"scheme_completed"},
+			"rounds":           {"7"},
+			"villain_stage":    {"2"},
+			"remaining_threat": {""},
+		}, url.Values{"hero_id": {spiderMan}, "aspect": {"justice"}, "remaining_hp": {"3"}})
+		require.Equal(t, http.StatusSeeOther, w.Code)
+
+		var endReason string
+		var rounds, stage int
+		var threat, remainingHP sql.NullInt64
+		err := db.QueryRow(`SELECT p.end_reason, p.rounds, p.villain_stage, p.remaining_threat, d.remaining_hp
+			FROM plays p JOIN decks d ON d.play_id = p.id
+			WHERE p.date LIKE '2024-03-14%'`).Scan(&endReason, &rounds, &stage, &threat, &remainingHP)
+		require.NoError(t, err)
+		assert.Equal(t, "scheme_completed", endReason)
+		assert.Equal(t, 7, rounds)
+		assert.Equal(t, 2, stage)
+		assert.False(t, threat.Valid)
+		assert.Equal(t, int64(3), remainingHP.Int64)
+	})
+
+	t.Run("End State Errors Keep Values", func(t *testing.T) {
+		w := postForm(url.Values{
+			"date":        {"2024-03-15"},
+			"scenario_id": {"1"},
+			"difficulty":  {"Standard I"},
+			"outcome":     {"win"},
+			"end_reason":  {"conceded"},
+			"rounds":      {"5"},
+		}, deckRow(spiderMan, "justice"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "does not go with a win")
+		assert.Contains(t, body, `<option value="conceded" selected>Conceded</option>`)
+		assert.Contains(t, body, `name="rounds" min="1" value="5"`)
+
+		w = postForm(url.Values{
+			"date":        {"2024-03-15"},
+			"scenario_id": {"1"},
+			"difficulty":  {"Standard I"},
+			"outcome":     {"win"},
+			"rounds":      {"many"},
+		}, deckRow(spiderMan, "justice"))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "rounds must be a whole number")
+	})
+
+	t.Run("Hero Errors Keep Rows", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			rows    []url.Values
+			message string
+		}{
+			{"No Heroes", nil, "add at least one hero"},
+			{"Duplicate Hero", []url.Values{deckRow(spiderMan, "justice"), deckRow(spiderMan, "aggression")}, "each hero can only appear once"},
+			{"Too Many Heroes", []url.Values{
+				deckRow(spiderMan, "justice"), deckRow(sheHulk, "justice"), deckRow(spiderMan, "justice"), deckRow(sheHulk, "justice"), deckRow(spiderMan, "justice"),
+			}, "at most 4 heroes"},
+			{"Unknown Aspect", []url.Values{deckRow(spiderMan, "speed")}, `unknown aspect &#34;speed&#34;`},
+			{"Missing Aspect", []url.Values{deckRow(spiderMan)}, "aspect is required"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				var before int
+				require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&before))
+
+				w := postForm(url.Values{
+					"date":        {"2024-03-14"},
+					"scenario_id": {"1"},
+					"difficulty":  {"Standard I"},
+					"outcome":     {"win"},
+				}, tc.rows...)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				body := w.Body.String()
+				assert.Contains(t, body, tc.message)
+				assert.Equal(t, max(len(tc.rows), 1), strings.Count(body, "<div data-hero-row"))
+
+				var after int
+				require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&after))
+				assert.Equal(t, before, after)
+			})
+		}
+	})
+}
+
+// deckRow describes one hero row of the New Play form for postForm in
+// TestHandlers_CreatePlay, which assigns the row keys.
+func deckRow(heroID string, aspects ...string) url.Values {
+	return url.Values{"hero_id": {heroID}, "aspect": aspects}
+}
+
+func TestHandlers_SearchPlays(t *testing.T) {
+	s := newTestServer(t)
+
+	play := &models.Play{Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), Outcome: "loss", Difficulty: "Standard I", ScenarioID: 1,
+		Notes: "Breakin' & Takin' flipped twice <b>again</b>"}
+	require.NoError(t, s.Plays.For(s.viewer).CreateWithDecks(play, "", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("HX-Request", "true")
+		return s.do(req, nil)
+	}
 
-	r.GET("/", Home)
-	r.GET("/plays", Plays)
-	r.GET("/plays/new", NewPlay)
+	assert.Contains(t, get("/plays").Body.String(), `hx-get="/plays/search"`)
+
+	w := get("/plays/search?q=" + url.QueryEscape("breakin takin"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `href="#play-`+strconv.Itoa(play.ID)+`"`)
+	assert.Contains(t, body, "Rhino &ndash; Spider-Man")
+	assert.Contains(t, body, `<mark class="bg-yellow-200 rounded px-0.5">Breakin</mark>`)
+	assert.Contains(t, body, "&lt;b&gt;again&lt;/b&gt;", "notes are escaped around the highlights")
+
+	assert.Contains(t, get("/plays/search?q=venom").Body.String(), "No plays match &ldquo;venom&rdquo;.")
+	assert.Empty(t, strings.TrimSpace(get("/plays/search?q=").Body.String()))
+}
+
+func TestHandlers_HeroRow(t *testing.T) {
+	s := newTestServer(t)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		return s.get("/plays/new/hero-row" + query)
+	}
+
+	t.Run("Adds Row", func(t *testing.T) {
+		// Row 1 was removed from the form, so the next key follows the
+		// largest one rather than the row count.
+		w := get("?deck_row=0&deck_row=2")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.NotContains(t, body, "<html")
+		assert.Equal(t, 1, strings.Count(body, "<div data-hero-row"))
+		assert.Contains(t, body, `name="deck_row" value="3"`)
+		assert.Contains(t, body, `name="aspect_3" value="justice"`)
+		assert.Contains(t, body, `<option value="1">Spider-Man</option>`)
+		assert.Contains(t, body, `name="player_name"`)
+	})
+
+	t.Run("Stops At Max Players", func(t *testing.T) {
+		w := get("?deck_row=0&deck_row=1&deck_row=2&deck_row=3")
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+}
+
+func TestHandlers_EncounterSetPicker(t *testing.T) {
+	s := newTestServer(t)
+
+	get := func(query string) string {
+		w := s.get("/plays/new/encounter-sets" + query)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	t.Run("Selects Recommended Sets", func(t *testing.T) {
+		body := get("?scenario_id=2")
+		assert.NotContains(t, body, "<html")
+		assert.Contains(t, body, `<optgroup label="Core Set">`)
+		assert.Contains(t, body, `<option value="1">Bomb Scare</option>`)
+		assert.Contains(t, body, `<option value="2" selected>Masters of Evil</option>`)
+	})
+
+	t.Run("No Scenario", func(t *testing.T) {
+		body := get("")
+		assert.NotContains(t, body, " selected>")
+	})
+}
+
+func TestHandlers_EditAndDeletePlay(t *testing.T) {
+	s := newTestServer(t)
+	repo := s.Plays.For(s.viewer)
+
+	rounds := 6
+	play := &models.Play{
+		Date:       time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Outcome:    "loss",
+		Difficulty: "Standard I",
+		Notes:      "Rhino charged twice",
+		EndReason:  "heroes_defeated",
+		Rounds:     &rounds,
+	}
+	require.NoError(t, repo.CreateWithDecks(play, "Rhino", []models.DeckEntry{{HeroName: "Hulk", Aspects: []string{"aggression"}}}))
+	playURL := "/plays/" + strconv.Itoa(play.ID)
+
+	send := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+		return s.serve(method, path, form, nil)
+	}
+
+	t.Run("Row Partial", func(t *testing.T) {
+		w := send(http.MethodGet, playURL, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `<tr id="play-`+strconv.Itoa(play.ID)+`"`)
+		assert.NotContains(t, body, "<html")
+		assert.Contains(t, body, "Rhino charged twice")
+		assert.Contains(t, body, `hx-get="`+playURL+`/edit"`)
+		assert.Contains(t, body, `hx-delete="`+playURL+`"`)
+		assert.Contains(t, body, "hx-confirm")
+		assert.Contains(t, body, "All heroes defeated")
+		assert.Contains(t, body, "6 rounds")
+	})
+
+	t.Run("Edit Form Partial", func(t *testing.T) {
+		w := send(http.MethodGet, playURL+"/edit", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `value="2024-06-01"`)
+		assert.Contains(t, body, `<option value="1" selected>Rhino</option>`)
+		assert.Contains(t, body, `hx-put="`+playURL+`"`)
+		assert.Contains(t, body, `<option value="loss" selected>`)
+		assert.Contains(t, body, `<option value="heroes_defeated" selected>`)
+		assert.Contains(t, body, `name="rounds" min="1" value="6"`)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		w := send(http.MethodPut, playURL, url.Values{
+			"date":        {"2024-06-02"},
+			"scenario_id": {"2"},
+			"difficulty":  {"Expert I"},
+			"outcome":     {"win"},
+			"notes":       {"Rematch"},
+			"end_reason":  {"villain_defeated"},
+			"rounds":      {"6"},
+		})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "Jun 2, 2024")
+		assert.Contains(t, body, "Klaw")
+		assert.Contains(t, body, "Hulk")
+
+		updated, err := repo.GetByID(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "win", updated.Outcome)
+		assert.Equal(t, "Expert I", updated.Difficulty)
+		assert.Equal(t, "Rematch", updated.Notes)
+		assert.Equal(t, "villain_defeated", updated.EndReason)
+		require.NotNil(t, updated.Rounds)
+		assert.Equal(t, 6, *updated.Rounds)
+	})
+
+	t.Run("Invalid Update Returns Form", func(t *testing.T) {
+		w := send(http.MethodPut, playURL, url.Values{
+			"date":        {"2024-06-02"},
+			"scenario_id": {""},
+			"difficulty":  {"Expert I"},
+			"outcome":     {"win"},
+		})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "scenario is required")
+		assert.Contains(t, w.Body.String(), `hx-put="`+playURL+`"`)
+	})
+
+	t.Run("Unknown Play", func(t *testing.T) {
+		for _, path := range []string{"/plays/9999", "/plays/9999/edit", "/plays/abc"} {
+			w := send(http.MethodGet, path, nil)
+			assert.Equal(t, http.StatusNotFound, w.Code, path)
+		}
+
+		w := send(http.MethodDelete, "/plays/9999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := send(http.MethodDelete, playURL, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		_, err := repo.GetByID(play.ID)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
+
+func TestHandlers_ErrorScenarios(t *testing.T) {
+	s := newTestServer(t)
 
 	t.Run("Non-existent Route", func(t *testing.T) {
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/nonexistent", nil)
-		r.ServeHTTP(w, req)
+		w := s.get("/nonexistent")
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 
 	t.Run("Invalid HTTP Method", func(t *testing.T) {
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodPost, "/", nil)
-		r.ServeHTTP(w, req)
+		w := s.post("/", nil)
 
 		// Gin returns 404 for routes that don't match method, not 405
 		assert.Equal(t, http.StatusNotFound, w.Code)
@@ -227,15 +675,10 @@ func TestHandlers_ErrorScenarios(t *testing.T) {
 }
 
 func TestHandlers_ResponseHeaders(t *testing.T) {
-	r, db := setupIntegrationTestRouter(t)
-	defer db.Close()
-
-	r.GET("/", Home)
+	s := newTestServer(t)
 
 	t.Run("Content Type Headers", func(t *testing.T) {
-		w := httptest.NewRecorder()
-		req, _ := http.NewRequest(http.MethodGet, "/", nil)
-		r.ServeHTTP(w, req)
+		w := s.get("/")
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/stats"
+)
+
+// playerRecord is one row of the players page: a player with their
+// overall record, which is empty until they have played.
+type playerRecord struct {
+	models.Player
+	Record *stats.Row
+}
+
+// Players lists the user's players with their record and the form to add
+// one.
+func Players(players *models.PlayerRepository, statsRepo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderPlayers(c, http.StatusOK, players.For(auth.Viewer(c)), statsRepo, "")
+	}
+}
+
+// CreatePlayer adds a player from the form's name field.
+func CreatePlayer(players *models.PlayerRepository, statsRepo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		players := players.For(auth.Viewer(c))
+		_, err := players.Create(c.PostForm("name"))
+		finishPlayerChange(c, players, statsRepo, err)
+	}
+}
+
+// RenamePlayer renames the player named by the :id route parameter.
+func RenamePlayer(players *models.PlayerRepository, statsRepo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := catalogID(c)
+		if !ok {
+			return
+		}
+		players := players.For(auth.Viewer(c))
+		finishPlayerChange(c, players, statsRepo, players.Rename(id, c.PostForm("name")))
+	}
+}
+
+// DeletePlayer removes the player named by the :id route parameter. Their
+// plays are kept and still show the name they were logged with.
+func DeletePlayer(players *models.PlayerRepository, statsRepo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := catalogID(c)
+		if !ok {
+			return
+		}
+		players := players.For(auth.Viewer(c))
+		finishPlayerChange(c, players, statsRepo, players.Delete(id))
+	}
+}
+
+// finishPlayerChange redirects back to the players page after a successful
+// change, or re-renders it with the problem.
+func finishPlayerChange(c *gin.Context, players *models.PlayerRepository, statsRepo *stats.Repository, err error) {
+	var validationErr *models.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		renderPlayers(c, http.StatusBadRequest, players, statsRepo, validationErr.Message)
+	case errors.Is(err, models.ErrNotFound):
+		c.Error(err)
+		c.AbortWithStatus(http.StatusNotFound)
+	case err != nil:
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+	default:
+		c.Redirect(http.StatusSeeOther, "/players")
+	}
+}
+
+func renderPlayers(c *gin.Context, status int, players *models.PlayerRepository, statsRepo *stats.Repository, message string) {
+	all, err := players.GetAll()
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	// A user's players only play in the plays that user logged, where
+	// player names are unique, so the stats rows can be matched by name.
+	viewer := auth.Viewer(c)
+	rows, err := statsRepo.For(viewer).By(stats.ByPlayer, stats.Filter{OwnerID: viewer.UserID})
+	if err != nil {
+		c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	byName := make(map[string]*stats.Row, len(rows))
+	for i := range rows {
+		byName[rows[i].Name] = &rows[i]
+	}
+	records := make([]playerRecord, 0, len(all))
+	for _, p := range all {
+		records = append(records, playerRecord{Player: p, Record: byName[p.Name]})
+	}
+
+	c.HTML(status, "players.html", gin.H{
+		"title":   "Players",
+		"players": records,
+		"error":   message,
+	})
+}
+
+// Player shows one of the user's players' record: their favorite heroes,
+// win rate by aspect, most-played scenarios and streaks. Hero and aspect
+// tables count only the decks the player played themselves.
+func Player(players *models.PlayerRepository, statsRepo *stats.Repository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		player, err := players.For(auth.Viewer(c)).GetByID(id)
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		statsRepo := statsRepo.For(auth.Viewer(c))
+		filter := stats.Filter{PlayerID: player.ID}
+		tables := make([]statsTable, 0, 3)
+		for _, t := range []struct {
+			title string
+			dim   stats.Dimension
+		}{
+			{"Favorite Heroes", stats.ByHero},
+			{"Win Rate by Aspect", stats.ByAspect},
+			{"Most-Played Scenarios", stats.ByScenario},
+		} {
+			rows, err := statsRepo.By(t.dim, filter)
+			if err != nil {
+				c.Error(err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			tables = append(tables, statsTable{Title: t.title, Rows: rows})
+		}
+
+		overall, err := statsRepo.By(stats.ByPlayer, filter)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		streaks, err := statsRepo.Streaks(filter)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		var record *stats.Row
+		if len(overall) > 0 {
+			record = &overall[0]
+		}
+		c.HTML(http.StatusOK, "player.html", gin.H{
+			"title":   player.Name,
+			"player":  player,
+			"record":  record,
+			"streaks": streaks,
+			"tables":  tables,
+		})
+	}
+}
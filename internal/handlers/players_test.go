@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+func TestPlayerHandlers(t *testing.T) {
+	s := newTestServer(t)
+	get, post := s.get, s.post
+	playerID := func(name string) int {
+		return s.id(t, "players", name)
+	}
+
+	t.Run("New Play Without Players", func(t *testing.T) {
+		w := get("/plays/new")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, strings.Count(w.Body.String(), "<div data-hero-row"), "the form starts with one empty hero row")
+	})
+
+	plays := s.Plays.For(s.viewer)
+	for _, p := range []struct {
+		date    time.Time
+		outcome string
+		decks   []models.DeckEntry
+	}{
+		{time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), "win", []models.DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: "Ann"},
+			{HeroName: "She-Hulk", Aspects: []string{"aggression"}, PlayerName: "Ben"},
+		}},
+		{time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), "loss", []models.DeckEntry{
+			{HeroName: "She-Hulk", Aspects: []string{"justice"}, PlayerName: "Ann"},
+		}},
+	} {
+		play := &models.Play{Date: p.date, Outcome: p.outcome, Difficulty: "Standard I", ScenarioID: 1}
+		require.NoError(t, plays.CreateWithDecks(play, "", p.decks))
+	}
+
+	t.Run("New Play Remembers Last Group", func(t *testing.T) {
+		w := get("/plays/new")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Equal(t, 1, strings.Count(body, "<div data-hero-row"), "only Ann played last")
+		assert.Contains(t, body, `name="player_name" value="Ann"`)
+		assert.Contains(t, body, `<datalist id="player-options">`)
+		assert.Contains(t, body, `<option value="Ben">`)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		w := get("/players")
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, "<title>Players - Marvel Champions Play Tracker</title>")
+		assert.Contains(t, body, `value="Ann"`)
+		assert.Contains(t, body, "50%")
+		assert.Contains(t, body, "/players/"+strconv.Itoa(playerID("Ben")))
+	})
+
+	t.Run("Player Page", func(t *testing.T) {
+		w := get("/players/" + strconv.Itoa(playerID("Ann")))
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		for _, heading := range []string{"Favorite Heroes", "Win Rate by Aspect", "Most-Played Scenarios"} {
+			assert.Contains(t, body, heading)
+		}
+		assert.Contains(t, body, "She-Hulk")
+		assert.Contains(t, body, "1 loss")
+		assert.NotContains(t, body, "aggression", "Ben's deck is not counted for Ann")
+
+		assert.Equal(t, http.StatusNotFound, get("/players/999").Code)
+		assert.Equal(t, http.StatusNotFound, get("/players/abc").Code)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		w := post("/players", url.Values{"name": {"Cat"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/players", w.Header().Get("Location"))
+
+		w = get("/players/" + strconv.Itoa(playerID("Cat")))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Cat has not played yet.")
+
+		w = post("/players", url.Values{"name": {"ann"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "already exists")
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		w := post("/players/"+strconv.Itoa(playerID("Ben"))+"/rename", url.Values{"name": {"Benjamin"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Contains(t, get("/players").Body.String(), `value="Benjamin"`)
+
+		w = post("/players/999/rename", url.Values{"name": {"Nobody"}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		w := post("/players/"+strconv.Itoa(playerID("Cat"))+"/delete", nil)
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.NotContains(t, get("/players").Body.String(), `value="Cat"`)
+
+		w = post("/players/999/delete", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+	t.Run("Other Users' Players", func(t *testing.T) {
+		user, err := s.Auth.Signup("other", "password1")
+		require.NoError(t, err)
+		id, err := s.Players.For(models.Viewer{UserID: user.ID}).Create("Ann")
+		require.NoError(t, err)
+		other := strconv.Itoa(id)
+
+		assert.Equal(t, 1, strings.Count(get("/players").Body.String(), `value="Ann"`), "only the user's own Ann is listed")
+		assert.Equal(t, http.StatusNotFound, get("/players/"+other).Code)
+		assert.Equal(t, http.StatusNotFound, post("/players/"+other+"/rename", url.Values{"name": {"Anna"}}).Code)
+		assert.Equal(t, http.StatusNotFound, post("/players/"+other+"/delete", nil).Code)
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/internal/stats"
+)
+
+// statsTable is one table on the stats page.
+type statsTable struct {
+	Title string
+	Rows  []stats.Row
+}
+
+// Stats renders win-rate tables for every stats dimension over the plays
+// the user may see, followed by how the losses on each scenario ended. The query
+// parameters from, to (YYYY-MM-DD) and players filter the plays counted,
+// scope narrows them to the user's own plays or a group's (see
+// stats.Filter.SetScope), and sort and dir order every table.
+func Stats(repo *stats.Repository, groups *models.GroupRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := repo.For(auth.Viewer(c))
+		groups, err := statsScopeGroups(c, groups)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		filter, err := parseStatsFilter(c)
+		if err == nil {
+			err = filter.SetScope(c.Query("scope"), auth.Viewer(c).UserID)
+		}
+		var validationErr *models.ValidationError
+		if errors.As(err, &validationErr) {
+			renderStats(c, http.StatusBadRequest, groups, nil, nil, validationErr.Message)
+			return
+		}
+
+		all, err := repo.All(filter)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		lossReasons, err := repo.LossReasons(filter)
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		key, desc := statsSort(c)
+		tables := make([]statsTable, 0, len(stats.Dimensions))
+		for _, dim := range stats.Dimensions {
+			rows := all[dim]
+			stats.Sort(rows, key, desc)
+			tables = append(tables, statsTable{Title: dim.Title(), Rows: rows})
+		}
+
+		renderStats(c, http.StatusOK, groups, tables, lossReasons, "")
+	}
+}
+
+// statsScopeGroups returns the groups offered as scopes on the stats page:
+// the user's own, or none for a visitor.
+func statsScopeGroups(c *gin.Context, groups *models.GroupRepository) ([]models.Group, error) {
+	viewer := auth.Viewer(c)
+	if viewer.UserID == 0 {
+		return nil, nil
+	}
+	return groups.For(viewer).GetAll()
+}
+
+func renderStats(c *gin.Context, status int, groups []models.Group, tables []statsTable, lossReasons []stats.LossReasonRow, message string) {
+	key, desc := statsSort(c)
+	c.HTML(status, "stats.html", gin.H{
+		"title":       "Statistics",
+		"loggedIn":    auth.Viewer(c).UserID != 0,
+		"groups":      groups,
+		"scope":       c.Query("scope"),
+		"tables":      tables,
+		"lossReasons": lossReasons,
+		"endReasons":  models.EndReasonsFor("loss"),
+		"error":       message,
+		"from":        c.Query("from"),
+		"to":          c.Query("to"),
+		"players":     c.Query("players"),
+		"sort":        key,
+		"desc":        desc,
+		"sortURLs":    statsSortURLs(c, key, desc),
+	})
+}
+
+// parseStatsFilter reads the from, to and players query parameters.
+func parseStatsFilter(c *gin.Context) (stats.Filter, error) {
+	return stats.ParseFilter(c.Query("from"), c.Query("to"), c.Query("players"))
+}
+
+// statsSort reads the sort and dir query parameters, defaulting to the most
+// played first.
+func statsSort(c *gin.Context) (key string, desc bool) {
+	key = c.DefaultQuery("sort", "plays")
+	for _, known := range stats.SortKeys {
+		if key == known {
+			return key, c.DefaultQuery("dir", "desc") == "desc"
+		}
+	}
+	return "plays", true
+}
+
+// statsSortURLs returns, for each sortable column, the link a column header
+// should point at: the current filters sorted by that column, flipping the
+// direction if it is already the sort column.
+func statsSortURLs(c *gin.Context, key string, desc bool) map[string]string {
+	urls := make(map[string]string, len(stats.SortKeys))
+	for _, column := range stats.SortKeys {
+		query := url.Values{}
+		for _, name := range []string{"from", "to", "players", "scope"} {
+			if value := c.Query(name); value != "" {
+				query.Set(name, value)
+			}
+		}
+
+		dir := "desc"
+		if column == "name" {
+			dir = "asc"
+		}
+		if column == key {
+			dir = "desc"
+			if desc {
+				dir = "asc"
+			}
+		}
+		query.Set("sort", column)
+		query.Set("dir", dir)
+		urls[column] = "/stats?" + query.Encode()
+	}
+	return urls
+}
@@ -0,0 +1,93 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+func TestStatsHandler(t *testing.T) {
+	s := newTestServer(t)
+	plays := s.Plays.For(s.viewer)
+	for _, p := range []struct {
+		date      time.Time
+		outcome   string
+		endReason string
+		decks     []models.DeckEntry
+	}{
+		{time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), "win", "", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}},
+		{time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), "loss", "conceded", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}, {HeroName: "She-Hulk", Aspects: []string{"pool"}}}},
+	} {
+		play := &models.Play{Date: p.date, Outcome: p.outcome, EndReason: p.endReason, Difficulty: "Standard I", ScenarioID: 1}
+		require.NoError(t, plays.CreateWithDecks(play, "", p.decks))
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		return s.get("/stats" + query)
+	}
+
+	t.Run("All Tables", func(t *testing.T) {
+		w := get("")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		for _, heading := range []string{"By Hero", "By Aspect", "By Hero and Aspect", "By Scenario", "By Difficulty"} {
+			assert.Contains(t, body, heading)
+		}
+		assert.Contains(t, body, "Spider-Man (justice)")
+		assert.Contains(t, body, "50%")
+		assert.Contains(t, body, "Feb 5, 2024")
+		assert.Contains(t, body, "Loss Reasons by Scenario")
+		assert.Contains(t, body, `1 <span class="text-gray-400">(100%)</span>`)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		w := get("?players=2")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `<option value="2" selected>2</option>`)
+		assert.Contains(t, body, "She-Hulk")
+		assert.Contains(t, body, `text-right">0%</td>`)
+		assert.NotContains(t, body, `text-right">100%</td>`, "the one-hero win is filtered out")
+
+		w = get("?from=2024-03-01")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "No plays match these filters.")
+	})
+
+	t.Run("Sort Links", func(t *testing.T) {
+		w := get("?players=1&sort=wins&dir=desc")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		// The current sort column flips direction; the others keep filters.
+		assert.Contains(t, body, `href="/stats?dir=asc&amp;players=1&amp;sort=wins"`)
+		assert.Contains(t, body, `href="/stats?dir=asc&amp;players=1&amp;sort=name"`)
+		assert.Contains(t, body, `href="/stats?dir=desc&amp;players=1&amp;sort=plays"`)
+	})
+
+	t.Run("Sorted Rows", func(t *testing.T) {
+		body := get("?sort=name&dir=asc").Body.String()
+		assert.Less(t, strings.Index(body, "She-Hulk"), strings.Index(body, "Spider-Man"))
+
+		body = get("?sort=plays&dir=desc").Body.String()
+		assert.Less(t, strings.Index(body, "Spider-Man"), strings.Index(body, "She-Hulk"))
+	})
+
+	t.Run("Invalid Filters", func(t *testing.T) {
+		w := get("?from=yesterday")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "from must be in YYYY-MM-DD format")
+
+		w = get("?players=9")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "players must be between 1 and 4")
+	})
+}
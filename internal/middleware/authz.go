@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/models"
+)
+
+// groupKey is the context key RequireGroupRole stores the group under.
+const groupKey = "middleware.group"
+
+// RequireGroupRole loads the group named by the :id route parameter for
+// the current user and lets the request through only if their role in it
+// is at least min. Someone who is not a member gets a 404, so a group's
+// existence is not revealed, and a member with too low a role gets a 403.
+// Handlers read the group back with Group.
+func RequireGroupRole(groups *models.GroupRepository, min models.GroupRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		group, err := groups.For(auth.Viewer(c)).GetByID(id)
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !group.Role.AtLeast(min) {
+			c.Error(fmt.Errorf("group %d: %s role needed, have %s", id, min, group.Role))
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Set(groupKey, group)
+		c.Next()
+	}
+}
+
+// Group returns the group loaded by RequireGroupRole, or nil outside it.
+func Group(c *gin.Context) *models.Group {
+	group, _ := c.Get(groupKey)
+	g, _ := group.(*models.Group)
+	return g
+}
+
+// RequirePlayEditor lets the request through only if the current user may
+// change the play named by the :id route parameter: their own plays, and
+// those of groups they own or edit. A play they cannot see is a 404 and
+// one they can only see is a 403.
+func RequirePlayEditor(plays *models.PlayRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		editable, err := plays.For(auth.Viewer(c)).Editable(id)
+		if errors.Is(err, models.ErrNotFound) {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if !editable {
+			c.Error(fmt.Errorf("play %d: not editable by user %d", id, auth.Viewer(c).UserID))
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/auth"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/migrations"
+)
+
+// setupAuthzTest migrates an in-memory database and adds the users alice,
+// bob and carol with ids 1 to 3. It returns a session cookie for each.
+func setupAuthzTest(t *testing.T) (*sql.DB, map[string]*http.Cookie) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	_, err = db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', ''), (3, 'carol', '')")
+	require.NoError(t, err)
+	store := auth.NewStore(db)
+	cookies := make(map[string]*http.Cookie)
+	for id, name := range []string{"alice", "bob", "carol"} {
+		token, err := store.CreateSession(id + 1)
+		require.NoError(t, err)
+		cookies[name] = &http.Cookie{Name: auth.CookieName, Value: token}
+	}
+	return db, cookies
+}
+
+func TestAuthorization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, cookies := setupAuthzTest(t)
+
+	groups := models.NewGroupRepository(db)
+	plays := models.NewPlayRepository(db)
+	group, err := groups.For(models.Viewer{UserID: 1}).Create("Tuesday Table")
+	require.NoError(t, err)
+	invite, err := groups.CreateInvite(group.ID, models.GroupViewer)
+	require.NoError(t, err)
+	_, err = groups.For(models.Viewer{UserID: 2}).Join(invite.Token)
+	require.NoError(t, err)
+
+	play := &models.Play{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", GroupID: group.ID}
+	require.NoError(t, plays.For(models.Viewer{UserID: 1}).CreateWithDecks(play, "Rhino", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+
+	r := setupRouter()
+	r.Use(auth.Sessions(auth.NewStore(db)))
+	r.GET("/groups/:id", RequireGroupRole(groups, models.GroupViewer), func(c *gin.Context) {
+		c.String(http.StatusOK, "%s %s", Group(c).Name, Group(c).Role)
+	})
+	r.POST("/groups/:id/delete", RequireGroupRole(groups, models.GroupOwner), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.DELETE("/plays/:id", RequirePlayEditor(plays), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	serve := func(method, path, user string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		if cookie := cookies[user]; cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	groupPath := "/groups/" + strconv.Itoa(group.ID)
+	playPath := "/plays/" + strconv.Itoa(play.ID)
+
+	t.Run("Group Roles", func(t *testing.T) {
+		w := serve(http.MethodGet, groupPath, "bob")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Tuesday Table viewer", w.Body.String())
+
+		w = serve(http.MethodPost, groupPath+"/delete", "bob")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Access Denied")
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, groupPath+"/delete", "alice").Code)
+
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, groupPath, "carol").Code, "non-members cannot tell the group exists")
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, groupPath, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/groups/x", "alice").Code)
+	})
+
+	t.Run("Play Editors", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, playPath, "alice").Code)
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodDelete, playPath, "bob").Code, "viewers only see the group's plays")
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, playPath, "carol").Code)
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/plays/999", "alice").Code)
+	})
+}
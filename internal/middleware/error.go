@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -13,7 +13,13 @@ func ErrorHandler() gin.HandlerFunc {
 
 		if len(c.Errors) > 0 {
 			err := c.Errors.Last()
-			log.Printf("Error: %v", err)
+			slog.Error("request failed", "err", err)
+
+			// Handlers that render their own error body, such as the JSON
+			// API, only need the error logged.
+			if c.Writer.Size() > 0 {
+				return
+			}
 
 			switch c.Writer.Status() {
 			case http.StatusNotFound:
@@ -23,6 +29,13 @@ func ErrorHandler() gin.HandlerFunc {
 					"code":    404,
 				})
 				return
+			case http.StatusForbidden:
+				c.HTML(http.StatusForbidden, "error.html", gin.H{
+					"title":   "Access Denied",
+					"message": "You don't have permission to do that.",
+					"code":    403,
+				})
+				return
 			case http.StatusInternalServerError:
 				c.HTML(http.StatusInternalServerError, "error.html", gin.H{
 					"title":   "Internal Server Error",
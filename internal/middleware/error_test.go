@@ -1,23 +1,25 @@
 package middleware
 
 import (
+	"bytes"
 	"errors"
 	"html/template"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"marvel_tracker/templates"
 )
 
 // setupRouter initializes a Gin router for testing.
 func setupRouter() *gin.Engine {
 	r := gin.New()
 
-	// Correctly load all templates from the templates directory.
-	// The path is relative to the project root, where `go test` is run.
-	tmpl, err := template.ParseGlob("../../templates/*.html")
+	// Load the templates the binary is built with.
+	tmpl, err := template.ParseFS(templates.FS, "*.html")
 	if err != nil {
 		panic("Failed to parse templates: " + err.Error())
 	}
@@ -46,6 +48,24 @@ func TestErrorHandler(t *testing.T) {
 		assert.Equal(t, "Success", w.Body.String())
 	})
 
+	t.Run("Logs At The Error Level", func(t *testing.T) {
+		var logged bytes.Buffer
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(slog.New(slog.NewTextHandler(&logged, &slog.HandlerOptions{Level: slog.LevelError})))
+
+		r := setupRouter()
+		r.GET("/", func(c *gin.Context) {
+			c.Error(errors.New("disk full"))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Contains(t, logged.String(), `level=ERROR msg="request failed" err="disk full"`)
+	})
+
 	t.Run("Not Found Error", func(t *testing.T) {
 		r := setupRouter()
 		r.GET("/", func(c *gin.Context) {
@@ -92,6 +112,21 @@ func TestErrorHandler(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "An Unexpected Error Occurred")
 	})
 
+	t.Run("Handler Wrote Its Own Body", func(t *testing.T) {
+		r := setupRouter()
+		r.GET("/", func(c *gin.Context) {
+			c.Error(errors.New("bad input"))
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "bad input"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": "bad input"}`, w.Body.String())
+	})
+
 	t.Run("Multiple Errors in Chain", func(t *testing.T) {
 		r := setupRouter()
 		r.GET("/", func(c *gin.Context) {
@@ -153,7 +188,7 @@ func TestErrorHandler(t *testing.T) {
 			expectedText string
 		}{
 			{http.StatusUnauthorized, "An Unexpected Error Occurred"},
-			{http.StatusForbidden, "An Unexpected Error Occurred"},
+			{http.StatusForbidden, "Access Denied"},
 			{http.StatusBadRequest, "An Unexpected Error Occurred"},
 			{http.StatusConflict, "An Unexpected Error Occurred"},
 			{http.StatusServiceUnavailable, "An Unexpected Error Occurred"},
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Aspect is a row of the aspects lookup table. Besides the four classic
+// aspects it includes Pool and Basic, and new aspects are added by
+// migration rather than code.
+type Aspect struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	SortOrder int    `json:"sort_order"`
+}
+
+type AspectRepository struct {
+	db *sql.DB
+}
+
+func NewAspectRepository(db *sql.DB) *AspectRepository {
+	return &AspectRepository{db: db}
+}
+
+// GetAll returns every aspect in the order they are offered on the New Play
+// form.
+func (r *AspectRepository) GetAll() ([]Aspect, error) {
+	rows, err := r.db.Query("SELECT id, name, sort_order FROM aspects ORDER BY sort_order, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aspects []Aspect
+	for rows.Next() {
+		var a Aspect
+		if err := rows.Scan(&a.ID, &a.Name, &a.SortOrder); err != nil {
+			return nil, err
+		}
+		aspects = append(aspects, a)
+	}
+	return aspects, rows.Err()
+}
+
+// resolveAspects maps aspect names to ids, ignoring case and repeats. An
+// unknown name is a ValidationError.
+func resolveAspects(db dbtx, names []string) ([]int, error) {
+	ids := make([]int, 0, len(names))
+	seen := make(map[int]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		var id int
+		err := db.QueryRow("SELECT id FROM aspects WHERE name = ?", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, &ValidationError{Field: "aspect", Message: fmt.Sprintf("unknown aspect %q", name)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,417 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// CampaignModes lists the values allowed in campaigns.mode. Expert
+// campaigns add the expert campaign cards from the campaign box.
+var CampaignModes = []string{"standard", "expert"}
+
+// CampaignBox is a campaign expansion with its scenarios in campaign
+// order.
+type CampaignBox struct {
+	PackID int
+	Name   string
+	Wave   int
+	// ReleasedOn is nil if the release date is not known.
+	ReleasedOn *time.Time
+	Scenarios  []Scenario
+}
+
+// Campaign is a run through the scenarios of one campaign box.
+type Campaign struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	PackID    int       `json:"pack_id"`
+	Pack      string    `json:"pack"`
+	Mode      string    `json:"mode"`
+	Notes     string    `json:"notes"`
+	StartedOn time.Time `json:"started_on"`
+	// Steps is the number of plays logged in the campaign.
+	Steps int `json:"steps"`
+	// Result is the outcome of the final play, or empty while the campaign
+	// is in progress.
+	Result    string    `json:"result,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished reports whether the final scenario of the campaign was logged.
+func (c Campaign) Finished() bool {
+	return c.Result != ""
+}
+
+// CampaignLog is the state a campaign carries from one scenario to the
+// next, as recorded on the campaign log sheet.
+type CampaignLog struct {
+	Heroes []CampaignHero `json:"heroes,omitempty"`
+	// Counters are values specific to a campaign box, such as the
+	// resources of The Rise of Red Skull or the ship upgrades of Galaxy's
+	// Most Wanted, by name.
+	Counters map[string]int `json:"counters,omitempty"`
+}
+
+// CampaignHero is one hero's part of the campaign log.
+type CampaignHero struct {
+	Hero string `json:"hero"`
+	// HitPoints carried over to the next scenario; nil if the hero starts
+	// it at full health.
+	HitPoints          *int     `json:"hit_points,omitempty"`
+	Upgrades           []string `json:"upgrades,omitempty"`
+	ObligationsRemoved []string `json:"obligations_removed,omitempty"`
+}
+
+// Validate checks that every hero and counter in the log is named and that
+// hit points are not negative.
+func (l CampaignLog) Validate() error {
+	seen := make(map[string]bool, len(l.Heroes))
+	for _, h := range l.Heroes {
+		name := strings.ToLower(strings.TrimSpace(h.Hero))
+		if name == "" {
+			return &ValidationError{Field: "log", Message: "each hero in the campaign log needs a name"}
+		}
+		if seen[name] {
+			return &ValidationError{Field: "log", Message: h.Hero + " appears twice in the campaign log"}
+		}
+		seen[name] = true
+		if h.HitPoints != nil && *h.HitPoints < 0 {
+			return &ValidationError{Field: "log", Message: "hit points cannot be negative"}
+		}
+	}
+	for name := range l.Counters {
+		if strings.TrimSpace(name) == "" {
+			return &ValidationError{Field: "log", Message: "each campaign counter needs a name"}
+		}
+	}
+	return nil
+}
+
+// CampaignEntry is one step of a campaign: a play and the campaign log as
+// it stood after that play.
+type CampaignEntry struct {
+	ID         int         `json:"id"`
+	CampaignID int         `json:"campaign_id"`
+	PlayID     int         `json:"play_id"`
+	Position   int         `json:"position"`
+	Final      bool        `json:"final"`
+	Log        CampaignLog `json:"log"`
+	Play       PlaySummary `json:"play"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type CampaignRepository struct {
+	db     *sql.DB
+	viewer *Viewer
+}
+
+func NewCampaignRepository(db *sql.DB) *CampaignRepository {
+	return &CampaignRepository{db: db}
+}
+
+// For returns a repository limited to the campaigns v may see. Like plays,
+// campaigns belong to the user who started them and are visible to others
+// only if that user shares their plays.
+func (r *CampaignRepository) For(v Viewer) *CampaignRepository {
+	return &CampaignRepository{db: r.db, viewer: &v}
+}
+
+// Boxes returns the campaign expansions in release order, each with its
+// scenarios in the order the campaign plays them.
+func (r *CampaignRepository) Boxes() ([]CampaignBox, error) {
+	rows, err := r.db.Query(`
+		SELECT p.id, p.name, p.wave, p.released_on, s.id, s.name
+		FROM packs p
+		JOIN scenarios s ON s.pack_id = p.id
+		WHERE p.kind = 'campaign'
+		ORDER BY p.wave, p.id, s.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boxes []CampaignBox
+	for rows.Next() {
+		var box CampaignBox
+		var releasedOn sql.NullTime
+		var s Scenario
+		if err := rows.Scan(&box.PackID, &box.Name, &box.Wave, &releasedOn, &s.ID, &s.Name); err != nil {
+			return nil, err
+		}
+		if releasedOn.Valid {
+			box.ReleasedOn = &releasedOn.Time
+		}
+		if n := len(boxes); n == 0 || boxes[n-1].PackID != box.PackID {
+			boxes = append(boxes, box)
+		}
+		last := &boxes[len(boxes)-1]
+		last.Scenarios = append(last.Scenarios, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return boxes, nil
+}
+
+// Box returns the campaign box with the given pack id, or ErrNotFound.
+func (r *CampaignRepository) Box(packID int) (*CampaignBox, error) {
+	boxes, err := r.Boxes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range boxes {
+		if boxes[i].PackID == packID {
+			return &boxes[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Create starts a campaign, which belongs to the repository's viewer. On
+// success c.ID is populated.
+func (r *CampaignRepository) Create(c *Campaign) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return &ValidationError{Field: "name", Message: "name is required"}
+	}
+	if c.Mode == "" {
+		c.Mode = CampaignModes[0]
+	}
+	if !contains(CampaignModes, c.Mode) {
+		return &ValidationError{Field: "mode", Message: "mode must be standard or expert"}
+	}
+	if c.StartedOn.IsZero() {
+		return &ValidationError{Field: "started_on", Message: "start date is required"}
+	}
+	var count int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM packs WHERE id = ? AND kind = 'campaign'", c.PackID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ValidationError{Field: "pack_id", Message: "choose a campaign box"}
+	}
+
+	var ownerID int
+	if r.viewer != nil {
+		ownerID = r.viewer.UserID
+	}
+	result, err := r.db.Exec(
+		"INSERT INTO campaigns (name, pack_id, mode, notes, started_on, owner_id) VALUES (?, ?, ?, ?, ?, ?)",
+		c.Name, c.PackID, c.Mode, c.Notes, c.StartedOn, nullIfZero(ownerID),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	return nil
+}
+
+const campaignSelect = `
+	SELECT c.id, c.name, c.pack_id, p.name, c.mode, COALESCE(c.notes, ''), c.started_on,
+	       (SELECT COUNT(*) FROM campaign_entries e WHERE e.campaign_id = c.id),
+	       COALESCE((SELECT pl.outcome FROM campaign_entries e JOIN plays pl ON pl.id = e.play_id
+	                 WHERE e.campaign_id = c.id AND e.final), ''),
+	       c.created_at, c.updated_at
+	FROM campaigns c
+	JOIN packs p ON p.id = c.pack_id`
+
+// GetAll returns every campaign, most recently started first.
+func (r *CampaignRepository) GetAll() ([]Campaign, error) {
+	visible, args := sharedWith(r.viewer, "c")
+	rows, err := r.db.Query(campaignSelect+" WHERE "+visible+" ORDER BY c.started_on DESC, c.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// GetByID returns the campaign with the given id, or ErrNotFound.
+func (r *CampaignRepository) GetByID(id int) (*Campaign, error) {
+	visible, args := sharedWith(r.viewer, "c")
+	c, err := scanCampaign(r.db.QueryRow(campaignSelect+" WHERE c.id = ? AND "+visible, append([]any{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+func scanCampaign(row rowScanner) (*Campaign, error) {
+	var c Campaign
+	err := row.Scan(&c.ID, &c.Name, &c.PackID, &c.Pack, &c.Mode, &c.Notes, &c.StartedOn,
+		&c.Steps, &c.Result, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Entries returns the steps of a campaign in the order they were played,
+// each with its play.
+func (r *CampaignRepository) Entries(campaignID int) ([]CampaignEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT id, campaign_id, play_id, position, final, log, created_at
+		FROM campaign_entries WHERE campaign_id = ? ORDER BY position`, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []CampaignEntry
+	for rows.Next() {
+		var e CampaignEntry
+		var log string
+		if err := rows.Scan(&e.ID, &e.CampaignID, &e.PlayID, &e.Position, &e.Final, &log, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(log), &e.Log); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	summaryRows, err := r.db.Query(
+		playSummarySelect+" WHERE p.id IN (SELECT play_id FROM campaign_entries WHERE campaign_id = ?) ORDER BY p.id, d.id",
+		campaignID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer summaryRows.Close()
+	summaries, err := scanPlaySummaries(summaryRows)
+	if err != nil {
+		return nil, err
+	}
+	plays := make(map[int]PlaySummary, len(summaries))
+	for _, s := range summaries {
+		plays[s.ID] = s
+	}
+	for i := range entries {
+		entries[i].Play = plays[entries[i].PlayID]
+	}
+	return entries, nil
+}
+
+// AvailablePlays returns the plays of the campaign's scenarios that are
+// not yet part of any campaign, newest first: the plays that can be logged
+// as its next step. Only the viewer's own plays are offered.
+func (r *CampaignRepository) AvailablePlays(campaignID int) ([]PlaySummary, error) {
+	owned, args := ownedBy(r.viewer, "p")
+	rows, err := r.db.Query(playSummarySelect+`
+		WHERE s.pack_id = (SELECT pack_id FROM campaigns WHERE id = ?)
+		  AND p.id NOT IN (SELECT play_id FROM campaign_entries)
+		  AND `+owned+`
+		ORDER BY p.date DESC, p.id DESC, d.id`, append([]any{campaignID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPlaySummaries(rows)
+}
+
+// AddEntry logs a play as the next step of a campaign, together with the
+// campaign log after it. Marking the entry final ends the campaign. The
+// play must be of one of the campaign's scenarios and not part of another
+// campaign. A viewer may only log their own plays in their own campaigns.
+// On success e.ID and e.Position are populated.
+func (r *CampaignRepository) AddEntry(e *CampaignEntry) error {
+	if err := e.Log.Validate(); err != nil {
+		return err
+	}
+	log, err := json.Marshal(e.Log)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ownedCampaign, args := ownedBy(r.viewer, "c")
+	var packID int
+	var finished bool
+	err = tx.QueryRow(`
+		SELECT pack_id, EXISTS (SELECT 1 FROM campaign_entries WHERE campaign_id = c.id AND final)
+		FROM campaigns c WHERE id = ? AND `+ownedCampaign, append([]any{e.CampaignID}, args...)...).Scan(&packID, &finished)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if finished {
+		return &ValidationError{Field: "play_id", Message: "the campaign is over"}
+	}
+
+	ownedPlay, args := ownedBy(r.viewer, "p")
+	var playPackID sql.NullInt64
+	var inCampaign bool
+	err = tx.QueryRow(`
+		SELECT s.pack_id, EXISTS (SELECT 1 FROM campaign_entries WHERE play_id = p.id)
+		FROM plays p JOIN scenarios s ON s.id = p.scenario_id
+		WHERE p.id = ? AND `+ownedPlay, append([]any{e.PlayID}, args...)...).Scan(&playPackID, &inCampaign)
+	if err == sql.ErrNoRows {
+		return &ValidationError{Field: "play_id", Message: "choose a play"}
+	}
+	if err != nil {
+		return err
+	}
+	if !playPackID.Valid || int(playPackID.Int64) != packID {
+		return &ValidationError{Field: "play_id", Message: "the play is not of a scenario from this campaign"}
+	}
+	if inCampaign {
+		return &ValidationError{Field: "play_id", Message: "the play is already part of a campaign"}
+	}
+
+	err = tx.QueryRow("SELECT COALESCE(MAX(position), 0) + 1 FROM campaign_entries WHERE campaign_id = ?", e.CampaignID).Scan(&e.Position)
+	if err != nil {
+		return err
+	}
+	result, err := tx.Exec(
+		"INSERT INTO campaign_entries (campaign_id, play_id, position, final, log) VALUES (?, ?, ?, ?, ?)",
+		e.CampaignID, e.PlayID, e.Position, e.Final, string(log),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE campaigns SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", e.CampaignID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	e.ID = int(id)
+	return nil
+}
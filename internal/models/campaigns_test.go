@@ -0,0 +1,158 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaignRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	plays := NewPlayRepository(db)
+	campaigns := NewCampaignRepository(db)
+
+	redSkull := catalogID(t, db, "packs", "The Rise of Red Skull")
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+	crossbones := catalogID(t, db, "scenarios", "Crossbones")
+	absorbingMan := catalogID(t, db, "scenarios", "Absorbing Man")
+	redSkullScenario := catalogID(t, db, "scenarios", "Red Skull")
+	spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+	hawkeye := catalogID(t, db, "heroes", "Hawkeye")
+
+	logPlay := func(scenarioID int, outcome string) int {
+		play := &Play{Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Outcome: outcome, Difficulty: "Standard I", ScenarioID: scenarioID}
+		require.NoError(t, plays.CreateWithDecks(play, "", []DeckEntry{
+			{HeroID: spiderMan, Aspects: []string{"justice"}},
+			{HeroID: hawkeye, Aspects: []string{"leadership"}},
+		}))
+		return play.ID
+	}
+	hp := func(n int) *int { return &n }
+
+	t.Run("Boxes", func(t *testing.T) {
+		boxes, err := campaigns.Boxes()
+		require.NoError(t, err)
+		i := slices.IndexFunc(boxes, func(b CampaignBox) bool { return b.PackID == redSkull })
+		require.NotEqual(t, -1, i)
+		assert.Equal(t, "The Rise of Red Skull", boxes[i].Name)
+		require.Len(t, boxes[i].Scenarios, 5)
+		assert.Equal(t, "Crossbones", boxes[i].Scenarios[0].Name)
+		assert.Equal(t, "Red Skull", boxes[i].Scenarios[4].Name)
+
+		_, err = campaigns.Box(catalogID(t, db, "packs", "Core Set"))
+		assert.ErrorIs(t, err, ErrNotFound, "the core set is not a campaign box")
+	})
+
+	campaign := &Campaign{Name: "Avengers Assemble", PackID: redSkull, StartedOn: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}
+
+	t.Run("Create", func(t *testing.T) {
+		var validationErr *ValidationError
+		err := campaigns.Create(&Campaign{Name: " ", PackID: redSkull, StartedOn: campaign.StartedOn})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "name", validationErr.Field)
+
+		err = campaigns.Create(&Campaign{Name: "Core", PackID: catalogID(t, db, "packs", "Core Set"), StartedOn: campaign.StartedOn})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "choose a campaign box", validationErr.Message)
+
+		err = campaigns.Create(&Campaign{Name: "Hard", PackID: redSkull, Mode: "heroic", StartedOn: campaign.StartedOn})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "mode", validationErr.Field)
+
+		require.NoError(t, campaigns.Create(campaign))
+		assert.NotZero(t, campaign.ID)
+
+		got, err := campaigns.GetByID(campaign.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Avengers Assemble", got.Name)
+		assert.Equal(t, "The Rise of Red Skull", got.Pack)
+		assert.Equal(t, "standard", got.Mode)
+		assert.Zero(t, got.Steps)
+		assert.False(t, got.Finished())
+
+		_, err = campaigns.GetByID(999)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Log Steps", func(t *testing.T) {
+		crossbonesPlay := logPlay(crossbones, "win")
+		rhinoPlay := logPlay(rhino, "win")
+
+		available, err := campaigns.AvailablePlays(campaign.ID)
+		require.NoError(t, err)
+		require.Len(t, available, 1, "only plays of the box's scenarios")
+		assert.Equal(t, crossbonesPlay, available[0].ID)
+
+		var validationErr *ValidationError
+		err = campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: rhinoPlay})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "the play is not of a scenario from this campaign", validationErr.Message)
+
+		err = campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: crossbonesPlay, Log: CampaignLog{
+			Heroes: []CampaignHero{{Hero: "Spider-Man", HitPoints: hp(-1)}},
+		}})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "hit points cannot be negative", validationErr.Message)
+
+		first := &CampaignEntry{CampaignID: campaign.ID, PlayID: crossbonesPlay, Log: CampaignLog{
+			Heroes: []CampaignHero{
+				{Hero: "Spider-Man", HitPoints: hp(7), Upgrades: []string{"Improvised Weapon"}},
+				{Hero: "Hawkeye", ObligationsRemoved: []string{"Hawkeye's obligation"}},
+			},
+			Counters: map[string]int{"Resources": 2},
+		}}
+		require.NoError(t, campaigns.AddEntry(first))
+		assert.Equal(t, 1, first.Position)
+
+		err = campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: crossbonesPlay})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "the play is already part of a campaign", validationErr.Message)
+
+		// A lost scenario is replayed.
+		lost := logPlay(absorbingMan, "loss")
+		require.NoError(t, campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: lost, Log: first.Log}))
+		replay := logPlay(absorbingMan, "win")
+		require.NoError(t, campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: replay, Log: first.Log}))
+
+		entries, err := campaigns.Entries(campaign.ID)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, []int{1, 2, 3}, []int{entries[0].Position, entries[1].Position, entries[2].Position})
+		assert.Equal(t, "Crossbones", entries[0].Play.Scenario)
+		assert.Equal(t, "loss", entries[1].Play.Outcome)
+		assert.Len(t, entries[0].Play.Heroes, 2)
+		assert.Equal(t, first.Log, entries[0].Log)
+
+		_, err = db.Exec("PRAGMA foreign_keys = ON")
+		require.NoError(t, err)
+		require.NoError(t, plays.Delete(lost))
+		entries, err = campaigns.Entries(campaign.ID)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2, "deleting a play removes its step")
+	})
+
+	t.Run("Finish", func(t *testing.T) {
+		final := logPlay(redSkullScenario, "win")
+		require.NoError(t, campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: final, Final: true}))
+
+		got, err := campaigns.GetByID(campaign.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Steps)
+		assert.Equal(t, "win", got.Result)
+		assert.True(t, got.Finished())
+
+		var validationErr *ValidationError
+		err = campaigns.AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: logPlay(crossbones, "win")})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "the campaign is over", validationErr.Message)
+
+		all, err := campaigns.GetAll()
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, *got, all[0])
+	})
+}
@@ -0,0 +1,495 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// CatalogEntry is the read model for the hero and scenario management
+// pages: a hero or scenario together with how often it has been played.
+type CatalogEntry struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Pack       string     `json:"pack,omitempty"`
+	Wave       int        `json:"wave,omitempty"`
+	ReleasedOn *time.Time `json:"released_on,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	Uses       int        `json:"uses"`
+}
+
+// Official reports whether the entry comes from the seeded catalog rather
+// than being added by a user.
+func (e CatalogEntry) Official() bool {
+	return e.Pack != ""
+}
+
+// Archived reports whether the entry is hidden from the New Play form.
+func (e CatalogEntry) Archived() bool {
+	return e.ArchivedAt != nil
+}
+
+type HeroRepository struct {
+	db *sql.DB
+}
+
+func NewHeroRepository(db *sql.DB) *HeroRepository {
+	return &HeroRepository{db: db}
+}
+
+// GetAll returns every hero ordered by name, including archived ones, with
+// pack details for heroes from the catalog.
+func (r *HeroRepository) GetAll() ([]Hero, error) {
+	return r.list(false)
+}
+
+// GetActive returns the heroes that have not been archived, ordered by
+// name. These are the heroes offered on the New Play form.
+func (r *HeroRepository) GetActive() ([]Hero, error) {
+	return r.list(true)
+}
+
+// GetByID returns the hero with the given id, or ErrNotFound.
+func (r *HeroRepository) GetByID(id int) (*Hero, error) {
+	row, err := heroesTable.get(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	hero := row.hero()
+	return &hero, nil
+}
+
+func (r *HeroRepository) list(activeOnly bool) ([]Hero, error) {
+	rows, err := heroesTable.list(r.db, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	heroes := make([]Hero, 0, len(rows))
+	for _, row := range rows {
+		heroes = append(heroes, row.hero())
+	}
+	return heroes, nil
+}
+
+// Entries returns every hero with the number of decks it appears in.
+func (r *HeroRepository) Entries() ([]CatalogEntry, error) {
+	return heroesTable.entries(r.db)
+}
+
+// Create adds a user-defined hero, such as a fan-made one, and returns its id.
+func (r *HeroRepository) Create(name string) (int, error) {
+	return heroesTable.create(r.db, name)
+}
+
+// Rename changes the name of a user-added hero.
+func (r *HeroRepository) Rename(id int, name string) error {
+	return heroesTable.rename(r.db, id, name)
+}
+
+// SetArchived hides a hero from the New Play form, or shows it again.
+func (r *HeroRepository) SetArchived(id int, archived bool) error {
+	return heroesTable.setArchived(r.db, id, archived)
+}
+
+// Merge moves every deck that uses hero fromID over to hero intoID and then
+// deletes fromID. It is meant for consolidating duplicates created by typos.
+func (r *HeroRepository) Merge(fromID, intoID int) error {
+	return heroesTable.merge(r.db, fromID, intoID)
+}
+
+// Delete removes a user-added hero that no deck uses. Heroes that have
+// been played should be archived or merged instead.
+func (r *HeroRepository) Delete(id int) error {
+	return heroesTable.delete(r.db, id)
+}
+
+type ScenarioRepository struct {
+	db *sql.DB
+}
+
+func NewScenarioRepository(db *sql.DB) *ScenarioRepository {
+	return &ScenarioRepository{db: db}
+}
+
+// GetAll returns every scenario ordered by name, including archived ones,
+// with pack details for scenarios from the catalog.
+func (r *ScenarioRepository) GetAll() ([]Scenario, error) {
+	return r.list(false)
+}
+
+// GetActive returns the scenarios that have not been archived, ordered by
+// name. These are the scenarios offered on the New Play form.
+func (r *ScenarioRepository) GetActive() ([]Scenario, error) {
+	return r.list(true)
+}
+
+// GetByID returns the scenario with the given id, or ErrNotFound.
+func (r *ScenarioRepository) GetByID(id int) (*Scenario, error) {
+	row, err := scenariosTable.get(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	scenario := row.scenario()
+	return &scenario, nil
+}
+
+func (r *ScenarioRepository) list(activeOnly bool) ([]Scenario, error) {
+	rows, err := scenariosTable.list(r.db, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios := make([]Scenario, 0, len(rows))
+	for _, row := range rows {
+		scenarios = append(scenarios, row.scenario())
+	}
+	return scenarios, nil
+}
+
+// Entries returns every scenario with the number of plays against it.
+func (r *ScenarioRepository) Entries() ([]CatalogEntry, error) {
+	return scenariosTable.entries(r.db)
+}
+
+// Create adds a user-defined scenario, such as a custom villain, and
+// returns its id.
+func (r *ScenarioRepository) Create(name string) (int, error) {
+	return scenariosTable.create(r.db, name)
+}
+
+// Rename changes the name of a user-added scenario.
+func (r *ScenarioRepository) Rename(id int, name string) error {
+	return scenariosTable.rename(r.db, id, name)
+}
+
+// SetArchived hides a scenario from the New Play form, or shows it again.
+func (r *ScenarioRepository) SetArchived(id int, archived bool) error {
+	return scenariosTable.setArchived(r.db, id, archived)
+}
+
+// Merge moves every play of scenario fromID over to scenario intoID and
+// then deletes fromID.
+func (r *ScenarioRepository) Merge(fromID, intoID int) error {
+	return scenariosTable.merge(r.db, fromID, intoID)
+}
+
+// Delete removes a user-added scenario that has never been played.
+func (r *ScenarioRepository) Delete(id int) error {
+	return scenariosTable.delete(r.db, id)
+}
+
+// catalogTable describes one of the name-keyed catalog tables and the
+// column that references it, so heroes and scenarios can share the same
+// management queries. All names are constants from this package.
+type catalogTable struct {
+	table     string
+	field     string
+	refTable  string
+	refColumn string
+}
+
+var (
+	heroesTable    = catalogTable{table: "heroes", field: "hero", refTable: "decks", refColumn: "hero_id"}
+	scenariosTable = catalogTable{table: "scenarios", field: "scenario", refTable: "plays", refColumn: "scenario_id"}
+)
+
+// catalogRow is a row of a catalog table with its pack joined in.
+type catalogRow struct {
+	ID         int
+	Name       string
+	PackID     *int
+	Pack       string
+	Wave       int
+	ReleasedOn *time.Time
+	ArchivedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	Uses       int
+}
+
+func (r catalogRow) hero() Hero {
+	return Hero{
+		ID:         r.ID,
+		Name:       r.Name,
+		PackID:     r.PackID,
+		Pack:       r.Pack,
+		Wave:       r.Wave,
+		ReleasedOn: r.ReleasedOn,
+		ArchivedAt: r.ArchivedAt,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
+
+func (r catalogRow) scenario() Scenario {
+	return Scenario{
+		ID:         r.ID,
+		Name:       r.Name,
+		PackID:     r.PackID,
+		Pack:       r.Pack,
+		Wave:       r.Wave,
+		ReleasedOn: r.ReleasedOn,
+		ArchivedAt: r.ArchivedAt,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
+
+func (t catalogTable) selectRows() string {
+	return `
+		SELECT t.id, t.name, t.pack_id, p.name, p.wave, p.released_on, t.archived_at, t.created_at, t.updated_at,
+		       (SELECT COUNT(*) FROM ` + t.refTable + ` r WHERE r.` + t.refColumn + ` = t.id)
+		FROM ` + t.table + ` t
+		LEFT JOIN packs p ON p.id = t.pack_id`
+}
+
+func (t catalogTable) list(db dbtx, activeOnly bool) ([]catalogRow, error) {
+	query := t.selectRows()
+	if activeOnly {
+		query += " WHERE t.archived_at IS NULL"
+	}
+	query += " ORDER BY t.name COLLATE NOCASE"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []catalogRow
+	for rows.Next() {
+		row, err := scanCatalogRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (t catalogTable) get(db dbtx, id int) (catalogRow, error) {
+	row, err := scanCatalogRow(db.QueryRow(t.selectRows()+" WHERE t.id = ?", id))
+	if err == sql.ErrNoRows {
+		return catalogRow{}, ErrNotFound
+	}
+	return row, err
+}
+
+func scanCatalogRow(s rowScanner) (catalogRow, error) {
+	var row catalogRow
+	var pack catalogPack
+	var archivedAt sql.NullTime
+	err := s.Scan(&row.ID, &row.Name, &pack.id, &pack.name, &pack.wave, &pack.releasedOn, &archivedAt,
+		&row.CreatedAt, &row.UpdatedAt, &row.Uses)
+	if err != nil {
+		return catalogRow{}, err
+	}
+	row.PackID, row.Pack, row.Wave, row.ReleasedOn = pack.values()
+	if archivedAt.Valid {
+		row.ArchivedAt = &archivedAt.Time
+	}
+	return row, nil
+}
+
+func (t catalogTable) entries(db dbtx) ([]CatalogEntry, error) {
+	rows, err := t.list(db, false)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]CatalogEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, CatalogEntry{
+			ID:         row.ID,
+			Name:       row.Name,
+			Pack:       row.Pack,
+			Wave:       row.Wave,
+			ReleasedOn: row.ReleasedOn,
+			ArchivedAt: row.ArchivedAt,
+			Uses:       row.Uses,
+		})
+	}
+	return entries, nil
+}
+
+func (t catalogTable) create(db dbtx, name string) (int, error) {
+	name, err := t.checkName(db, 0, name)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec("INSERT INTO "+t.table+" (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (t catalogTable) rename(db dbtx, id int, name string) error {
+	official, err := t.isOfficial(db, id)
+	if err != nil {
+		return err
+	}
+	if official {
+		return &ValidationError{Field: "name", Message: "official " + t.table + " cannot be renamed"}
+	}
+
+	name, err = t.checkName(db, id, name)
+	if err != nil {
+		return err
+	}
+
+	result, err := db.Exec("UPDATE "+t.table+" SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", name, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+func (t catalogTable) setArchived(db dbtx, id int, archived bool) error {
+	query := "UPDATE " + t.table + " SET archived_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+	if archived {
+		query = "UPDATE " + t.table + " SET archived_at = COALESCE(archived_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = ?"
+	}
+
+	result, err := db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// merge re-points every reference to fromID at intoID and deletes fromID,
+// all in one transaction. Official entries cannot be merged away because a
+// later catalog migration would simply recreate them.
+func (t catalogTable) merge(db *sql.DB, fromID, intoID int) error {
+	if fromID == intoID {
+		return &ValidationError{Field: "into_id", Message: "cannot merge a " + t.field + " into itself"}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	official, err := t.isOfficial(tx, fromID)
+	if err != nil {
+		return err
+	}
+	if official {
+		return &ValidationError{Field: "from_id", Message: "official " + t.table + " cannot be merged away"}
+	}
+	if _, err := t.isOfficial(tx, intoID); err != nil {
+		return err
+	}
+
+	if t.refTable == "decks" {
+		// A play may not list the same hero twice, so refuse merges that
+		// would produce that rather than silently dropping a deck.
+		var shared int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM decks a JOIN decks b ON a.play_id = b.play_id
+			WHERE a.hero_id = ? AND b.hero_id = ?`, fromID, intoID).Scan(&shared)
+		if err != nil {
+			return err
+		}
+		if shared > 0 {
+			return &ValidationError{Field: "from_id", Message: "both heroes appear in the same play"}
+		}
+	}
+
+	_, err = tx.Exec("UPDATE "+t.refTable+" SET "+t.refColumn+" = ?, updated_at = CURRENT_TIMESTAMP WHERE "+t.refColumn+" = ?", intoID, fromID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+t.table+" WHERE id = ?", fromID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// delete removes a user-added row that nothing references. Official rows
+// would come back with the next catalog migration, and referenced rows
+// would take history with them, so both are refused.
+func (t catalogTable) delete(db dbtx, id int) error {
+	row, err := t.get(db, id)
+	if err != nil {
+		return err
+	}
+	if row.PackID != nil {
+		return &ValidationError{Field: "id", Message: "official " + t.table + " cannot be deleted"}
+	}
+	if row.Uses > 0 {
+		return &ValidationError{Field: "id", Message: "this " + t.field + " has been played; archive or merge it instead"}
+	}
+
+	_, err = db.Exec("DELETE FROM "+t.table+" WHERE id = ?", id)
+	return err
+}
+
+// checkName trims name and makes sure no other row already uses it,
+// ignoring case. excludeID is the row being renamed, or zero.
+func (t catalogTable) checkName(db dbtx, excludeID int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &ValidationError{Field: "name", Message: "name is required"}
+	}
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM "+t.table+" WHERE name = ? COLLATE NOCASE AND id != ?", name, excludeID).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", &ValidationError{Field: "name", Message: "a " + t.field + " named " + name + " already exists"}
+	}
+	return name, nil
+}
+
+// isOfficial reports whether the row belongs to a catalog pack, or returns
+// ErrNotFound if there is no such row.
+func (t catalogTable) isOfficial(db dbtx, id int) (bool, error) {
+	var packID sql.NullInt64
+	err := db.QueryRow("SELECT pack_id FROM "+t.table+" WHERE id = ?", id).Scan(&packID)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	return packID.Valid, nil
+}
+
+// catalogPack holds the nullable columns of the packs table joined onto a
+// hero or scenario row. Heroes and scenarios added by users have no pack.
+type catalogPack struct {
+	id         sql.NullInt64
+	name       sql.NullString
+	wave       sql.NullInt64
+	releasedOn sql.NullTime
+}
+
+// values returns the pack's id, name, wave and release date, which is nil
+// if it is not known.
+func (p catalogPack) values() (*int, string, int, *time.Time) {
+	if !p.id.Valid {
+		return nil, "", 0, nil
+	}
+	id := int(p.id.Int64)
+	var releasedOn *time.Time
+	if p.releasedOn.Valid {
+		releasedOn = &p.releasedOn.Time
+	}
+	return &id, p.name.String, int(p.wave.Int64), releasedOn
+}
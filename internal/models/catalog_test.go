@@ -0,0 +1,335 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeroRepository_GetAll(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewHeroRepository(db)
+
+	_, err := db.Exec("INSERT INTO heroes (name) VALUES ('squirrel Girl')")
+	require.NoError(t, err)
+
+	heroes, err := repo.GetAll()
+	require.NoError(t, err)
+	require.Len(t, heroes, countRows(t, db, "heroes"))
+
+	// Sorted by name, ignoring case
+	assert.True(t, slices.IsSortedFunc(heroes, func(a, b Hero) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	}))
+
+	i := slices.IndexFunc(heroes, func(h Hero) bool { return h.Name == "squirrel Girl" })
+	require.NotEqual(t, -1, i)
+	assert.Nil(t, heroes[i].PackID)
+	assert.Empty(t, heroes[i].Pack)
+	assert.Nil(t, heroes[i].ReleasedOn)
+
+	i = slices.IndexFunc(heroes, func(h Hero) bool { return h.Name == "Iron Man" })
+	require.NotEqual(t, -1, i)
+	require.NotNil(t, heroes[i].PackID)
+	assert.Equal(t, catalogID(t, db, "packs", "Core Set"), *heroes[i].PackID)
+	assert.Equal(t, "Core Set", heroes[i].Pack)
+	assert.Equal(t, 1, heroes[i].Wave)
+	require.NotNil(t, heroes[i].ReleasedOn)
+	assert.Equal(t, "2019-11-01", heroes[i].ReleasedOn.Format(time.DateOnly))
+}
+
+func TestScenarioRepository_GetAll(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewScenarioRepository(db)
+
+	res, err := db.Exec("INSERT INTO packs (name, kind, wave) VALUES ('Fan Campaign', 'campaign', 99)")
+	require.NoError(t, err)
+	packID, err := res.LastInsertId()
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO scenarios (name, pack_id) VALUES ('Galactus', ?), ('Dormammu', NULL)", packID)
+	require.NoError(t, err)
+
+	scenarios, err := repo.GetAll()
+	require.NoError(t, err)
+	require.Len(t, scenarios, countRows(t, db, "scenarios"))
+	byName := make(map[string]Scenario, len(scenarios))
+	for _, s := range scenarios {
+		byName[s.Name] = s
+	}
+
+	assert.Equal(t, "The Rise of Red Skull", byName["Crossbones"].Pack)
+	assert.Equal(t, 2, byName["Crossbones"].Wave)
+	require.NotNil(t, byName["Crossbones"].ReleasedOn)
+	assert.Equal(t, "2020-06-26", byName["Crossbones"].ReleasedOn.Format(time.DateOnly))
+
+	assert.Equal(t, "Fan Campaign", byName["Galactus"].Pack)
+	assert.Nil(t, byName["Galactus"].ReleasedOn, "the release date is not known")
+
+	assert.Nil(t, byName["Dormammu"].PackID)
+}
+
+func TestPlayRepository_CreateWithDecksByID(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+	groot := catalogID(t, db, "heroes", "Groot")
+
+	t.Run("Known IDs", func(t *testing.T) {
+		play := &Play{
+			Date:       time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+			ScenarioID: rhino,
+		}
+		require.NoError(t, repo.CreateWithDecks(play, "", []DeckEntry{{HeroID: groot, Aspects: []string{"protection"}}}))
+
+		summary, err := repo.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Rhino", summary.Scenario)
+		assert.Equal(t, []HeroAspect{{HeroID: groot, Hero: "Groot", Aspects: []string{"protection"}}}, summary.Heroes)
+	})
+
+	t.Run("Unknown IDs", func(t *testing.T) {
+		testCases := []struct {
+			name       string
+			scenarioID int
+			heroID     int
+			field      string
+		}{
+			{"Unknown Scenario", 9999, groot, "scenario"},
+			{"Unknown Hero", rhino, 9999, "hero"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				play := &Play{
+					Date:       time.Date(2024, 4, 2, 0, 0, 0, 0, time.UTC),
+					Outcome:    "win",
+					Difficulty: "Standard I",
+					ScenarioID: tc.scenarioID,
+				}
+				err := repo.CreateWithDecks(play, "", []DeckEntry{{HeroID: tc.heroID, Aspects: []string{"justice"}}})
+
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tc.field, validationErr.Field)
+			})
+		}
+	})
+}
+
+func TestHeroRepository_Management(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	heroes := NewHeroRepository(db)
+	plays := NewPlayRepository(db)
+
+	spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+
+	t.Run("Create", func(t *testing.T) {
+		id, err := heroes.Create("  Squirrel Girl ")
+		require.NoError(t, err)
+		assert.NotZero(t, id)
+
+		var name string
+		require.NoError(t, db.QueryRow("SELECT name FROM heroes WHERE id = ?", id).Scan(&name))
+		assert.Equal(t, "Squirrel Girl", name)
+	})
+
+	t.Run("Create Rejects Duplicates and Blanks", func(t *testing.T) {
+		var validationErr *ValidationError
+
+		_, err := heroes.Create("spider-man")
+		require.ErrorAs(t, err, &validationErr)
+		assert.Contains(t, validationErr.Message, "already exists")
+
+		_, err = heroes.Create("   ")
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "name is required", validationErr.Message)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		id, err := heroes.Create("Moon Knigth")
+		require.NoError(t, err)
+
+		require.NoError(t, heroes.Rename(id, "Moon Knight"))
+
+		var name string
+		require.NoError(t, db.QueryRow("SELECT name FROM heroes WHERE id = ?", id).Scan(&name))
+		assert.Equal(t, "Moon Knight", name)
+
+		// Renaming to its own name with different case is allowed
+		require.NoError(t, heroes.Rename(id, "moon knight"))
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, heroes.Rename(spiderMan, "Spidey"), &validationErr, "official heroes cannot be renamed")
+		assert.ErrorIs(t, heroes.Rename(999, "Nobody"), ErrNotFound)
+	})
+
+	t.Run("Archive and Restore", func(t *testing.T) {
+		id, err := heroes.Create("Retired Hero")
+		require.NoError(t, err)
+
+		require.NoError(t, heroes.SetArchived(id, true))
+		active, err := heroes.GetActive()
+		require.NoError(t, err)
+		for _, h := range active {
+			assert.NotEqual(t, id, h.ID)
+		}
+
+		all, err := heroes.GetAll()
+		require.NoError(t, err)
+		var archived *Hero
+		for i := range all {
+			if all[i].ID == id {
+				archived = &all[i]
+			}
+		}
+		require.NotNil(t, archived)
+		assert.NotNil(t, archived.ArchivedAt)
+
+		require.NoError(t, heroes.SetArchived(id, false))
+		active, err = heroes.GetActive()
+		require.NoError(t, err)
+		var found bool
+		for _, h := range active {
+			found = found || h.ID == id
+		}
+		assert.True(t, found)
+
+		assert.ErrorIs(t, heroes.SetArchived(999, true), ErrNotFound)
+	})
+
+	t.Run("Get and Delete", func(t *testing.T) {
+		hero, err := heroes.GetByID(spiderMan)
+		require.NoError(t, err)
+		assert.Equal(t, "Spider-Man", hero.Name)
+		assert.Equal(t, "Core Set", hero.Pack)
+
+		_, err = heroes.GetByID(999)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		id, err := heroes.Create("Unplayed Hero")
+		require.NoError(t, err)
+		require.NoError(t, heroes.Delete(id))
+		_, err = heroes.GetByID(id)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, heroes.Delete(id), ErrNotFound)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, heroes.Delete(spiderMan), &validationErr)
+		assert.Equal(t, "official heroes cannot be deleted", validationErr.Message)
+
+		played, err := heroes.Create("Played Hero")
+		require.NoError(t, err)
+		play := &Play{Date: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino}
+		require.NoError(t, plays.CreateWithDecks(play, "", []DeckEntry{{HeroID: played, Aspects: []string{"pool"}}}))
+		require.ErrorAs(t, heroes.Delete(played), &validationErr)
+		assert.Equal(t, "this hero has been played; archive or merge it instead", validationErr.Message)
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		typoID, err := heroes.Create("Spidre-Man")
+		require.NoError(t, err)
+
+		play := &Play{
+			Date:       time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+			ScenarioID: rhino,
+		}
+		require.NoError(t, plays.CreateWithDecks(play, "", []DeckEntry{{HeroID: typoID, Aspects: []string{"justice"}}}))
+
+		require.NoError(t, heroes.Merge(typoID, spiderMan))
+
+		summary, err := plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []HeroAspect{{HeroID: spiderMan, Hero: "Spider-Man", Aspects: []string{"justice"}}}, summary.Heroes)
+
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes WHERE id = ?", typoID).Scan(&count))
+		assert.Zero(t, count)
+
+		entries, err := heroes.Entries()
+		require.NoError(t, err)
+		for _, e := range entries {
+			if e.ID == spiderMan {
+				assert.Equal(t, 1, e.Uses)
+				assert.True(t, e.Official())
+			}
+		}
+	})
+
+	t.Run("Merge Refusals", func(t *testing.T) {
+		a, err := heroes.Create("Hero A")
+		require.NoError(t, err)
+		b, err := heroes.Create("Hero B")
+		require.NoError(t, err)
+
+		play := &Play{
+			Date:       time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC),
+			Outcome:    "loss",
+			Difficulty: "Standard I",
+			ScenarioID: rhino,
+		}
+		require.NoError(t, plays.CreateWithDecks(play, "", []DeckEntry{
+			{HeroID: a, Aspects: []string{"justice"}},
+			{HeroID: b, Aspects: []string{"leadership"}},
+		}))
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, heroes.Merge(a, b), &validationErr, "heroes in the same play")
+		assert.ErrorAs(t, heroes.Merge(a, a), &validationErr, "merge into itself")
+		assert.ErrorAs(t, heroes.Merge(spiderMan, a), &validationErr, "official hero merged away")
+		assert.ErrorIs(t, heroes.Merge(a, 999), ErrNotFound)
+
+		var deckCount int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM decks WHERE play_id = ?", play.ID).Scan(&deckCount))
+		assert.Equal(t, 2, deckCount)
+	})
+}
+
+func TestScenarioRepository_Merge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	scenarios := NewScenarioRepository(db)
+	plays := NewPlayRepository(db)
+
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+	typoID, err := scenarios.Create("Rhyno")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		play := &Play{
+			Date:       time.Date(2024, 8, 1+i, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+			ScenarioID: typoID,
+		}
+		require.NoError(t, plays.CreateWithDecks(play, "", nil))
+	}
+
+	require.NoError(t, scenarios.Merge(typoID, rhino))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays WHERE scenario_id = ?", rhino).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	entries, err := scenarios.Entries()
+	require.NoError(t, err)
+	i := slices.IndexFunc(entries, func(e CatalogEntry) bool { return e.ID == rhino })
+	require.NotEqual(t, -1, i)
+	assert.Equal(t, "Rhino", entries[i].Name)
+	assert.Equal(t, 2, entries[i].Uses)
+	assert.True(t, entries[i].Official())
+	assert.False(t, slices.ContainsFunc(entries, func(e CatalogEntry) bool { return e.ID == typoID }))
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DeckFilter narrows DeckRepository.List. Zero fields match everything.
+type DeckFilter struct {
+	PlayID int
+	HeroID int
+}
+
+type DeckRepository struct {
+	db     *sql.DB
+	viewer *Viewer
+}
+
+func NewDeckRepository(db *sql.DB) *DeckRepository {
+	return &DeckRepository{db: db}
+}
+
+// For returns a repository limited to the decks of the plays v may see,
+// which only changes the decks of plays v may change.
+func (r *DeckRepository) For(v Viewer) *DeckRepository {
+	return &DeckRepository{db: r.db, viewer: &v}
+}
+
+// playsWhere returns an SQL condition that holds for decks d whose play
+// matches cond, one of visibleTo and editableBy.
+func (r *DeckRepository) playsWhere(cond func(*Viewer, string) (string, []any)) (string, []any) {
+	if r.viewer == nil {
+		return "1", nil
+	}
+	where, args := cond(r.viewer, "p")
+	return "d.play_id IN (SELECT p.id FROM plays p WHERE " + where + ")", args
+}
+
+const deckSelect = `
+	SELECT d.id, d.play_id, d.hero_id, COALESCE(d.player_id, 0), COALESCE(pl.name, d.player_name, ''),
+	       d.remaining_hp, d.created_at, d.updated_at,
+	       (SELECT GROUP_CONCAT(a.name, ',' ORDER BY a.sort_order)
+	        FROM deck_aspects da JOIN aspects a ON a.id = da.aspect_id
+	        WHERE da.deck_id = d.id)
+	FROM decks d
+	LEFT JOIN players pl ON pl.id = d.player_id`
+
+// List returns the decks matching f, ordered by play and then the order
+// they were added in.
+func (r *DeckRepository) List(f DeckFilter) ([]Deck, error) {
+	visible, args := r.playsWhere(visibleTo)
+	where := []string{visible}
+	if f.PlayID != 0 {
+		where = append(where, "d.play_id = ?")
+		args = append(args, f.PlayID)
+	}
+	if f.HeroID != 0 {
+		where = append(where, "d.hero_id = ?")
+		args = append(args, f.HeroID)
+	}
+
+	query := deckSelect + " WHERE " + strings.Join(where, " AND ")
+	rows, err := r.db.Query(query+" ORDER BY d.play_id, d.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var decks []Deck
+	for rows.Next() {
+		d, err := scanDeck(rows)
+		if err != nil {
+			return nil, err
+		}
+		decks = append(decks, *d)
+	}
+	return decks, rows.Err()
+}
+
+// GetByID returns the deck with the given id, or ErrNotFound.
+func (r *DeckRepository) GetByID(id int) (*Deck, error) {
+	visible, args := r.playsWhere(visibleTo)
+	d, err := scanDeck(r.db.QueryRow(deckSelect+" WHERE d.id = ? AND "+visible, append([]any{id}, args...)...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return d, err
+}
+
+// Create adds a deck to an existing play. The same rules as for
+// CreateWithDecks apply: the hero must exist and not already be in the
+// play, the play may not exceed MaxPlayers decks, and every aspect must be
+// in the aspects table. On success d.ID is populated.
+func (r *DeckRepository) Create(d *Deck) error {
+	return r.save(d, func(tx *sql.Tx, aspectIDs []int) error {
+		id, err := insertDeck(tx, d.PlayID, d.HeroID, aspectIDs, d.PlayerID, d.RemainingHP)
+		d.ID = id
+		return err
+	})
+}
+
+// Update changes the hero, aspects, player and remaining hit points of a
+// deck. The deck stays with its play.
+func (r *DeckRepository) Update(d *Deck) error {
+	editable, args := r.playsWhere(editableBy)
+	var playID int
+	err := r.db.QueryRow("SELECT play_id FROM decks d WHERE id = ? AND "+editable, append([]any{d.ID}, args...)...).Scan(&playID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	d.PlayID = playID
+
+	return r.save(d, func(tx *sql.Tx, aspectIDs []int) error {
+		_, err := tx.Exec(
+			`UPDATE decks SET hero_id = ?, player_id = ?, player_name = (SELECT name FROM players WHERE id = ?),
+			        remaining_hp = ?, updated_at = CURRENT_TIMESTAMP
+			 WHERE id = ?`,
+			d.HeroID, nullIfZero(d.PlayerID), d.PlayerID, d.RemainingHP, d.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM deck_aspects WHERE deck_id = ?", d.ID); err != nil {
+			return err
+		}
+		return insertDeckAspects(tx, d.ID, aspectIDs)
+	})
+}
+
+// save validates d against its play inside a transaction and then runs
+// write with the resolved aspect ids. d.PlayerID is set to the resolved
+// player, or zero if the deck has none.
+func (r *DeckRepository) save(d *Deck, write func(tx *sql.Tx, aspectIDs []int) error) error {
+	entry := DeckEntry{HeroID: d.HeroID, Aspects: d.Aspects, PlayerID: d.PlayerID, PlayerName: d.PlayerName, RemainingHP: d.RemainingHP}
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	editable, args := editableBy(r.viewer, "p")
+	// The deck's player is one of the players of the play's owner.
+	var ownerID sql.NullInt64
+	err = tx.QueryRow("SELECT owner_id FROM plays p WHERE id = ? AND "+editable, append([]any{d.PlayID}, args...)...).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return &ValidationError{Field: "play", Message: "unknown play"}
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := resolveByIDOrName(tx, "heroes", "hero", d.HeroID, ""); err != nil {
+		return err
+	}
+
+	var others, sameHero int
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(hero_id = ?), 0) FROM decks
+		WHERE play_id = ? AND id != ?`, d.HeroID, d.PlayID, d.ID).Scan(&others, &sameHero)
+	if err != nil {
+		return err
+	}
+	if sameHero > 0 {
+		return &ValidationError{Field: "hero", Message: "each hero can only appear once in a play"}
+	}
+	if others >= MaxPlayers {
+		return &ValidationError{Field: "hero", Message: fmt.Sprintf("a play can have at most %d heroes", MaxPlayers)}
+	}
+
+	aspectIDs, err := resolveAspects(tx, d.Aspects)
+	if err != nil {
+		return err
+	}
+	if d.PlayerID, err = resolvePlayer(tx, int(ownerID.Int64), d.PlayerID, d.PlayerName); err != nil {
+		return err
+	}
+	if err := write(tx, aspectIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes a deck and its aspects.
+func (r *DeckRepository) Delete(id int) error {
+	editable, args := r.playsWhere(editableBy)
+	result, err := r.db.Exec("DELETE FROM decks AS d WHERE id = ? AND "+editable, append([]any{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// insertDeck adds one deck with its aspects to a play and returns its id.
+// playerID is zero for a deck without a player; otherwise the player's
+// current name is also written to player_name.
+func insertDeck(db dbtx, playID, heroID int, aspectIDs []int, playerID int, remainingHP *int) (int, error) {
+	result, err := db.Exec(
+		`INSERT INTO decks (play_id, hero_id, player_id, player_name, remaining_hp)
+		 VALUES (?, ?, ?, (SELECT name FROM players WHERE id = ?), ?)`,
+		playID, heroID, nullIfZero(playerID), playerID, remainingHP,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), insertDeckAspects(db, int(id), aspectIDs)
+}
+
+func insertDeckAspects(db dbtx, deckID int, aspectIDs []int) error {
+	for _, aspectID := range aspectIDs {
+		if _, err := db.Exec("INSERT INTO deck_aspects (deck_id, aspect_id) VALUES (?, ?)", deckID, aspectID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDeck(row rowScanner) (*Deck, error) {
+	var d Deck
+	var aspects sql.NullString
+	err := row.Scan(&d.ID, &d.PlayID, &d.HeroID, &d.PlayerID, &d.PlayerName, &d.RemainingHP, &d.CreatedAt, &d.UpdatedAt, &aspects)
+	if err != nil {
+		return nil, err
+	}
+	d.Aspects = splitAspects(aspects.String)
+	return &d, nil
+}
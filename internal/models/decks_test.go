@@ -0,0 +1,125 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeckRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	plays := NewPlayRepository(db)
+	decks := NewDeckRepository(db)
+
+	spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+	sheHulk := catalogID(t, db, "heroes", "She-Hulk")
+	ironMan := catalogID(t, db, "heroes", "Iron Man")
+	thor := catalogID(t, db, "heroes", "Thor")
+	blackWidow := catalogID(t, db, "heroes", "Black Widow")
+
+	play := &Play{Date: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", ScenarioID: catalogID(t, db, "scenarios", "Rhino")}
+	require.NoError(t, plays.CreateWithDecks(play, "", []DeckEntry{
+		{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerName: "Sam"},
+	}))
+
+	t.Run("List and Get", func(t *testing.T) {
+		list, err := decks.List(DeckFilter{PlayID: play.ID})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, spiderMan, list[0].HeroID)
+		assert.Equal(t, []string{"justice"}, list[0].Aspects)
+		assert.Equal(t, "Sam", list[0].PlayerName)
+
+		got, err := decks.GetByID(list[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, list[0], *got)
+
+		_, err = decks.GetByID(999)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		none, err := decks.List(DeckFilter{HeroID: sheHulk})
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		d := &Deck{PlayID: play.ID, HeroID: sheHulk, Aspects: []string{"Protection", "aggression"}}
+		require.NoError(t, decks.Create(d))
+		assert.NotZero(t, d.ID)
+
+		got, err := decks.GetByID(d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"aggression", "protection"}, got.Aspects)
+	})
+
+	t.Run("Create Rejects Invalid Decks", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			deck    Deck
+			message string
+		}{
+			"Unknown Play":   {Deck{PlayID: 999, HeroID: ironMan, Aspects: []string{"justice"}}, "unknown play"},
+			"Unknown Hero":   {Deck{PlayID: play.ID, HeroID: 999, Aspects: []string{"justice"}}, "unknown hero"},
+			"Duplicate Hero": {Deck{PlayID: play.ID, HeroID: spiderMan, Aspects: []string{"justice"}}, "each hero can only appear once in a play"},
+			"Unknown Aspect": {Deck{PlayID: play.ID, HeroID: ironMan, Aspects: []string{"chaos"}}, `unknown aspect "chaos"`},
+			"No Aspect":      {Deck{PlayID: play.ID, HeroID: ironMan}, "aspect is required"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				var validationErr *ValidationError
+				err := decks.Create(&tc.deck)
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tc.message, validationErr.Message)
+			})
+		}
+	})
+
+	t.Run("Create Enforces Player Limit", func(t *testing.T) {
+		require.NoError(t, decks.Create(&Deck{PlayID: play.ID, HeroID: ironMan, Aspects: []string{"leadership"}}))
+		require.NoError(t, decks.Create(&Deck{PlayID: play.ID, HeroID: thor, Aspects: []string{"aggression"}}))
+
+		var validationErr *ValidationError
+		err := decks.Create(&Deck{PlayID: play.ID, HeroID: blackWidow, Aspects: []string{"justice"}})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "a play can have at most 4 heroes", validationErr.Message)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		list, err := decks.List(DeckFilter{PlayID: play.ID, HeroID: spiderMan})
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+
+		// Swapping in a hero from outside the play is allowed even when the
+		// play is full, since the deck replaces itself.
+		d := &Deck{ID: list[0].ID, HeroID: blackWidow, Aspects: []string{"pool"}}
+		require.NoError(t, decks.Update(d))
+		assert.Equal(t, play.ID, d.PlayID)
+
+		got, err := decks.GetByID(d.ID)
+		require.NoError(t, err)
+		assert.Equal(t, blackWidow, got.HeroID)
+		assert.Equal(t, []string{"pool"}, got.Aspects)
+		assert.Empty(t, got.PlayerName)
+
+		var validationErr *ValidationError
+		err = decks.Update(&Deck{ID: d.ID, HeroID: sheHulk, Aspects: []string{"pool"}})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "each hero can only appear once in a play", validationErr.Message)
+
+		assert.ErrorIs(t, decks.Update(&Deck{ID: 999, HeroID: spiderMan, Aspects: []string{"pool"}}), ErrNotFound)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		list, err := decks.List(DeckFilter{PlayID: play.ID})
+		require.NoError(t, err)
+		require.Len(t, list, 4)
+
+		require.NoError(t, decks.Delete(list[0].ID))
+		assert.ErrorIs(t, decks.Delete(list[0].ID), ErrNotFound)
+
+		list, err = decks.List(DeckFilter{PlayID: play.ID})
+		require.NoError(t, err)
+		assert.Len(t, list, 3)
+	})
+}
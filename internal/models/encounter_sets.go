@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// EncounterSet is a modular encounter set that can be shuffled into a
+// scenario's encounter deck.
+type EncounterSet struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	PackID     *int       `json:"pack_id,omitempty"`
+	Pack       string     `json:"pack,omitempty"`
+	Wave       int        `json:"wave,omitempty"`
+	ReleasedOn *time.Time `json:"released_on,omitempty"`
+}
+
+type EncounterSetRepository struct {
+	db *sql.DB
+}
+
+func NewEncounterSetRepository(db *sql.DB) *EncounterSetRepository {
+	return &EncounterSetRepository{db: db}
+}
+
+// GetAll returns every encounter set grouped by release: official sets by
+// wave and pack, then sets added by users, each ordered by name.
+func (r *EncounterSetRepository) GetAll() ([]EncounterSet, error) {
+	rows, err := r.db.Query(`
+		SELECT e.id, e.name, e.pack_id, p.name, p.wave, p.released_on
+		FROM encounter_sets e
+		LEFT JOIN packs p ON p.id = e.pack_id
+		ORDER BY p.id IS NULL, p.wave, p.id, e.name COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []EncounterSet
+	for rows.Next() {
+		var e EncounterSet
+		var pack catalogPack
+		if err := rows.Scan(&e.ID, &e.Name, &pack.id, &pack.name, &pack.wave, &pack.releasedOn); err != nil {
+			return nil, err
+		}
+		e.PackID, e.Pack, e.Wave, e.ReleasedOn = pack.values()
+		sets = append(sets, e)
+	}
+	return sets, rows.Err()
+}
+
+// Recommended returns the ids of the modular sets the rules recommend for
+// a scenario. It is empty for scenarios without a recorded recommendation.
+func (r *EncounterSetRepository) Recommended(scenarioID int) ([]int, error) {
+	rows, err := r.db.Query(
+		"SELECT encounter_set_id FROM scenario_encounter_sets WHERE scenario_id = ? ORDER BY encounter_set_id",
+		scenarioID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// resolveEncounterSets checks that every id names an encounter set and
+// drops repeats. An unknown id is a ValidationError.
+func resolveEncounterSets(db dbtx, ids []int) ([]int, error) {
+	resolved := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM encounter_sets WHERE id = ?", id).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, &ValidationError{Field: "encounter_set", Message: "unknown modular set"}
+		}
+		resolved = append(resolved, id)
+	}
+	return resolved, nil
+}
+
+func insertPlayEncounterSets(db dbtx, playID int, ids []int) error {
+	for _, id := range ids {
+		if _, err := db.Exec("INSERT INTO play_encounter_sets (play_id, encounter_set_id) VALUES (?, ?)", playID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
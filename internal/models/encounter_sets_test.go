@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncounterSetRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	plays := NewPlayRepository(db)
+	repo := NewEncounterSetRepository(db)
+
+	_, err := db.Exec("INSERT INTO encounter_sets (name) VALUES ('A Custom Set')")
+	require.NoError(t, err)
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+	bombScare := catalogID(t, db, "encounter_sets", "Bomb Scare")
+	mastersOfEvil := catalogID(t, db, "encounter_sets", "Masters of Evil")
+
+	t.Run("GetAll", func(t *testing.T) {
+		sets, err := repo.GetAll()
+		require.NoError(t, err)
+		require.Len(t, sets, countRows(t, db, "encounter_sets"))
+		names := make([]string, len(sets))
+		for i, set := range sets {
+			names[i] = set.Name
+		}
+		assert.Equal(t, []string{"Bomb Scare", "Legions of Hydra", "Masters of Evil", "The Doomsday Chair", "Under Attack", "A Mess of Things"}, names[:6],
+			"official sets by pack")
+		assert.Equal(t, "Core Set", sets[0].Pack)
+		assert.Equal(t, "The Green Goblin", sets[5].Pack)
+		assert.Equal(t, "A Custom Set", names[len(names)-1], "then sets added by users")
+		assert.Nil(t, sets[len(sets)-1].PackID)
+	})
+
+	t.Run("Recommended", func(t *testing.T) {
+		ids, err := repo.Recommended(rhino)
+		require.NoError(t, err)
+		assert.Equal(t, []int{bombScare}, ids)
+
+		ids, err = repo.Recommended(999)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+
+	deck := []DeckEntry{{HeroID: catalogID(t, db, "heroes", "Spider-Man"), Aspects: []string{"justice"}}}
+
+	t.Run("Saved With Play", func(t *testing.T) {
+		play := &Play{Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I",
+			ScenarioID: rhino, EncounterSetIDs: []int{bombScare, mastersOfEvil, bombScare}}
+		require.NoError(t, plays.CreateWithDecks(play, "", deck))
+
+		summary, err := plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Bomb Scare", "Masters of Evil"}, summary.EncounterSets, "repeats are dropped")
+		assert.Equal(t, "Bomb Scare, Masters of Evil", summary.EncounterSetLabel())
+
+		none := &Play{Date: play.Date, Outcome: "loss", Difficulty: "Standard I", ScenarioID: rhino}
+		require.NoError(t, plays.CreateWithDecks(none, "", deck))
+		summary, err = plays.GetSummary(none.ID)
+		require.NoError(t, err)
+		assert.Empty(t, summary.EncounterSets)
+	})
+
+	t.Run("Unknown Set", func(t *testing.T) {
+		play := &Play{Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I",
+			ScenarioID: rhino, EncounterSetIDs: []int{999}}
+		var validationErr *ValidationError
+		err := plays.CreateWithDecks(play, "", deck)
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "encounter_set", validationErr.Field)
+		assert.Zero(t, play.ID, "the play is not saved")
+	})
+}
@@ -0,0 +1,411 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// GroupRole is what a member may do in a group.
+type GroupRole string
+
+const (
+	// GroupOwner manages the group: its name, members and invites.
+	GroupOwner GroupRole = "owner"
+	// GroupEditor logs plays into the group and changes its plays.
+	GroupEditor GroupRole = "editor"
+	// GroupViewer sees the group's plays.
+	GroupViewer GroupRole = "viewer"
+)
+
+// GroupRoles lists the roles from the most to the least allowed.
+var GroupRoles = []GroupRole{GroupOwner, GroupEditor, GroupViewer}
+
+// AtLeast reports whether r allows everything min does. An empty role, as
+// for someone who is not a member, allows nothing.
+func (r GroupRole) AtLeast(min GroupRole) bool {
+	return r.rank() > 0 && r.rank() <= min.rank()
+}
+
+func (r GroupRole) rank() int {
+	for i, role := range GroupRoles {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// InviteTTL is how long an invite link can be used.
+const InviteTTL = 7 * 24 * time.Hour
+
+// maxGroupName is the longest group name accepted.
+const maxGroupName = 100
+
+// Group is a set of users who play together. Role is the viewer's role in
+// the group, and empty for a repository without a viewer.
+type Group struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Role      GroupRole `json:"role,omitempty"`
+	Members   int       `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GroupMember is a user's membership of a group.
+type GroupMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Role     GroupRole `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// GroupInvite is a link that lets whoever has it join a group with Role.
+type GroupInvite struct {
+	Token     string    `json:"token"`
+	GroupID   int       `json:"group_id"`
+	Group     string    `json:"group"`
+	Role      GroupRole `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// errNoViewer is returned by the methods that act as a user when the
+// repository has none.
+var errNoViewer = errors.New("groups: the repository has no viewer")
+
+type GroupRepository struct {
+	db     *sql.DB
+	viewer *Viewer
+	now    func() time.Time
+}
+
+func NewGroupRepository(db *sql.DB) *GroupRepository {
+	return &GroupRepository{db: db, now: time.Now}
+}
+
+// For returns a repository that acts as v: it lists and looks up only the
+// groups v belongs to, with v's role, and creates and joins groups as v.
+// Checking that v's role allows a change is left to the caller.
+func (r *GroupRepository) For(v Viewer) *GroupRepository {
+	return &GroupRepository{db: r.db, viewer: &v, now: r.now}
+}
+
+func (r *GroupRepository) user() (int, error) {
+	if r.viewer == nil || r.viewer.UserID == 0 {
+		return 0, errNoViewer
+	}
+	return r.viewer.UserID, nil
+}
+
+func checkGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &ValidationError{Field: "name", Message: "name is required"}
+	}
+	if len(name) > maxGroupName {
+		return "", &ValidationError{Field: "name", Message: "name must be at most 100 characters"}
+	}
+	return name, nil
+}
+
+// Create adds a group with the viewer as its owner.
+func (r *GroupRepository) Create(name string) (*Group, error) {
+	userID, err := r.user()
+	if err != nil {
+		return nil, err
+	}
+	name, err = checkGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO groups (name) VALUES (?)", name)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO group_members (group_id, user_id, role) VALUES (?, ?, ?)", id, userID, GroupOwner); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(int(id))
+}
+
+// groupSelect reads a group with the viewer's role, bound as its first
+// argument, and its number of members.
+const groupSelect = `
+	SELECT g.id, g.name, COALESCE(m.role, ''),
+	       (SELECT COUNT(*) FROM group_members c WHERE c.group_id = g.id), g.created_at
+	FROM groups g
+	LEFT JOIN group_members m ON m.group_id = g.id AND m.user_id = ?`
+
+// GetAll returns the viewer's groups ordered by name, or every group for a
+// repository without a viewer.
+func (r *GroupRepository) GetAll() ([]Group, error) {
+	var userID int
+	where := "1"
+	if r.viewer != nil {
+		userID, where = r.viewer.UserID, "m.role IS NOT NULL"
+	}
+	rows, err := r.db.Query(groupSelect+" WHERE "+where+" ORDER BY g.name COLLATE NOCASE, g.id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Role, &g.Members, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GetByID returns the group with the given id, or ErrNotFound if there is
+// none or the viewer is not a member.
+func (r *GroupRepository) GetByID(id int) (*Group, error) {
+	var userID int
+	where := "g.id = ?"
+	if r.viewer != nil {
+		userID, where = r.viewer.UserID, where+" AND m.role IS NOT NULL"
+	}
+	var g Group
+	err := r.db.QueryRow(groupSelect+" WHERE "+where, userID, id).
+		Scan(&g.ID, &g.Name, &g.Role, &g.Members, &g.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// Rename changes a group's name.
+func (r *GroupRepository) Rename(id int, name string) error {
+	name, err := checkGroupName(name)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec("UPDATE groups SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", name, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// Delete removes a group with its members and invites. Its plays are kept
+// by the users who logged them.
+func (r *GroupRepository) Delete(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"UPDATE plays SET group_id = NULL WHERE group_id = ?",
+		"DELETE FROM group_invites WHERE group_id = ?",
+		"DELETE FROM group_members WHERE group_id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return err
+		}
+	}
+	result, err := tx.Exec("DELETE FROM groups WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Members returns the members of a group, owners first and then by name.
+func (r *GroupRepository) Members(groupID int) ([]GroupMember, error) {
+	rows, err := r.db.Query(`
+		SELECT m.user_id, u.username, m.role, m.joined_at
+		FROM group_members m JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = ?
+		ORDER BY CASE m.role WHEN 'owner' THEN 1 WHEN 'editor' THEN 2 ELSE 3 END, u.username COLLATE NOCASE`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []GroupMember
+	for rows.Next() {
+		var m GroupMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetRole changes a member's role. A group always keeps at least one
+// owner.
+func (r *GroupRepository) SetRole(groupID, userID int, role GroupRole) error {
+	if role.rank() == 0 {
+		return &ValidationError{Field: "role", Message: "role must be owner, editor or viewer"}
+	}
+	return r.changeMember(groupID, userID, role != GroupOwner,
+		"UPDATE group_members SET role = ? WHERE group_id = ? AND user_id = ?", role, groupID, userID)
+}
+
+// RemoveMember takes a user out of a group, whether they leave or an owner
+// removes them. The last owner cannot leave; they can delete the group
+// instead.
+func (r *GroupRepository) RemoveMember(groupID, userID int) error {
+	return r.changeMember(groupID, userID, true,
+		"DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID)
+}
+
+// changeMember runs query, which changes the membership of userID, unless
+// the member does not exist or the change would leave the group without
+// an owner. losesOwner is whether the change takes away the member's
+// ownership, if they have it.
+func (r *GroupRepository) changeMember(groupID, userID int, losesOwner bool, query string, args ...any) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role GroupRole
+	err = tx.QueryRow("SELECT role FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role == GroupOwner && losesOwner {
+		var owners int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM group_members WHERE group_id = ? AND role = 'owner'", groupID).Scan(&owners); err != nil {
+			return err
+		}
+		if owners == 1 {
+			return &ValidationError{Field: "role", Message: "a group needs at least one owner"}
+		}
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateInvite makes an invite link to a group for new editors or viewers.
+// Owners are made by promoting a member.
+func (r *GroupRepository) CreateInvite(groupID int, role GroupRole) (*GroupInvite, error) {
+	if role != GroupEditor && role != GroupViewer {
+		return nil, &ValidationError{Field: "role", Message: "invites are for editors or viewers"}
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	var createdBy int
+	if r.viewer != nil {
+		createdBy = r.viewer.UserID
+	}
+	now := r.now().UTC()
+	_, err := r.db.Exec(
+		"INSERT INTO group_invites (token, group_id, role, created_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token, groupID, role, nullIfZero(createdBy), now, now.Add(InviteTTL),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.Invite(token)
+}
+
+const inviteSelect = `
+	SELECT i.token, i.group_id, g.name, i.role, i.created_at, i.expires_at
+	FROM group_invites i JOIN groups g ON g.id = i.group_id`
+
+// Invites returns a group's invites that have not expired, newest first.
+func (r *GroupRepository) Invites(groupID int) ([]GroupInvite, error) {
+	rows, err := r.db.Query(inviteSelect+" WHERE i.group_id = ? AND i.expires_at > ? ORDER BY i.created_at DESC",
+		groupID, r.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []GroupInvite
+	for rows.Next() {
+		var i GroupInvite
+		if err := rows.Scan(&i.Token, &i.GroupID, &i.Group, &i.Role, &i.CreatedAt, &i.ExpiresAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, i)
+	}
+	return invites, rows.Err()
+}
+
+// Invite returns the invite with the given token, or ErrNotFound if there
+// is none or it has expired.
+func (r *GroupRepository) Invite(token string) (*GroupInvite, error) {
+	var i GroupInvite
+	err := r.db.QueryRow(inviteSelect+" WHERE i.token = ? AND i.expires_at > ?", token, r.now().UTC()).
+		Scan(&i.Token, &i.GroupID, &i.Group, &i.Role, &i.CreatedAt, &i.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// RevokeInvite stops an invite link of a group from working.
+func (r *GroupRepository) RevokeInvite(groupID int, token string) error {
+	result, err := r.db.Exec("DELETE FROM group_invites WHERE group_id = ? AND token = ?", groupID, token)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// Join makes the viewer a member of the invite's group with the invite's
+// role. A member keeps the role they already have. It returns the group,
+// or ErrNotFound if the invite does not exist or has expired.
+func (r *GroupRepository) Join(token string) (*Group, error) {
+	userID, err := r.user()
+	if err != nil {
+		return nil, err
+	}
+	invite, err := r.Invite(token)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.db.Exec("INSERT OR IGNORE INTO group_members (group_id, user_id, role) VALUES (?, ?, ?)",
+		invite.GroupID, userID, invite.Role)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(invite.GroupID)
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupRole(t *testing.T) {
+	assert.True(t, GroupOwner.AtLeast(GroupEditor))
+	assert.True(t, GroupEditor.AtLeast(GroupEditor))
+	assert.False(t, GroupViewer.AtLeast(GroupEditor))
+	assert.False(t, GroupRole("").AtLeast(GroupViewer), "a non-member has no role")
+	assert.False(t, GroupRole("admin").AtLeast(GroupViewer))
+}
+
+func TestGroupRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', ''), (3, 'carol', '');
+	`)
+	require.NoError(t, err)
+
+	repo := NewGroupRepository(db)
+	alice := repo.For(Viewer{UserID: 1})
+	bob := repo.For(Viewer{UserID: 2})
+	carol := repo.For(Viewer{UserID: 3})
+
+	group, err := alice.Create("  Tuesday Table ")
+	require.NoError(t, err)
+
+	t.Run("Create", func(t *testing.T) {
+		assert.Equal(t, "Tuesday Table", group.Name)
+		assert.Equal(t, GroupOwner, group.Role, "the creator owns the group")
+		assert.Equal(t, 1, group.Members)
+
+		var validationErr *ValidationError
+		_, err := alice.Create(" ")
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "name", validationErr.Field)
+		_, err = repo.For(Viewer{}).Create("Visitors")
+		assert.ErrorIs(t, err, errNoViewer)
+	})
+
+	t.Run("Only Members See A Group", func(t *testing.T) {
+		groups, err := alice.GetAll()
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+
+		groups, err = bob.GetAll()
+		require.NoError(t, err)
+		assert.Empty(t, groups)
+		_, err = bob.GetByID(group.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		unscoped, err := repo.GetByID(group.ID)
+		require.NoError(t, err)
+		assert.Empty(t, unscoped.Role)
+	})
+
+	t.Run("Invites", func(t *testing.T) {
+		var validationErr *ValidationError
+		_, err := alice.CreateInvite(group.ID, GroupOwner)
+		assert.ErrorAs(t, err, &validationErr, "owners are made by promoting a member")
+
+		invite, err := alice.CreateInvite(group.ID, GroupEditor)
+		require.NoError(t, err)
+		assert.NotEmpty(t, invite.Token)
+		assert.Equal(t, "Tuesday Table", invite.Group)
+
+		joined, err := bob.Join(invite.Token)
+		require.NoError(t, err)
+		assert.Equal(t, GroupEditor, joined.Role)
+		assert.Equal(t, 2, joined.Members)
+
+		viewerInvite, err := alice.CreateInvite(group.ID, GroupViewer)
+		require.NoError(t, err)
+		joined, err = bob.Join(viewerInvite.Token)
+		require.NoError(t, err)
+		assert.Equal(t, GroupEditor, joined.Role, "joining again keeps the role")
+
+		invites, err := alice.Invites(group.ID)
+		require.NoError(t, err)
+		assert.Len(t, invites, 2)
+		require.NoError(t, alice.RevokeInvite(group.ID, invite.Token))
+		_, err = carol.Join(invite.Token)
+		assert.ErrorIs(t, err, ErrNotFound, "a revoked invite no longer works")
+		assert.ErrorIs(t, alice.RevokeInvite(group.ID, invite.Token), ErrNotFound)
+
+		later := &GroupRepository{db: db, now: func() time.Time { return time.Now().Add(InviteTTL + time.Hour) }}
+		_, err = later.For(Viewer{UserID: 3}).Join(viewerInvite.Token)
+		assert.ErrorIs(t, err, ErrNotFound, "an expired invite no longer works")
+
+		_, err = carol.Join(viewerInvite.Token)
+		require.NoError(t, err)
+	})
+
+	t.Run("Members", func(t *testing.T) {
+		members, err := repo.Members(group.ID)
+		require.NoError(t, err)
+		require.Len(t, members, 3)
+		assert.Equal(t, []string{"alice", "bob", "carol"}, []string{members[0].Username, members[1].Username, members[2].Username})
+		assert.Equal(t, []GroupRole{GroupOwner, GroupEditor, GroupViewer}, []GroupRole{members[0].Role, members[1].Role, members[2].Role})
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, repo.SetRole(group.ID, 1, GroupEditor), &validationErr)
+		assert.Equal(t, "a group needs at least one owner", validationErr.Message)
+		assert.ErrorAs(t, repo.RemoveMember(group.ID, 1), &validationErr, "the last owner cannot leave")
+		assert.ErrorAs(t, repo.SetRole(group.ID, 2, "admin"), &validationErr)
+		assert.ErrorIs(t, repo.SetRole(group.ID, 99, GroupViewer), ErrNotFound)
+
+		require.NoError(t, repo.SetRole(group.ID, 2, GroupOwner))
+		require.NoError(t, repo.SetRole(group.ID, 1, GroupEditor), "bob is an owner now")
+		require.NoError(t, repo.SetRole(group.ID, 1, GroupOwner))
+		require.NoError(t, repo.SetRole(group.ID, 2, GroupEditor))
+	})
+
+	t.Run("Group Plays", func(t *testing.T) {
+		plays := NewPlayRepository(db)
+		rhino := catalogID(t, db, "scenarios", "Rhino")
+		spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+		logPlay := func(repo *PlayRepository, groupID int) (*Play, error) {
+			play := &Play{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino, GroupID: groupID}
+			return play, repo.CreateWithDecks(play, "", []DeckEntry{{HeroID: spiderMan, Aspects: []string{"justice"}}})
+		}
+
+		bobPlay, err := logPlay(plays.For(Viewer{UserID: 2}), group.ID)
+		require.NoError(t, err)
+		summary, err := plays.For(Viewer{UserID: 3}).GetSummary(bobPlay.ID)
+		require.NoError(t, err, "every member sees the group's plays")
+		assert.Equal(t, "Tuesday Table", summary.Group)
+
+		var validationErr *ValidationError
+		_, err = logPlay(plays.For(Viewer{UserID: 3}), group.ID)
+		require.ErrorAs(t, err, &validationErr, "viewers cannot log plays into the group")
+		assert.Equal(t, "group_id", validationErr.Field)
+
+		for userID, want := range map[int]bool{1: true, 2: true, 3: false} {
+			editable, err := plays.For(Viewer{UserID: userID}).Editable(bobPlay.ID)
+			require.NoError(t, err)
+			assert.Equal(t, want, editable, "user %d", userID)
+		}
+		edited := *bobPlay
+		edited.Notes = "fixed by the owner"
+		require.NoError(t, plays.For(Viewer{UserID: 1}).Update(&edited, ""))
+		assert.ErrorIs(t, plays.For(Viewer{UserID: 3}).Delete(bobPlay.ID), ErrNotFound)
+
+		require.NoError(t, repo.RemoveMember(group.ID, 3))
+		_, err = plays.For(Viewer{UserID: 3}).Editable(bobPlay.ID)
+		assert.ErrorIs(t, err, ErrNotFound, "a former member no longer sees the group's plays")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, repo.Delete(group.ID))
+		_, err := alice.GetByID(group.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		var groupPlays int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays WHERE group_id IS NOT NULL").Scan(&groupPlays))
+		assert.Zero(t, groupPlays)
+		var plays int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&plays))
+		assert.Equal(t, 1, plays, "the group's plays are kept")
+		assert.ErrorIs(t, repo.Delete(group.ID), ErrNotFound)
+	})
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// PlayImport is one play read from an import file, with its scenario and
+// heroes given by name as in CreateWithDecks.
+type PlayImport struct {
+	Play     Play
+	Scenario string
+	Decks    []DeckEntry
+	// EncounterSets names the modular sets used. Like heroes, names that
+	// are not in the catalog yet are added to it, and the sets are saved
+	// along with any in Play.EncounterSetIDs.
+	EncounterSets []string
+}
+
+// ImportResult reports what happened to one PlayImport.
+type ImportResult struct {
+	// Err is the ValidationError that kept the play from being saved.
+	Err error
+	// Duplicate is set when the owner already has a play with the same
+	// SourceID, in which case the play is skipped rather than saved again.
+	Duplicate bool
+	// Added lists the scenario, hero and modular set names the play adds
+	// to the catalog.
+	Added []string
+}
+
+// Import saves plays in a single transaction, all or nothing. It returns
+// one result per play; the transaction is committed only if commit is set
+// and no play has an error, so passing commit=false previews an import
+// without changing anything. Plays whose SourceID the viewer has already
+// imported are skipped, which makes importing the same file twice
+// harmless; another user's copy of the same play does not count. Only
+// database failures are returned as err.
+func (r *PlayRepository) Import(plays []PlayImport, commit bool) (results []ImportResult, committed bool, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	results = make([]ImportResult, len(plays))
+	failed := false
+	for i := range plays {
+		imp := plays[i]
+		r.own(&imp.Play)
+		if imp.Play.SourceID != "" {
+			var count int
+			err := tx.QueryRow(
+				"SELECT COUNT(*) FROM plays WHERE source_id = ? AND owner_id IS ?",
+				imp.Play.SourceID, nullIfZero(imp.Play.OwnerID),
+			).Scan(&count)
+			if err != nil {
+				return nil, false, err
+			}
+			if count > 0 {
+				results[i].Duplicate = true
+				continue
+			}
+		}
+
+		results[i].Added, err = newCatalogNames(tx, imp)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// A savepoint undoes whatever a rejected play had already written,
+		// such as a new scenario, so later plays are checked against the
+		// catalog as it will really be.
+		if _, err := tx.Exec("SAVEPOINT import_play"); err != nil {
+			return nil, false, err
+		}
+		err := resolveImportedEncounterSets(tx, &imp)
+		if err == nil {
+			err = createWithDecks(tx, &imp.Play, imp.Scenario, imp.Decks)
+		}
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			results[i] = ImportResult{Err: err}
+			failed = true
+			if _, err := tx.Exec("ROLLBACK TO import_play"); err != nil {
+				return nil, false, err
+			}
+		} else if err != nil {
+			return nil, false, err
+		}
+		if _, err := tx.Exec("RELEASE import_play"); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if !commit || failed {
+		return results, false, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// resolveImportedEncounterSets adds the ids of imp.EncounterSets to
+// imp.Play.EncounterSetIDs, adding the names the catalog lacks.
+func resolveImportedEncounterSets(tx dbtx, imp *PlayImport) error {
+	ids := slices.Clone(imp.Play.EncounterSetIDs)
+	for _, name := range imp.EncounterSets {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		id, err := findOrCreateByName(tx, "encounter_sets", name)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	imp.Play.EncounterSetIDs = ids
+	return nil
+}
+
+// newCatalogNames returns the names imp refers to that are not yet in the
+// scenarios, heroes or encounter_sets tables.
+func newCatalogNames(db dbtx, imp PlayImport) ([]string, error) {
+	type ref struct {
+		table string
+		id    int
+		name  string
+	}
+	refs := []ref{{"scenarios", imp.Play.ScenarioID, imp.Scenario}}
+	for _, d := range imp.Decks {
+		refs = append(refs, ref{"heroes", d.HeroID, d.HeroName})
+	}
+	for _, name := range imp.EncounterSets {
+		refs = append(refs, ref{"encounter_sets", 0, name})
+	}
+
+	var added []string
+	for _, ref := range refs {
+		name := strings.TrimSpace(ref.name)
+		if ref.id != 0 || name == "" {
+			continue
+		}
+		var id int
+		err := db.QueryRow("SELECT id FROM "+ref.table+" WHERE name = ? COLLATE NOCASE", name).Scan(&id)
+		if err == sql.ErrNoRows {
+			added = append(added, name)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayRepository_Import(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	play := func(day int, difficulty string, heroes ...string) PlayImport {
+		imp := PlayImport{
+			Play:     Play{Date: time.Date(2024, 5, day, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: difficulty},
+			Scenario: "Rhino",
+		}
+		for _, hero := range heroes {
+			imp.Decks = append(imp.Decks, DeckEntry{HeroName: hero, Aspects: []string{"justice"}})
+		}
+		return imp
+	}
+	countPlays := func() int {
+		var n int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&n))
+		return n
+	}
+
+	t.Run("Preview Saves Nothing", func(t *testing.T) {
+		results, committed, err := repo.Import([]PlayImport{play(1, "Standard I", "Squirrel Girl")}, false)
+		require.NoError(t, err)
+		assert.False(t, committed)
+		require.Len(t, results, 1)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, []string{"Squirrel Girl"}, results[0].Added)
+		assert.Zero(t, countPlays())
+	})
+
+	t.Run("One Bad Play Rolls Back All", func(t *testing.T) {
+		results, committed, err := repo.Import([]PlayImport{
+			play(1, "Standard I", "Squirrel Girl"),
+			play(2, "Legendary", "Moon Knight"),
+			play(3, "Standard I", "Moon Knight"),
+		}, true)
+		require.NoError(t, err)
+		assert.False(t, committed)
+		assert.NoError(t, results[0].Err)
+		var validationErr *ValidationError
+		require.ErrorAs(t, results[1].Err, &validationErr)
+		assert.Equal(t, "unknown difficulty", validationErr.Message)
+		// The rejected play did not add Moon Knight, so the next one does.
+		assert.Equal(t, []string{"Moon Knight"}, results[2].Added)
+		assert.Zero(t, countPlays())
+	})
+
+	t.Run("Commit", func(t *testing.T) {
+		results, committed, err := repo.Import([]PlayImport{
+			play(1, "Standard I", "Squirrel Girl"),
+			play(2, "Expert I", "Squirrel Girl", "Moon Knight", "Spider-Man"),
+		}, true)
+		require.NoError(t, err)
+		assert.True(t, committed)
+		assert.Equal(t, []string{"Squirrel Girl"}, results[0].Added)
+		assert.Equal(t, []string{"Moon Knight"}, results[1].Added, "Spider-Man is in the catalog")
+		assert.Equal(t, 2, countPlays())
+	})
+	t.Run("Source IDs Are Per Owner", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', '')")
+		require.NoError(t, err)
+		imported := play(3, "Standard I", "Spider-Man")
+		imported.Play.SourceID = "bgstats:1"
+
+		for _, viewer := range []Viewer{{UserID: 1}, {UserID: 2}} {
+			results, committed, err := repo.For(viewer).Import([]PlayImport{imported}, true)
+			require.NoError(t, err)
+			assert.True(t, committed)
+			assert.False(t, results[0].Duplicate, "user %d has not imported the play", viewer.UserID)
+		}
+		results, _, err := repo.For(Viewer{UserID: 1}).Import([]PlayImport{imported}, true)
+		require.NoError(t, err)
+		assert.True(t, results[0].Duplicate)
+		assert.Equal(t, 4, countPlays())
+	})
+}
@@ -2,21 +2,106 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
+// Difficulties lists the difficulty levels accepted for a play, in the
+// order they are offered on the New Play form.
+var Difficulties = []string{
+	"Standard I",
+	"Standard II",
+	"Expert I",
+	"Expert II",
+	"Heroic I",
+	"Heroic II",
+	"Heroic III",
+	"Heroic IV",
+}
+
+// Outcomes lists the values allowed in plays.outcome.
+var Outcomes = []string{"win", "loss"}
+
+// EndReason is a value allowed in plays.end_reason. Each reason belongs
+// to one outcome.
+type EndReason struct {
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Outcome string `json:"outcome"`
+}
+
+// EndReasons lists how a play can end, wins first, in the order they are
+// offered on the play forms.
+var EndReasons = []EndReason{
+	{Name: "villain_defeated", Label: "Villain defeated", Outcome: "win"},
+	{Name: "objective_completed", Label: "Scenario objective completed", Outcome: "win"},
+	{Name: "scheme_completed", Label: "Main scheme completed", Outcome: "loss"},
+	{Name: "heroes_defeated", Label: "All heroes defeated", Outcome: "loss"},
+	{Name: "conceded", Label: "Conceded", Outcome: "loss"},
+}
+
+// EndReasonsFor returns the end reasons that belong to outcome.
+func EndReasonsFor(outcome string) []EndReason {
+	var reasons []EndReason
+	for _, r := range EndReasons {
+		if r.Outcome == outcome {
+			reasons = append(reasons, r)
+		}
+	}
+	return reasons
+}
+
+// EndReasonLabel returns the display label of an end reason, or name
+// itself if it is not one of EndReasons.
+func EndReasonLabel(name string) string {
+	if r, ok := findEndReason(name); ok {
+		return r.Label
+	}
+	return name
+}
+
+// MaxPlayers is the largest number of heroes the game supports in a single
+// play without expansion rules.
+const MaxPlayers = 4
+
+// ErrNotFound is returned when a requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ValidationError reports a problem with user-supplied data that the user
+// can fix, as opposed to a database or server failure.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
 type Hero struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	PackID     *int       `json:"pack_id,omitempty"`
+	Pack       string     `json:"pack,omitempty"`
+	Wave       int        `json:"wave,omitempty"`
+	ReleasedOn *time.Time `json:"released_on,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
 type Scenario struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	PackID     *int       `json:"pack_id,omitempty"`
+	Pack       string     `json:"pack,omitempty"`
+	Wave       int        `json:"wave,omitempty"`
+	ReleasedOn *time.Time `json:"released_on,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
 }
 
 type Play struct {
@@ -26,29 +111,196 @@ type Play struct {
 	Difficulty string    `json:"difficulty"`
 	Notes      string    `json:"notes"`
 	ScenarioID int       `json:"scenario_id"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	// SourceID identifies an imported play in the file it came from, such
+	// as "bgstats:<uuid>". It is empty for plays logged in the tracker.
+	SourceID string `json:"source_id,omitempty"`
+	// EndReason is one of EndReasons, and together with the fields after
+	// it describes how the game ended. All of them are optional.
+	EndReason       string `json:"end_reason,omitempty"`
+	Rounds          *int   `json:"rounds,omitempty"`
+	VillainStage    *int   `json:"villain_stage,omitempty"`
+	RemainingThreat *int   `json:"remaining_threat,omitempty"`
+	// EncounterSetIDs are the modular sets used, saved along with the play
+	// by CreateWithDecks. Plays logged before modular sets were tracked
+	// have none.
+	EncounterSetIDs []int `json:"encounter_set_ids,omitempty"`
+	// OwnerID is the user who logged the play. Plays from before accounts
+	// existed may have none.
+	OwnerID int `json:"owner_id,omitempty"`
+	// GroupID is the group the play was logged into, whose members all see
+	// it. Zero for a play that is not part of a group.
+	GroupID   int       `json:"group_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type Deck struct {
-	ID        int       `json:"id"`
-	PlayID    int       `json:"play_id"`
-	HeroID    int       `json:"hero_id"`
-	Aspect    string    `json:"aspect"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int       `json:"id"`
+	PlayID      int       `json:"play_id"`
+	HeroID      int       `json:"hero_id"`
+	Aspects     []string  `json:"aspects"`
+	PlayerID    int       `json:"player_id,omitempty"`
+	PlayerName  string    `json:"player_name,omitempty"`
+	RemainingHP *int      `json:"remaining_hp,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DeckEntry is a hero and its aspects submitted together with a new play.
+// The hero is given either by HeroID or, when HeroID is zero, by HeroName,
+// in which case it is resolved to a heroes row when the play is saved.
+// Aspects are names from the aspects table. The player is optional and,
+// like the hero, given by PlayerID or by PlayerName; a new name adds a
+// player. RemainingHP, the hero's hit points when the game ended, is also
+// optional.
+type DeckEntry struct {
+	HeroID      int
+	HeroName    string
+	Aspects     []string
+	PlayerID    int
+	PlayerName  string
+	RemainingHP *int
+}
+
+// Validate checks the fields of a play that the database cannot check on
+// its own. ScenarioID is not checked because it is usually resolved while
+// the play is being saved.
+func (p *Play) Validate() error {
+	if p.Date.IsZero() {
+		return &ValidationError{Field: "date", Message: "date is required"}
+	}
+	if !contains(Outcomes, p.Outcome) {
+		return &ValidationError{Field: "outcome", Message: "outcome must be win or loss"}
+	}
+	if !contains(Difficulties, p.Difficulty) {
+		return &ValidationError{Field: "difficulty", Message: "unknown difficulty"}
+	}
+	if p.EndReason != "" {
+		reason, ok := findEndReason(p.EndReason)
+		if !ok {
+			return &ValidationError{Field: "end_reason", Message: "unknown end reason"}
+		}
+		if reason.Outcome != p.Outcome {
+			return &ValidationError{Field: "end_reason", Message: fmt.Sprintf("%q does not go with a %s", reason.Label, p.Outcome)}
+		}
+	}
+	if p.Rounds != nil && *p.Rounds < 1 {
+		return &ValidationError{Field: "rounds", Message: "rounds must be at least 1"}
+	}
+	if p.VillainStage != nil && *p.VillainStage < 1 {
+		return &ValidationError{Field: "villain_stage", Message: "villain stage must be at least 1"}
+	}
+	if p.RemainingThreat != nil && *p.RemainingThreat < 0 {
+		return &ValidationError{Field: "remaining_threat", Message: "remaining threat cannot be negative"}
+	}
+	return nil
+}
+
+func findEndReason(name string) (EndReason, bool) {
+	for _, r := range EndReasons {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return EndReason{}, false
+}
+
+// Validate checks that the entry names a hero and at least one aspect.
+// Whether the aspects exist is checked against the aspects table when the
+// entry is saved.
+func (d DeckEntry) Validate() error {
+	if d.HeroID == 0 && strings.TrimSpace(d.HeroName) == "" {
+		return &ValidationError{Field: "hero", Message: "hero is required"}
+	}
+	if len(d.Aspects) == 0 {
+		return &ValidationError{Field: "aspect", Message: "aspect is required"}
+	}
+	if d.RemainingHP != nil && *d.RemainingHP < 0 {
+		return &ValidationError{Field: "remaining_hp", Message: "remaining hit points cannot be negative"}
+	}
+	return nil
+}
+
+// nullIfEmpty maps an empty string to NULL for optional text columns.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullIfZero maps a zero id to NULL for optional references.
+func nullIfZero(id int) any {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// dbtx is the subset of *sql.DB and *sql.Tx used by the repositories, so
+// the same helpers can run inside or outside a transaction.
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 type PlayRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	viewer *Viewer
 }
 
 func NewPlayRepository(db *sql.DB) *PlayRepository {
 	return &PlayRepository{db: db}
 }
 
+// For returns a repository limited to the plays v may see and change.
+// Plays it creates belong to v.
+func (r *PlayRepository) For(v Viewer) *PlayRepository {
+	return &PlayRepository{db: r.db, viewer: &v}
+}
+
+// own makes p belong to the repository's viewer, if it has one.
+func (r *PlayRepository) own(p *Play) {
+	if r.viewer != nil {
+		p.OwnerID = r.viewer.UserID
+	}
+}
+
+// checkGroup reports a ValidationError unless p.GroupID is zero or a group
+// the repository's viewer may log plays into, which is one they own or
+// edit.
+func (r *PlayRepository) checkGroup(db dbtx, p *Play) error {
+	if p.GroupID == 0 {
+		return nil
+	}
+	query, args := "SELECT COUNT(*) FROM groups WHERE id = ?", []any{p.GroupID}
+	if r.viewer != nil {
+		query = "SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ? AND role IN ('owner', 'editor')"
+		args = append(args, r.viewer.UserID)
+	}
+	var count int
+	if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ValidationError{Field: "group_id", Message: "you cannot log plays into that group"}
+	}
+	return nil
+}
+
 func (r *PlayRepository) GetAll() ([]Play, error) {
-	rows, err := r.db.Query("SELECT id, date, outcome, difficulty, notes, scenario_id, created_at, updated_at FROM plays ORDER BY date DESC")
+	visible, args := visibleTo(r.viewer, "p")
+	rows, err := r.db.Query("SELECT id, date, outcome, difficulty, notes, scenario_id, created_at, updated_at FROM plays p WHERE "+visible+" ORDER BY date DESC", args...)
 	if err != nil {
 		return nil, err
 	}
@@ -68,9 +320,211 @@ func (r *PlayRepository) GetAll() ([]Play, error) {
 }
 
 func (r *PlayRepository) Create(p *Play) error {
-	result, err := r.db.Exec(
-		"INSERT INTO plays (date, outcome, difficulty, notes, scenario_id) VALUES (?, ?, ?, ?, ?)",
-		p.Date, p.Outcome, p.Difficulty, p.Notes, p.ScenarioID,
+	r.own(p)
+	if err := r.checkGroup(r.db, p); err != nil {
+		return err
+	}
+	return insertPlay(r.db, p)
+}
+
+// CreateWithDecks saves a play together with one deck per entry and the
+// modular sets in p.EncounterSetIDs in a single transaction. If
+// p.ScenarioID is zero the scenario is looked up by name, and heroes
+// without an id likewise; names that do not exist yet are created. A
+// viewer may only log a play into a group they own or edit. On success
+// p.ID and p.ScenarioID are populated.
+func (r *PlayRepository) CreateWithDecks(p *Play, scenarioName string, entries []DeckEntry) error {
+	r.own(p)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.checkGroup(tx, p); err != nil {
+		return err
+	}
+	if err := createWithDecks(tx, p, scenarioName, entries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createWithDecks does the work of CreateWithDecks inside the caller's
+// transaction.
+func createWithDecks(tx dbtx, p *Play, scenarioName string, entries []DeckEntry) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if len(entries) > MaxPlayers {
+		return &ValidationError{Field: "hero", Message: fmt.Sprintf("a play can have at most %d heroes", MaxPlayers)}
+	}
+	for _, entry := range entries {
+		if err := entry.Validate(); err != nil {
+			return err
+		}
+	}
+
+	scenarioID, err := resolveByIDOrName(tx, "scenarios", "scenario", p.ScenarioID, scenarioName)
+	if err != nil {
+		return err
+	}
+	p.ScenarioID = scenarioID
+
+	// Heroes are resolved and checked for duplicates before the play is
+	// inserted, so the same hero given once by id and once by name is still
+	// caught and p is left untouched on failure.
+	heroIDs := make([]int, len(entries))
+	seen := make(map[int]bool, len(entries))
+	for i, entry := range entries {
+		heroID, err := resolveByIDOrName(tx, "heroes", "hero", entry.HeroID, entry.HeroName)
+		if err != nil {
+			return err
+		}
+		if seen[heroID] {
+			return &ValidationError{Field: "hero", Message: "each hero can only appear once in a play"}
+		}
+		seen[heroID] = true
+		heroIDs[i] = heroID
+	}
+
+	aspectIDs := make([][]int, len(entries))
+	playerIDs := make([]int, len(entries))
+	for i, entry := range entries {
+		if aspectIDs[i], err = resolveAspects(tx, entry.Aspects); err != nil {
+			return err
+		}
+		if playerIDs[i], err = resolvePlayer(tx, p.OwnerID, entry.PlayerID, entry.PlayerName); err != nil {
+			return err
+		}
+	}
+
+	encounterSetIDs, err := resolveEncounterSets(tx, p.EncounterSetIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := insertPlay(tx, p); err != nil {
+		return err
+	}
+	if err := insertPlayEncounterSets(tx, p.ID, encounterSetIDs); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		if _, err := insertDeck(tx, p.ID, heroIDs[i], aspectIDs[i], playerIDs[i], entry.RemainingHP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetByID returns the play with the given id, or ErrNotFound.
+func (r *PlayRepository) GetByID(id int) (*Play, error) {
+	visible, args := visibleTo(r.viewer, "p")
+	var p Play
+	err := r.db.QueryRow(
+		`SELECT id, date, outcome, difficulty, COALESCE(notes, ''), scenario_id,
+		        COALESCE(end_reason, ''), rounds, villain_stage, remaining_threat, COALESCE(owner_id, 0), COALESCE(group_id, 0), created_at, updated_at
+		 FROM plays p WHERE id = ? AND `+visible,
+		append([]any{id}, args...)...,
+	).Scan(&p.ID, &p.Date, &p.Outcome, &p.Difficulty, &p.Notes, &p.ScenarioID,
+		&p.EndReason, &p.Rounds, &p.VillainStage, &p.RemainingThreat, &p.OwnerID, &p.GroupID, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Editable reports whether the viewer may change the play with the given
+// id, or returns ErrNotFound if they cannot see it.
+func (r *PlayRepository) Editable(id int) (bool, error) {
+	visible, args := visibleTo(r.viewer, "p")
+	editable, editableArgs := editableBy(r.viewer, "p")
+	var ok bool
+	err := r.db.QueryRow("SELECT "+editable+" FROM plays p WHERE p.id = ? AND "+visible,
+		append(append(editableArgs, id), args...)...).Scan(&ok)
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// Update saves the editable fields of an existing play. The scenario is
+// resolved from p.ScenarioID or scenarioName as in CreateWithDecks. Decks
+// and modular sets are left untouched, and so is the group the play was
+// logged into. A viewer may only update their own plays and those of
+// groups they own or edit; other plays are reported as ErrNotFound.
+func (r *PlayRepository) Update(p *Play, scenarioName string) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	scenarioID, err := resolveByIDOrName(tx, "scenarios", "scenario", p.ScenarioID, scenarioName)
+	if err != nil {
+		return err
+	}
+	p.ScenarioID = scenarioID
+
+	editable, editableArgs := editableBy(r.viewer, "plays")
+	result, err := tx.Exec(
+		`UPDATE plays SET date = ?, outcome = ?, difficulty = ?, notes = ?, scenario_id = ?,
+		        end_reason = ?, rounds = ?, villain_stage = ?, remaining_threat = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND `+editable,
+		append([]any{p.Date, p.Outcome, p.Difficulty, p.Notes, p.ScenarioID,
+			nullIfEmpty(p.EndReason), p.Rounds, p.VillainStage, p.RemainingThreat, p.ID}, editableArgs...)...,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a play. Its decks are removed by the ON DELETE CASCADE on
+// decks.play_id, which requires foreign keys to be enabled on the
+// connection. As with Update, a viewer may only delete the plays they may
+// change.
+func (r *PlayRepository) Delete(id int) error {
+	editable, args := editableBy(r.viewer, "plays")
+	result, err := r.db.Exec("DELETE FROM plays WHERE id = ? AND "+editable, append([]any{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+func requireRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func insertPlay(db dbtx, p *Play) error {
+	result, err := db.Exec(
+		`INSERT INTO plays (date, outcome, difficulty, notes, scenario_id, source_id, end_reason, rounds, villain_stage, remaining_threat, owner_id, group_id)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.Date, p.Outcome, p.Difficulty, p.Notes, p.ScenarioID, nullIfEmpty(p.SourceID),
+		nullIfEmpty(p.EndReason), p.Rounds, p.VillainStage, p.RemainingThreat, nullIfZero(p.OwnerID), nullIfZero(p.GroupID),
 	)
 	if err != nil {
 		return err
@@ -84,3 +538,50 @@ func (r *PlayRepository) Create(p *Play) error {
 	p.ID = int(id)
 	return nil
 }
+
+// resolveByIDOrName returns id if it refers to an existing row in table.
+// When id is zero the row is found or created by name instead. field names
+// the form field in any ValidationError.
+func resolveByIDOrName(db dbtx, table, field string, id int, name string) (int, error) {
+	if id != 0 {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE id = ?", id).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, &ValidationError{Field: field, Message: "unknown " + field}
+		}
+		return id, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, &ValidationError{Field: field, Message: field + " is required"}
+	}
+	return findOrCreateByName(db, table, name)
+}
+
+// findOrCreateByName returns the id of the row in table whose name matches
+// name case-insensitively, inserting a new row if there is none. table is
+// always a constant supplied by this package, never user input.
+func findOrCreateByName(db dbtx, table, name string) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM "+table+" WHERE name = ? COLLATE NOCASE", name).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	result, err := db.Exec("INSERT INTO "+table+" (name) VALUES (?)", name)
+	if err != nil {
+		return 0, err
+	}
+
+	newID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(newID), nil
+}
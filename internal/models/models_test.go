@@ -8,41 +8,36 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/migrations"
 )
 
+// setupTestDB migrates an in-memory database with the shipped migrations,
+// so that the tests run against the schema and catalog the server uses.
 func setupTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite3", ":memory:")
 	require.NoError(t, err)
+	// Every connection to :memory: opens a database of its own.
+	db.SetMaxOpenConns(1)
 
-	// Create test schema
-	schema := `
-	CREATE TABLE plays (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		date DATE NOT NULL,
-		outcome TEXT NOT NULL CHECK(outcome IN ('win', 'loss')),
-		difficulty TEXT NOT NULL,
-		notes TEXT,
-		scenario_id INTEGER NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE scenarios (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	_, err = db.Exec(schema)
-	require.NoError(t, err)
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+	return db
+}
 
-	// Insert test scenario
-	_, err = db.Exec("INSERT INTO scenarios (id, name) VALUES (1, 'Rhino')")
-	require.NoError(t, err)
+// catalogID returns the id the seeded catalog gave the named row.
+func catalogID(t *testing.T, db *sql.DB, table, name string) int {
+	t.Helper()
+	var id int
+	require.NoError(t, db.QueryRow("SELECT id FROM "+table+" WHERE name = ?", name).Scan(&id))
+	return id
+}
 
-	return db
+// countRows returns the number of rows in table.
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+table).Scan(&n))
+	return n
 }
 
 func TestNewPlayRepository(t *testing.T) {
@@ -111,6 +106,168 @@ func TestPlayRepository_Create(t *testing.T) {
 	})
 }
 
+func TestPlayRepository_CreateWithDecks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	heroCount := countRows(t, db, "heroes")
+	scenarioCount := countRows(t, db, "scenarios")
+
+	t.Run("Creates Scenario, Heroes and Decks", func(t *testing.T) {
+		play := &Play{
+			Date:       time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+		entries := []DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}},
+			{HeroName: "Squirrel Girl", Aspects: []string{"leadership"}},
+		}
+
+		err := repo.CreateWithDecks(play, "Galactus", entries)
+		require.NoError(t, err)
+		assert.NotZero(t, play.ID)
+		assert.Equal(t, scenarioCount+1, countRows(t, db, "scenarios"), "Galactus should be a new scenario")
+
+		var deckCount int
+		err = db.QueryRow("SELECT COUNT(*) FROM decks WHERE play_id = ?", play.ID).Scan(&deckCount)
+		require.NoError(t, err)
+		assert.Equal(t, 2, deckCount)
+
+		assert.Equal(t, heroCount+1, countRows(t, db, "heroes"), "only Squirrel Girl should be a new hero")
+	})
+
+	t.Run("Reuses Existing Rows Case-Insensitively", func(t *testing.T) {
+		play := &Play{
+			Date:       time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC),
+			Outcome:    "loss",
+			Difficulty: "Expert I",
+		}
+
+		err := repo.CreateWithDecks(play, "rhino", []DeckEntry{{HeroName: "squirrel girl", Aspects: []string{"aggression"}}})
+		require.NoError(t, err)
+		assert.Equal(t, catalogID(t, db, "scenarios", "Rhino"), play.ScenarioID)
+		assert.Equal(t, heroCount+1, countRows(t, db, "heroes"))
+	})
+
+	t.Run("Stores Player Names", func(t *testing.T) {
+		play := &Play{
+			Date:       time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+
+		err := repo.CreateWithDecks(play, "Rhino", []DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: " Sam "}})
+		require.NoError(t, err)
+
+		var name string
+		err = db.QueryRow("SELECT player_name FROM decks WHERE play_id = ?", play.ID).Scan(&name)
+		require.NoError(t, err)
+		assert.Equal(t, "Sam", name)
+	})
+
+	t.Run("Stores End State", func(t *testing.T) {
+		n := func(n int) *int { return &n }
+		play := &Play{
+			Date:            time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC),
+			Outcome:         "loss",
+			Difficulty:      "Expert I",
+			EndReason:       "scheme_completed",
+			Rounds:          n(9),
+			VillainStage:    n(2),
+			RemainingThreat: n(0),
+		}
+		entries := []DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}, RemainingHP: n(4)}}
+		require.NoError(t, repo.CreateWithDecks(play, "Rhino", entries))
+
+		found, err := repo.GetByID(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "scheme_completed", found.EndReason)
+		assert.Equal(t, n(9), found.Rounds)
+		assert.Equal(t, n(2), found.VillainStage)
+		assert.Equal(t, n(0), found.RemainingThreat)
+
+		summary, err := repo.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Main scheme completed", summary.EndReasonLabel())
+		require.Len(t, summary.Heroes, 1)
+		assert.Equal(t, n(4), summary.Heroes[0].RemainingHP)
+	})
+
+	t.Run("Validation Errors", func(t *testing.T) {
+		n := func(n int) *int { return &n }
+		valid := Play{
+			Date:       time.Date(2024, 2, 3, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+
+		testCases := []struct {
+			name     string
+			play     Play
+			scenario string
+			entries  []DeckEntry
+			field    string
+		}{
+			{"Missing Scenario", valid, "  ", nil, "scenario"},
+			{"Missing Date", Play{Outcome: "win", Difficulty: "Standard I"}, "Rhino", nil, "date"},
+			{"Bad Outcome", Play{Date: valid.Date, Outcome: "draw", Difficulty: "Standard I"}, "Rhino", nil, "outcome"},
+			{"Bad Difficulty", Play{Date: valid.Date, Outcome: "win", Difficulty: "Easy"}, "Rhino", nil, "difficulty"},
+			{"Unknown End Reason", Play{Date: valid.Date, Outcome: "win", Difficulty: "Standard I", EndReason: "timed_out"}, "Rhino", nil, "end_reason"},
+			{"End Reason Against Outcome", Play{Date: valid.Date, Outcome: "win", Difficulty: "Standard I", EndReason: "conceded"}, "Rhino", nil, "end_reason"},
+			{"Zero Rounds", Play{Date: valid.Date, Outcome: "win", Difficulty: "Standard I", Rounds: n(0)}, "Rhino", nil, "rounds"},
+			{"Zero Villain Stage", Play{Date: valid.Date, Outcome: "win", Difficulty: "Standard I", VillainStage: n(0)}, "Rhino", nil, "villain_stage"},
+			{"Negative Threat", Play{Date: valid.Date, Outcome: "win", Difficulty: "Standard I", RemainingThreat: n(-1)}, "Rhino", nil, "remaining_threat"},
+			{"Negative Hit Points", valid, "Rhino", []DeckEntry{{HeroName: "Hulk", Aspects: []string{"justice"}, RemainingHP: n(-2)}}, "remaining_hp"},
+			{"Unknown Aspect", valid, "Rhino", []DeckEntry{{HeroName: "Hulk", Aspects: []string{"speed"}}}, "aspect"},
+			{"Missing Aspect", valid, "Rhino", []DeckEntry{{HeroName: "Hulk"}}, "aspect"},
+			{"Missing Hero", valid, "Rhino", []DeckEntry{{Aspects: []string{"justice"}}}, "hero"},
+			{"Duplicate Hero", valid, "Rhino", []DeckEntry{{HeroID: 1, Aspects: []string{"justice"}}, {HeroName: "SPIDER-MAN", Aspects: []string{"aggression"}}}, "hero"},
+			{"Too Many Heroes", valid, "Rhino", []DeckEntry{
+				{HeroName: "A", Aspects: []string{"justice"}}, {HeroName: "B", Aspects: []string{"justice"}}, {HeroName: "C", Aspects: []string{"justice"}},
+				{HeroName: "D", Aspects: []string{"justice"}}, {HeroName: "E", Aspects: []string{"justice"}},
+			}, "hero"},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				play := tc.play
+				err := repo.CreateWithDecks(&play, tc.scenario, tc.entries)
+
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, tc.field, validationErr.Field)
+				assert.Zero(t, play.ID)
+			})
+		}
+	})
+
+	t.Run("Rolls Back on Deck Failure", func(t *testing.T) {
+		var before int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&before))
+
+		_, err := db.Exec("DROP TABLE decks")
+		require.NoError(t, err)
+
+		play := &Play{
+			Date:       time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+		err = repo.CreateWithDecks(play, "Dormammu", []DeckEntry{{HeroName: "Moon Knight", Aspects: []string{"aggression"}}})
+		assert.Error(t, err)
+
+		var after int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&after))
+		assert.Equal(t, before, after)
+
+		var scenarioCount int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM scenarios WHERE name = 'Dormammu'").Scan(&scenarioCount))
+		assert.Zero(t, scenarioCount)
+	})
+}
+
 func TestPlayRepository_GetAll(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -164,6 +321,107 @@ func TestPlayRepository_GetAll(t *testing.T) {
 	})
 }
 
+func TestPlayRepository_GetByID(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	play := &Play{
+		Date:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Outcome:    "win",
+		Difficulty: "Standard II",
+		Notes:      "Quick win",
+		ScenarioID: 1,
+	}
+	require.NoError(t, repo.Create(play))
+
+	found, err := repo.GetByID(play.ID)
+	require.NoError(t, err)
+	assert.Equal(t, play.ID, found.ID)
+	assert.Equal(t, "Standard II", found.Difficulty)
+	assert.Equal(t, "Quick win", found.Notes)
+	assert.True(t, play.Date.Equal(found.Date))
+
+	_, err = repo.GetByID(play.ID + 100)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPlayRepository_Update(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	play := &Play{
+		Date:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Outcome:    "loss",
+		Difficulty: "Standard I",
+	}
+	require.NoError(t, repo.CreateWithDecks(play, "Rhino", []DeckEntry{{HeroName: "Thor", Aspects: []string{"aggression"}}}))
+
+	t.Run("Valid Update", func(t *testing.T) {
+		play.Outcome = "win"
+		play.Notes = "Second attempt"
+		require.NoError(t, repo.Update(play, "Mutagen Formula"))
+
+		found, err := repo.GetByID(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "win", found.Outcome)
+		assert.Equal(t, "Second attempt", found.Notes)
+		assert.Equal(t, play.ScenarioID, found.ScenarioID)
+
+		var deckCount int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM decks WHERE play_id = ?", play.ID).Scan(&deckCount))
+		assert.Equal(t, 1, deckCount)
+	})
+
+	t.Run("Invalid Update", func(t *testing.T) {
+		invalid := *play
+		invalid.Difficulty = "Nightmare"
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, repo.Update(&invalid, "Rhino"), &validationErr)
+	})
+
+	t.Run("Missing Play", func(t *testing.T) {
+		missing := *play
+		missing.ID = 9999
+
+		assert.ErrorIs(t, repo.Update(&missing, "Rhino"), ErrNotFound)
+	})
+}
+
+func TestPlayRepository_Delete(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	// The pragma is per connection, so pin the pool to the one it ran on.
+	db.SetMaxOpenConns(1)
+	_, err := db.Exec("PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+
+	play := &Play{
+		Date:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Outcome:    "win",
+		Difficulty: "Standard I",
+	}
+	require.NoError(t, repo.CreateWithDecks(play, "Rhino", []DeckEntry{
+		{HeroName: "Thor", Aspects: []string{"aggression"}},
+		{HeroName: "Wasp", Aspects: []string{"leadership"}},
+	}))
+
+	require.NoError(t, repo.Delete(play.ID))
+
+	_, err = repo.GetByID(play.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var deckCount int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM decks").Scan(&deckCount))
+	assert.Zero(t, deckCount, "decks should be removed by ON DELETE CASCADE")
+
+	assert.ErrorIs(t, repo.Delete(play.ID), ErrNotFound)
+}
+
 func TestPlay_JSONMarshaling(t *testing.T) {
 	play := Play{
 		ID:         1,
@@ -217,7 +475,7 @@ func TestDeck_Struct(t *testing.T) {
 		ID:        1,
 		PlayID:    1,
 		HeroID:    1,
-		Aspect:    "leadership",
+		Aspects:   []string{"leadership", "protection"},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -225,7 +483,7 @@ func TestDeck_Struct(t *testing.T) {
 	assert.Equal(t, 1, deck.ID)
 	assert.Equal(t, 1, deck.PlayID)
 	assert.Equal(t, 1, deck.HeroID)
-	assert.Equal(t, "leadership", deck.Aspect)
+	assert.Equal(t, []string{"leadership", "protection"}, deck.Aspects)
 	assert.NotZero(t, deck.CreatedAt)
 	assert.NotZero(t, deck.UpdatedAt)
 }
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Player is a person who plays heroes. Decks link to players so each
+// person's record can be followed across plays.
+type Player struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PlayerRepository struct {
+	db     *sql.DB
+	viewer *Viewer
+}
+
+func NewPlayerRepository(db *sql.DB) *PlayerRepository {
+	return &PlayerRepository{db: db}
+}
+
+// For returns a repository limited to v's own players: it lists, changes
+// and adds only players owned by v, and LastGroup looks only at v's own
+// plays. Each user keeps their own list of the people they play with.
+func (r *PlayerRepository) For(v Viewer) *PlayerRepository {
+	return &PlayerRepository{db: r.db, viewer: &v}
+}
+
+// GetAll returns every player the repository's viewer owns ordered by
+// name.
+func (r *PlayerRepository) GetAll() ([]Player, error) {
+	owned, args := ownedBy(r.viewer, "pl")
+	rows, err := r.db.Query("SELECT pl.id, pl.name, pl.created_at, pl.updated_at FROM players pl WHERE "+owned+" ORDER BY pl.name COLLATE NOCASE", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPlayers(rows)
+}
+
+// GetByID returns the player with the given id, or ErrNotFound.
+func (r *PlayerRepository) GetByID(id int) (*Player, error) {
+	owned, args := ownedBy(r.viewer, "pl")
+	var p Player
+	err := r.db.QueryRow("SELECT pl.id, pl.name, pl.created_at, pl.updated_at FROM players pl WHERE pl.id = ? AND "+owned, append([]any{id}, args...)...).
+		Scan(&p.ID, &p.Name, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create adds a player owned by the repository's viewer, if it has one.
+// An owner's player names are unique regardless of case.
+func (r *PlayerRepository) Create(name string) (int, error) {
+	ownerID := r.ownerID()
+	name, err := checkPlayerName(r.db, ownerID, 0, name)
+	if err != nil {
+		return 0, err
+	}
+	result, err := r.db.Exec("INSERT INTO players (name, owner_id) VALUES (?, ?)", name, nullIfZero(ownerID))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Rename changes a player's name. Plays show the new name wherever the
+// deck is linked to the player.
+func (r *PlayerRepository) Rename(id int, name string) error {
+	owned, args := ownedBy(r.viewer, "pl")
+	var ownerID sql.NullInt64
+	err := r.db.QueryRow("SELECT pl.owner_id FROM players pl WHERE pl.id = ? AND "+owned, append([]any{id}, args...)...).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	name, err = checkPlayerName(r.db, int(ownerID.Int64), id, name)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec("UPDATE players SET name = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", name, id)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// Delete removes a player. Their decks keep the player's name but are no
+// longer linked to a player, through the ON DELETE SET NULL on
+// decks.player_id.
+func (r *PlayerRepository) Delete(id int) error {
+	owned, args := ownedBy(r.viewer, "pl")
+	result, err := r.db.Exec("DELETE FROM players AS pl WHERE pl.id = ? AND "+owned, append([]any{id}, args...)...)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// ownerID returns the id of the user new players belong to: the viewer,
+// or zero for none when the repository is not limited to a viewer.
+func (r *PlayerRepository) ownerID() int {
+	if r.viewer == nil {
+		return 0
+	}
+	return r.viewer.UserID
+}
+
+// LastGroup returns the players of the most recent play that recorded any,
+// in the order their decks were added. It is empty if no play has players.
+func (r *PlayerRepository) LastGroup() ([]Player, error) {
+	owned, args := ownedBy(r.viewer, "p")
+	rows, err := r.db.Query(`
+		SELECT pl.id, pl.name, pl.created_at, pl.updated_at
+		FROM decks d JOIN players pl ON pl.id = d.player_id
+		WHERE d.play_id = (
+			SELECT p.id FROM plays p
+			WHERE EXISTS (SELECT 1 FROM decks pd WHERE pd.play_id = p.id AND pd.player_id IS NOT NULL)
+			  AND `+owned+`
+			ORDER BY p.date DESC, p.id DESC
+			LIMIT 1
+		)
+		ORDER BY d.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPlayers(rows)
+}
+
+func scanPlayers(rows *sql.Rows) ([]Player, error) {
+	var players []Player
+	for rows.Next() {
+		var p Player
+		if err := rows.Scan(&p.ID, &p.Name, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		players = append(players, p)
+	}
+	return players, rows.Err()
+}
+
+// checkPlayerName trims name and checks that it is not blank and that no
+// other player of the same owner, zero for none, has it.
+func checkPlayerName(db dbtx, ownerID, excludeID int, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &ValidationError{Field: "name", Message: "name is required"}
+	}
+
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM players WHERE name = ? COLLATE NOCASE AND owner_id IS ? AND id != ?", name, nullIfZero(ownerID), excludeID).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", &ValidationError{Field: "name", Message: "a player named " + name + " already exists"}
+	}
+	return name, nil
+}
+
+// resolvePlayer returns the player a deck is linked to, from the players
+// of ownerID, the owner of the deck's play or zero for none. A non-zero id
+// must name one of those players; otherwise the player is found or added
+// by name. A blank name with no id means the deck has no player, and zero
+// is returned.
+func resolvePlayer(db dbtx, ownerID, id int, name string) (int, error) {
+	owner := nullIfZero(ownerID)
+	if id != 0 {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM players WHERE id = ? AND owner_id IS ?", id, owner).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, &ValidationError{Field: "player", Message: "unknown player"}
+		}
+		return id, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, nil
+	}
+	err := db.QueryRow("SELECT id FROM players WHERE name = ? COLLATE NOCASE AND owner_id IS ?", name, owner).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+	result, err := db.Exec("INSERT INTO players (name, owner_id) VALUES (?, ?)", name, owner)
+	if err != nil {
+		return 0, err
+	}
+	newID, err := result.LastInsertId()
+	return int(newID), err
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	plays := NewPlayRepository(db)
+	repo := NewPlayerRepository(db)
+
+	rhino := catalogID(t, db, "scenarios", "Rhino")
+	spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+	sheHulk := catalogID(t, db, "heroes", "She-Hulk")
+	blackPanther := catalogID(t, db, "heroes", "Black Panther")
+
+	logPlay := func(t *testing.T, date string, decks ...DeckEntry) *Play {
+		d, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		play := &Play{Date: d, Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino}
+		require.NoError(t, plays.CreateWithDecks(play, "", decks))
+		return play
+	}
+
+	t.Run("Create And Rename", func(t *testing.T) {
+		id, err := repo.Create("Alice")
+		require.NoError(t, err)
+
+		var validationErr *ValidationError
+		_, err = repo.Create("alice")
+		require.ErrorAs(t, err, &validationErr, "names are unique regardless of case")
+		assert.Equal(t, "name", validationErr.Field)
+
+		require.NoError(t, repo.Rename(id, "Alicia"))
+		player, err := repo.GetByID(id)
+		require.NoError(t, err)
+		assert.Equal(t, "Alicia", player.Name)
+
+		assert.ErrorIs(t, repo.Rename(999, "Nobody"), ErrNotFound)
+		_, err = repo.GetByID(999)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Decks Link By Name", func(t *testing.T) {
+		play := logPlay(t, "2024-03-01",
+			DeckEntry{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerName: " alicia "},
+			DeckEntry{HeroID: sheHulk, Aspects: []string{"aggression"}, PlayerName: "Bob"},
+			DeckEntry{HeroID: blackPanther, Aspects: []string{"protection"}})
+
+		summary, err := plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		require.Len(t, summary.Heroes, 3)
+		assert.Equal(t, "Alicia", summary.Heroes[0].PlayerName, "an existing player is matched regardless of case")
+		assert.NotZero(t, summary.Heroes[0].PlayerID)
+		assert.Equal(t, "Bob", summary.Heroes[1].PlayerName, "a new name adds a player")
+		assert.Zero(t, summary.Heroes[2].PlayerID)
+
+		players, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Len(t, players, 2)
+
+		require.NoError(t, repo.Rename(summary.Heroes[1].PlayerID, "Robert"))
+		summary, err = plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Robert", summary.Heroes[1].PlayerName, "plays show the player's current name")
+	})
+
+	t.Run("Decks Link By ID", func(t *testing.T) {
+		players, err := repo.GetAll()
+		require.NoError(t, err)
+		play := logPlay(t, "2024-03-02", DeckEntry{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerID: players[0].ID})
+		summary, err := plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, players[0].Name, summary.Heroes[0].PlayerName)
+
+		var validationErr *ValidationError
+		err = plays.CreateWithDecks(&Play{Date: play.Date, Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino}, "",
+			[]DeckEntry{{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerID: 999}})
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "player", validationErr.Field)
+	})
+
+	t.Run("LastGroup", func(t *testing.T) {
+		logPlay(t, "2024-03-10",
+			DeckEntry{HeroID: sheHulk, Aspects: []string{"justice"}, PlayerName: "Robert"},
+			DeckEntry{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerName: "Carol"})
+		logPlay(t, "2024-03-05", DeckEntry{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerName: "Alicia"})
+		logPlay(t, "2024-03-20", DeckEntry{HeroID: spiderMan, Aspects: []string{"justice"}})
+
+		group, err := repo.LastGroup()
+		require.NoError(t, err)
+		require.Len(t, group, 2, "the latest play without players is skipped")
+		assert.Equal(t, "Robert", group[0].Name)
+		assert.Equal(t, "Carol", group[1].Name)
+	})
+
+	t.Run("Delete Keeps Plays", func(t *testing.T) {
+		players, err := repo.GetAll()
+		require.NoError(t, err)
+		var carol Player
+		for _, p := range players {
+			if p.Name == "Carol" {
+				carol = p
+			}
+		}
+		require.NotZero(t, carol.ID)
+
+		_, err = db.Exec("PRAGMA foreign_keys = ON")
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(carol.ID))
+		assert.ErrorIs(t, repo.Delete(carol.ID), ErrNotFound)
+
+		summaries, err := plays.GetSummaries()
+		require.NoError(t, err)
+		var found bool
+		for _, s := range summaries {
+			for _, h := range s.Heroes {
+				if h.PlayerName == "Carol" {
+					found = true
+					assert.Zero(t, h.PlayerID, "the deck is no longer linked")
+				}
+			}
+		}
+		assert.True(t, found, "the deck keeps the name it was logged with")
+	})
+	t.Run("Scoped To Owner", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', '')")
+		require.NoError(t, err)
+		alice, bob := repo.For(Viewer{UserID: 1}), repo.For(Viewer{UserID: 2})
+
+		aliceSam, err := alice.Create("Sam")
+		require.NoError(t, err)
+		bobSam, err := bob.Create("sam")
+		require.NoError(t, err, "each user has their own players")
+		_, err = alice.Create("Robert")
+		require.NoError(t, err, "names taken by players without an owner are free")
+
+		players, err := alice.GetAll()
+		require.NoError(t, err)
+		require.Len(t, players, 2)
+		assert.Equal(t, "Robert", players[0].Name)
+		assert.Equal(t, aliceSam, players[1].ID)
+
+		_, err = alice.GetByID(bobSam)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, alice.Rename(bobSam, "Samuel"), ErrNotFound)
+		assert.ErrorIs(t, alice.Delete(bobSam), ErrNotFound)
+		var validationErr *ValidationError
+		require.ErrorAs(t, bob.Rename(bobSam, "  "), &validationErr)
+
+		// Decks link to the players of the play's owner.
+		play := &Play{Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino}
+		require.NoError(t, plays.For(Viewer{UserID: 2}).CreateWithDecks(play, "", []DeckEntry{{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerName: "SAM"}}))
+		summary, err := plays.GetSummary(play.ID)
+		require.NoError(t, err)
+		assert.Equal(t, bobSam, summary.Heroes[0].PlayerID)
+
+		err = plays.For(Viewer{UserID: 2}).CreateWithDecks(&Play{Date: play.Date, Outcome: "win", Difficulty: "Standard I", ScenarioID: rhino}, "",
+			[]DeckEntry{{HeroID: spiderMan, Aspects: []string{"justice"}, PlayerID: aliceSam}})
+		require.ErrorAs(t, err, &validationErr, "another user's player cannot be used")
+		assert.Equal(t, "player", validationErr.Field)
+	})
+}
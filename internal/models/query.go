@@ -0,0 +1,25 @@
+package models
+
+import "strings"
+
+// conditions builds the WHERE clause of a query one condition at a time,
+// keeping each condition's arguments in order beside it.
+type conditions struct {
+	conds []string
+	args  []any
+}
+
+// add appends cond, with the arguments for its placeholders.
+func (c *conditions) add(cond string, args ...any) {
+	c.conds = append(c.conds, cond)
+	c.args = append(c.args, args...)
+}
+
+// where returns the conditions joined with AND, and their arguments. It
+// returns "1" when there are no conditions.
+func (c *conditions) where() (string, []any) {
+	if len(c.conds) == 0 {
+		return "1", nil
+	}
+	return strings.Join(c.conds, " AND "), c.args
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnippetPart is a piece of a search snippet. Match marks the pieces that
+// matched the query, which the page highlights.
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// PlayMatch is a play found by Search, with the piece of its notes,
+// scenario or heroes that matched best.
+type PlayMatch struct {
+	PlaySummary
+	Snippet []SnippetPart `json:"snippet"`
+}
+
+// searchHit is a play id found by searchHits, with its snippet marked up
+// with snippetOpen and snippetClose around each match.
+type searchHit struct {
+	ID      int
+	Snippet string
+}
+
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// Search returns up to limit of the plays the viewer may see whose notes,
+// scenario or hero names contain every word of query, the best matches
+// first. Words match as prefixes, so a query can be searched as it is
+// typed. Punctuation is ignored: "Breakin' & Takin'" looks for "breakin"
+// and "takin".
+//
+// When the server is built with the sqlite_fts5 tag the play_search index
+// ranks the matches (see FullTextSearch); otherwise the plays are scanned
+// with LIKE and the newest matches come first.
+func (r *PlayRepository) Search(query string, limit int) ([]PlayMatch, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	hits, err := r.searchHits(terms, limit)
+	if err != nil || len(hits) == 0 {
+		return nil, err
+	}
+
+	placeholders := make([]string, len(hits))
+	args := make([]any, len(hits))
+	for i, hit := range hits {
+		placeholders[i] = "?"
+		args[i] = hit.ID
+	}
+	rows, err := r.db.Query(playSummarySelect+" WHERE p.id IN ("+strings.Join(placeholders, ", ")+") ORDER BY p.id, d.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries, err := scanPlaySummaries(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]PlaySummary, len(summaries))
+	for _, s := range summaries {
+		byID[s.ID] = s
+	}
+	matches := make([]PlayMatch, 0, len(hits))
+	for _, hit := range hits {
+		if s, ok := byID[hit.ID]; ok {
+			matches = append(matches, PlayMatch{PlaySummary: s, Snippet: parseSnippet(hit.Snippet)})
+		}
+	}
+	return matches, nil
+}
+
+// searchTerms splits a query into the words it looks for, the way the
+// play_search index tokenizes text: runs of letters and digits.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// parseSnippet splits a snippet marked up by searchHits into its parts.
+func parseSnippet(snippet string) []SnippetPart {
+	var parts []SnippetPart
+	for snippet != "" {
+		open := strings.Index(snippet, snippetOpen)
+		if open < 0 {
+			parts = append(parts, SnippetPart{Text: snippet})
+			break
+		}
+		if open > 0 {
+			parts = append(parts, SnippetPart{Text: snippet[:open]})
+		}
+		snippet = snippet[open+len(snippetOpen):]
+		end := strings.Index(snippet, snippetClose)
+		if end < 0 {
+			end = len(snippet)
+		}
+		parts = append(parts, SnippetPart{Text: snippet[:end], Match: true})
+		snippet = strings.TrimPrefix(snippet[end:], snippetClose)
+	}
+	return parts
+}
@@ -0,0 +1,43 @@
+//go:build sqlite_fts5
+
+package models
+
+import "strings"
+
+// FullTextSearch reports whether Search uses the play_search FTS5 index,
+// which needs go-sqlite3 built with the sqlite_fts5 tag.
+const FullTextSearch = true
+
+// searchHits looks the terms up in the play_search index, best matches by
+// BM25 first. Each term is quoted, so that the index reads it as a word
+// rather than as query syntax, and matched as a prefix.
+func (r *PlayRepository) searchHits(terms []string, limit int) ([]searchHit, error) {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + term + `"*`
+	}
+
+	visible, args := visibleTo(r.viewer, "p")
+	rows, err := r.db.Query(`
+		SELECT ps.rowid, snippet(play_search, -1, char(2), char(3), '…', 12)
+		FROM play_search ps
+		JOIN plays p ON p.id = ps.rowid
+		WHERE play_search MATCH ? AND `+visible+`
+		ORDER BY bm25(play_search), p.date DESC
+		LIMIT ?`,
+		append(append([]any{strings.Join(quoted, " ")}, args...), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []searchHit
+	for rows.Next() {
+		var hit searchHit
+		if err := rows.Scan(&hit.ID, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
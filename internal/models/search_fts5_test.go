@@ -0,0 +1,46 @@
+//go:build sqlite_fts5
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFTS5Build(t *testing.T) {
+	db := setupSearchDB(t)
+
+	var enabled bool
+	require.NoError(t, db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled))
+	assert.True(t, enabled, "the sqlite_fts5 tag compiles FTS5 into go-sqlite3")
+	assert.True(t, FullTextSearch)
+
+	var applied int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM migrations WHERE filename = '013_play_search.sql'").Scan(&applied))
+	assert.Equal(t, 1, applied)
+}
+
+func TestPlayRepository_SearchRanking(t *testing.T) {
+	db := setupSearchDB(t)
+	repo := NewPlayRepository(db)
+
+	logPlay := func(date, scenario, notes string) *Play {
+		d, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		play := &Play{Date: d, Outcome: "win", Difficulty: "Standard I", Notes: notes}
+		require.NoError(t, repo.CreateWithDecks(play, scenario, []DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+		return play
+	}
+	passing := logPlay("2024-03-01", "Klaw", "A long evening where the Rhino minion showed up late among many other things that happened")
+	focused := logPlay("2024-01-01", "Rhino", "Rhino charged, Rhino stunned")
+
+	matches, err := repo.Search("rhino", 10)
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, focused.ID, matches[0].ID, "the play about Rhino ranks above the newer one that mentions him")
+	assert.Equal(t, passing.ID, matches[1].ID)
+	assert.Contains(t, matches[1].Snippet, SnippetPart{Text: "Rhino", Match: true})
+}
@@ -0,0 +1,107 @@
+//go:build !sqlite_fts5
+
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// FullTextSearch reports whether Search uses the play_search FTS5 index,
+// which needs go-sqlite3 built with the sqlite_fts5 tag. This build scans
+// plays with LIKE instead.
+const FullTextSearch = false
+
+// snippetWindow is roughly how many bytes of text a LIKE snippet shows
+// around the first match, to keep long notes to a line or two.
+const snippetWindow = 100
+
+// searchHits finds the plays whose notes, scenario or heroes contain every
+// term, newest first. Terms are letters and digits only, so they need no
+// escaping in a LIKE pattern.
+func (r *PlayRepository) searchHits(terms []string, limit int) ([]searchHit, error) {
+	const heroNames = "(SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = p.id)"
+
+	visible, args := visibleTo(r.viewer, "p")
+	conds := []string{visible}
+	for _, term := range terms {
+		conds = append(conds, "(p.notes LIKE ? OR s.name LIKE ? OR "+heroNames+" LIKE ?)")
+		pattern := "%" + term + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	rows, err := r.db.Query(`
+		SELECT p.id, COALESCE(p.notes, ''), s.name, `+heroNames+`
+		FROM plays p
+		JOIN scenarios s ON s.id = p.scenario_id
+		WHERE `+strings.Join(conds, " AND ")+`
+		ORDER BY p.date DESC, p.id DESC
+		LIMIT ?`,
+		append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pattern := termPattern(terms)
+	var hits []searchHit
+	for rows.Next() {
+		var hit searchHit
+		var notes, scenario, heroes string
+		if err := rows.Scan(&hit.ID, &notes, &scenario, &heroes); err != nil {
+			return nil, err
+		}
+		for _, text := range []string{notes, scenario, heroes} {
+			if pattern.MatchString(text) {
+				hit.Snippet = markSnippet(text, pattern)
+				break
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// termPattern matches any of the terms, ignoring case.
+func termPattern(terms []string) *regexp.Regexp {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// markSnippet cuts text down to about snippetWindow bytes around the first
+// match of pattern, on word boundaries, and marks every match in it as
+// snippet() does in the FTS5 build.
+func markSnippet(text string, pattern *regexp.Regexp) string {
+	if len(text) > snippetWindow {
+		first := pattern.FindStringIndex(text)[0]
+		start := max(0, first-snippetWindow/3)
+		end := min(len(text), start+snippetWindow)
+		if start > 0 {
+			if space := strings.IndexByte(text[start:first], ' '); space >= 0 {
+				start += space + 1
+			} else {
+				start = first
+			}
+		}
+		if end < len(text) {
+			if space := strings.LastIndexByte(text[first:end], ' '); space > 0 {
+				end = first + space
+			}
+			for end < len(text) && !utf8.RuneStart(text[end]) {
+				end++
+			}
+		}
+		prefix, suffix := "", ""
+		if start > 0 {
+			prefix = "…"
+		}
+		if end < len(text) {
+			suffix = "…"
+		}
+		text = prefix + text[start:end] + suffix
+	}
+	return pattern.ReplaceAllString(text, snippetOpen+"$0"+snippetClose)
+}
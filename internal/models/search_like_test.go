@@ -0,0 +1,36 @@
+//go:build !sqlite_fts5
+
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLikeSearchBuild(t *testing.T) {
+	db := setupSearchDB(t)
+
+	var enabled bool
+	require.NoError(t, db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled))
+	assert.False(t, enabled, "go-sqlite3 leaves FTS5 out without the sqlite_fts5 tag")
+	assert.False(t, FullTextSearch)
+
+	var tables int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'play_search'").Scan(&tables))
+	assert.Zero(t, tables, "the index migration is skipped")
+}
+
+func TestMarkSnippet(t *testing.T) {
+	pattern := termPattern([]string{"rhino"})
+	assert.Equal(t, "\x02Rhino\x03 charged", markSnippet("Rhino charged", pattern))
+
+	long := strings.Repeat("word ", 30) + "then RHINO charged " + strings.Repeat("more ", 30)
+	snippet := markSnippet(long, pattern)
+	assert.Contains(t, snippet, "then \x02RHINO\x03 charged")
+	assert.True(t, strings.HasPrefix(snippet, "…word "), snippet)
+	assert.True(t, strings.HasSuffix(snippet, "more…"), snippet)
+	assert.LessOrEqual(t, len(snippet), snippetWindow+10)
+}
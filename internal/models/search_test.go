@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupSearchDB migrates an in-memory database, which adds the play_search
+// index in FTS5 builds, with two users.
+func setupSearchDB(t *testing.T) *sql.DB {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+
+	_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', '')")
+	require.NoError(t, err)
+	return db
+}
+
+// matchedText returns the highlighted parts of a match's snippet.
+func matchedText(m PlayMatch) []string {
+	var matched []string
+	for _, part := range m.Snippet {
+		if part.Match {
+			matched = append(matched, part.Text)
+		}
+	}
+	return matched
+}
+
+func TestPlayRepository_Search(t *testing.T) {
+	db := setupSearchDB(t)
+	repo := NewPlayRepository(db)
+	alice := repo.For(Viewer{UserID: 1})
+
+	logPlay := func(repo *PlayRepository, date, scenario, notes string, heroes ...string) *Play {
+		d, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		play := &Play{Date: d, Outcome: "loss", Difficulty: "Standard I", Notes: notes}
+		var decks []DeckEntry
+		for _, hero := range heroes {
+			decks = append(decks, DeckEntry{HeroName: hero, Aspects: []string{"justice"}})
+		}
+		require.NoError(t, repo.CreateWithDecks(play, scenario, decks))
+		return play
+	}
+	ids := func(matches []PlayMatch) []int {
+		var ids []int
+		for _, m := range matches {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	breakin := logPlay(alice, "2024-01-05", "Rhino", "Breakin' & Takin' flipped twice, then Rhino charged", "Spider-Man")
+	klaw := logPlay(alice, "2024-02-10", "Klaw", "Close game", "She-Hulk", "Captain Marvel")
+	bobs := logPlay(repo.For(Viewer{UserID: 2}), "2024-03-01", "Rhino", "Takin' everything", "Spider-Man")
+
+	t.Run("Notes", func(t *testing.T) {
+		matches, err := alice.Search("Breakin' & Takin'", 10)
+		require.NoError(t, err)
+		require.Equal(t, []int{breakin.ID}, ids(matches))
+		assert.Equal(t, "Rhino", matches[0].Scenario)
+		assert.Equal(t, []string{"Breakin", "Takin"}, matchedText(matches[0]))
+	})
+
+	t.Run("Prefixes And Names", func(t *testing.T) {
+		matches, err := alice.Search("captain marv", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{klaw.ID}, ids(matches), "hero names are searched as they are typed")
+
+		matches, err = alice.Search("klaw", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{klaw.ID}, ids(matches))
+		assert.Equal(t, []string{"Klaw"}, matchedText(matches[0]))
+	})
+
+	t.Run("Only Visible Plays", func(t *testing.T) {
+		matches, err := alice.Search("takin", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{breakin.ID}, ids(matches), "bob does not share his plays")
+
+		matches, err = repo.Search("takin", 10)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []int{breakin.ID, bobs.ID}, ids(matches))
+
+		matches, err = repo.Search("takin", 1)
+		require.NoError(t, err)
+		assert.Len(t, matches, 1)
+	})
+
+	t.Run("Nothing To Search", func(t *testing.T) {
+		for _, query := range []string{"", "  ", "&", `"*`} {
+			matches, err := alice.Search(query, 10)
+			require.NoError(t, err)
+			assert.Empty(t, matches, query)
+		}
+		matches, err := alice.Search("venom", 10)
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	})
+
+	t.Run("Follows Changes", func(t *testing.T) {
+		edited := *klaw
+		edited.Notes = "Sonic Boom ended it"
+		require.NoError(t, alice.Update(&edited, ""))
+		matches, err := alice.Search("sonic", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{klaw.ID}, ids(matches))
+		matches, err = alice.Search("close", 10)
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+
+		_, err = db.Exec("UPDATE heroes SET name = 'Jennifer Walters' WHERE name = 'She-Hulk'")
+		require.NoError(t, err)
+		matches, err = alice.Search("jennifer", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{klaw.ID}, ids(matches))
+
+		_, err = db.Exec("PRAGMA foreign_keys = ON")
+		require.NoError(t, err)
+		require.NoError(t, alice.Delete(klaw.ID))
+		matches, err = alice.Search("sonic", 10)
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	})
+}
+
+func TestParseSnippet(t *testing.T) {
+	assert.Nil(t, parseSnippet(""))
+	assert.Equal(t, []SnippetPart{{Text: "plain"}}, parseSnippet("plain"))
+	assert.Equal(t, []SnippetPart{
+		{Text: "Breakin", Match: true},
+		{Text: "' & "},
+		{Text: "Takin", Match: true},
+		{Text: "' flipped"},
+	}, parseSnippet("\x02Breakin\x03' & \x02Takin\x03' flipped"))
+}
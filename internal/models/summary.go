@@ -0,0 +1,404 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeroAspect is a hero as played in a particular play, with the aspects it
+// was built with.
+type HeroAspect struct {
+	HeroID      int      `json:"hero_id"`
+	Hero        string   `json:"hero"`
+	Aspects     []string `json:"aspects"`
+	PlayerID    int      `json:"player_id,omitempty"`
+	PlayerName  string   `json:"player_name,omitempty"`
+	RemainingHP *int     `json:"remaining_hp,omitempty"`
+}
+
+// AspectLabel returns the deck's aspects joined for display, such as
+// "justice / protection".
+func (h HeroAspect) AspectLabel() string {
+	return strings.Join(h.Aspects, " / ")
+}
+
+// PlaySummary is the read model for listing plays: a play joined with its
+// scenario name and every hero that took part.
+type PlaySummary struct {
+	ID         int       `json:"id"`
+	Date       time.Time `json:"date"`
+	Outcome    string    `json:"outcome"`
+	Difficulty string    `json:"difficulty"`
+	Notes      string    `json:"notes"`
+	ScenarioID int       `json:"scenario_id"`
+	Scenario   string    `json:"scenario"`
+	SourceID   string    `json:"source_id,omitempty"`
+	// OwnerID and Owner are the id and username of the user who logged
+	// the play, if any.
+	OwnerID int    `json:"owner_id,omitempty"`
+	Owner   string `json:"owner,omitempty"`
+	// GroupID and Group are the id and name of the group the play was
+	// logged into, if any.
+	GroupID int    `json:"group_id,omitempty"`
+	Group   string `json:"group,omitempty"`
+	// The end state fields are as on Play and are optional.
+	EndReason       string       `json:"end_reason,omitempty"`
+	Rounds          *int         `json:"rounds,omitempty"`
+	VillainStage    *int         `json:"villain_stage,omitempty"`
+	RemainingThreat *int         `json:"remaining_threat,omitempty"`
+	Heroes          []HeroAspect `json:"heroes"`
+	// EncounterSets names the modular sets used, in name order. It is
+	// empty when they were not recorded.
+	EncounterSets []string `json:"encounter_sets,omitempty"`
+}
+
+// EncounterSetLabel returns the modular sets joined for display, such as
+// "Bomb Scare, Masters of Evil".
+func (s PlaySummary) EncounterSetLabel() string {
+	return strings.Join(s.EncounterSets, ", ")
+}
+
+// EndReasonLabel returns the display label of the end reason, or "" if
+// none was recorded.
+func (s PlaySummary) EndReasonLabel() string {
+	return EndReasonLabel(s.EndReason)
+}
+
+// FormattedDate returns the play date in the form shown on the plays page.
+func (s PlaySummary) FormattedDate() string {
+	return s.Date.Format("Jan 2, 2006")
+}
+
+const playSummarySelect = `
+	SELECT p.id, p.date, p.outcome, p.difficulty, COALESCE(p.notes, ''), p.scenario_id, s.name,
+	       COALESCE(p.source_id, ''), COALESCE(p.end_reason, ''), p.rounds, p.villain_stage, p.remaining_threat,
+	       COALESCE(p.owner_id, 0), COALESCE(u.username, ''), COALESCE(p.group_id, 0), COALESCE(g.name, ''),
+	       d.hero_id, h.name, d.player_id, COALESCE(pl.name, d.player_name), d.remaining_hp,
+	       (SELECT GROUP_CONCAT(a.name, ',' ORDER BY a.sort_order)
+	        FROM deck_aspects da JOIN aspects a ON a.id = da.aspect_id
+	        WHERE da.deck_id = d.id),
+	       (SELECT GROUP_CONCAT(e.name, char(31) ORDER BY e.name COLLATE NOCASE)
+	        FROM play_encounter_sets pe JOIN encounter_sets e ON e.id = pe.encounter_set_id
+	        WHERE pe.play_id = p.id)
+	FROM plays p
+	JOIN scenarios s ON s.id = p.scenario_id
+	LEFT JOIN users u ON u.id = p.owner_id
+	LEFT JOIN groups g ON g.id = p.group_id
+	LEFT JOIN decks d ON d.play_id = p.id
+	LEFT JOIN heroes h ON h.id = d.hero_id
+	LEFT JOIN players pl ON pl.id = d.player_id`
+
+// GetSummaries returns every play, newest first, with scenario and hero
+// names joined in. Plays and their decks are read in a single query.
+func (r *PlayRepository) GetSummaries() ([]PlaySummary, error) {
+	visible, args := visibleTo(r.viewer, "p")
+	rows, err := r.db.Query(playSummarySelect+" WHERE "+visible+" ORDER BY p.date DESC, p.id DESC, d.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPlaySummaries(rows)
+}
+
+// PlayFilter narrows ListSummaries and ListPage. Zero fields match
+// everything; From and To are inclusive dates. HeroID and Aspect match a
+// single deck when both are set, so that they find plays of the hero built
+// with the aspect. Players is the number of heroes in the play.
+type PlayFilter struct {
+	ScenarioID int
+	HeroID     int
+	Aspect     string
+	Outcome    string
+	Difficulty string
+	Players    int
+	From       time.Time
+	To         time.Time
+}
+
+// IsZero reports whether f matches every play.
+func (f PlayFilter) IsZero() bool {
+	return f == PlayFilter{}
+}
+
+// conditions returns the conditions on plays p for f.
+func (f PlayFilter) conditions() *conditions {
+	q := &conditions{}
+	if f.ScenarioID != 0 {
+		q.add("p.scenario_id = ?", f.ScenarioID)
+	}
+	if f.HeroID != 0 || f.Aspect != "" {
+		deck := &conditions{}
+		deck.add("fd.play_id = p.id")
+		if f.HeroID != 0 {
+			deck.add("fd.hero_id = ?", f.HeroID)
+		}
+		if f.Aspect != "" {
+			deck.add(`EXISTS (SELECT 1 FROM deck_aspects fda JOIN aspects fa ON fa.id = fda.aspect_id
+				WHERE fda.deck_id = fd.id AND fa.name = ? COLLATE NOCASE)`, f.Aspect)
+		}
+		where, args := deck.where()
+		q.add("EXISTS (SELECT 1 FROM decks fd WHERE "+where+")", args...)
+	}
+	if f.Outcome != "" {
+		q.add("p.outcome = ?", f.Outcome)
+	}
+	if f.Difficulty != "" {
+		q.add("p.difficulty = ?", f.Difficulty)
+	}
+	if f.Players != 0 {
+		q.add("(SELECT COUNT(*) FROM decks fc WHERE fc.play_id = p.id) = ?", f.Players)
+	}
+	if !f.From.IsZero() {
+		q.add("p.date >= ?", f.From.Format("2006-01-02"))
+	}
+	if !f.To.IsZero() {
+		// Dates are stored with a time part, so compare against the start
+		// of the following day to include all of To.
+		q.add("p.date < ?", f.To.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	return q
+}
+
+// ListSummaries returns one page of the plays matching f, newest first,
+// together with the number of matching plays across all pages.
+func (r *PlayRepository) ListSummaries(f PlayFilter, limit, offset int) ([]PlaySummary, int, error) {
+	q := f.conditions()
+	visible, visibleArgs := visibleTo(r.viewer, "p")
+	q.add(visible, visibleArgs...)
+	where, args := q.where()
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM plays p WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page := `SELECT p.id FROM plays p WHERE ` + where + ` ORDER BY p.date DESC, p.id DESC LIMIT ? OFFSET ?`
+	rows, err := r.db.Query(
+		playSummarySelect+" WHERE p.id IN ("+page+") ORDER BY p.date DESC, p.id DESC, d.id",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries, err := scanPlaySummaries(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return summaries, total, nil
+}
+
+// PlaySortKeys lists the columns the plays list can be sorted by.
+var PlaySortKeys = []string{"date", "scenario", "result", "heroes"}
+
+// PlaySort orders ListPage by one of PlaySortKeys, with plays that tie
+// ordered by id in the same direction. The zero PlaySort lists the newest
+// plays first.
+type PlaySort struct {
+	Key string
+	Asc bool
+}
+
+// playSortColumn is how ListPage sorts by one of PlaySortKeys: by expr, an
+// expression on plays p joined to scenarios s. value writes a play's value
+// of expr for a PlayCursor, and arg reads it back as a query argument.
+type playSortColumn struct {
+	expr  string
+	value func(PlaySummary) string
+	arg   func(string) (any, error)
+}
+
+var playSortColumns = map[string]playSortColumn{
+	"date": {
+		expr:  "p.date",
+		value: func(s PlaySummary) string { return s.Date.Format("2006-01-02") },
+		arg: func(v string) (any, error) {
+			return time.Parse("2006-01-02", v)
+		},
+	},
+	"scenario": {
+		expr:  "s.name COLLATE NOCASE",
+		value: func(s PlaySummary) string { return s.Scenario },
+		arg:   func(v string) (any, error) { return v, nil },
+	},
+	"result": {
+		expr:  "p.outcome",
+		value: func(s PlaySummary) string { return s.Outcome },
+		arg: func(v string) (any, error) {
+			if !slices.Contains(Outcomes, v) {
+				return nil, fmt.Errorf("unknown outcome %q", v)
+			}
+			return v, nil
+		},
+	},
+	"heroes": {
+		expr:  "(SELECT COUNT(*) FROM decks sd WHERE sd.play_id = p.id)",
+		value: func(s PlaySummary) string { return strconv.Itoa(len(s.Heroes)) },
+		arg: func(v string) (any, error) {
+			return strconv.Atoi(v)
+		},
+	},
+}
+
+func (s PlaySort) column() playSortColumn {
+	if column, ok := playSortColumns[s.Key]; ok {
+		return column
+	}
+	return playSortColumns["date"]
+}
+
+// orderBy returns the ORDER BY terms of s.
+func (s PlaySort) orderBy() string {
+	dir := " DESC"
+	if s.Asc {
+		dir = " ASC"
+	}
+	return s.column().expr + dir + ", p.id" + dir
+}
+
+// PlayCursor marks where a page of plays ends: the sort column's value for
+// its last play, and the play's id. It carries the values the list is
+// ordered by rather than just the id, so that the next page starts in the
+// right place even when that play has since been deleted.
+type PlayCursor struct {
+	Value string
+	ID    int
+}
+
+// IsZero reports whether c is the cursor of the start of the list.
+func (c PlayCursor) IsZero() bool {
+	return c == PlayCursor{}
+}
+
+// ValidCursor reports whether c can continue a list in the order s: whether
+// its value is one the sort column can hold.
+func (s PlaySort) ValidCursor(c PlayCursor) bool {
+	_, err := s.column().arg(c.Value)
+	return err == nil && c.ID > 0
+}
+
+// ListPage returns up to limit of the plays matching f in the order s,
+// starting after the cursor after, or from the first play when after is
+// zero. It also returns the cursor of the next page, which is zero on the
+// last page.
+//
+// Unlike ListSummaries' offsets, the pages do not shift when plays are
+// logged or deleted in between.
+func (r *PlayRepository) ListPage(f PlayFilter, s PlaySort, after PlayCursor, limit int) ([]PlaySummary, PlayCursor, error) {
+	column := s.column()
+	q := f.conditions()
+	visible, visibleArgs := visibleTo(r.viewer, "p")
+	q.add(visible, visibleArgs...)
+	if !after.IsZero() {
+		value, err := column.arg(after.Value)
+		if err != nil {
+			return nil, PlayCursor{}, fmt.Errorf("invalid cursor: %w", err)
+		}
+		cmp := "<"
+		if s.Asc {
+			cmp = ">"
+		}
+		q.add("("+column.expr+", p.id) "+cmp+" (?, ?)", value, after.ID)
+	}
+	where, args := q.where()
+
+	// Read one play more than asked for to learn whether there is a
+	// next page.
+	orderBy := s.orderBy()
+	page := `SELECT p.id FROM plays p JOIN scenarios s ON s.id = p.scenario_id WHERE ` + where + ` ORDER BY ` + orderBy + ` LIMIT ?`
+	rows, err := r.db.Query(
+		playSummarySelect+" WHERE p.id IN ("+page+") ORDER BY "+orderBy+", d.id",
+		append(args, limit+1)...,
+	)
+	if err != nil {
+		return nil, PlayCursor{}, err
+	}
+	defer rows.Close()
+
+	summaries, err := scanPlaySummaries(rows)
+	if err != nil {
+		return nil, PlayCursor{}, err
+	}
+	if len(summaries) <= limit {
+		return summaries, PlayCursor{}, nil
+	}
+	summaries = summaries[:limit]
+	last := summaries[limit-1]
+	return summaries, PlayCursor{Value: column.value(last), ID: last.ID}, nil
+}
+
+// GetSummary returns the summary of a single play, or ErrNotFound.
+func (r *PlayRepository) GetSummary(id int) (*PlaySummary, error) {
+	visible, args := visibleTo(r.viewer, "p")
+	rows, err := r.db.Query(playSummarySelect+" WHERE p.id = ? AND "+visible+" ORDER BY d.id", append([]any{id}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries, err := scanPlaySummaries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, ErrNotFound
+	}
+	return &summaries[0], nil
+}
+
+// scanPlaySummaries folds the one-row-per-deck result of playSummarySelect
+// into one PlaySummary per play. Rows for the same play must be adjacent.
+// Each deck's aspects arrive as a single comma-separated column, and the
+// play's modular sets as one column separated by the unit separator, since
+// a user-added set name may contain a comma.
+func scanPlaySummaries(rows *sql.Rows) ([]PlaySummary, error) {
+	var summaries []PlaySummary
+	for rows.Next() {
+		var s PlaySummary
+		var heroID, playerID sql.NullInt64
+		var remainingHP *int
+		var heroName, playerName, aspects, encounterSets sql.NullString
+		err := rows.Scan(&s.ID, &s.Date, &s.Outcome, &s.Difficulty, &s.Notes, &s.ScenarioID, &s.Scenario,
+			&s.SourceID, &s.EndReason, &s.Rounds, &s.VillainStage, &s.RemainingThreat, &s.OwnerID, &s.Owner, &s.GroupID, &s.Group,
+			&heroID, &heroName, &playerID, &playerName, &remainingHP, &aspects, &encounterSets)
+		if err != nil {
+			return nil, err
+		}
+		if encounterSets.String != "" {
+			s.EncounterSets = strings.Split(encounterSets.String, "\x1f")
+		}
+
+		if n := len(summaries); n == 0 || summaries[n-1].ID != s.ID {
+			summaries = append(summaries, s)
+		}
+		if heroID.Valid {
+			last := &summaries[len(summaries)-1]
+			last.Heroes = append(last.Heroes, HeroAspect{
+				HeroID:      int(heroID.Int64),
+				Hero:        heroName.String,
+				Aspects:     splitAspects(aspects.String),
+				PlayerID:    int(playerID.Int64),
+				PlayerName:  playerName.String,
+				RemainingHP: remainingHP,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+func splitAspects(joined string) []string {
+	if joined == "" {
+		return nil
+	}
+	return strings.Split(joined, ",")
+}
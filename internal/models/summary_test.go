@@ -0,0 +1,300 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayRepository_GetSummaries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	t.Run("Empty Database", func(t *testing.T) {
+		summaries, err := repo.GetSummaries()
+		assert.NoError(t, err)
+		assert.Empty(t, summaries)
+	})
+
+	t.Run("Joins Scenario and Heroes", func(t *testing.T) {
+		older := &Play{
+			Date:       time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			Outcome:    "loss",
+			Difficulty: "Expert I",
+			Notes:      "Crisis Protocol flipped early",
+		}
+		require.NoError(t, repo.CreateWithDecks(older, "Rhino", []DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}},
+			{HeroName: "Iron Man", Aspects: []string{"aggression"}},
+		}))
+
+		newer := &Play{
+			Date:       time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+		require.NoError(t, repo.CreateWithDecks(newer, "Ultron", nil))
+
+		summaries, err := repo.GetSummaries()
+		require.NoError(t, err)
+		require.Len(t, summaries, 2)
+
+		assert.Equal(t, newer.ID, summaries[0].ID)
+		assert.Equal(t, "Ultron", summaries[0].Scenario)
+		assert.Empty(t, summaries[0].Heroes)
+
+		assert.Equal(t, older.ID, summaries[1].ID)
+		assert.Equal(t, "Rhino", summaries[1].Scenario)
+		assert.Equal(t, "Crisis Protocol flipped early", summaries[1].Notes)
+		assert.Equal(t, []HeroAspect{
+			{HeroID: catalogID(t, db, "heroes", "Spider-Man"), Hero: "Spider-Man", Aspects: []string{"justice"}},
+			{HeroID: catalogID(t, db, "heroes", "Iron Man"), Hero: "Iron Man", Aspects: []string{"aggression"}},
+		}, summaries[1].Heroes)
+		assert.Equal(t, "Jan 10, 2024", summaries[1].FormattedDate())
+	})
+
+	t.Run("Multiple Aspects", func(t *testing.T) {
+		play := &Play{
+			Date:       time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+		}
+		require.NoError(t, repo.CreateWithDecks(play, "Rhino", []DeckEntry{
+			{HeroName: "Adam Warlock", Aspects: []string{"protection", "Leadership", "justice", "aggression", "justice"}},
+			{HeroName: "Cyclops", Aspects: []string{"pool"}},
+		}))
+
+		summary, err := repo.GetSummary(play.ID)
+		require.NoError(t, err)
+		require.Len(t, summary.Heroes, 2)
+		assert.Equal(t, []string{"leadership", "justice", "aggression", "protection"}, summary.Heroes[0].Aspects)
+		assert.Equal(t, "leadership / justice / aggression / protection", summary.Heroes[0].AspectLabel())
+		assert.Equal(t, []string{"pool"}, summary.Heroes[1].Aspects)
+	})
+}
+
+func TestPlayRepository_ListSummaries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	logPlay := func(day int, scenario, outcome, difficulty string, heroes ...string) int {
+		play := &Play{Date: time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC), Outcome: outcome, Difficulty: difficulty}
+		var decks []DeckEntry
+		for _, hero := range heroes {
+			decks = append(decks, DeckEntry{HeroName: hero, Aspects: []string{"justice"}})
+		}
+		require.NoError(t, repo.CreateWithDecks(play, scenario, decks))
+		return play.ID
+	}
+	first := logPlay(1, "Rhino", "win", "Standard I", "Spider-Man", "She-Hulk")
+	second := logPlay(2, "Klaw", "loss", "Expert I", "Spider-Man")
+	third := logPlay(3, "Rhino", "loss", "Standard I", "She-Hulk")
+
+	ids := func(summaries []PlaySummary) []int {
+		var ids []int
+		for _, s := range summaries {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	t.Run("Pages Newest First", func(t *testing.T) {
+		page, total, err := repo.ListSummaries(PlayFilter{}, 2, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []int{third, second}, ids(page))
+
+		page, total, err = repo.ListSummaries(PlayFilter{}, 2, 2)
+		require.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Equal(t, []int{first}, ids(page))
+		// Every deck of a play is returned even though the page is limited.
+		assert.Len(t, page[0].Heroes, 2)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		var heroID int
+		require.NoError(t, db.QueryRow("SELECT id FROM heroes WHERE name = 'Spider-Man'").Scan(&heroID))
+		var rhinoID int
+		require.NoError(t, db.QueryRow("SELECT id FROM scenarios WHERE name = 'Rhino'").Scan(&rhinoID))
+
+		for name, tc := range map[string]struct {
+			filter PlayFilter
+			want   []int
+		}{
+			"Scenario":   {PlayFilter{ScenarioID: rhinoID}, []int{third, first}},
+			"Hero":       {PlayFilter{HeroID: heroID}, []int{second, first}},
+			"Outcome":    {PlayFilter{Outcome: "loss"}, []int{third, second}},
+			"Difficulty": {PlayFilter{Difficulty: "Expert I"}, []int{second}},
+			"Players":    {PlayFilter{Players: 2}, []int{first}},
+			"Aspect":     {PlayFilter{Aspect: "Justice"}, []int{third, second, first}},
+			"Date Range": {PlayFilter{From: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)}, []int{second}},
+			"Combined":   {PlayFilter{HeroID: heroID, Outcome: "win"}, []int{first}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				page, total, err := repo.ListSummaries(tc.filter, 10, 0)
+				require.NoError(t, err)
+				assert.Equal(t, len(tc.want), total)
+				assert.Equal(t, tc.want, ids(page))
+			})
+		}
+	})
+}
+
+func TestPlayRepository_ListPage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	logPlay := func(day int, decks ...DeckEntry) int {
+		play := &Play{Date: time.Date(2024, 4, day, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I"}
+		require.NoError(t, repo.CreateWithDecks(play, "Rhino", decks))
+		return play.ID
+	}
+	spider := func(aspect string) DeckEntry { return DeckEntry{HeroName: "Spider-Man", Aspects: []string{aspect}} }
+	hulk := func(aspect string) DeckEntry { return DeckEntry{HeroName: "She-Hulk", Aspects: []string{aspect}} }
+
+	first := logPlay(1, spider("justice"), hulk("aggression"))
+	second := logPlay(2, spider("aggression"))
+	// Logged later on the same day as second, so ids break the tie.
+	third := logPlay(2, hulk("justice"))
+	fourth := logPlay(3, spider("protection"), hulk("justice"))
+
+	ids := func(summaries []PlaySummary) []int {
+		var ids []int
+		for _, s := range summaries {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	t.Run("Pages", func(t *testing.T) {
+		page, next, err := repo.ListPage(PlayFilter{}, PlaySort{}, PlayCursor{}, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int{fourth, third}, ids(page))
+		assert.Equal(t, PlayCursor{Value: "2024-04-02", ID: third}, next)
+
+		page, next, err = repo.ListPage(PlayFilter{}, PlaySort{}, next, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int{second, first}, ids(page))
+		assert.True(t, next.IsZero(), "the last page has no next page")
+		assert.Len(t, page[1].Heroes, 2)
+	})
+
+	t.Run("Pages Stay Put", func(t *testing.T) {
+		_, next, err := repo.ListPage(PlayFilter{}, PlaySort{}, PlayCursor{}, 1)
+		require.NoError(t, err)
+		logPlay(4, spider("justice"))
+
+		page, _, err := repo.ListPage(PlayFilter{}, PlaySort{}, next, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int{third}, ids(page), "a newer play does not shift the next page")
+	})
+
+	t.Run("Page After A Deleted Play", func(t *testing.T) {
+		_, next, err := repo.ListPage(PlayFilter{}, PlaySort{}, PlayCursor{}, 3)
+		require.NoError(t, err)
+		require.Equal(t, third, next.ID)
+		require.NoError(t, repo.Delete(third))
+
+		page, _, err := repo.ListPage(PlayFilter{}, PlaySort{}, next, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{second, first}, ids(page), "the next page starts where the deleted play was")
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		var spiderID int
+		require.NoError(t, db.QueryRow("SELECT id FROM heroes WHERE name = 'Spider-Man'").Scan(&spiderID))
+
+		page, next, err := repo.ListPage(PlayFilter{HeroID: spiderID, Aspect: "aggression"}, PlaySort{}, PlayCursor{}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int{second}, ids(page), "hero and aspect match the same deck")
+		assert.True(t, next.IsZero())
+
+		page, next, err = repo.ListPage(PlayFilter{Aspect: "justice", Players: 2}, PlaySort{}, PlayCursor{}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int{fourth}, ids(page))
+		page, next, err = repo.ListPage(PlayFilter{Aspect: "justice", Players: 2}, PlaySort{}, next, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int{first}, ids(page))
+		assert.True(t, next.IsZero())
+	})
+
+	t.Run("Only Visible Plays", func(t *testing.T) {
+		page, _, err := repo.For(Viewer{UserID: 99}).ListPage(PlayFilter{}, PlaySort{}, PlayCursor{}, 10)
+		require.NoError(t, err)
+		assert.Empty(t, page)
+	})
+}
+
+func TestPlayRepository_ListPageSorted(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	repo := NewPlayRepository(db)
+
+	logPlay := func(day int, scenario, outcome string, heroes ...string) int {
+		var decks []DeckEntry
+		for _, hero := range heroes {
+			decks = append(decks, DeckEntry{HeroName: hero, Aspects: []string{"justice"}})
+		}
+		play := &Play{Date: time.Date(2024, 5, day, 0, 0, 0, 0, time.UTC), Outcome: outcome, Difficulty: "Standard I"}
+		require.NoError(t, repo.CreateWithDecks(play, scenario, decks))
+		return play.ID
+	}
+	rhino := logPlay(1, "Rhino", "win", "Spider-Man")
+	klaw := logPlay(2, "Klaw", "loss", "Spider-Man", "She-Hulk", "Thor")
+	ultron := logPlay(3, "ultron", "win", "Thor", "Hulk")
+	klawAgain := logPlay(4, "Klaw", "win", "Hulk", "Thor")
+
+	// pages reads the whole list in order, a page of two plays at a time.
+	pages := func(order PlaySort) []int {
+		var ids []int
+		var after PlayCursor
+		for {
+			page, next, err := repo.ListPage(PlayFilter{}, order, after, 2)
+			require.NoError(t, err)
+			for _, s := range page {
+				ids = append(ids, s.ID)
+			}
+			if next.IsZero() {
+				return ids
+			}
+			after = next
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		order PlaySort
+		want  []int
+	}{
+		"Newest First":   {PlaySort{}, []int{klawAgain, ultron, klaw, rhino}},
+		"Oldest First":   {PlaySort{Key: "date", Asc: true}, []int{rhino, klaw, ultron, klawAgain}},
+		"Scenario":       {PlaySort{Key: "scenario", Asc: true}, []int{klaw, klawAgain, rhino, ultron}},
+		"Scenario Desc":  {PlaySort{Key: "scenario"}, []int{ultron, rhino, klawAgain, klaw}},
+		"Result":         {PlaySort{Key: "result", Asc: true}, []int{klaw, rhino, ultron, klawAgain}},
+		"Most Heroes":    {PlaySort{Key: "heroes"}, []int{klaw, klawAgain, ultron, rhino}},
+		"Fewest Heroes":  {PlaySort{Key: "heroes", Asc: true}, []int{rhino, ultron, klawAgain, klaw}},
+		"Unknown Column": {PlaySort{Key: "notes"}, []int{klawAgain, ultron, klaw, rhino}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, pages(tc.order))
+		})
+	}
+
+	t.Run("Cursor", func(t *testing.T) {
+		order := PlaySort{Key: "heroes"}
+		_, next, err := repo.ListPage(PlayFilter{}, order, PlayCursor{}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, PlayCursor{Value: "3", ID: klaw}, next)
+
+		assert.True(t, order.ValidCursor(next))
+		assert.False(t, order.ValidCursor(PlayCursor{Value: "many", ID: klaw}))
+		assert.False(t, PlaySort{Key: "result"}.ValidCursor(PlayCursor{Value: "draw", ID: klaw}))
+		_, _, err = repo.ListPage(PlayFilter{}, PlaySort{}, PlayCursor{Value: "May 1", ID: klaw}, 1)
+		assert.Error(t, err)
+	})
+}
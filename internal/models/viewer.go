@@ -0,0 +1,63 @@
+package models
+
+// Viewer is the user that plays are read and changed for. Repositories
+// returned by a For method show a viewer their own plays, those of users
+// who share their plays and those logged into the viewer's groups, and
+// only let them change their own plays and, as a group owner or editor,
+// the group's. A zero UserID is a visitor who has not logged in and sees
+// only shared plays.
+//
+// Repositories from the New constructors are not limited to a viewer and
+// see every play; the command line and tests use them.
+type Viewer struct {
+	UserID int
+}
+
+// VisiblePlays returns an SQL condition on the plays table under alias
+// that holds for the plays v may see, with its arguments.
+func (v Viewer) VisiblePlays(alias string) (string, []any) {
+	return "(" + alias + ".owner_id = ? OR " + alias + ".owner_id IN (SELECT id FROM users WHERE shares_plays = 1)" +
+			" OR " + alias + ".group_id IN (SELECT group_id FROM group_members WHERE user_id = ?))",
+		[]any{v.UserID, v.UserID}
+}
+
+// visibleTo is VisiblePlays for an optional viewer; a nil viewer sees
+// every play.
+func visibleTo(v *Viewer, alias string) (string, []any) {
+	if v == nil {
+		return "1", nil
+	}
+	return v.VisiblePlays(alias)
+}
+
+// editableBy returns an SQL condition on the plays table under alias that
+// holds for the plays v may change: their own, and those of groups they
+// own or edit. A nil viewer may change every play.
+func editableBy(v *Viewer, alias string) (string, []any) {
+	if v == nil {
+		return "1", nil
+	}
+	return "(" + alias + ".owner_id = ? OR " + alias + ".group_id IN (SELECT group_id FROM group_members WHERE user_id = ? AND role IN ('owner', 'editor')))",
+		[]any{v.UserID, v.UserID}
+}
+
+// sharedWith returns an SQL condition on the campaigns table under alias
+// that holds for the campaigns v may see: their own and those of users who
+// share their plays. Campaigns are not logged into groups.
+func sharedWith(v *Viewer, alias string) (string, []any) {
+	if v == nil {
+		return "1", nil
+	}
+	return "(" + alias + ".owner_id = ? OR " + alias + ".owner_id IN (SELECT id FROM users WHERE shares_plays = 1))",
+		[]any{v.UserID}
+}
+
+// ownedBy returns an SQL condition on the plays, campaigns or players
+// table under alias that holds for the rows v owns. A nil viewer owns
+// everything.
+func ownedBy(v *Viewer, alias string) (string, []any) {
+	if v == nil {
+		return "1", nil
+	}
+	return alias + ".owner_id = ?", []any{v.UserID}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewer(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, username, password_hash) VALUES (1, 'alice', ''), (2, 'bob', '');
+	`)
+	require.NoError(t, err)
+	crossbones := catalogID(t, db, "scenarios", "Crossbones")
+	spiderMan := catalogID(t, db, "heroes", "Spider-Man")
+
+	all := NewPlayRepository(db)
+	alice := all.For(Viewer{UserID: 1})
+	bob := all.For(Viewer{UserID: 2})
+	visitor := all.For(Viewer{})
+
+	logPlay := func(t *testing.T, repo *PlayRepository) *Play {
+		play := &Play{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Outcome: "win", Difficulty: "Standard I", ScenarioID: crossbones}
+		require.NoError(t, repo.CreateWithDecks(play, "", []DeckEntry{{HeroID: spiderMan, Aspects: []string{"justice"}}}))
+		return play
+	}
+	ids := func(t *testing.T, repo *PlayRepository) []int {
+		summaries, err := repo.GetSummaries()
+		require.NoError(t, err)
+		var ids []int
+		for _, s := range summaries {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	alicePlay := logPlay(t, alice)
+	bobPlay := logPlay(t, bob)
+
+	t.Run("Plays Belong To Their Logger", func(t *testing.T) {
+		assert.Equal(t, 1, alicePlay.OwnerID)
+		summary, err := all.GetSummary(alicePlay.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", summary.Owner)
+
+		assert.Equal(t, []int{alicePlay.ID}, ids(t, alice))
+		assert.Equal(t, []int{bobPlay.ID}, ids(t, bob))
+		assert.Empty(t, ids(t, visitor))
+		assert.Len(t, ids(t, all), 2, "an unscoped repository sees every play")
+
+		_, err = alice.GetSummary(bobPlay.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		decks, err := NewDeckRepository(db).For(Viewer{UserID: 1}).List(DeckFilter{})
+		require.NoError(t, err)
+		assert.Len(t, decks, 1)
+	})
+
+	t.Run("Shared Plays Are Visible But Not Editable", func(t *testing.T) {
+		_, err := db.Exec("UPDATE users SET shares_plays = 1 WHERE id = 2")
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []int{alicePlay.ID, bobPlay.ID}, ids(t, alice))
+		assert.Equal(t, []int{bobPlay.ID}, ids(t, visitor))
+
+		edited := *bobPlay
+		edited.Notes = "mine now"
+		assert.ErrorIs(t, alice.Update(&edited, ""), ErrNotFound)
+		assert.ErrorIs(t, alice.Delete(bobPlay.ID), ErrNotFound)
+		require.NoError(t, bob.Update(&edited, ""))
+
+		decks, err := NewDeckRepository(db).For(Viewer{UserID: 1}).List(DeckFilter{PlayID: bobPlay.ID})
+		require.NoError(t, err)
+		require.Len(t, decks, 1)
+		assert.ErrorIs(t, NewDeckRepository(db).For(Viewer{UserID: 1}).Delete(decks[0].ID), ErrNotFound)
+	})
+
+	t.Run("Campaigns", func(t *testing.T) {
+		campaigns := NewCampaignRepository(db)
+		campaign := &Campaign{Name: "Run", PackID: catalogID(t, db, "packs", "The Rise of Red Skull"), Mode: "standard", StartedOn: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		require.NoError(t, campaigns.For(Viewer{UserID: 1}).Create(campaign))
+
+		_, err := campaigns.For(Viewer{}).GetByID(campaign.ID)
+		assert.ErrorIs(t, err, ErrNotFound, "alice does not share her plays")
+
+		available, err := campaigns.For(Viewer{UserID: 1}).AvailablePlays(campaign.ID)
+		require.NoError(t, err)
+		require.Len(t, available, 1, "only alice's own plays can be logged")
+		assert.Equal(t, alicePlay.ID, available[0].ID)
+
+		err = campaigns.For(Viewer{UserID: 2}).AddEntry(&CampaignEntry{CampaignID: campaign.ID, PlayID: bobPlay.ID})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
@@ -0,0 +1,242 @@
+package playio
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"marvel_tracker/internal/models"
+)
+
+// The BoardGameGeek plays XML has no fields for the scenario or heroes, so
+// plays follow the convention most Marvel Champions players use on BGG:
+// each player's color is their hero, with aspects in parentheses, and the
+// comments start with "Scenario:" and "Difficulty:" lines followed by the
+// notes.
+const (
+	bggScenarioLabel   = "Scenario:"
+	bggDifficultyLabel = "Difficulty:"
+	bggSourcePrefix    = "bgg:"
+)
+
+type bggPlays struct {
+	XMLName    xml.Name  `xml:"plays"`
+	Username   string    `xml:"username,attr"`
+	UserID     string    `xml:"userid,attr"`
+	Total      int       `xml:"total,attr"`
+	Page       int       `xml:"page,attr"`
+	TermsOfUse string    `xml:"termsofuse,attr,omitempty"`
+	Plays      []bggPlay `xml:"play"`
+}
+
+type bggPlay struct {
+	ID         int         `xml:"id,attr"`
+	Date       string      `xml:"date,attr"`
+	Quantity   int         `xml:"quantity,attr"`
+	Length     int         `xml:"length,attr"`
+	Incomplete int         `xml:"incomplete,attr"`
+	NoWinStats int         `xml:"nowinstats,attr"`
+	Location   string      `xml:"location,attr"`
+	Item       bggItem     `xml:"item"`
+	Comments   string      `xml:"comments,omitempty"`
+	Players    []bggPlayer `xml:"players>player"`
+}
+
+type bggItem struct {
+	Name       string       `xml:"name,attr"`
+	ObjectType string       `xml:"objecttype,attr"`
+	ObjectID   int          `xml:"objectid,attr"`
+	Subtypes   []bggSubtype `xml:"subtypes>subtype"`
+}
+
+type bggSubtype struct {
+	Value string `xml:"value,attr"`
+}
+
+type bggPlayer struct {
+	Username      string `xml:"username,attr"`
+	UserID        string `xml:"userid,attr"`
+	Name          string `xml:"name,attr"`
+	StartPosition string `xml:"startposition,attr"`
+	Color         string `xml:"color,attr"`
+	Score         string `xml:"score,attr"`
+	New           string `xml:"new,attr"`
+	Rating        string `xml:"rating,attr"`
+	Win           string `xml:"win,attr"`
+}
+
+// WriteBGG writes plays, newest first, as a BGG plays XML document. Plays
+// that came from BGG keep their play id; the others have id 0, as plays
+// not yet logged on BGG.
+func WriteBGG(w io.Writer, plays []models.PlaySummary) error {
+	doc := bggPlays{Total: len(plays), Page: 1}
+	for _, p := range plays {
+		play := bggPlay{
+			Date:     p.Date.Format(dateLayout),
+			Quantity: 1,
+			Item: bggItem{
+				Name:       "Marvel Champions: The Card Game",
+				ObjectType: "thing",
+				ObjectID:   MarvelChampionsBGGID,
+				Subtypes:   []bggSubtype{{Value: "boardgame"}},
+			},
+			Comments: bggComments(p),
+		}
+		if id, ok := strings.CutPrefix(p.SourceID, bggSourcePrefix); ok {
+			play.ID, _ = strconv.Atoi(id)
+		}
+
+		win := "0"
+		if p.Outcome == "win" {
+			win = "1"
+		}
+		for i, h := range p.Heroes {
+			play.Players = append(play.Players, bggPlayer{
+				Name:          h.PlayerName,
+				StartPosition: strconv.Itoa(i + 1),
+				Color:         bggColor(h),
+				New:           "0",
+				Rating:        "0",
+				Win:           win,
+			})
+		}
+		doc.Plays = append(doc.Plays, play)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func bggComments(p models.PlaySummary) string {
+	comments := bggScenarioLabel + " " + p.Scenario + "\n" + bggDifficultyLabel + " " + p.Difficulty
+	if p.Notes != "" {
+		comments += "\n" + p.Notes
+	}
+	return comments
+}
+
+// bggColor writes a hero as "Adam Warlock (Leadership/Justice)".
+func bggColor(h models.HeroAspect) string {
+	aspects := make([]string, len(h.Aspects))
+	for i, a := range h.Aspects {
+		aspects[i] = strings.ToUpper(a[:1]) + a[1:]
+	}
+	return h.Hero + " (" + strings.Join(aspects, "/") + ")"
+}
+
+// ReadBGG reads the Marvel Champions plays from a BGG plays XML document,
+// such as a saved page of the BGG XML API, oldest first. Plays other apps
+// logged without the Scenario line use the first line of the comments as
+// the scenario, and the difficulty may also follow the scenario in
+// parentheses as in "Rhino (Expert I)". Each play element becomes one play
+// whatever its quantity. Row.Line counts the Marvel Champions plays.
+func ReadBGG(r io.Reader, opts Options) ([]Row, error) {
+	var doc bggPlays
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("not a BGG plays file: %w", err)
+	}
+
+	var plays []bggPlay
+	for _, p := range doc.Plays {
+		if isMarvelChampions(p.Item.ObjectID, p.Item.Name) {
+			plays = append(plays, p)
+		}
+	}
+	if len(plays) == 0 {
+		return nil, fmt.Errorf("the file has no Marvel Champions plays")
+	}
+	// BGG lists plays newest first; play ids grow as plays are logged.
+	sort.SliceStable(plays, func(i, j int) bool {
+		if plays[i].Date != plays[j].Date {
+			return plays[i].Date < plays[j].Date
+		}
+		return plays[i].ID < plays[j].ID
+	})
+
+	rows := make([]Row, len(plays))
+	for i, p := range plays {
+		rows[i] = Row{Line: i + 1}
+		rows[i].Play, rows[i].Error = parseBGGPlay(p, opts)
+	}
+	return rows, nil
+}
+
+func parseBGGPlay(p bggPlay, opts Options) (models.PlayImport, string) {
+	imp := models.PlayImport{Play: models.Play{Outcome: "loss"}}
+	if p.ID != 0 {
+		imp.Play.SourceID = bggSourcePrefix + strconv.Itoa(p.ID)
+	}
+
+	lines := strings.Split(strings.TrimSpace(p.Comments), "\n")
+	labelled := false
+	for _, line := range lines {
+		labelled = labelled || hasLabel(strings.TrimSpace(line), bggScenarioLabel)
+	}
+	var scenario, difficulty string
+	var notes []string
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case hasLabel(line, bggScenarioLabel):
+			scenario = strings.TrimSpace(line[len(bggScenarioLabel):])
+		case hasLabel(line, bggDifficultyLabel):
+			difficulty = strings.TrimSpace(line[len(bggDifficultyLabel):])
+		case i == 0 && !labelled:
+			scenario = line
+		default:
+			notes = append(notes, line)
+		}
+	}
+	imp.Scenario, imp.Play.Difficulty = scenarioAndDifficulty(scenario, opts)
+	if difficulty != "" {
+		// An unknown difficulty is kept so that saving the play reports it.
+		imp.Play.Difficulty = difficulty
+		for _, d := range models.Difficulties {
+			if strings.EqualFold(difficulty, d) {
+				imp.Play.Difficulty = d
+			}
+		}
+	}
+	imp.Play.Notes = strings.TrimSpace(strings.Join(notes, "\n"))
+
+	for _, player := range p.Players {
+		if player.Win == "1" {
+			imp.Play.Outcome = "win"
+		}
+		name := strings.TrimSpace(player.Name)
+		if name == "" {
+			name = strings.TrimSpace(player.Username)
+		}
+		imp.Decks = append(imp.Decks, deckFromRole(player.Color, name, opts))
+	}
+
+	date, err := time.Parse(dateLayout, p.Date)
+	if err != nil {
+		return imp, fmt.Sprintf("play date %q is not a date", p.Date)
+	}
+	imp.Play.Date = date
+
+	if imp.Scenario == "" {
+		return imp, "no scenario; start the comments with a line such as \"Scenario: Rhino\""
+	}
+	if len(imp.Decks) == 0 {
+		return imp, "the play has no players"
+	}
+	return imp, fillAspects(imp.Decks, opts, "color")
+}
+
+func hasLabel(line, label string) bool {
+	return len(line) >= len(label) && strings.EqualFold(line[:len(label)], label)
+}
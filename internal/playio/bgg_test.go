@@ -0,0 +1,178 @@
+package playio
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+func readBGGFixture(t *testing.T, opts Options) []Row {
+	f, err := os.Open("testdata/bgg_plays.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := ReadBGG(f, opts)
+	require.NoError(t, err)
+	return rows
+}
+
+func TestReadBGG(t *testing.T) {
+	rows := readBGGFixture(t, Options{Aspects: testAspects, Difficulty: "Standard I"})
+
+	// Only the Marvel Champions plays, oldest first.
+	require.Len(t, rows, 4)
+
+	assert.Equal(t, Row{Line: 1, Play: models.PlayImport{
+		Play: models.Play{
+			Date:       time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+			Notes:      "First game with the core set",
+			SourceID:   "bgg:80000001",
+		},
+		Scenario: "Rhino",
+		Decks: []models.DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: "Sam"},
+			{HeroName: "She-Hulk", Aspects: []string{"aggression"}, PlayerName: "Alex"},
+		},
+	}}, rows[0])
+
+	t.Run("Scenario and Difficulty from the Comments", func(t *testing.T) {
+		assert.Equal(t, "Klaw", rows[2].Play.Scenario)
+		assert.Equal(t, "Expert I", rows[2].Play.Play.Difficulty)
+		assert.Empty(t, rows[2].Play.Play.Notes)
+		assert.Equal(t, "Ultron", rows[3].Play.Scenario)
+		assert.Equal(t, "Heroic I", rows[3].Play.Play.Difficulty)
+		assert.Equal(t, "Lost to the drones.\nNext time more thwart.", rows[3].Play.Play.Notes)
+		assert.Equal(t, "loss", rows[3].Play.Play.Outcome)
+	})
+
+	t.Run("Players", func(t *testing.T) {
+		// Usernames stand in for missing names.
+		assert.Equal(t, []models.DeckEntry{
+			{HeroName: "She-Hulk", Aspects: []string{"aggression"}, PlayerName: "alexgeek"},
+		}, rows[2].Play.Decks)
+		assert.Equal(t, []models.DeckEntry{
+			{HeroName: "Adam Warlock", Aspects: []string{"leadership", "justice", "aggression", "protection"}, PlayerName: "Sam"},
+			{HeroName: "Spider-Man (Miles Morales)", Aspects: []string{"protection"}},
+		}, rows[3].Play.Decks)
+	})
+
+	t.Run("Missing Aspect", func(t *testing.T) {
+		assert.Equal(t, `Captain Marvel has no aspect; add it to the color, as in "Captain Marvel (Justice)", or choose a default aspect`, rows[1].Error)
+
+		rows := readBGGFixture(t, Options{Aspects: testAspects, Difficulty: "Standard I", Aspect: "basic"})
+		assert.Empty(t, rows[1].Error)
+		assert.Equal(t, []string{"basic"}, rows[1].Play.Decks[0].Aspects)
+	})
+
+	t.Run("Unusable Files", func(t *testing.T) {
+		_, err := ReadBGG(strings.NewReader(`{"plays": []}`), Options{})
+		assert.ErrorContains(t, err, "not a BGG plays file")
+
+		_, err = ReadBGG(strings.NewReader(`<plays><play id="1" date="2024-01-01"><item name="Wingspan" objectid="266192"/></play></plays>`), Options{})
+		assert.EqualError(t, err, "the file has no Marvel Champions plays")
+	})
+
+	t.Run("Missing Scenario or Hero", func(t *testing.T) {
+		rows, err := ReadBGG(strings.NewReader(`<plays>
+			<play id="1" date="2024-01-01"><item name="Marvel Champions: The Card Game" objectid="285774"/>
+				<players><player name="Sam" color="Thor (Aggression)"/></players></play>
+			<play id="2" date="2024-01-02"><item name="Marvel Champions: The Card Game" objectid="285774"/><comments>Rhino</comments>
+				<players><player name="Sam" color=""/></players></play>
+			<play id="3" date="2024-01-03"><item name="Marvel Champions: The Card Game" objectid="285774"/><comments>Rhino</comments></play>
+		</plays>`), Options{Aspects: testAspects, Difficulty: "Standard I"})
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, `no scenario; start the comments with a line such as "Scenario: Rhino"`, rows[0].Error)
+		assert.Equal(t, "a player has no hero; set it as the player's color", rows[1].Error)
+		assert.Equal(t, "the play has no players", rows[2].Error)
+	})
+}
+
+func TestWriteBGG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteBGG(&buf, []models.PlaySummary{
+		{
+			Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Scenario: "Klaw", Difficulty: "Expert I", Outcome: "loss",
+			Notes:    "Two lines\nof <notes> & more",
+			SourceID: "bgg:80000002",
+			Heroes:   []models.HeroAspect{{Hero: "Spider-Man (Miles Morales)", Aspects: []string{"protection"}, PlayerName: "Sam"}},
+		},
+		{
+			Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Scenario: "Rhino", Difficulty: "Standard I", Outcome: "win",
+			SourceID: "bgstats:c3c3c3c3-0000-4000-8000-000000000001",
+			Heroes: []models.HeroAspect{
+				{Hero: "Spider-Man", Aspects: []string{"justice"}},
+				{Hero: "Adam Warlock", Aspects: []string{"leadership", "justice"}, PlayerName: "Alex"},
+			},
+		},
+	}))
+
+	want, err := os.ReadFile("testdata/bgg_export.xml")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), buf.String())
+}
+
+func TestBGGRoundTrip(t *testing.T) {
+	source := setupTestDB(t)
+	plays := models.NewPlayRepository(source)
+	opts := Options{Aspects: testAspects, Difficulty: "Standard I", Aspect: "basic"}
+
+	rows := readBGGFixture(t, opts)
+	committed, err := Apply(plays, rows, true)
+	require.NoError(t, err)
+	require.True(t, committed, "rows: %+v", rows)
+	assert.Equal(t, Counts{Ready: 4}, Count(rows))
+
+	t.Run("Re-import Skips Known Plays", func(t *testing.T) {
+		rows := readBGGFixture(t, opts)
+		_, err := Apply(plays, rows, true)
+		require.NoError(t, err)
+		assert.Equal(t, Counts{Duplicates: 4}, Count(rows))
+	})
+
+	want, err := plays.GetSummaries()
+	require.NoError(t, err)
+	var exported bytes.Buffer
+	require.NoError(t, WriteBGG(&exported, want))
+
+	// The exported plays keep their BGG ids, so importing them into a
+	// database that already has them changes nothing.
+	rows, err = ReadBGG(bytes.NewReader(exported.Bytes()), opts)
+	require.NoError(t, err)
+	_, err = Apply(plays, rows, false)
+	require.NoError(t, err)
+	assert.Equal(t, Counts{Duplicates: 4}, Count(rows))
+
+	target := setupTestDB(t)
+	rows, err = ReadBGG(bytes.NewReader(exported.Bytes()), opts)
+	require.NoError(t, err)
+	committed, err = Apply(models.NewPlayRepository(target), rows, true)
+	require.NoError(t, err)
+	require.True(t, committed, "rows: %+v", rows)
+
+	got, err := models.NewPlayRepository(target).GetSummaries()
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Date.Equal(got[i].Date))
+		assert.Equal(t, want[i].Scenario, got[i].Scenario)
+		assert.Equal(t, want[i].Difficulty, got[i].Difficulty)
+		assert.Equal(t, want[i].Outcome, got[i].Outcome)
+		assert.Equal(t, want[i].Notes, got[i].Notes)
+		assert.Equal(t, want[i].SourceID, got[i].SourceID)
+		require.Len(t, got[i].Heroes, len(want[i].Heroes))
+		for j := range want[i].Heroes {
+			assert.Equal(t, want[i].Heroes[j].Hero, got[i].Heroes[j].Hero)
+			assert.Equal(t, want[i].Heroes[j].Aspects, got[i].Heroes[j].Aspects)
+			assert.Equal(t, want[i].Heroes[j].PlayerName, got[i].Heroes[j].PlayerName)
+		}
+	}
+}
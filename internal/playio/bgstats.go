@@ -0,0 +1,118 @@
+package playio
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"marvel_tracker/internal/models"
+)
+
+// bgStatsBackup is the part of a BG Stats JSON backup the importer reads.
+type bgStatsBackup struct {
+	Games []struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		BGGID int    `json:"bggId"`
+	} `json:"games"`
+	Players []struct {
+		ID          int    `json:"id"`
+		Name        string `json:"name"`
+		IsAnonymous bool   `json:"isAnonymous"`
+	} `json:"players"`
+	Plays []bgStatsPlay `json:"plays"`
+}
+
+type bgStatsPlay struct {
+	UUID         string `json:"uuid"`
+	PlayDate     string `json:"playDate"`
+	GameRefID    int    `json:"gameRefId"`
+	Board        string `json:"board"`
+	Comments     string `json:"comments"`
+	PlayerScores []struct {
+		PlayerRefID int    `json:"playerRefId"`
+		Winner      bool   `json:"winner"`
+		Role        string `json:"role"`
+	} `json:"playerScores"`
+}
+
+// ReadBGStats reads the Marvel Champions plays from a BG Stats JSON backup,
+// oldest first. The scenario is taken from the board, optionally followed
+// by the difficulty in parentheses ("Rhino (Expert I)"), and each player's
+// hero from their role, optionally followed by aspects ("Adam Warlock
+// (Leadership/Justice)"). A play is won if any player is marked as a
+// winner. Row.Line counts the Marvel Champions plays in the backup.
+func ReadBGStats(r io.Reader, opts Options) ([]Row, error) {
+	var backup bgStatsBackup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("not a BG Stats backup: %w", err)
+	}
+
+	games := make(map[int]bool)
+	for _, g := range backup.Games {
+		if isMarvelChampions(g.BGGID, g.Name) {
+			games[g.ID] = true
+		}
+	}
+	if len(games) == 0 {
+		return nil, fmt.Errorf("the backup has no Marvel Champions plays")
+	}
+	players := make(map[int]string)
+	for _, p := range backup.Players {
+		if !p.IsAnonymous {
+			players[p.ID] = strings.TrimSpace(p.Name)
+		}
+	}
+
+	var plays []bgStatsPlay
+	for _, p := range backup.Plays {
+		if games[p.GameRefID] {
+			plays = append(plays, p)
+		}
+	}
+	sort.SliceStable(plays, func(i, j int) bool { return plays[i].PlayDate < plays[j].PlayDate })
+
+	rows := make([]Row, len(plays))
+	for i, p := range plays {
+		rows[i] = Row{Line: i + 1}
+		rows[i].Play, rows[i].Error = parseBGStatsPlay(p, players, opts)
+	}
+	return rows, nil
+}
+
+func parseBGStatsPlay(p bgStatsPlay, players map[int]string, opts Options) (models.PlayImport, string) {
+	imp := models.PlayImport{
+		Play: models.Play{
+			Outcome: "loss",
+			Notes:   strings.TrimSpace(p.Comments),
+		},
+	}
+	if p.UUID != "" {
+		imp.Play.SourceID = "bgstats:" + p.UUID
+	}
+
+	imp.Scenario, imp.Play.Difficulty = scenarioAndDifficulty(p.Board, opts)
+	for _, score := range p.PlayerScores {
+		if score.Winner {
+			imp.Play.Outcome = "win"
+		}
+		imp.Decks = append(imp.Decks, deckFromRole(score.Role, players[score.PlayerRefID], opts))
+	}
+
+	date, err := time.Parse(dateLayout, strings.TrimSpace(first(p.PlayDate, 10)))
+	if err != nil {
+		return imp, fmt.Sprintf("play date %q is not a date", p.PlayDate)
+	}
+	imp.Play.Date = date
+
+	if imp.Scenario == "" {
+		return imp, "no scenario; set the board of this play in BG Stats"
+	}
+	if len(imp.Decks) == 0 {
+		return imp, "the play has no players"
+	}
+	return imp, fillAspects(imp.Decks, opts, "role")
+}
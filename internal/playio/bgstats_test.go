@@ -0,0 +1,136 @@
+package playio
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/models"
+)
+
+var testAspects = []string{"leadership", "justice", "aggression", "protection", "pool", "basic"}
+
+func readBGStatsFixture(t *testing.T, opts Options) []Row {
+	f, err := os.Open("testdata/bgstats.json")
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := ReadBGStats(f, opts)
+	require.NoError(t, err)
+	return rows
+}
+
+func TestReadBGStats(t *testing.T) {
+	rows := readBGStatsFixture(t, Options{Aspects: testAspects, Difficulty: "Standard I"})
+
+	// Only the Marvel Champions plays, oldest first.
+	require.Len(t, rows, 4)
+
+	assert.Equal(t, Row{Line: 1, Play: models.PlayImport{
+		Play: models.Play{
+			Date:       time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+			Outcome:    "win",
+			Difficulty: "Standard I",
+			Notes:      "First game with the core set",
+			SourceID:   "bgstats:c3c3c3c3-0000-4000-8000-000000000001",
+		},
+		Scenario: "Rhino",
+		Decks: []models.DeckEntry{
+			{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: "Sam"},
+			{HeroName: "She-Hulk", Aspects: []string{"aggression"}, PlayerName: "Alex"},
+		},
+	}}, rows[0])
+
+	t.Run("Difficulty from the Board", func(t *testing.T) {
+		assert.Equal(t, "Klaw", rows[1].Play.Scenario)
+		assert.Equal(t, "Expert I", rows[1].Play.Play.Difficulty)
+		assert.Equal(t, "loss", rows[1].Play.Play.Outcome)
+		assert.Equal(t, "Ultron", rows[2].Play.Scenario)
+		assert.Equal(t, "Heroic I", rows[2].Play.Play.Difficulty)
+	})
+
+	t.Run("Hero Names with Parentheses", func(t *testing.T) {
+		assert.Equal(t, []models.DeckEntry{
+			{HeroName: "Spider-Man (Miles Morales)", Aspects: []string{"protection"}, PlayerName: "Sam"},
+		}, rows[1].Play.Decks)
+	})
+
+	t.Run("Anonymous Players and Multiple Aspects", func(t *testing.T) {
+		assert.Equal(t, []models.DeckEntry{
+			{HeroName: "Adam Warlock", Aspects: []string{"leadership", "justice", "aggression", "protection"}},
+		}, rows[2].Play.Decks)
+	})
+
+	t.Run("Missing Aspect", func(t *testing.T) {
+		assert.Equal(t, `Captain Marvel has no aspect; add it to the role, as in "Captain Marvel (Justice)", or choose a default aspect`, rows[3].Error)
+
+		rows := readBGStatsFixture(t, Options{Aspects: testAspects, Difficulty: "Standard I", Aspect: "basic"})
+		assert.Empty(t, rows[3].Error)
+		assert.Equal(t, []string{"basic"}, rows[3].Play.Decks[0].Aspects)
+	})
+
+	t.Run("Unusable Files", func(t *testing.T) {
+		_, err := ReadBGStats(strings.NewReader("date,scenario\n"), Options{})
+		assert.ErrorContains(t, err, "not a BG Stats backup")
+
+		_, err = ReadBGStats(strings.NewReader(`{"games": [{"id": 1, "name": "Wingspan", "bggId": 266192}], "plays": []}`), Options{})
+		assert.EqualError(t, err, "the backup has no Marvel Champions plays")
+	})
+
+	t.Run("Missing Scenario or Hero", func(t *testing.T) {
+		rows, err := ReadBGStats(strings.NewReader(`{
+			"games": [{"id": 1, "name": "Marvel Champions"}],
+			"plays": [
+				{"uuid": "a", "playDate": "2024-01-01 10:00:00", "gameRefId": 1, "board": "", "playerScores": [{"playerRefId": 1, "role": "Thor (Aggression)"}]},
+				{"uuid": "b", "playDate": "2024-01-02 10:00:00", "gameRefId": 1, "board": "Rhino", "playerScores": [{"playerRefId": 1, "role": ""}]},
+				{"uuid": "c", "playDate": "someday", "gameRefId": 1, "board": "Rhino", "playerScores": [{"playerRefId": 1, "role": "Thor (Aggression)"}]}
+			]}`), Options{Aspects: testAspects, Difficulty: "Standard I"})
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, "no scenario; set the board of this play in BG Stats", rows[0].Error)
+		assert.Equal(t, "a player has no hero; set it as the player's role", rows[1].Error)
+		assert.Equal(t, `play date "someday" is not a date`, rows[2].Error)
+	})
+}
+
+func TestBGStatsImport(t *testing.T) {
+	db := setupTestDB(t)
+	repo := models.NewPlayRepository(db)
+	opts := Options{Aspects: testAspects, Difficulty: "Standard I", Aspect: "basic"}
+
+	rows := readBGStatsFixture(t, opts)
+	committed, err := Apply(repo, rows, true)
+	require.NoError(t, err)
+	require.True(t, committed, "rows: %+v", rows)
+	assert.Equal(t, Counts{Ready: 4}, Count(rows))
+
+	summaries, err := repo.GetSummaries()
+	require.NoError(t, err)
+	require.Len(t, summaries, 4)
+	assert.Equal(t, "Rhino", summaries[0].Scenario)
+	assert.Equal(t, "Captain Marvel", summaries[0].Heroes[0].Hero)
+	assert.Equal(t, "Klaw", summaries[2].Scenario)
+	assert.Equal(t, []models.HeroAspect{
+		{HeroID: summaries[2].Heroes[0].HeroID, Hero: "Spider-Man (Miles Morales)", Aspects: []string{"protection"}, PlayerID: summaries[2].Heroes[0].PlayerID, PlayerName: "Sam"},
+	}, summaries[2].Heroes)
+	assert.NotZero(t, summaries[2].Heroes[0].PlayerID, "importing a player name adds the player")
+
+	var sourceID string
+	require.NoError(t, db.QueryRow("SELECT source_id FROM plays WHERE id = ?", summaries[3].ID).Scan(&sourceID))
+	assert.Equal(t, "bgstats:c3c3c3c3-0000-4000-8000-000000000001", sourceID)
+
+	t.Run("Re-import Skips Known Plays", func(t *testing.T) {
+		rows := readBGStatsFixture(t, opts)
+		committed, err := Apply(repo, rows, true)
+		require.NoError(t, err)
+		assert.True(t, committed)
+		assert.Equal(t, Counts{Duplicates: 4}, Count(rows))
+
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM plays").Scan(&count))
+		assert.Equal(t, 4, count)
+	})
+}
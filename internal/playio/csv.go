@@ -0,0 +1,261 @@
+package playio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"marvel_tracker/internal/models"
+)
+
+// CSVHeader lists the columns written by WriteCSV. ReadCSV matches columns
+// by name, ignoring case and order; every column but date, scenario,
+// difficulty, outcome and heroes is optional.
+//
+// The modulars column lists the modular sets used, separated by
+// semicolons, as in "Bomb Scare; Masters of Evil". The heroes column holds
+// "hero:aspect" pairs separated by semicolons, with multiple aspects
+// separated by slashes, as in
+// "Spider-Man:justice; Adam Warlock:leadership/justice". The players and
+// remaining_hp columns, when present, list the player and remaining hit
+// points of each hero in the same order. end_reason holds one of the
+// models.EndReasons names, such as villain_defeated.
+var CSVHeader = []string{
+	"date", "scenario", "modulars", "difficulty", "outcome", "notes", "heroes", "players", "remaining_hp",
+	"end_reason", "rounds", "villain_stage", "remaining_threat",
+}
+
+const dateLayout = "2006-01-02"
+
+var requiredCSVColumns = []string{"date", "scenario", "difficulty", "outcome", "heroes"}
+
+// WriteCSV writes plays in the format ReadCSV reads. plays are expected
+// newest first, as returned by GetSummaries, and are written oldest first
+// so that importing the file keeps plays from the same day in order.
+func WriteCSV(w io.Writer, plays []models.PlaySummary) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for i := len(plays) - 1; i >= 0; i-- {
+		p := plays[i]
+		heroes := make([]string, len(p.Heroes))
+		players := make([]string, len(p.Heroes))
+		remainingHP := make([]string, len(p.Heroes))
+		for j, h := range p.Heroes {
+			heroes[j] = h.Hero + ":" + strings.Join(h.Aspects, "/")
+			players[j] = h.PlayerName
+			remainingHP[j] = formatOptionalInt(h.RemainingHP)
+		}
+
+		err := out.Write([]string{
+			p.Date.Format(dateLayout),
+			p.Scenario,
+			strings.Join(p.EncounterSets, "; "),
+			p.Difficulty,
+			p.Outcome,
+			p.Notes,
+			strings.Join(heroes, "; "),
+			joinAligned(players),
+			joinAligned(remainingHP),
+			p.EndReason,
+			formatOptionalInt(p.Rounds),
+			formatOptionalInt(p.VillainStage),
+			formatOptionalInt(p.RemainingThreat),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// ReadCSV reads plays written by WriteCSV or by hand. Records that cannot
+// be parsed are returned with Error set; err is only returned when the
+// file as a whole is unusable, such as a missing header.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	in := csv.NewReader(r)
+	in.FieldsPerRecord = -1
+	in.TrimLeadingSpace = true
+
+	header, err := in.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	// Spreadsheet programs often start the file with a byte order mark.
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", name)
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := in.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, Row{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := in.FieldPos(0)
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := Row{Line: line}
+		row.Play, row.Error = parseCSVRecord(field)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseCSVRecord(field func(string) string) (models.PlayImport, string) {
+	imp := models.PlayImport{
+		Play: models.Play{
+			Outcome:    strings.ToLower(field("outcome")),
+			Difficulty: field("difficulty"),
+			Notes:      field("notes"),
+			EndReason:  strings.ToLower(field("end_reason")),
+		},
+		Scenario:      field("scenario"),
+		EncounterSets: splitList(field("modulars")),
+	}
+
+	date, err := time.Parse(dateLayout, field("date"))
+	if err != nil {
+		return imp, fmt.Sprintf("date %q must be in YYYY-MM-DD format", field("date"))
+	}
+	imp.Play.Date = date
+
+	for _, n := range []struct {
+		column, label string
+		value         **int
+	}{
+		{"rounds", "rounds", &imp.Play.Rounds},
+		{"villain_stage", "villain stage", &imp.Play.VillainStage},
+		{"remaining_threat", "remaining threat", &imp.Play.RemainingThreat},
+	} {
+		if *n.value, err = parseOptionalInt(field(n.column)); err != nil {
+			return imp, fmt.Sprintf("%s %q must be a whole number", n.label, field(n.column))
+		}
+	}
+
+	var players, remainingHP []string
+	if raw := field("players"); raw != "" {
+		players = strings.Split(raw, ";")
+	}
+	if raw := field("remaining_hp"); raw != "" {
+		remainingHP = strings.Split(raw, ";")
+	}
+	for i, pair := range splitList(field("heroes")) {
+		// Hero names may contain slashes (SP//dr) but not colons, so the
+		// aspects start after the last colon.
+		hero, aspects, ok := cutLast(pair, ":")
+		if !ok || strings.TrimSpace(aspects) == "" {
+			return imp, fmt.Sprintf("hero %q has no aspect; write it as hero:aspect", strings.TrimSpace(pair))
+		}
+		deck := models.DeckEntry{HeroName: strings.TrimSpace(hero)}
+		for _, aspect := range strings.Split(aspects, "/") {
+			if aspect = strings.TrimSpace(aspect); aspect != "" {
+				deck.Aspects = append(deck.Aspects, strings.ToLower(aspect))
+			}
+		}
+		if i < len(players) {
+			deck.PlayerName = strings.TrimSpace(players[i])
+		}
+		if i < len(remainingHP) {
+			if deck.RemainingHP, err = parseOptionalInt(remainingHP[i]); err != nil {
+				return imp, fmt.Sprintf("remaining hit points %q must be a whole number", strings.TrimSpace(remainingHP[i]))
+			}
+		}
+		imp.Decks = append(imp.Decks, deck)
+	}
+	if len(imp.Decks) == 0 {
+		return imp, "add at least one hero"
+	}
+	if len(players) > len(imp.Decks) {
+		return imp, "there are more players than heroes"
+	}
+	if len(remainingHP) > len(imp.Decks) {
+		return imp, "there are more remaining hit points than heroes"
+	}
+	return imp, ""
+}
+
+// joinAligned joins values that line up with the heroes column, dropping
+// the empty ones at the end so that a column nobody filled in stays empty.
+func joinAligned(values []string) string {
+	for len(values) > 0 && values[len(values)-1] == "" {
+		values = values[:len(values)-1]
+	}
+	return strings.Join(values, "; ")
+}
+
+// formatOptionalInt writes an optional number, nil as "".
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// parseOptionalInt reads a number written by formatOptionalInt.
+func parseOptionalInt(s string) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// splitList splits a semicolon-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,249 @@
+package playio
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/migrations"
+)
+
+// setupTestDB migrates an in-memory database with the shipped migrations,
+// so the official catalog is available.
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+	return db
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Run("Parses Heroes, Aspects, Players and Modulars", func(t *testing.T) {
+		rows, err := ReadCSV(strings.NewReader(
+			"\ufeffDate,Scenario,Difficulty,Outcome,Heroes,Players,Notes,Modulars\n" +
+				"2024-03-01,Rhino,Standard I,WIN,\"Spider-Man:justice; Adam Warlock:Leadership/justice\",\"Sam; Alex\",\"Close, but \"\"fun\"\"\",Bomb Scare;\n" +
+				"\n" +
+				"2024-03-02,Collector: Infiltrate the Museum,Expert I,loss,SP//dr:protection,,\n"))
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Empty(t, rows[0].Error)
+		assert.Equal(t, models.PlayImport{
+			Play: models.Play{
+				Date:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				Outcome:    "win",
+				Difficulty: "Standard I",
+				Notes:      `Close, but "fun"`,
+			},
+			Scenario:      "Rhino",
+			EncounterSets: []string{"Bomb Scare"},
+			Decks: []models.DeckEntry{
+				{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: "Sam"},
+				{HeroName: "Adam Warlock", Aspects: []string{"leadership", "justice"}, PlayerName: "Alex"},
+			},
+		}, rows[0].Play)
+
+		assert.Equal(t, 4, rows[1].Line)
+		assert.Equal(t, "Collector: Infiltrate the Museum", rows[1].Play.Scenario)
+		assert.Equal(t, []models.DeckEntry{{HeroName: "SP//dr", Aspects: []string{"protection"}}}, rows[1].Play.Decks)
+	})
+
+	t.Run("Row Errors", func(t *testing.T) {
+		rows, err := ReadCSV(strings.NewReader(
+			"date,scenario,difficulty,outcome,heroes\n" +
+				"03/01/2024,Rhino,Standard I,win,Spider-Man:justice\n" +
+				"2024-03-01,Rhino,Standard I,win,Spider-Man\n" +
+				"2024-03-01,Rhino,Standard I,win,\n" +
+				"2024-03-01,Rhino,Standard I,win,Spider-Man:justice\n"))
+		require.NoError(t, err)
+		require.Len(t, rows, 4)
+		assert.Equal(t, `date "03/01/2024" must be in YYYY-MM-DD format`, rows[0].Error)
+		assert.Equal(t, `hero "Spider-Man" has no aspect; write it as hero:aspect`, rows[1].Error)
+		assert.Equal(t, "add at least one hero", rows[2].Error)
+		assert.Empty(t, rows[3].Error)
+
+		rows, err = ReadCSV(strings.NewReader(
+			"date,scenario,difficulty,outcome,heroes,remaining_hp,rounds\n" +
+				"2024-03-01,Rhino,Standard I,win,Spider-Man:justice,,seven\n" +
+				"2024-03-01,Rhino,Standard I,win,Spider-Man:justice,lots,\n" +
+				"2024-03-01,Rhino,Standard I,win,Spider-Man:justice,3; 4,\n"))
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, `rounds "seven" must be a whole number`, rows[0].Error)
+		assert.Equal(t, `remaining hit points "lots" must be a whole number`, rows[1].Error)
+		assert.Equal(t, "there are more remaining hit points than heroes", rows[2].Error)
+	})
+
+	t.Run("Unusable Files", func(t *testing.T) {
+		_, err := ReadCSV(strings.NewReader(""))
+		assert.EqualError(t, err, "the file is empty")
+
+		_, err = ReadCSV(strings.NewReader("date,scenario,difficulty,outcome\n"))
+		assert.EqualError(t, err, "the header has no heroes column")
+	})
+}
+
+func TestWriteCSV(t *testing.T) {
+	n := func(n int) *int { return &n }
+	var buf bytes.Buffer
+	require.NoError(t, WriteCSV(&buf, []models.PlaySummary{
+		{
+			Date: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), Scenario: "Klaw", Difficulty: "Expert I", Outcome: "loss",
+			Notes:  "Two lines\nof notes",
+			Heroes: []models.HeroAspect{{Hero: "She-Hulk", Aspects: []string{"aggression"}}},
+		},
+		{
+			Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Scenario: "Rhino", Difficulty: "Standard I", Outcome: "win",
+			EndReason: "villain_defeated", Rounds: n(7), VillainStage: n(2), RemainingThreat: n(0),
+			EncounterSets: []string{"Bomb Scare", "Masters of Evil"},
+			Heroes: []models.HeroAspect{
+				{Hero: "Spider-Man", Aspects: []string{"justice"}, RemainingHP: n(3)},
+				{Hero: "Adam Warlock", Aspects: []string{"leadership", "justice"}, PlayerName: "Alex"},
+			},
+		},
+	}))
+
+	assert.Equal(t,
+		"date,scenario,modulars,difficulty,outcome,notes,heroes,players,remaining_hp,end_reason,rounds,villain_stage,remaining_threat\n"+
+			"2024-03-01,Rhino,Bomb Scare; Masters of Evil,Standard I,win,,Spider-Man:justice; Adam Warlock:leadership/justice,; Alex,3,villain_defeated,7,2,0\n"+
+			"2024-03-02,Klaw,,Expert I,loss,\"Two lines\nof notes\",She-Hulk:aggression,,,,,,\n",
+		buf.String())
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	source := setupTestDB(t)
+	plays := models.NewPlayRepository(source)
+
+	n := func(n int) *int { return &n }
+	logPlay := func(play models.Play, date, scenario string, decks ...models.DeckEntry) {
+		var err error
+		play.Date, err = time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		require.NoError(t, plays.CreateWithDecks(&play, scenario, decks))
+	}
+	// Modular sets are saved by id, so look them up, adding one that is
+	// not in the catalog the target database starts with.
+	_, err := source.Exec("INSERT INTO encounter_sets (name) VALUES ('Homebrew Goons')")
+	require.NoError(t, err)
+	setIDs := func(names ...string) []int {
+		ids := make([]int, len(names))
+		for i, name := range names {
+			require.NoError(t, source.QueryRow("SELECT id FROM encounter_sets WHERE name = ?", name).Scan(&ids[i]))
+		}
+		return ids
+	}
+	logPlay(models.Play{Outcome: "win", Difficulty: "Standard I", Notes: "First game, \"easy\"",
+		EndReason: "villain_defeated", Rounds: n(8), VillainStage: n(2), RemainingThreat: n(0),
+		EncounterSetIDs: setIDs("Bomb Scare", "Masters of Evil")},
+		"2024-03-01", "Rhino",
+		models.DeckEntry{HeroName: "Spider-Man", Aspects: []string{"justice"}, PlayerName: "Sam", RemainingHP: n(0)},
+		models.DeckEntry{HeroName: "Adam Warlock", Aspects: []string{"leadership", "justice", "aggression", "protection"}, RemainingHP: n(5)})
+	logPlay(models.Play{Outcome: "loss", Difficulty: "Expert I", EndReason: "scheme_completed", Rounds: n(6)},
+		"2024-03-01", "Collector: Escape the Museum",
+		models.DeckEntry{HeroName: "SP//dr", Aspects: []string{"pool"}})
+	logPlay(models.Play{Outcome: "loss", Difficulty: "Heroic I", Notes: "Line one\nline two",
+		EncounterSetIDs: setIDs("Homebrew Goons")},
+		"2024-04-10", "Homebrew Villain",
+		models.DeckEntry{HeroName: "Homebrew Hero", Aspects: []string{"basic"}, PlayerName: "Alex"},
+		models.DeckEntry{HeroName: "She-Hulk", Aspects: []string{"aggression"}, RemainingHP: n(11)})
+
+	want, err := plays.GetSummaries()
+	require.NoError(t, err)
+
+	var exported bytes.Buffer
+	require.NoError(t, WriteCSV(&exported, want))
+
+	target := setupTestDB(t)
+	rows, err := ReadCSV(bytes.NewReader(exported.Bytes()))
+	require.NoError(t, err)
+	committed, err := Apply(models.NewPlayRepository(target), rows, true)
+	require.NoError(t, err)
+	require.True(t, committed, "rows: %+v", rows)
+
+	got, err := models.NewPlayRepository(target).GetSummaries()
+	require.NoError(t, err)
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.Equal(t, want[i].ID, got[i].ID)
+		assert.True(t, want[i].Date.Equal(got[i].Date))
+		assert.Equal(t, want[i].Scenario, got[i].Scenario)
+		assert.Equal(t, want[i].Difficulty, got[i].Difficulty)
+		assert.Equal(t, want[i].Outcome, got[i].Outcome)
+		assert.Equal(t, want[i].Notes, got[i].Notes)
+		assert.Equal(t, want[i].EndReason, got[i].EndReason)
+		assert.Equal(t, want[i].Rounds, got[i].Rounds)
+		assert.Equal(t, want[i].VillainStage, got[i].VillainStage)
+		assert.Equal(t, want[i].RemainingThreat, got[i].RemainingThreat)
+		assert.Equal(t, want[i].EncounterSets, got[i].EncounterSets)
+		require.Len(t, got[i].Heroes, len(want[i].Heroes))
+		// Ids of custom heroes may differ between the databases, so they
+		// are compared by name.
+		for j := range want[i].Heroes {
+			assert.Equal(t, want[i].Heroes[j].Hero, got[i].Heroes[j].Hero)
+			assert.Equal(t, want[i].Heroes[j].Aspects, got[i].Heroes[j].Aspects)
+			assert.Equal(t, want[i].Heroes[j].PlayerName, got[i].Heroes[j].PlayerName)
+			assert.Equal(t, want[i].Heroes[j].RemainingHP, got[i].Heroes[j].RemainingHP)
+		}
+	}
+
+	var again bytes.Buffer
+	require.NoError(t, WriteCSV(&again, got))
+	assert.Equal(t, exported.String(), again.String())
+}
+
+func TestApply(t *testing.T) {
+	db := setupTestDB(t)
+	repo := models.NewPlayRepository(db)
+
+	rows, err := ReadCSV(strings.NewReader(
+		"date,scenario,difficulty,outcome,heroes\n" +
+			"2024-03-01,Rhino,Standard I,win,Spider-Man:justice\n" +
+			"2024-03-02,Homebrew Villain,Standard I,win,New Hero:justice\n" +
+			"2024-03-03,Rhino,Legendary,win,Spider-Man:justice\n" +
+			"2024-03-04,Rhino,Standard I,win,Spider-Man:chaos\n" +
+			"2024-03-05,Rhino,Standard I,win,Spider-Man:justice; spider-man:justice\n" +
+			"bad date,Rhino,Standard I,win,Spider-Man:justice\n"))
+	require.NoError(t, err)
+
+	committed, err := Apply(repo, rows, true)
+	require.NoError(t, err)
+	assert.False(t, committed)
+	assert.Equal(t, Counts{Ready: 2, Failed: 4}, Count(rows))
+
+	assert.Empty(t, rows[0].Error)
+	assert.Empty(t, rows[0].Added)
+	assert.Empty(t, rows[1].Error)
+	assert.Equal(t, []string{"Homebrew Villain", "New Hero"}, rows[1].Added)
+	assert.Equal(t, "unknown difficulty", rows[2].Error)
+	assert.Equal(t, `unknown aspect "chaos"`, rows[3].Error)
+	assert.Equal(t, "each hero can only appear once in a play", rows[4].Error)
+	assert.Contains(t, rows[5].Error, "YYYY-MM-DD")
+
+	// Nothing was saved, not even the valid rows or new catalog entries.
+	summaries, err := repo.GetSummaries()
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM heroes WHERE name = 'New Hero'").Scan(&count))
+	assert.Zero(t, count)
+
+	// With only the valid rows the import goes through.
+	valid := []Row{rows[0], rows[1]}
+	committed, err = Apply(repo, valid, true)
+	require.NoError(t, err)
+	assert.True(t, committed)
+	summaries, err = repo.GetSummaries()
+	require.NoError(t, err)
+	assert.Len(t, summaries, 2)
+}
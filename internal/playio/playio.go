@@ -0,0 +1,205 @@
+// Package playio reads and writes play history in the file formats the
+// tracker can import and export. Readers turn each record into a Row that
+// is checked against the database with Apply before anything is saved.
+package playio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"marvel_tracker/internal/models"
+)
+
+// MarvelChampionsBGGID is the BoardGameGeek id of Marvel Champions: The
+// Card Game, which BG Stats also records for games added from BGG.
+const MarvelChampionsBGGID = 285774
+
+// isMarvelChampions reports whether a game from another app is Marvel
+// Champions, by its BGG id or, for games entered by hand, its name.
+func isMarvelChampions(bggID int, name string) bool {
+	return bggID == MarvelChampionsBGGID || strings.Contains(strings.ToLower(name), "marvel champions")
+}
+
+// Options fill in what apps that are not built for Marvel Champions do not
+// record for a play.
+type Options struct {
+	// Aspects are the known aspect names, used to tell an aspect in a
+	// role such as "Spider-Man (Justice)" from part of a hero name such as
+	// "Spider-Man (Miles Morales)".
+	Aspects []string
+	// Difficulty is used for plays that do not name one.
+	Difficulty string
+	// Aspect is used for heroes that do not name one. When it is empty
+	// such heroes are reported as errors.
+	Aspect string
+}
+
+// Row is one play read from an import file.
+type Row struct {
+	// Line is the position of the record in the file, for messages.
+	Line int
+	Play models.PlayImport
+	// Error explains why the row cannot be imported. It is set by the
+	// reader for malformed records and by Apply for plays the database
+	// rejects.
+	Error string
+	// Added lists the scenario and hero names the row adds to the catalog.
+	Added []string
+	// Duplicate is set by Apply when the play was imported before.
+	Duplicate bool
+}
+
+// Importer saves plays; it is implemented by models.PlayRepository.
+type Importer interface {
+	Import(plays []models.PlayImport, commit bool) ([]models.ImportResult, bool, error)
+}
+
+// Apply checks rows against the database and, if commit is set and every
+// row is valid, saves them in a single transaction. It records any problem
+// on the rows themselves and reports whether the plays were saved.
+func Apply(repo Importer, rows []Row, commit bool) (bool, error) {
+	plays := make([]models.PlayImport, 0, len(rows))
+	index := make([]int, 0, len(rows))
+	for i, row := range rows {
+		if row.Error == "" {
+			plays = append(plays, row.Play)
+			index = append(index, i)
+		}
+	}
+
+	valid := len(plays) == len(rows)
+	results, committed, err := repo.Import(plays, commit && valid)
+	if err != nil {
+		return false, err
+	}
+	for i, result := range results {
+		row := &rows[index[i]]
+		row.Added = result.Added
+		row.Duplicate = result.Duplicate
+		var validationErr *models.ValidationError
+		if errors.As(result.Err, &validationErr) {
+			row.Error = validationErr.Message
+		}
+	}
+	return committed, nil
+}
+
+// Counts summarizes a checked import.
+type Counts struct {
+	Ready      int // rows that will be imported
+	Duplicates int // rows imported before, which are skipped
+	Failed     int // rows with an Error
+}
+
+// Count tallies rows after Apply.
+func Count(rows []Row) Counts {
+	var c Counts
+	for _, row := range rows {
+		switch {
+		case row.Error != "":
+			c.Failed++
+		case row.Duplicate:
+			c.Duplicates++
+		default:
+			c.Ready++
+		}
+	}
+	return c
+}
+
+// scenarioAndDifficulty splits "Rhino (Expert I)" into the scenario and
+// difficulty, falling back to opts.Difficulty when s names none.
+func scenarioAndDifficulty(s string, opts Options) (string, string) {
+	scenario, difficulty := splitSuffix(s, func(s string) (string, bool) {
+		for _, d := range models.Difficulties {
+			if strings.EqualFold(s, d) {
+				return d, true
+			}
+		}
+		return "", false
+	})
+	if difficulty == "" {
+		difficulty = opts.Difficulty
+	}
+	return scenario, difficulty
+}
+
+// deckFromRole reads a hero and its aspects written as "Adam Warlock
+// (Leadership/Justice)", the way players record heroes in apps that only
+// have a free-text role or color per player.
+func deckFromRole(role, player string, opts Options) models.DeckEntry {
+	hero, aspects := splitSuffix(role, func(s string) (string, bool) {
+		return s, isAspectList(s, opts.Aspects)
+	})
+	deck := models.DeckEntry{HeroName: hero, PlayerName: player}
+	for _, aspect := range strings.FieldsFunc(aspects, isAspectSeparator) {
+		deck.Aspects = append(deck.Aspects, strings.ToLower(strings.TrimSpace(aspect)))
+	}
+	return deck
+}
+
+// fillAspects gives heroes without an aspect opts.Aspect, or returns a
+// message naming the first such hero. field is what the source app calls
+// the text the hero was read from.
+func fillAspects(decks []models.DeckEntry, opts Options, field string) string {
+	for i := range decks {
+		d := &decks[i]
+		if d.HeroName == "" {
+			return fmt.Sprintf("a player has no hero; set it as the player's %s", field)
+		}
+		if len(d.Aspects) == 0 {
+			if opts.Aspect == "" {
+				return fmt.Sprintf("%s has no aspect; add it to the %s, as in %q, or choose a default aspect", d.HeroName, field, d.HeroName+" (Justice)")
+			}
+			d.Aspects = []string{opts.Aspect}
+		}
+	}
+	return ""
+}
+
+// splitSuffix splits "name (suffix)" into name and the value match returns
+// for suffix. If s has no parenthesized suffix, or match rejects it, s is
+// returned whole.
+func splitSuffix(s string, match func(string) (string, bool)) (string, string) {
+	s = strings.TrimSpace(s)
+	open := strings.LastIndex(s, "(")
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return s, ""
+	}
+	value, ok := match(strings.TrimSpace(s[open+1 : len(s)-1]))
+	if !ok {
+		return s, ""
+	}
+	return strings.TrimSpace(s[:open]), value
+}
+
+func isAspectSeparator(r rune) bool {
+	return r == '/' || r == ',' || r == '+'
+}
+
+// isAspectList reports whether s is one or more known aspect names.
+func isAspectList(s string, known []string) bool {
+	names := strings.FieldsFunc(s, isAspectSeparator)
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		found := false
+		for _, k := range known {
+			found = found || strings.EqualFold(strings.TrimSpace(name), k)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// first returns at most the first n bytes of s.
+func first(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,478 @@
+// Package stats computes win-rate statistics over recorded plays. The
+// aggregations run in SQL and return plain rows, so the same results can be
+// rendered by the HTML handlers or encoded as JSON.
+package stats
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"marvel_tracker/internal/models"
+)
+
+// Dimension is what plays are grouped by.
+type Dimension string
+
+const (
+	ByHero         Dimension = "hero"
+	ByAspect       Dimension = "aspect"
+	ByHeroAspect   Dimension = "hero_aspect"
+	ByScenario     Dimension = "scenario"
+	ByDifficulty   Dimension = "difficulty"
+	ByEncounterSet Dimension = "encounter_set"
+	ByPlayer       Dimension = "player"
+)
+
+// Dimensions lists every dimension in the order they are shown on the
+// stats page.
+var Dimensions = []Dimension{ByHero, ByAspect, ByHeroAspect, ByScenario, ByDifficulty, ByEncounterSet, ByPlayer}
+
+// Title returns the heading used for the dimension's table.
+func (d Dimension) Title() string {
+	switch d {
+	case ByHero:
+		return "Hero"
+	case ByAspect:
+		return "Aspect"
+	case ByHeroAspect:
+		return "Hero and Aspect"
+	case ByScenario:
+		return "Scenario"
+	case ByDifficulty:
+		return "Difficulty"
+	case ByEncounterSet:
+		return "Modular Set"
+	case ByPlayer:
+		return "Player"
+	}
+	return string(d)
+}
+
+// Filter restricts which plays are counted. Zero values mean no
+// restriction. From and To are inclusive dates. PlayerID keeps only the
+// plays that player took part in and, for the hero and aspect dimensions,
+// only the decks they played. OwnerID and GroupID keep only the plays that
+// user logged or that were logged into that group; see SetScope.
+type Filter struct {
+	From     time.Time `json:"from,omitempty"`
+	To       time.Time `json:"to,omitempty"`
+	Players  int       `json:"players,omitempty"`
+	PlayerID int       `json:"player_id,omitempty"`
+	OwnerID  int       `json:"owner_id,omitempty"`
+	GroupID  int       `json:"group_id,omitempty"`
+}
+
+// SetScope narrows f to the plays a scope names for the user with the
+// given id: "me" for the plays they logged, "group:ID" for the plays
+// logged into a group, and "all" or "" for every play they may see. An
+// unknown scope, or "me" for a visitor, is a *models.ValidationError.
+func (f *Filter) SetScope(scope string, userID int) error {
+	scope = strings.TrimSpace(scope)
+	switch {
+	case scope == "" || scope == "all":
+		return nil
+	case scope == "me":
+		if userID == 0 {
+			return &models.ValidationError{Field: "scope", Message: "log in to see your own stats"}
+		}
+		f.OwnerID = userID
+		return nil
+	case strings.HasPrefix(scope, "group:"):
+		id, err := strconv.Atoi(strings.TrimPrefix(scope, "group:"))
+		if err == nil && id > 0 {
+			f.GroupID = id
+			return nil
+		}
+	}
+	return &models.ValidationError{Field: "scope", Message: "scope must be all, me or group:ID"}
+}
+
+// ParseFilter builds a Filter from the raw from, to (YYYY-MM-DD) and
+// players values of a request. Blank values leave the filter open;
+// malformed ones are a *models.ValidationError.
+func ParseFilter(from, to, players string) (Filter, error) {
+	var f Filter
+	var err error
+
+	if from = strings.TrimSpace(from); from != "" {
+		if f.From, err = time.Parse("2006-01-02", from); err != nil {
+			return Filter{}, &models.ValidationError{Field: "from", Message: "from must be in YYYY-MM-DD format"}
+		}
+	}
+	if to = strings.TrimSpace(to); to != "" {
+		if f.To, err = time.Parse("2006-01-02", to); err != nil {
+			return Filter{}, &models.ValidationError{Field: "to", Message: "to must be in YYYY-MM-DD format"}
+		}
+	}
+	if players = strings.TrimSpace(players); players != "" {
+		f.Players, err = strconv.Atoi(players)
+		if err != nil || f.Players < 1 || f.Players > models.MaxPlayers {
+			return Filter{}, &models.ValidationError{Field: "players", Message: fmt.Sprintf("players must be between 1 and %d", models.MaxPlayers)}
+		}
+	}
+	return f, nil
+}
+
+// Row is the result for one group: how many plays it appeared in and how
+// they ended. A play counts once per group even if, say, two of its decks
+// share an aspect.
+type Row struct {
+	Name       string    `json:"name"`
+	Plays      int       `json:"plays"`
+	Wins       int       `json:"wins"`
+	Losses     int       `json:"losses"`
+	WinRate    float64   `json:"win_rate"`
+	LastPlayed time.Time `json:"last_played"`
+}
+
+// WinRatePercent returns the win rate formatted for display, such as "67%".
+func (r Row) WinRatePercent() string {
+	return fmt.Sprintf("%.0f%%", r.WinRate*100)
+}
+
+// FormattedLastPlayed returns the last play date in the form used on the
+// plays page.
+func (r Row) FormattedLastPlayed() string {
+	return r.LastPlayed.Format("Jan 2, 2006")
+}
+
+// groupings maps each dimension to the joins that attach its groups to the
+// filtered plays (aliased f) and their filtered decks (fd), the expression
+// naming a group, and the columns to group by.
+var groupings = map[Dimension]struct {
+	joins   string
+	name    string
+	groupBy string
+}{
+	ByHero: {
+		joins:   "JOIN fd d ON d.play_id = f.id JOIN heroes h ON h.id = d.hero_id",
+		name:    "h.name",
+		groupBy: "h.id",
+	},
+	ByAspect: {
+		joins: `JOIN fd d ON d.play_id = f.id
+			JOIN deck_aspects da ON da.deck_id = d.id
+			JOIN aspects a ON a.id = da.aspect_id`,
+		name:    "a.name",
+		groupBy: "a.id",
+	},
+	ByHeroAspect: {
+		joins: `JOIN (
+				SELECT d.play_id, d.hero_id,
+				       (SELECT GROUP_CONCAT(a.name, ' / ' ORDER BY a.sort_order)
+				        FROM deck_aspects da JOIN aspects a ON a.id = da.aspect_id
+				        WHERE da.deck_id = d.id) AS aspects
+				FROM fd d
+			) d ON d.play_id = f.id
+			JOIN heroes h ON h.id = d.hero_id`,
+		name:    "h.name || COALESCE(' (' || d.aspects || ')', '')",
+		groupBy: "h.id, d.aspects",
+	},
+	ByScenario: {
+		joins:   "JOIN scenarios s ON s.id = f.scenario_id",
+		name:    "s.name",
+		groupBy: "s.id",
+	},
+	ByDifficulty: {
+		name:    "f.difficulty",
+		groupBy: "f.difficulty",
+	},
+	// Plays without recorded modular sets have no group here, so they do
+	// not drag any set's win rate up or down.
+	ByEncounterSet: {
+		joins: `JOIN play_encounter_sets pe ON pe.play_id = f.id
+			JOIN encounter_sets e ON e.id = pe.encounter_set_id`,
+		name:    "e.name",
+		groupBy: "e.id",
+	},
+	// Decks without a linked player are left out, like plays without
+	// recorded modular sets above.
+	ByPlayer: {
+		joins:   "JOIN fd d ON d.play_id = f.id JOIN players pl ON pl.id = d.player_id",
+		name:    "pl.name",
+		groupBy: "pl.id",
+	},
+}
+
+type Repository struct {
+	db     *sql.DB
+	viewer *models.Viewer
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// For returns a repository that counts only the plays v may see.
+func (r *Repository) For(v models.Viewer) *Repository {
+	return &Repository{db: r.db, viewer: &v}
+}
+
+// By returns one row per group of the given dimension, most played first.
+func (r *Repository) By(dim Dimension, f Filter) ([]Row, error) {
+	g, ok := groupings[dim]
+	if !ok {
+		return nil, fmt.Errorf("unknown stats dimension %q", dim)
+	}
+
+	filtered, args := r.filteredPlays(f)
+	decks, deckArgs := filteredDecks(f)
+	args = append(args, deckArgs...)
+	query := `WITH f AS (` + filtered + `), fd AS (` + decks + `)
+		SELECT ` + g.name + `,
+		       COUNT(DISTINCT f.id),
+		       COUNT(DISTINCT CASE WHEN f.outcome = 'win' THEN f.id END),
+		       COUNT(DISTINCT CASE WHEN f.outcome = 'loss' THEN f.id END),
+		       DATE(MAX(f.date))
+		FROM f ` + g.joins + `
+		GROUP BY ` + g.groupBy + `
+		ORDER BY 2 DESC, 1`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []Row
+	for rows.Next() {
+		var row Row
+		var lastPlayed string
+		if err := rows.Scan(&row.Name, &row.Plays, &row.Wins, &row.Losses, &lastPlayed); err != nil {
+			return nil, err
+		}
+		if row.LastPlayed, err = time.Parse("2006-01-02", lastPlayed); err != nil {
+			return nil, err
+		}
+		if row.Plays > 0 {
+			row.WinRate = float64(row.Wins) / float64(row.Plays)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// All returns the rows for every dimension in Dimensions.
+func (r *Repository) All(f Filter) (map[Dimension][]Row, error) {
+	all := make(map[Dimension][]Row, len(Dimensions))
+	for _, dim := range Dimensions {
+		rows, err := r.By(dim, f)
+		if err != nil {
+			return nil, err
+		}
+		all[dim] = rows
+	}
+	return all, nil
+}
+
+// LossReasonRow counts how the losses on one scenario ended. Reasons is
+// keyed by end reason name; losses logged without a reason are counted in
+// Unrecorded.
+type LossReasonRow struct {
+	Scenario   string         `json:"scenario"`
+	Losses     int            `json:"losses"`
+	Reasons    map[string]int `json:"reasons"`
+	Unrecorded int            `json:"unrecorded"`
+}
+
+// Share returns the given count as a percentage of the scenario's losses,
+// formatted for display, such as "40%".
+func (r LossReasonRow) Share(count int) string {
+	if r.Losses == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(count)/float64(r.Losses)*100)
+}
+
+// Count returns the number of losses with the named end reason.
+func (r LossReasonRow) Count(reason string) int {
+	return r.Reasons[reason]
+}
+
+// LossReasons returns the distribution of end reasons over the losses on
+// each scenario, scenarios with the most losses first.
+func (r *Repository) LossReasons(f Filter) ([]LossReasonRow, error) {
+	filtered, args := r.filteredPlays(f)
+	rows, err := r.db.Query(`WITH f AS (`+filtered+`)
+		SELECT s.name, COALESCE(f.end_reason, ''), COUNT(*)
+		FROM f JOIN scenarios s ON s.id = f.scenario_id
+		WHERE f.outcome = 'loss'
+		GROUP BY s.id, f.end_reason
+		ORDER BY s.name, s.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []LossReasonRow
+	for rows.Next() {
+		var scenario, reason string
+		var count int
+		if err := rows.Scan(&scenario, &reason, &count); err != nil {
+			return nil, err
+		}
+		if n := len(result); n == 0 || result[n-1].Scenario != scenario {
+			result = append(result, LossReasonRow{Scenario: scenario, Reasons: map[string]int{}})
+		}
+		last := &result[len(result)-1]
+		last.Losses += count
+		if reason == "" {
+			last.Unrecorded += count
+		} else {
+			last.Reasons[reason] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Losses > result[j].Losses
+	})
+	return result, nil
+}
+
+// filteredPlays returns a query selecting the plays that match f and
+// that the repository's viewer may see, along with its arguments.
+func (r *Repository) filteredPlays(f Filter) (string, []any) {
+	var where []string
+	var args []any
+	if r.viewer != nil {
+		visible, visibleArgs := r.viewer.VisiblePlays("p")
+		where = append(where, visible)
+		args = append(args, visibleArgs...)
+	}
+	if !f.From.IsZero() {
+		where = append(where, "p.date >= ?")
+		args = append(args, f.From.Format("2006-01-02"))
+	}
+	if !f.To.IsZero() {
+		// Dates are stored with a time part, so compare against the start
+		// of the following day to include all of To.
+		where = append(where, "p.date < ?")
+		args = append(args, f.To.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+	if f.Players > 0 {
+		where = append(where, "(SELECT COUNT(*) FROM decks pd WHERE pd.play_id = p.id) = ?")
+		args = append(args, f.Players)
+	}
+
+	if f.PlayerID > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM decks pd WHERE pd.play_id = p.id AND pd.player_id = ?)")
+		args = append(args, f.PlayerID)
+	}
+	if f.OwnerID > 0 {
+		where = append(where, "p.owner_id = ?")
+		args = append(args, f.OwnerID)
+	}
+	if f.GroupID > 0 {
+		where = append(where, "p.group_id = ?")
+		args = append(args, f.GroupID)
+	}
+
+	query := "SELECT p.id, p.date, p.outcome, p.difficulty, p.scenario_id, p.end_reason FROM plays p"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	return query, args
+}
+
+// filteredDecks returns a query selecting the decks f counts, along with
+// its arguments. Only the player filter narrows decks; the play filters
+// apply through the join onto the filtered plays.
+func filteredDecks(f Filter) (string, []any) {
+	if f.PlayerID > 0 {
+		return "SELECT * FROM decks WHERE player_id = ?", []any{f.PlayerID}
+	}
+	return "SELECT * FROM decks", nil
+}
+
+// Streaks describes runs of consecutive results among the filtered plays
+// in date order.
+type Streaks struct {
+	// Current is the length of the run the latest play belongs to, and
+	// CurrentOutcome whether it is a run of wins or losses.
+	Current        int    `json:"current"`
+	CurrentOutcome string `json:"current_outcome,omitempty"`
+	LongestWin     int    `json:"longest_win"`
+	LongestLoss    int    `json:"longest_loss"`
+}
+
+// CurrentLabel returns the current streak for display, such as "3 wins",
+// or "" when there are no plays.
+func (s Streaks) CurrentLabel() string {
+	switch {
+	case s.Current == 0:
+		return ""
+	case s.CurrentOutcome == "win" && s.Current == 1:
+		return "1 win"
+	case s.CurrentOutcome == "win":
+		return fmt.Sprintf("%d wins", s.Current)
+	case s.Current == 1:
+		return "1 loss"
+	}
+	return fmt.Sprintf("%d losses", s.Current)
+}
+
+// Streaks returns the current and longest streaks of the plays matching f.
+func (r *Repository) Streaks(f Filter) (Streaks, error) {
+	filtered, args := r.filteredPlays(f)
+	rows, err := r.db.Query(`WITH f AS (`+filtered+`) SELECT outcome FROM f ORDER BY date, id`, args...)
+	if err != nil {
+		return Streaks{}, err
+	}
+	defer rows.Close()
+
+	var s Streaks
+	for rows.Next() {
+		var outcome string
+		if err := rows.Scan(&outcome); err != nil {
+			return Streaks{}, err
+		}
+		if outcome == s.CurrentOutcome {
+			s.Current++
+		} else {
+			s.Current, s.CurrentOutcome = 1, outcome
+		}
+		if outcome == "win" {
+			s.LongestWin = max(s.LongestWin, s.Current)
+		} else {
+			s.LongestLoss = max(s.LongestLoss, s.Current)
+		}
+	}
+	return s, rows.Err()
+}
+
+// SortKeys lists the columns rows can be sorted by.
+var SortKeys = []string{"name", "plays", "wins", "losses", "win_rate", "last_played"}
+
+// Sort orders rows in place by the given column, falling back to name so
+// the order is stable. Unknown columns leave the rows as they are.
+func Sort(rows []Row, key string, desc bool) {
+	less := map[string]func(a, b Row) bool{
+		"name":        func(a, b Row) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+		"plays":       func(a, b Row) bool { return a.Plays < b.Plays },
+		"wins":        func(a, b Row) bool { return a.Wins < b.Wins },
+		"losses":      func(a, b Row) bool { return a.Losses < b.Losses },
+		"win_rate":    func(a, b Row) bool { return a.WinRate < b.WinRate },
+		"last_played": func(a, b Row) bool { return a.LastPlayed.Before(b.LastPlayed) },
+	}[key]
+	if less == nil {
+		return
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if less(a, b) {
+			return !desc
+		}
+		if less(b, a) {
+			return desc
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
@@ -0,0 +1,343 @@
+package stats
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"marvel_tracker/internal/config"
+	"marvel_tracker/internal/models"
+	"marvel_tracker/migrations"
+)
+
+// setupTestDB migrates an in-memory database with the shipped migrations
+// and logs a handful of plays:
+//
+//	Jan 5  Rhino   Standard I  win   Spider-Man (justice), She-Hulk (aggression)
+//	Feb 10 Rhino   Expert I    loss  Spider-Man (justice)
+//	Mar 15 Klaw    Standard I  win   Spider-Man (protection)
+//	Mar 20 Klaw    Standard I  loss  Adam Warlock (all four), She-Hulk (justice)
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, config.RunMigrations(db, migrations.FS))
+
+	plays := models.NewPlayRepository(db)
+	logPlay := func(date, scenario, difficulty, outcome string, decks ...models.DeckEntry) {
+		d, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		play := &models.Play{Date: d, Outcome: outcome, Difficulty: difficulty}
+		require.NoError(t, plays.CreateWithDecks(play, scenario, decks))
+	}
+	deck := func(hero string, aspects ...string) models.DeckEntry {
+		return models.DeckEntry{HeroName: hero, Aspects: aspects}
+	}
+
+	logPlay("2024-01-05", "Rhino", "Standard I", "win", deck("Spider-Man", "justice"), deck("She-Hulk", "aggression"))
+	logPlay("2024-02-10", "Rhino", "Expert I", "loss", deck("Spider-Man", "justice"))
+	logPlay("2024-03-15", "Klaw", "Standard I", "win", deck("Spider-Man", "protection"))
+	logPlay("2024-03-20", "Klaw", "Standard I", "loss",
+		deck("Adam Warlock", "leadership", "justice", "aggression", "protection"), deck("She-Hulk", "justice"))
+
+	return db
+}
+
+func rowNamed(t *testing.T, rows []Row, name string) Row {
+	for _, row := range rows {
+		if row.Name == name {
+			return row
+		}
+	}
+	t.Fatalf("no row named %q in %v", name, rows)
+	return Row{}
+}
+
+func TestRepository_By(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+
+	t.Run("Hero", func(t *testing.T) {
+		rows, err := repo.By(ByHero, Filter{})
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+
+		spiderMan := rows[0]
+		assert.Equal(t, "Spider-Man", spiderMan.Name)
+		assert.Equal(t, 3, spiderMan.Plays)
+		assert.Equal(t, 2, spiderMan.Wins)
+		assert.Equal(t, 1, spiderMan.Losses)
+		assert.InDelta(t, 2.0/3.0, spiderMan.WinRate, 0.001)
+		assert.Equal(t, "67%", spiderMan.WinRatePercent())
+		assert.Equal(t, "Mar 15, 2024", spiderMan.FormattedLastPlayed())
+	})
+
+	t.Run("Aspect Counts Each Play Once", func(t *testing.T) {
+		rows, err := repo.By(ByAspect, Filter{})
+		require.NoError(t, err)
+
+		// The last play has two justice decks but is one play.
+		justice := rowNamed(t, rows, "justice")
+		assert.Equal(t, 3, justice.Plays)
+		assert.Equal(t, 1, justice.Wins)
+		assert.Equal(t, 2, justice.Losses)
+
+		leadership := rowNamed(t, rows, "leadership")
+		assert.Equal(t, 1, leadership.Plays)
+	})
+
+	t.Run("Hero Aspect", func(t *testing.T) {
+		rows, err := repo.By(ByHeroAspect, Filter{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, rowNamed(t, rows, "Spider-Man (justice)").Plays)
+		assert.Equal(t, 1, rowNamed(t, rows, "Spider-Man (protection)").Plays)
+		assert.Equal(t, 1, rowNamed(t, rows, "Adam Warlock (leadership / justice / aggression / protection)").Plays)
+	})
+
+	t.Run("Scenario and Difficulty", func(t *testing.T) {
+		rows, err := repo.By(ByScenario, Filter{})
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, Row{Name: "Klaw", Plays: 2, Wins: 1, Losses: 1, WinRate: 0.5, LastPlayed: time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)}, rows[0])
+
+		rows, err = repo.By(ByDifficulty, Filter{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Standard I", "Expert I"}, []string{rows[0].Name, rows[1].Name})
+		assert.Equal(t, 3, rows[0].Plays)
+	})
+
+	t.Run("Modular Set", func(t *testing.T) {
+		// Both Rhino plays used Bomb Scare and the Klaw win Masters of
+		// Evil; the Klaw loss has no modular sets recorded.
+		_, err := db.Exec(`
+			INSERT INTO play_encounter_sets (play_id, encounter_set_id)
+			SELECT p.id, e.id FROM plays p JOIN scenarios s ON s.id = p.scenario_id, encounter_sets e
+			WHERE (s.name = 'Rhino' AND e.name = 'Bomb Scare')
+			   OR (s.name = 'Klaw' AND p.outcome = 'win' AND e.name = 'Masters of Evil')`)
+		require.NoError(t, err)
+
+		rows, err := repo.By(ByEncounterSet, Filter{})
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, Row{Name: "Bomb Scare", Plays: 2, Wins: 1, Losses: 1, WinRate: 0.5, LastPlayed: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)}, rows[0])
+		assert.Equal(t, 1, rowNamed(t, rows, "Masters of Evil").Wins)
+	})
+
+	t.Run("Date Range", func(t *testing.T) {
+		rows, err := repo.By(ByScenario, Filter{
+			From: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		})
+		require.NoError(t, err)
+
+		// Both ends are inclusive.
+		assert.Equal(t, 1, rowNamed(t, rows, "Rhino").Plays)
+		assert.Equal(t, 1, rowNamed(t, rows, "Klaw").Plays)
+	})
+
+	t.Run("Player Count", func(t *testing.T) {
+		rows, err := repo.By(ByHero, Filter{Players: 2})
+		require.NoError(t, err)
+
+		assert.Len(t, rows, 3)
+		assert.Equal(t, 2, rowNamed(t, rows, "She-Hulk").Plays)
+		assert.Equal(t, 1, rowNamed(t, rows, "Spider-Man").Plays)
+	})
+
+	t.Run("No Plays Match", func(t *testing.T) {
+		rows, err := repo.By(ByHero, Filter{From: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+		assert.Empty(t, rows)
+	})
+
+	t.Run("Unknown Dimension", func(t *testing.T) {
+		_, err := repo.By("villain", Filter{})
+		assert.Error(t, err)
+	})
+
+	t.Run("All", func(t *testing.T) {
+		all, err := repo.All(Filter{})
+		require.NoError(t, err)
+		assert.Len(t, all, len(Dimensions))
+	})
+}
+
+func TestRepository_LossReasons(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	plays := models.NewPlayRepository(db)
+
+	for _, reason := range []string{"scheme_completed", "scheme_completed", "heroes_defeated"} {
+		play := &models.Play{Date: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), Outcome: "loss", Difficulty: "Standard I", EndReason: reason}
+		require.NoError(t, plays.CreateWithDecks(play, "Klaw", []models.DeckEntry{{HeroName: "Spider-Man", Aspects: []string{"justice"}}}))
+	}
+
+	rows, err := repo.LossReasons(Filter{})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	klaw := rows[0]
+	assert.Equal(t, "Klaw", klaw.Scenario)
+	assert.Equal(t, 4, klaw.Losses)
+	assert.Equal(t, 2, klaw.Count("scheme_completed"))
+	assert.Equal(t, 1, klaw.Count("heroes_defeated"))
+	assert.Zero(t, klaw.Count("conceded"))
+	assert.Equal(t, 1, klaw.Unrecorded, "the loss from setupTestDB has no reason")
+	assert.Equal(t, "50%", klaw.Share(klaw.Count("scheme_completed")))
+
+	rhino := rows[1]
+	assert.Equal(t, "Rhino", rhino.Scenario)
+	assert.Equal(t, 1, rhino.Losses)
+	assert.Equal(t, 1, rhino.Unrecorded)
+
+	t.Run("Filtered", func(t *testing.T) {
+		rows, err := repo.LossReasons(Filter{From: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, 3, rows[0].Losses)
+		assert.Zero(t, rows[0].Unrecorded)
+	})
+}
+
+func TestRepository_Players(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+	plays := models.NewPlayRepository(db)
+
+	logPlay := func(date, scenario, outcome string, decks ...models.DeckEntry) {
+		d, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		play := &models.Play{Date: d, Outcome: outcome, Difficulty: "Standard I"}
+		require.NoError(t, plays.CreateWithDecks(play, scenario, decks))
+	}
+	deck := func(player, hero, aspect string) models.DeckEntry {
+		return models.DeckEntry{HeroName: hero, Aspects: []string{aspect}, PlayerName: player}
+	}
+	logPlay("2024-04-01", "Rhino", "win", deck("Ann", "Spider-Man", "justice"), deck("Ben", "She-Hulk", "aggression"))
+	logPlay("2024-04-02", "Rhino", "win", deck("Ann", "Spider-Man", "justice"))
+	logPlay("2024-04-03", "Klaw", "loss", deck("Ben", "Adam Warlock", "leadership"))
+	logPlay("2024-04-04", "Klaw", "win", deck("Ann", "She-Hulk", "protection"), deck("Ben", "Spider-Man", "justice"))
+
+	var annID int
+	require.NoError(t, db.QueryRow("SELECT id FROM players WHERE name = 'Ann'").Scan(&annID))
+
+	t.Run("By Player", func(t *testing.T) {
+		rows, err := repo.By(ByPlayer, Filter{})
+		require.NoError(t, err)
+		require.Len(t, rows, 2, "plays without players are left out")
+		assert.Equal(t, Row{Name: "Ann", Plays: 3, Wins: 3, WinRate: 1, LastPlayed: time.Date(2024, 4, 4, 0, 0, 0, 0, time.UTC)}, rows[0])
+		assert.Equal(t, 1, rowNamed(t, rows, "Ben").Losses)
+	})
+
+	t.Run("Filter Counts Only The Player's Decks", func(t *testing.T) {
+		filter := Filter{PlayerID: annID}
+
+		heroes, err := repo.By(ByHero, filter)
+		require.NoError(t, err)
+		require.Len(t, heroes, 2)
+		assert.Equal(t, "Spider-Man", heroes[0].Name)
+		assert.Equal(t, 2, heroes[0].Plays)
+		assert.Equal(t, 1, rowNamed(t, heroes, "She-Hulk").Plays)
+
+		aspects, err := repo.By(ByAspect, filter)
+		require.NoError(t, err)
+		assert.Len(t, aspects, 2, "Ben's aggression deck is not Ann's")
+		assert.Equal(t, 2, rowNamed(t, aspects, "justice").Plays)
+
+		scenarios, err := repo.By(ByScenario, filter)
+		require.NoError(t, err)
+		assert.Equal(t, 2, rowNamed(t, scenarios, "Rhino").Plays)
+		assert.Equal(t, 1, rowNamed(t, scenarios, "Klaw").Plays)
+	})
+
+	t.Run("Streaks", func(t *testing.T) {
+		streaks, err := repo.Streaks(Filter{PlayerID: annID})
+		require.NoError(t, err)
+		assert.Equal(t, Streaks{Current: 3, CurrentOutcome: "win", LongestWin: 3}, streaks)
+		assert.Equal(t, "3 wins", streaks.CurrentLabel())
+
+		// Every play: win, loss, win, loss, then win, win, loss, win.
+		streaks, err = repo.Streaks(Filter{})
+		require.NoError(t, err)
+		assert.Equal(t, Streaks{Current: 1, CurrentOutcome: "win", LongestWin: 2, LongestLoss: 1}, streaks)
+		assert.Equal(t, "1 win", streaks.CurrentLabel())
+
+		streaks, err = repo.Streaks(Filter{From: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+		assert.Zero(t, streaks)
+		assert.Empty(t, streaks.CurrentLabel())
+	})
+}
+
+func TestRepository_Scope(t *testing.T) {
+	db := setupTestDB(t)
+	repo := NewRepository(db)
+
+	// Ann logged the two Rhino plays, one of them into her group; Ben
+	// logged the Klaw plays.
+	_, err := db.Exec("INSERT INTO users (id, username, password_hash) VALUES (1, 'ann', ''), (2, 'ben', '')")
+	require.NoError(t, err)
+	group, err := models.NewGroupRepository(db).For(models.Viewer{UserID: 1}).Create("Tuesday Table")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE plays SET owner_id = CASE WHEN scenario_id = (SELECT id FROM scenarios WHERE name = 'Rhino') THEN 1 ELSE 2 END")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE plays SET group_id = ? WHERE date < '2024-01-06'", group.ID)
+	require.NoError(t, err)
+
+	count := func(scope string, userID int) int {
+		var f Filter
+		require.NoError(t, f.SetScope(scope, userID))
+		rows, err := repo.By(ByDifficulty, f)
+		require.NoError(t, err)
+		plays := 0
+		for _, row := range rows {
+			plays += row.Plays
+		}
+		return plays
+	}
+	assert.Equal(t, 4, count("", 1))
+	assert.Equal(t, 4, count("all", 1))
+	assert.Equal(t, 2, count("me", 1))
+	assert.Equal(t, 2, count("me", 2))
+	assert.Equal(t, 1, count("group:"+strconv.Itoa(group.ID), 1))
+
+	t.Run("Invalid", func(t *testing.T) {
+		var f Filter
+		for _, scope := range []string{"everyone", "group:", "group:x", "group:-1"} {
+			var validationErr *models.ValidationError
+			assert.ErrorAs(t, f.SetScope(scope, 1), &validationErr, scope)
+		}
+		err := f.SetScope("me", 0)
+		var validationErr *models.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "scope", validationErr.Field)
+		assert.Zero(t, f)
+	})
+}
+
+func TestSort(t *testing.T) {
+	rows := []Row{
+		{Name: "b", Plays: 2, WinRate: 0.5},
+		{Name: "A", Plays: 2, WinRate: 1},
+		{Name: "c", Plays: 5, WinRate: 0.2},
+	}
+
+	Sort(rows, "win_rate", true)
+	assert.Equal(t, []string{"A", "b", "c"}, []string{rows[0].Name, rows[1].Name, rows[2].Name})
+
+	Sort(rows, "plays", false)
+	assert.Equal(t, []string{"A", "b", "c"}, []string{rows[0].Name, rows[1].Name, rows[2].Name}, "ties fall back to name")
+
+	Sort(rows, "plays", true)
+	assert.Equal(t, "c", rows[0].Name)
+
+	Sort(rows, "nonsense", false)
+	assert.Equal(t, "c", rows[0].Name)
+}
@@ -0,0 +1,10 @@
+-- Undoing the initial schema drops every play.
+DROP INDEX IF EXISTS idx_decks_hero_id;
+DROP INDEX IF EXISTS idx_decks_play_id;
+DROP INDEX IF EXISTS idx_plays_scenario_id;
+DROP INDEX IF EXISTS idx_plays_date;
+
+DROP TABLE IF EXISTS decks;
+DROP TABLE IF EXISTS plays;
+DROP TABLE IF EXISTS scenarios;
+DROP TABLE IF EXISTS heroes;
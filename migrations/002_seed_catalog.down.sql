@@ -0,0 +1,20 @@
+-- Catalog heroes and scenarios that no play uses are removed. Those that
+-- plays use stay, and become user-added entries without a pack, as do
+-- user-added rows the catalog was matched to.
+DROP INDEX IF EXISTS idx_scenarios_pack_id;
+DROP INDEX IF EXISTS idx_heroes_pack_id;
+
+DELETE FROM heroes
+WHERE seeded_by = '002_seed_catalog'
+  AND id NOT IN (SELECT hero_id FROM decks);
+
+DELETE FROM scenarios
+WHERE seeded_by = '002_seed_catalog'
+  AND id NOT IN (SELECT scenario_id FROM plays);
+
+ALTER TABLE heroes DROP COLUMN seeded_by;
+ALTER TABLE scenarios DROP COLUMN seeded_by;
+ALTER TABLE heroes DROP COLUMN pack_id;
+ALTER TABLE scenarios DROP COLUMN pack_id;
+
+DROP TABLE IF EXISTS packs;
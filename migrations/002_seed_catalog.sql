@@ -0,0 +1,205 @@
+-- Official product catalog.
+--
+-- Every hero and scenario shipped in an official product belongs to a pack,
+-- which records the release wave and date. Rows in heroes and scenarios
+-- without a pack_id were added by users and are never removed by catalog
+-- migrations.
+--
+-- Later catalog migrations follow the same pattern: insert packs with
+-- ON CONFLICT(name) DO UPDATE, then match heroes and scenarios by name,
+-- ignoring case as the app does. A user-added row whose name matches a
+-- catalog entry keeps its id (so existing plays are unaffected) and is
+-- attached to the pack; the rest are inserted with seeded_by set to the
+-- migration's name, so that its down migration removes only the rows it
+-- added. Catalog migrations must never DELETE from heroes or scenarios.
+--
+-- Release dates are left NULL where they have not been confirmed; they can
+-- be filled in by a later catalog migration.
+
+CREATE TABLE IF NOT EXISTS packs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    kind TEXT NOT NULL CHECK(kind IN ('core', 'campaign', 'hero', 'scenario')),
+    wave INTEGER NOT NULL,
+    released_on DATE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE heroes ADD COLUMN pack_id INTEGER REFERENCES packs(id);
+ALTER TABLE scenarios ADD COLUMN pack_id INTEGER REFERENCES packs(id);
+ALTER TABLE heroes ADD COLUMN seeded_by TEXT;
+ALTER TABLE scenarios ADD COLUMN seeded_by TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_heroes_pack_id ON heroes(pack_id);
+CREATE INDEX IF NOT EXISTS idx_scenarios_pack_id ON scenarios(pack_id);
+
+INSERT INTO packs (name, kind, wave)
+SELECT column1, column2, column3 FROM (VALUES
+    ('Core Set', 'core', 1),
+    ('The Green Goblin', 'scenario', 1),
+    ('The Wrecking Crew', 'scenario', 1),
+    ('Captain America', 'hero', 1),
+    ('Ms. Marvel', 'hero', 1),
+    ('Thor', 'hero', 1),
+    ('Black Widow', 'hero', 1),
+    ('Doctor Strange', 'hero', 1),
+    ('Hulk', 'hero', 1),
+    ('The Rise of Red Skull', 'campaign', 2),
+    ('The Once and Future Kang', 'scenario', 2),
+    ('Ant-Man', 'hero', 2),
+    ('Wasp', 'hero', 2),
+    ('Quicksilver', 'hero', 2),
+    ('Scarlet Witch', 'hero', 2),
+    ('Galaxy''s Most Wanted', 'campaign', 3),
+    ('Star-Lord', 'hero', 3),
+    ('Gamora', 'hero', 3),
+    ('Drax', 'hero', 3),
+    ('Venom', 'hero', 3),
+    ('The Hood', 'scenario', 3),
+    ('The Mad Titan''s Shadow', 'campaign', 4),
+    ('Nebula', 'hero', 4),
+    ('War Machine', 'hero', 4),
+    ('Valkyrie', 'hero', 4),
+    ('Vision', 'hero', 4),
+    ('Sinister Motives', 'campaign', 5),
+    ('Nova', 'hero', 5),
+    ('Ironheart', 'hero', 5),
+    ('Spider-Ham', 'hero', 5),
+    ('SP//dr', 'hero', 5),
+    ('Mutant Genesis', 'campaign', 6),
+    ('Cyclops', 'hero', 6),
+    ('Phoenix', 'hero', 6),
+    ('Wolverine', 'hero', 6),
+    ('Storm', 'hero', 6),
+    ('Gambit', 'hero', 6),
+    ('Rogue', 'hero', 6),
+    ('MojoMania', 'scenario', 6),
+    ('NeXt Evolution', 'campaign', 7),
+    ('Psylocke', 'hero', 7),
+    ('Angel', 'hero', 7),
+    ('X-23', 'hero', 7),
+    ('Deadpool', 'hero', 7)
+) WHERE true
+ON CONFLICT(name) DO UPDATE SET
+    kind = excluded.kind,
+    wave = excluded.wave,
+    updated_at = CURRENT_TIMESTAMP;
+
+CREATE TEMP TABLE catalog_heroes AS
+SELECT v.column1 AS name, packs.id AS pack_id FROM (VALUES
+    ('Spider-Man', 'Core Set'),
+    ('Captain Marvel', 'Core Set'),
+    ('She-Hulk', 'Core Set'),
+    ('Iron Man', 'Core Set'),
+    ('Black Panther', 'Core Set'),
+    ('Captain America', 'Captain America'),
+    ('Ms. Marvel', 'Ms. Marvel'),
+    ('Thor', 'Thor'),
+    ('Black Widow', 'Black Widow'),
+    ('Doctor Strange', 'Doctor Strange'),
+    ('Hulk', 'Hulk'),
+    ('Hawkeye', 'The Rise of Red Skull'),
+    ('Spider-Woman', 'The Rise of Red Skull'),
+    ('Ant-Man', 'Ant-Man'),
+    ('Wasp', 'Wasp'),
+    ('Quicksilver', 'Quicksilver'),
+    ('Scarlet Witch', 'Scarlet Witch'),
+    ('Rocket Raccoon', 'Galaxy''s Most Wanted'),
+    ('Groot', 'Galaxy''s Most Wanted'),
+    ('Star-Lord', 'Star-Lord'),
+    ('Gamora', 'Gamora'),
+    ('Drax', 'Drax'),
+    ('Venom', 'Venom'),
+    ('Spectrum', 'The Mad Titan''s Shadow'),
+    ('Adam Warlock', 'The Mad Titan''s Shadow'),
+    ('Nebula', 'Nebula'),
+    ('War Machine', 'War Machine'),
+    ('Valkyrie', 'Valkyrie'),
+    ('Vision', 'Vision'),
+    ('Ghost-Spider', 'Sinister Motives'),
+    ('Spider-Man (Miles Morales)', 'Sinister Motives'),
+    ('Nova', 'Nova'),
+    ('Ironheart', 'Ironheart'),
+    ('Spider-Ham', 'Spider-Ham'),
+    ('SP//dr', 'SP//dr'),
+    ('Colossus', 'Mutant Genesis'),
+    ('Shadowcat', 'Mutant Genesis'),
+    ('Cyclops', 'Cyclops'),
+    ('Phoenix', 'Phoenix'),
+    ('Wolverine', 'Wolverine'),
+    ('Storm', 'Storm'),
+    ('Gambit', 'Gambit'),
+    ('Rogue', 'Rogue'),
+    ('Cable', 'NeXt Evolution'),
+    ('Domino', 'NeXt Evolution'),
+    ('Psylocke', 'Psylocke'),
+    ('Angel', 'Angel'),
+    ('X-23', 'X-23'),
+    ('Deadpool', 'Deadpool')
+) AS v
+JOIN packs ON packs.name = v.column2;
+
+UPDATE heroes SET pack_id = c.pack_id, updated_at = CURRENT_TIMESTAMP
+FROM catalog_heroes AS c
+WHERE heroes.name = c.name COLLATE NOCASE;
+
+INSERT INTO heroes (name, pack_id, seeded_by)
+SELECT c.name, c.pack_id, '002_seed_catalog' FROM catalog_heroes AS c
+WHERE NOT EXISTS (SELECT 1 FROM heroes WHERE heroes.name = c.name COLLATE NOCASE);
+
+DROP TABLE catalog_heroes;
+
+CREATE TEMP TABLE catalog_scenarios AS
+SELECT v.column1 AS name, packs.id AS pack_id FROM (VALUES
+    ('Rhino', 'Core Set'),
+    ('Klaw', 'Core Set'),
+    ('Ultron', 'Core Set'),
+    ('Risky Business', 'The Green Goblin'),
+    ('Mutagen Formula', 'The Green Goblin'),
+    ('Wrecking Crew', 'The Wrecking Crew'),
+    ('Crossbones', 'The Rise of Red Skull'),
+    ('Absorbing Man', 'The Rise of Red Skull'),
+    ('Taskmaster', 'The Rise of Red Skull'),
+    ('Zola', 'The Rise of Red Skull'),
+    ('Red Skull', 'The Rise of Red Skull'),
+    ('Kang', 'The Once and Future Kang'),
+    ('Drang', 'Galaxy''s Most Wanted'),
+    ('Collector: Infiltrate the Museum', 'Galaxy''s Most Wanted'),
+    ('Collector: Escape the Museum', 'Galaxy''s Most Wanted'),
+    ('Nebula', 'Galaxy''s Most Wanted'),
+    ('Ronan the Accuser', 'Galaxy''s Most Wanted'),
+    ('The Hood', 'The Hood'),
+    ('Ebony Maw', 'The Mad Titan''s Shadow'),
+    ('Tower Defense', 'The Mad Titan''s Shadow'),
+    ('Thanos', 'The Mad Titan''s Shadow'),
+    ('Hela', 'The Mad Titan''s Shadow'),
+    ('Loki', 'The Mad Titan''s Shadow'),
+    ('Sandman', 'Sinister Motives'),
+    ('Venom', 'Sinister Motives'),
+    ('Mysterio', 'Sinister Motives'),
+    ('The Sinister Six', 'Sinister Motives'),
+    ('Venom Goblin', 'Sinister Motives'),
+    ('Sabretooth', 'Mutant Genesis'),
+    ('Project Wideawake', 'Mutant Genesis'),
+    ('Master Mold', 'Mutant Genesis'),
+    ('Mansion Attack', 'Mutant Genesis'),
+    ('Magneto', 'Mutant Genesis'),
+    ('Mojo', 'MojoMania'),
+    ('Morlock Siege', 'NeXt Evolution'),
+    ('On the Run', 'NeXt Evolution'),
+    ('Juggernaut', 'NeXt Evolution'),
+    ('Mister Sinister', 'NeXt Evolution'),
+    ('Stryfe', 'NeXt Evolution')
+) AS v
+JOIN packs ON packs.name = v.column2;
+
+UPDATE scenarios SET pack_id = c.pack_id, updated_at = CURRENT_TIMESTAMP
+FROM catalog_scenarios AS c
+WHERE scenarios.name = c.name COLLATE NOCASE;
+
+INSERT INTO scenarios (name, pack_id, seeded_by)
+SELECT c.name, c.pack_id, '002_seed_catalog' FROM catalog_scenarios AS c
+WHERE NOT EXISTS (SELECT 1 FROM scenarios WHERE scenarios.name = c.name COLLATE NOCASE);
+
+DROP TABLE catalog_scenarios;
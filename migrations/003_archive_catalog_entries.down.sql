@@ -0,0 +1,2 @@
+ALTER TABLE heroes DROP COLUMN archived_at;
+ALTER TABLE scenarios DROP COLUMN archived_at;
@@ -0,0 +1,4 @@
+-- Allow heroes and scenarios to be archived. Archived entries are hidden
+-- from the New Play form but keep their history.
+ALTER TABLE heroes ADD COLUMN archived_at DATETIME;
+ALTER TABLE scenarios ADD COLUMN archived_at DATETIME;
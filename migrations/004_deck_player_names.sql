@@ -0,0 +1,2 @@
+-- Record who played each hero. The name is free text and optional.
+ALTER TABLE decks ADD COLUMN player_name TEXT;
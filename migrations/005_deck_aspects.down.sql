@@ -0,0 +1,37 @@
+-- Put a single aspect back on each deck.
+--
+-- decks.aspect only holds one of the four classic aspects, so a deck built
+-- with more than one keeps the first in aspect order. A deck built only
+-- with Pool or Basic has no aspect to keep; the NOT NULL constraint then
+-- fails and the migration is not undone.
+
+CREATE TABLE decks_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    play_id INTEGER NOT NULL,
+    hero_id INTEGER NOT NULL,
+    aspect TEXT NOT NULL CHECK(aspect IN ('leadership', 'justice', 'aggression', 'protection')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    player_name TEXT,
+    FOREIGN KEY (play_id) REFERENCES plays(id) ON DELETE CASCADE,
+    FOREIGN KEY (hero_id) REFERENCES heroes(id)
+);
+
+INSERT INTO decks_old (id, play_id, hero_id, aspect, created_at, updated_at, player_name)
+SELECT d.id, d.play_id, d.hero_id,
+       (SELECT a.name FROM deck_aspects da JOIN aspects a ON a.id = da.aspect_id
+        WHERE da.deck_id = d.id AND a.name IN ('leadership', 'justice', 'aggression', 'protection')
+        ORDER BY a.sort_order LIMIT 1),
+       d.created_at, d.updated_at, d.player_name
+FROM decks d;
+
+DROP INDEX IF EXISTS idx_deck_aspects_aspect_id;
+DROP TABLE IF EXISTS deck_aspects;
+DROP TABLE decks;
+
+ALTER TABLE decks_old RENAME TO decks;
+
+CREATE INDEX IF NOT EXISTS idx_decks_play_id ON decks(play_id);
+CREATE INDEX IF NOT EXISTS idx_decks_hero_id ON decks(hero_id);
+
+DROP TABLE IF EXISTS aspects;
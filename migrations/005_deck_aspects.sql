@@ -0,0 +1,58 @@
+-- Move deck aspects into their own table.
+--
+-- decks.aspect only allowed the four classic aspects and a single value per
+-- deck, which rules out the Pool aspect, Basic-only decks and heroes such as
+-- Spider-Woman or Adam Warlock who build with more than one aspect. Aspects
+-- are now rows in a lookup table, and deck_aspects links each deck to one or
+-- more of them. New aspects are added by inserting into aspects.
+--
+-- decks is rebuilt without its aspect column. deck_aspects is created
+-- against the new table before the rename so its foreign key follows it,
+-- and the old table is dropped before anything references it.
+
+CREATE TABLE IF NOT EXISTS aspects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO aspects (name, sort_order) VALUES
+    ('leadership', 1),
+    ('justice', 2),
+    ('aggression', 3),
+    ('protection', 4),
+    ('pool', 5),
+    ('basic', 6)
+ON CONFLICT(name) DO UPDATE SET sort_order = excluded.sort_order;
+
+CREATE TABLE decks_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    play_id INTEGER NOT NULL,
+    hero_id INTEGER NOT NULL,
+    player_name TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (play_id) REFERENCES plays(id) ON DELETE CASCADE,
+    FOREIGN KEY (hero_id) REFERENCES heroes(id)
+);
+
+INSERT INTO decks_new (id, play_id, hero_id, player_name, created_at, updated_at)
+SELECT id, play_id, hero_id, player_name, created_at, updated_at FROM decks;
+
+CREATE TABLE IF NOT EXISTS deck_aspects (
+    deck_id INTEGER NOT NULL REFERENCES decks_new(id) ON DELETE CASCADE,
+    aspect_id INTEGER NOT NULL REFERENCES aspects(id),
+    PRIMARY KEY (deck_id, aspect_id)
+);
+
+INSERT INTO deck_aspects (deck_id, aspect_id)
+SELECT d.id, a.id FROM decks d JOIN aspects a ON a.name = d.aspect;
+
+DROP TABLE decks;
+
+ALTER TABLE decks_new RENAME TO decks;
+
+CREATE INDEX IF NOT EXISTS idx_decks_play_id ON decks(play_id);
+CREATE INDEX IF NOT EXISTS idx_decks_hero_id ON decks(hero_id);
+CREATE INDEX IF NOT EXISTS idx_deck_aspects_aspect_id ON deck_aspects(aspect_id);
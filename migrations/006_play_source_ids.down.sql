@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_plays_source_id;
+ALTER TABLE plays DROP COLUMN source_id;
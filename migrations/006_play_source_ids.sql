@@ -0,0 +1,6 @@
+-- Remember where an imported play came from, such as "bgstats:<uuid>", so
+-- importing the same backup again skips plays that are already here.
+-- SQLite allows any number of NULLs in a unique index, so plays logged in
+-- the tracker itself need no source.
+ALTER TABLE plays ADD COLUMN source_id TEXT;
+CREATE UNIQUE INDEX idx_plays_source_id ON plays(source_id);
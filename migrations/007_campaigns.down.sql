@@ -0,0 +1,4 @@
+-- Undoing campaigns drops the campaigns and their logs; their plays stay.
+DROP INDEX IF EXISTS idx_campaigns_pack_id;
+DROP TABLE IF EXISTS campaign_entries;
+DROP TABLE IF EXISTS campaigns;
@@ -0,0 +1,39 @@
+-- Campaign mode.
+--
+-- A campaign is a run through the scenarios of one campaign box, such as
+-- The Rise of Red Skull, with state carried from one scenario to the next.
+-- Each step of a campaign is an ordinary play, linked to the campaign by a
+-- campaign_entries row in the order the scenarios were played; a lost
+-- scenario is replayed, so a scenario may appear more than once.
+--
+-- The campaign log after a step is stored on its entry as JSON: hit points
+-- carried over, upgrades and obligations removed per hero, and counters
+-- specific to the box. The campaign's current log is that of its last
+-- entry, so deleting a play rolls the log back with it.
+--
+-- A campaign is over once an entry is marked final; its result is the
+-- outcome of that play.
+
+CREATE TABLE IF NOT EXISTS campaigns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    pack_id INTEGER NOT NULL REFERENCES packs(id),
+    mode TEXT NOT NULL DEFAULT 'standard' CHECK(mode IN ('standard', 'expert')),
+    notes TEXT,
+    started_on DATE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS campaign_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    play_id INTEGER NOT NULL UNIQUE REFERENCES plays(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    final INTEGER NOT NULL DEFAULT 0,
+    log TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (campaign_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_pack_id ON campaigns(pack_id);
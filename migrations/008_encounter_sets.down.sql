@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_play_encounter_sets_encounter_set_id;
+DROP INDEX IF EXISTS idx_encounter_sets_pack_id;
+DROP TABLE IF EXISTS play_encounter_sets;
+DROP TABLE IF EXISTS scenario_encounter_sets;
+DROP TABLE IF EXISTS encounter_sets;
@@ -0,0 +1,139 @@
+-- Modular encounter sets.
+--
+-- A scenario is played with its own encounter set plus one or more modular
+-- sets, which change its difficulty a great deal. encounter_sets is the
+-- catalog of modular sets, following the same rules as heroes and
+-- scenarios: official sets belong to a pack and are upserted by name, and
+-- rows without a pack_id were added by users.
+--
+-- scenario_encounter_sets records the modular sets each scenario's rules
+-- recommend, which the New Play form selects by default. Recommendations
+-- are only recorded where they have been confirmed; the rest can be added
+-- by a later catalog migration, and until then the form selects nothing.
+--
+-- play_encounter_sets records the modular sets actually used in a play.
+-- Plays logged before this migration simply have no rows, meaning their
+-- modular sets are unknown.
+
+CREATE TABLE IF NOT EXISTS encounter_sets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    pack_id INTEGER REFERENCES packs(id),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS scenario_encounter_sets (
+    scenario_id INTEGER NOT NULL REFERENCES scenarios(id) ON DELETE CASCADE,
+    encounter_set_id INTEGER NOT NULL REFERENCES encounter_sets(id) ON DELETE CASCADE,
+    PRIMARY KEY (scenario_id, encounter_set_id)
+);
+
+CREATE TABLE IF NOT EXISTS play_encounter_sets (
+    play_id INTEGER NOT NULL REFERENCES plays(id) ON DELETE CASCADE,
+    encounter_set_id INTEGER NOT NULL REFERENCES encounter_sets(id),
+    PRIMARY KEY (play_id, encounter_set_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_encounter_sets_pack_id ON encounter_sets(pack_id);
+CREATE INDEX IF NOT EXISTS idx_play_encounter_sets_encounter_set_id ON play_encounter_sets(encounter_set_id);
+
+INSERT INTO encounter_sets (name, pack_id)
+SELECT v.column1, packs.id FROM (VALUES
+    ('Bomb Scare', 'Core Set'),
+    ('Masters of Evil', 'Core Set'),
+    ('Under Attack', 'Core Set'),
+    ('Legions of Hydra', 'Core Set'),
+    ('The Doomsday Chair', 'Core Set'),
+    ('Goblin Gimmicks', 'The Green Goblin'),
+    ('A Mess of Things', 'The Green Goblin'),
+    ('Power Drain', 'The Green Goblin'),
+    ('Running Interference', 'The Green Goblin'),
+    ('Hydra Assault', 'The Rise of Red Skull'),
+    ('Weapon Master', 'The Rise of Red Skull'),
+    ('Hydra Patrol', 'The Rise of Red Skull'),
+    ('Temporal', 'The Once and Future Kang'),
+    ('Anachronauts', 'The Once and Future Kang'),
+    ('Master of Time', 'The Once and Future Kang'),
+    ('Band of Badoon', 'Galaxy''s Most Wanted'),
+    ('Galactic Artifacts', 'Galaxy''s Most Wanted'),
+    ('Kree Militants', 'Galaxy''s Most Wanted'),
+    ('Menagerie Medley', 'Galaxy''s Most Wanted'),
+    ('Space Pirates', 'Galaxy''s Most Wanted'),
+    ('Badoon Headhunter', 'Galaxy''s Most Wanted'),
+    ('Power Stone', 'Galaxy''s Most Wanted'),
+    ('Beasty Boys', 'The Hood'),
+    ('Brothers Grimm', 'The Hood'),
+    ('Crossfire''s Crew', 'The Hood'),
+    ('Mister Hyde', 'The Hood'),
+    ('Ransacked Armory', 'The Hood'),
+    ('Sinister Syndicate', 'The Hood'),
+    ('State of Emergency', 'The Hood'),
+    ('Streets of Mayhem', 'The Hood'),
+    ('Wrecking Crew', 'The Hood'),
+    ('Black Order', 'The Mad Titan''s Shadow'),
+    ('Armies of Titan', 'The Mad Titan''s Shadow'),
+    ('Children of Thanos', 'The Mad Titan''s Shadow'),
+    ('Infinity Gauntlet', 'The Mad Titan''s Shadow'),
+    ('Legions of Hel', 'The Mad Titan''s Shadow'),
+    ('Frost Giants', 'The Mad Titan''s Shadow'),
+    ('Enchantress', 'The Mad Titan''s Shadow'),
+    ('City in Chaos', 'Sinister Motives'),
+    ('Down to Earth', 'Sinister Motives'),
+    ('Goblin Gear', 'Sinister Motives'),
+    ('Guerrilla Tactics', 'Sinister Motives'),
+    ('Osborn Tech', 'Sinister Motives'),
+    ('Personal Nightmare', 'Sinister Motives'),
+    ('Sinister Assault', 'Sinister Motives'),
+    ('Symbiotic Strength', 'Sinister Motives'),
+    ('Whispers of Paranoia', 'Sinister Motives'),
+    ('Acolytes', 'Mutant Genesis'),
+    ('Brotherhood', 'Mutant Genesis'),
+    ('Future Past', 'Mutant Genesis'),
+    ('Mystique', 'Mutant Genesis'),
+    ('Sentinels', 'Mutant Genesis'),
+    ('Zero Tolerance', 'Mutant Genesis'),
+    ('Crime', 'MojoMania'),
+    ('Fantasy', 'MojoMania'),
+    ('Horror', 'MojoMania'),
+    ('Sci-Fi', 'MojoMania'),
+    ('Sitcom', 'MojoMania'),
+    ('Western', 'MojoMania'),
+    ('Black Tom Cassidy', 'NeXt Evolution'),
+    ('Extreme Measures', 'NeXt Evolution'),
+    ('Military Grade', 'NeXt Evolution'),
+    ('Mutant Slayers', 'NeXt Evolution'),
+    ('Nasty Boys', 'NeXt Evolution')
+) AS v
+JOIN packs ON packs.name = v.column2
+WHERE true
+ON CONFLICT(name) DO UPDATE SET
+    pack_id = excluded.pack_id,
+    updated_at = CURRENT_TIMESTAMP;
+
+INSERT OR IGNORE INTO scenario_encounter_sets (scenario_id, encounter_set_id)
+SELECT s.id, e.id FROM (VALUES
+    ('Rhino', 'Bomb Scare'),
+    ('Klaw', 'Masters of Evil'),
+    ('Ultron', 'Under Attack'),
+    ('Risky Business', 'Goblin Gimmicks'),
+    ('Mutagen Formula', 'Goblin Gimmicks'),
+    ('Drang', 'Band of Badoon'),
+    ('Collector: Infiltrate the Museum', 'Galactic Artifacts'),
+    ('Collector: Escape the Museum', 'Menagerie Medley'),
+    ('Nebula', 'Space Pirates'),
+    ('Ronan the Accuser', 'Kree Militants'),
+    ('Sandman', 'City in Chaos'),
+    ('Venom', 'Symbiotic Strength'),
+    ('Mysterio', 'Personal Nightmare'),
+    ('The Sinister Six', 'Guerrilla Tactics'),
+    ('Venom Goblin', 'Symbiotic Strength'),
+    ('Sabretooth', 'Brotherhood'),
+    ('Project Wideawake', 'Zero Tolerance'),
+    ('Master Mold', 'Sentinels'),
+    ('Mansion Attack', 'Brotherhood'),
+    ('Mansion Attack', 'Mystique'),
+    ('Magneto', 'Acolytes')
+) AS v
+JOIN scenarios s ON s.name = v.column1
+JOIN encounter_sets e ON e.name = v.column2;
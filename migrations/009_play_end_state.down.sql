@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_plays_end_reason;
+ALTER TABLE plays DROP COLUMN end_reason;
+ALTER TABLE plays DROP COLUMN rounds;
+ALTER TABLE plays DROP COLUMN villain_stage;
+ALTER TABLE plays DROP COLUMN remaining_threat;
+ALTER TABLE decks DROP COLUMN remaining_hp;
@@ -0,0 +1,19 @@
+-- How a play ended.
+--
+-- outcome only says win or loss. end_reason records why: a win is either
+-- the villain defeated or a scenario objective completed, and a loss is
+-- the main scheme completing, every hero being defeated, or a concession.
+-- The application checks that the reason matches the outcome.
+--
+-- rounds, villain_stage and remaining_threat describe the board when the
+-- game ended, and decks.remaining_hp each hero's hit points. Every new
+-- column is nullable: plays logged before this migration, and plays where
+-- nobody kept track, simply have no end state recorded.
+
+ALTER TABLE plays ADD COLUMN end_reason TEXT CHECK(end_reason IN ('villain_defeated', 'objective_completed', 'scheme_completed', 'heroes_defeated', 'conceded'));
+ALTER TABLE plays ADD COLUMN rounds INTEGER CHECK(rounds >= 1);
+ALTER TABLE plays ADD COLUMN villain_stage INTEGER CHECK(villain_stage >= 1);
+ALTER TABLE plays ADD COLUMN remaining_threat INTEGER CHECK(remaining_threat >= 0);
+ALTER TABLE decks ADD COLUMN remaining_hp INTEGER CHECK(remaining_hp >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_plays_end_reason ON plays(end_reason);
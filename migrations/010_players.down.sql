@@ -0,0 +1,4 @@
+-- Decks keep the names written on them in player_name.
+DROP INDEX IF EXISTS idx_decks_player_id;
+ALTER TABLE decks DROP COLUMN player_id;
+DROP TABLE IF EXISTS players;
@@ -0,0 +1,25 @@
+-- Players are the people at the table. A deck links to its player through
+-- player_id; player_name keeps the name as it was written on the play, so
+-- plays logged before players existed and decks whose player was deleted
+-- still show who played them.
+CREATE TABLE IF NOT EXISTS players (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE decks ADD COLUMN player_id INTEGER REFERENCES players(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_decks_player_id ON decks(player_id);
+
+-- Every name already written on a deck becomes a player, matched without
+-- regard to case.
+INSERT OR IGNORE INTO players (name)
+SELECT TRIM(player_name) FROM decks
+WHERE TRIM(player_name) <> ''
+ORDER BY id;
+
+UPDATE decks
+SET player_id = (SELECT p.id FROM players p WHERE p.name = TRIM(decks.player_name))
+WHERE TRIM(player_name) <> '';
@@ -0,0 +1,10 @@
+-- Undoing accounts drops every user and session; plays and campaigns stay
+-- but no longer have an owner.
+DROP INDEX IF EXISTS idx_campaigns_owner_id;
+ALTER TABLE campaigns DROP COLUMN owner_id;
+DROP INDEX IF EXISTS idx_plays_owner_id;
+ALTER TABLE plays DROP COLUMN owner_id;
+
+DROP INDEX IF EXISTS idx_sessions_user_id;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,33 @@
+-- Users are the accounts people log in with. Passwords are stored only as
+-- bcrypt hashes. A user who shares their plays lets everyone on the site
+-- see their play history and campaigns.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL,
+    shares_plays INTEGER NOT NULL DEFAULT 0 CHECK (shares_plays IN (0, 1)),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A session is a login on one browser. The id is the SHA-256 hash of the
+-- token in the session cookie, so the table alone cannot be used to log in.
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+
+-- Plays and campaigns belong to the user who logged them. Those from
+-- before accounts existed have no owner until the first user signs up and
+-- takes them over.
+ALTER TABLE plays ADD COLUMN owner_id INTEGER REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS idx_plays_owner_id ON plays(owner_id);
+
+ALTER TABLE campaigns ADD COLUMN owner_id INTEGER REFERENCES users(id);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_owner_id ON campaigns(owner_id);
@@ -0,0 +1,9 @@
+-- Plays logged into a group stay with the users who logged them.
+DROP INDEX IF EXISTS idx_plays_group_id;
+ALTER TABLE plays DROP COLUMN group_id;
+
+DROP INDEX IF EXISTS idx_group_invites_group_id;
+DROP TABLE IF EXISTS group_invites;
+DROP INDEX IF EXISTS idx_group_members_user_id;
+DROP TABLE IF EXISTS group_members;
+DROP TABLE IF EXISTS groups;
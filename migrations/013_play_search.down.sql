@@ -0,0 +1,10 @@
+-- Dropping play_search needs FTS5, as any change to the index does.
+DROP TRIGGER IF EXISTS play_search_scenario_rename;
+DROP TRIGGER IF EXISTS play_search_hero_rename;
+DROP TRIGGER IF EXISTS play_search_deck_delete;
+DROP TRIGGER IF EXISTS play_search_deck_update;
+DROP TRIGGER IF EXISTS play_search_deck_insert;
+DROP TRIGGER IF EXISTS play_search_play_delete;
+DROP TRIGGER IF EXISTS play_search_play_update;
+DROP TRIGGER IF EXISTS play_search_play_insert;
+DROP TABLE IF EXISTS play_search;
@@ -0,0 +1,74 @@
+-- requires: fts5
+-- play_search is the full-text index behind the plays search box: one row
+-- per play, with the play id as its rowid, holding the play's notes, its
+-- scenario's name and the names of its heroes. The triggers below keep it
+-- in step with plays, decks and renames in the catalog.
+--
+-- FTS5 is only compiled into go-sqlite3 with the sqlite_fts5 build tag;
+-- without it this migration is skipped and search falls back to LIKE.
+CREATE VIRTUAL TABLE IF NOT EXISTS play_search USING fts5(
+    notes,
+    scenario,
+    heroes,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+INSERT INTO play_search (rowid, notes, scenario, heroes)
+SELECT p.id, COALESCE(p.notes, ''), s.name,
+       (SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = p.id)
+FROM plays p
+JOIN scenarios s ON s.id = p.scenario_id;
+
+CREATE TRIGGER IF NOT EXISTS play_search_play_insert AFTER INSERT ON plays
+BEGIN
+    INSERT INTO play_search (rowid, notes, scenario, heroes)
+    VALUES (new.id, COALESCE(new.notes, ''), (SELECT name FROM scenarios WHERE id = new.scenario_id), '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS play_search_play_update AFTER UPDATE OF notes, scenario_id ON plays
+BEGIN
+    UPDATE play_search
+    SET notes = COALESCE(new.notes, ''), scenario = (SELECT name FROM scenarios WHERE id = new.scenario_id)
+    WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS play_search_play_delete AFTER DELETE ON plays
+BEGIN
+    DELETE FROM play_search WHERE rowid = old.id;
+END;
+
+-- A play's heroes change with its decks.
+CREATE TRIGGER IF NOT EXISTS play_search_deck_insert AFTER INSERT ON decks
+BEGIN
+    UPDATE play_search
+    SET heroes = (SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = new.play_id)
+    WHERE rowid = new.play_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS play_search_deck_update AFTER UPDATE OF hero_id ON decks
+BEGIN
+    UPDATE play_search
+    SET heroes = (SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = new.play_id)
+    WHERE rowid = new.play_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS play_search_deck_delete AFTER DELETE ON decks
+BEGIN
+    UPDATE play_search
+    SET heroes = (SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = old.play_id)
+    WHERE rowid = old.play_id;
+END;
+
+-- Renaming a hero or scenario in the catalog renames it in every play.
+CREATE TRIGGER IF NOT EXISTS play_search_hero_rename AFTER UPDATE OF name ON heroes
+BEGIN
+    UPDATE play_search
+    SET heroes = (SELECT COALESCE(GROUP_CONCAT(h.name, ' '), '') FROM decks d JOIN heroes h ON h.id = d.hero_id WHERE d.play_id = play_search.rowid)
+    WHERE rowid IN (SELECT play_id FROM decks WHERE hero_id = new.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS play_search_scenario_rename AFTER UPDATE OF name ON scenarios
+BEGIN
+    UPDATE play_search SET scenario = new.name
+    WHERE rowid IN (SELECT id FROM plays WHERE scenario_id = new.id);
+END;
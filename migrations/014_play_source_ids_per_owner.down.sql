@@ -0,0 +1,4 @@
+-- This fails if more than one user has imported the same play; delete
+-- the extra copies first.
+DROP INDEX IF EXISTS idx_plays_owner_source_id;
+CREATE UNIQUE INDEX idx_plays_source_id ON plays(source_id);
@@ -0,0 +1,7 @@
+-- A source id only identifies a play within one user's history: two
+-- people importing the same BG Stats backup or BGG play list each get
+-- their own copy, and neither import counts as a duplicate of the other.
+-- Plays without an owner are NULL here, and SQLite treats NULLs as
+-- distinct in a unique index, so the import itself skips their repeats.
+DROP INDEX IF EXISTS idx_plays_source_id;
+CREATE UNIQUE INDEX idx_plays_owner_source_id ON plays(owner_id, source_id);
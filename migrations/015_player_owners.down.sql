@@ -0,0 +1,36 @@
+-- Make player names unique site-wide again. Players of different users
+-- who share a name become one player, the one with the lowest id, and
+-- their decks are linked to it.
+
+CREATE TABLE players_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO players_old (id, name, created_at, updated_at)
+SELECT MIN(id), name, MIN(created_at), MAX(updated_at)
+FROM players
+GROUP BY name;
+
+CREATE TABLE deck_players (
+    deck_id INTEGER PRIMARY KEY,
+    player_id INTEGER NOT NULL
+);
+
+INSERT INTO deck_players (deck_id, player_id)
+SELECT d.id, o.id
+FROM decks d
+JOIN players pl ON pl.id = d.player_id
+JOIN players_old o ON o.name = pl.name;
+
+DROP TABLE players;
+
+ALTER TABLE players_old RENAME TO players;
+
+UPDATE decks
+SET player_id = (SELECT dp.player_id FROM deck_players dp WHERE dp.deck_id = decks.id)
+WHERE id IN (SELECT deck_id FROM deck_players);
+
+DROP TABLE deck_players;
@@ -0,0 +1,61 @@
+-- Players belong to the user who added them, like plays: each user keeps
+-- their own list of the people they play with, and two users can both have
+-- a player named Sam. Names are unique per owner rather than site-wide, so
+-- players is rebuilt with owner_id and a UNIQUE (owner_id, name).
+--
+-- A player keeps their id and goes to the owner of the first play they
+-- were in, or to the first user if they have not played yet. A player who
+-- also played in other users' plays is copied once for each of those
+-- users, and the decks of those plays are linked to the copies. Players of
+-- plays without an owner have none either, until the first user signs up
+-- and takes them over with the plays.
+--
+-- Dropping the old table unlinks every deck through ON DELETE SET NULL, so
+-- the links are kept in deck_players meanwhile and restored after.
+
+CREATE TABLE players_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL COLLATE NOCASE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    owner_id INTEGER REFERENCES users(id),
+    UNIQUE (owner_id, name)
+);
+
+INSERT INTO players_new (id, name, created_at, updated_at, owner_id)
+SELECT pl.id, pl.name, pl.created_at, pl.updated_at,
+       CASE WHEN EXISTS (SELECT 1 FROM decks d WHERE d.player_id = pl.id)
+            THEN (SELECT p.owner_id FROM decks d JOIN plays p ON p.id = d.play_id
+                  WHERE d.player_id = pl.id ORDER BY d.id LIMIT 1)
+            ELSE (SELECT MIN(id) FROM users)
+       END
+FROM players pl;
+
+INSERT INTO players_new (name, created_at, updated_at, owner_id)
+SELECT DISTINCT pl.name, pl.created_at, pl.updated_at, p.owner_id
+FROM decks d
+JOIN plays p ON p.id = d.play_id
+JOIN players pl ON pl.id = d.player_id
+WHERE NOT EXISTS (SELECT 1 FROM players_new n WHERE n.id = pl.id AND n.owner_id IS p.owner_id);
+
+CREATE TABLE deck_players (
+    deck_id INTEGER PRIMARY KEY,
+    player_id INTEGER NOT NULL
+);
+
+INSERT INTO deck_players (deck_id, player_id)
+SELECT d.id, n.id
+FROM decks d
+JOIN plays p ON p.id = d.play_id
+JOIN players pl ON pl.id = d.player_id
+JOIN players_new n ON n.name = pl.name AND n.owner_id IS p.owner_id;
+
+DROP TABLE players;
+
+ALTER TABLE players_new RENAME TO players;
+
+UPDATE decks
+SET player_id = (SELECT dp.player_id FROM deck_players dp WHERE dp.deck_id = decks.id)
+WHERE id IN (SELECT deck_id FROM deck_players);
+
+DROP TABLE deck_players;
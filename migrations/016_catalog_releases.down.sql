@@ -0,0 +1,24 @@
+-- The heroes and scenarios of waves 8 and 9 that no play uses are removed.
+-- Those that plays use stay, and become user-added entries without a pack,
+-- as with 002_seed_catalog, as do user-added rows the catalog was matched
+-- to. Packs a campaign was started from stay too.
+
+DELETE FROM heroes
+WHERE seeded_by = '016_catalog_releases'
+  AND id NOT IN (SELECT hero_id FROM decks);
+
+DELETE FROM scenarios
+WHERE seeded_by = '016_catalog_releases'
+  AND id NOT IN (SELECT scenario_id FROM plays);
+
+UPDATE heroes SET pack_id = NULL, seeded_by = NULL
+WHERE pack_id IN (SELECT id FROM packs WHERE wave > 7);
+
+UPDATE scenarios SET pack_id = NULL, seeded_by = NULL
+WHERE pack_id IN (SELECT id FROM packs WHERE wave > 7);
+
+DELETE FROM packs
+WHERE wave > 7
+  AND id NOT IN (SELECT pack_id FROM campaigns);
+
+UPDATE packs SET released_on = NULL;
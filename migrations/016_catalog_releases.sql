@@ -0,0 +1,126 @@
+-- Catalog update: release dates and waves 8 and 9.
+--
+-- Fills in packs.released_on, which 002_seed_catalog left NULL, with the
+-- US release date of every pack, and adds the packs released since: the
+-- Age of Apocalypse and Agents of S.H.I.E.L.D. campaign boxes with their
+-- hero packs. Heroes and scenarios are matched by name as in
+-- 002_seed_catalog, so a user-added row whose name matches keeps its id
+-- and is attached to its pack.
+--
+-- Scenarios of a campaign box are listed in campaign order, which is the
+-- order CampaignRepository.Boxes offers them in.
+
+INSERT INTO packs (name, kind, wave, released_on)
+SELECT column1, column2, column3, column4 FROM (VALUES
+    ('Core Set', 'core', 1, '2019-11-01'),
+    ('The Green Goblin', 'scenario', 1, '2019-11-22'),
+    ('Captain America', 'hero', 1, '2019-12-13'),
+    ('Ms. Marvel', 'hero', 1, '2019-12-13'),
+    ('Thor', 'hero', 1, '2020-01-24'),
+    ('Black Widow', 'hero', 1, '2020-01-24'),
+    ('Doctor Strange', 'hero', 1, '2020-02-21'),
+    ('The Wrecking Crew', 'scenario', 1, '2020-02-21'),
+    ('Hulk', 'hero', 1, '2020-03-27'),
+    ('The Rise of Red Skull', 'campaign', 2, '2020-06-26'),
+    ('The Once and Future Kang', 'scenario', 2, '2020-07-24'),
+    ('Ant-Man', 'hero', 2, '2020-08-14'),
+    ('Wasp', 'hero', 2, '2020-08-14'),
+    ('Quicksilver', 'hero', 2, '2020-10-16'),
+    ('Scarlet Witch', 'hero', 2, '2020-10-16'),
+    ('Galaxy''s Most Wanted', 'campaign', 3, '2021-01-29'),
+    ('Star-Lord', 'hero', 3, '2021-03-05'),
+    ('Gamora', 'hero', 3, '2021-03-05'),
+    ('Drax', 'hero', 3, '2021-04-30'),
+    ('Venom', 'hero', 3, '2021-04-30'),
+    ('The Hood', 'scenario', 3, '2021-12-10'),
+    ('The Mad Titan''s Shadow', 'campaign', 4, '2021-07-30'),
+    ('Nebula', 'hero', 4, '2021-09-24'),
+    ('War Machine', 'hero', 4, '2021-09-24'),
+    ('Valkyrie', 'hero', 4, '2021-11-19'),
+    ('Vision', 'hero', 4, '2021-11-19'),
+    ('Sinister Motives', 'campaign', 5, '2022-01-28'),
+    ('Nova', 'hero', 5, '2022-03-25'),
+    ('Ironheart', 'hero', 5, '2022-03-25'),
+    ('Spider-Ham', 'hero', 5, '2022-05-27'),
+    ('SP//dr', 'hero', 5, '2022-05-27'),
+    ('Mutant Genesis', 'campaign', 6, '2022-07-29'),
+    ('Cyclops', 'hero', 6, '2022-09-16'),
+    ('Phoenix', 'hero', 6, '2022-09-16'),
+    ('Wolverine', 'hero', 6, '2022-11-18'),
+    ('Storm', 'hero', 6, '2022-11-18'),
+    ('Gambit', 'hero', 6, '2023-01-20'),
+    ('Rogue', 'hero', 6, '2023-01-20'),
+    ('MojoMania', 'scenario', 6, '2022-12-16'),
+    ('NeXt Evolution', 'campaign', 7, '2023-03-31'),
+    ('Psylocke', 'hero', 7, '2023-05-26'),
+    ('Angel', 'hero', 7, '2023-05-26'),
+    ('X-23', 'hero', 7, '2023-08-25'),
+    ('Deadpool', 'hero', 7, '2023-08-25'),
+    ('Age of Apocalypse', 'campaign', 8, '2023-11-17'),
+    ('Iceman', 'hero', 8, '2024-01-26'),
+    ('Jubilee', 'hero', 8, '2024-01-26'),
+    ('Nightcrawler', 'hero', 8, '2024-03-29'),
+    ('Magneto', 'hero', 8, '2024-03-29'),
+    ('Agents of S.H.I.E.L.D.', 'campaign', 9, '2024-05-31'),
+    ('Black Panther', 'hero', 9, '2024-07-26'),
+    ('Silk', 'hero', 9, '2024-07-26'),
+    ('Falcon', 'hero', 9, '2024-09-27'),
+    ('Winter Soldier', 'hero', 9, '2024-09-27')
+) WHERE true
+ON CONFLICT(name) DO UPDATE SET
+    kind = excluded.kind,
+    wave = excluded.wave,
+    released_on = excluded.released_on,
+    updated_at = CURRENT_TIMESTAMP;
+
+CREATE TEMP TABLE catalog_heroes AS
+SELECT v.column1 AS name, packs.id AS pack_id FROM (VALUES
+    ('Bishop', 'Age of Apocalypse'),
+    ('Magik', 'Age of Apocalypse'),
+    ('Iceman', 'Iceman'),
+    ('Jubilee', 'Jubilee'),
+    ('Nightcrawler', 'Nightcrawler'),
+    ('Magneto', 'Magneto'),
+    ('Maria Hill', 'Agents of S.H.I.E.L.D.'),
+    ('Nick Fury', 'Agents of S.H.I.E.L.D.'),
+    ('Black Panther (Shuri)', 'Black Panther'),
+    ('Silk', 'Silk'),
+    ('Falcon', 'Falcon'),
+    ('Winter Soldier', 'Winter Soldier')
+) AS v
+JOIN packs ON packs.name = v.column2;
+
+UPDATE heroes SET pack_id = c.pack_id, updated_at = CURRENT_TIMESTAMP
+FROM catalog_heroes AS c
+WHERE heroes.name = c.name COLLATE NOCASE;
+
+INSERT INTO heroes (name, pack_id, seeded_by)
+SELECT c.name, c.pack_id, '016_catalog_releases' FROM catalog_heroes AS c
+WHERE NOT EXISTS (SELECT 1 FROM heroes WHERE heroes.name = c.name COLLATE NOCASE);
+
+DROP TABLE catalog_heroes;
+
+CREATE TEMP TABLE catalog_scenarios AS
+SELECT v.column1 AS name, packs.id AS pack_id FROM (VALUES
+    ('Unus', 'Age of Apocalypse'),
+    ('Four Horsemen', 'Age of Apocalypse'),
+    ('Apocalypse', 'Age of Apocalypse'),
+    ('Dark Beast', 'Age of Apocalypse'),
+    ('En Sabah Nur', 'Age of Apocalypse'),
+    ('Black Widow', 'Agents of S.H.I.E.L.D.'),
+    ('Batroc', 'Agents of S.H.I.E.L.D.'),
+    ('M.O.D.O.K.', 'Agents of S.H.I.E.L.D.'),
+    ('Thunderbolts', 'Agents of S.H.I.E.L.D.'),
+    ('Baron Zemo', 'Agents of S.H.I.E.L.D.')
+) AS v
+JOIN packs ON packs.name = v.column2;
+
+UPDATE scenarios SET pack_id = c.pack_id, updated_at = CURRENT_TIMESTAMP
+FROM catalog_scenarios AS c
+WHERE scenarios.name = c.name COLLATE NOCASE;
+
+INSERT INTO scenarios (name, pack_id, seeded_by)
+SELECT c.name, c.pack_id, '016_catalog_releases' FROM catalog_scenarios AS c
+WHERE NOT EXISTS (SELECT 1 FROM scenarios WHERE scenarios.name = c.name COLLATE NOCASE);
+
+DROP TABLE catalog_scenarios;
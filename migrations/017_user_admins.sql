@@ -0,0 +1,7 @@
+-- Admins look after the shared hero and scenario catalog: they alone may
+-- rename, merge, archive and delete its entries, while every user can add
+-- custom ones. The first account is the admin; others are made admins with
+-- the admin command.
+ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0 CHECK (is_admin IN (0, 1));
+
+UPDATE users SET is_admin = 1 WHERE id = (SELECT MIN(id) FROM users);
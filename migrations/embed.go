@@ -0,0 +1,11 @@
+// Package migrations holds the SQL migrations, built into the binary so
+// that the server can migrate its database from any working directory.
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.sql migrations and their NNN_name.down.sql down
+// migrations.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,10 @@
+// Package static holds the files the server serves under /static, built
+// into the binary.
+package static
+
+import "embed"
+
+// FS holds the static files.
+//
+//go:embed *.svg
+var FS embed.FS
@@ -0,0 +1,11 @@
+// Package templates holds the server's HTML templates, built into the
+// binary.
+package templates
+
+import "embed"
+
+// FS holds the *.html templates, named by file name as LoadHTMLGlob names
+// them.
+//
+//go:embed *.html
+var FS embed.FS